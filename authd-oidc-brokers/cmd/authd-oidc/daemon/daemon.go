@@ -4,14 +4,17 @@ package daemon
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 
+	"github.com/canonical/authd/authd-oidc-brokers/internal/audit"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/daemon"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/dbusservice"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/metrics"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	log "github.com/ubuntu/authd/log"
@@ -110,6 +113,7 @@ func New(name string) *App {
 
 	// subcommands
 	a.installVersion()
+	a.installConfig()
 
 	return &a
 }
@@ -140,15 +144,66 @@ func (a *App) serve(config daemonConfig) error {
 		return fmt.Errorf("error initializing broker configuration directory %q: %v", brokerConfigDir, err)
 	}
 
-	b, err := broker.New(broker.Config{
-		ConfigFile: config.Paths.BrokerConf,
-		DataDir:    config.Paths.DataDir,
-	})
+	// The broker of the [oidc] section is always created, and one more for each additional provider of the config.
+	providerIDs, err := broker.ProviderIDs(config.Paths.BrokerConf)
 	if err != nil {
-		return err
+		return fmt.Errorf("could not read providers from config file %q: %v", config.Paths.BrokerConf, err)
+	}
+	brokers := make(map[string]*broker.Broker)
+	for _, id := range append([]string{""}, providerIDs...) {
+		b, err := broker.New(broker.Config{
+			ConfigFile: config.Paths.BrokerConf,
+			DataDir:    config.Paths.DataDir,
+			ProviderID: id,
+		})
+		if err != nil {
+			if id != "" {
+				return fmt.Errorf("provider %q: %w", id, err)
+			}
+			return err
+		}
+		brokers[id] = b
+	}
+
+	metricsAddr, err := broker.MetricsListenAddress(config.Paths.BrokerConf)
+	if err != nil {
+		return fmt.Errorf("could not read metrics settings from config file %q: %v", config.Paths.BrokerConf, err)
+	}
+	if metricsAddr != "" {
+		metricsServer, err := metrics.Serve(metricsAddr)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := metricsServer.Stop(); err != nil {
+				log.Warningf(context.Background(), "Failed to stop the metrics server: %v", err)
+			}
+		}()
+	}
+
+	auditOutput, err := broker.AuditOutput(config.Paths.BrokerConf)
+	if err != nil {
+		return fmt.Errorf("could not read audit settings from config file %q: %v", config.Paths.BrokerConf, err)
+	}
+	switch auditOutput {
+	case broker.AuditOutputLog:
+	case broker.AuditOutputNone:
+		audit.SetOutput(io.Discard)
+	default:
+		f, err := audit.OpenFile(auditOutput)
+		if err != nil {
+			return err
+		}
+		audit.SetOutput(f)
+		defer func() {
+			audit.SetOutput(nil)
+			if err := f.Close(); err != nil {
+				log.Warningf(context.Background(), "Failed to close the audit log file: %v", err)
+			}
+		}()
 	}
 
-	s, err := dbusservice.New(ctx, b)
+	s, err := dbusservice.New(ctx, brokers)
 	if err != nil {
 		return err
 	}
@@ -54,6 +54,50 @@ func TestVersion(t *testing.T) {
 	require.Equal(t, consts.Version, fields[1], "Wrong version")
 }
 
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		noFile bool
+
+		wantOutput []string
+		wantErr    bool
+	}{
+		"Validates_the_config_of_the_daemon_by_default": {noFile: true, wantOutput: []string{"PASS  [oidc] issuer is reachable", "is valid"}},
+		"Validates_the_given_file":                      {config: "[oidc]\nissuer = %s\nclient_id = client_id\n", wantOutput: []string{"PASS  [oidc] issuer is reachable", "is valid"}},
+
+		"Error_if_the_given_file_is_invalid": {
+			config:     "[oidc]\nissuer = %s\nextra_scopes = groups groups\n",
+			wantOutput: []string{"FAIL  [oidc] required settings are set", "'client_id' is required", "FAIL  [oidc] extra scopes are valid", "PASS  [oidc] issuer is reachable"},
+			wantErr:    true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := []string{"config", "validate"}
+			if !tc.noFile {
+				p := filepath.Join(t.TempDir(), "broker.conf")
+				err := os.WriteFile(p, []byte(fmt.Sprintf(tc.config, issuerURL)), 0600)
+				require.NoError(t, err, "Setup: could not write broker configuration")
+				args = append(args, "--file", p)
+			}
+			a := daemon.NewForTests(t, nil, issuerURL, args...)
+
+			getStdout := captureStdout(t)
+			err := a.Run()
+			out := getStdout()
+
+			for _, want := range tc.wantOutput {
+				require.Contains(t, out, want, "Output should contain the result of the checks")
+			}
+			if tc.wantErr {
+				require.Error(t, err, "Run should return an error")
+				return
+			}
+			require.NoError(t, err, "Run should not return an error, output: %s", out)
+		})
+	}
+}
+
 func TestNoUsageError(t *testing.T) {
 	a := daemon.NewForTests(t, nil, issuerURL, "completion", "bash")
 
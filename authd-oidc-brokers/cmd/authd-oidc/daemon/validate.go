@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker"
+	"github.com/spf13/cobra"
+)
+
+func (a *App) installConfig() {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the broker configuration",
+		Args:  cobra.NoArgs,
+	}
+
+	var file string
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the broker configuration file and exit",
+		Long: `Check the broker configuration file and its drop-in files without modifying anything: that they can be parsed,
+that the required settings are set, that the extra scopes are valid, that the templates compile and that the issuer
+is reachable. The daemon does not need to be running.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if file == "" {
+				file = a.config.Paths.BrokerConf
+			}
+			return validateConfig(cmd.Context(), cmd.OutOrStdout(), file)
+		},
+	}
+	validateCmd.Flags().StringVarP(&file, "file", "f", "", "broker configuration file to check (defaults to the one of the daemon)")
+
+	cmd.AddCommand(validateCmd)
+	a.rootCmd.AddCommand(cmd)
+}
+
+// validateConfig checks the broker configuration file and prints the result of each check to w. It returns an error if
+// any check failed.
+func validateConfig(ctx context.Context, w io.Writer, path string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var failed int
+	for _, check := range broker.ValidateConfig(ctx, path) {
+		name := check.Name
+		if check.Section != "" {
+			name = fmt.Sprintf("[%s] %s", check.Section, check.Name)
+		}
+		if check.Err == nil {
+			fmt.Fprintf(w, "PASS  %s\n", name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL  %s\n", name)
+		for _, line := range strings.Split(check.Err.Error(), "\n") {
+			fmt.Fprintf(w, "        %s\n", line)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("configuration file %q is invalid: %d check(s) failed", path, failed)
+	}
+	fmt.Fprintf(w, "Configuration file %q is valid\n", path)
+	return nil
+}
@@ -0,0 +1,95 @@
+// Package audit records the authentication decisions of the broker as structured (JSON) audit events.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ubuntu/authd/log"
+)
+
+// Level is the level at which the audit events are logged when they are written to the log of the broker.
+// The log package has no level dedicated to audit events, so the notice level is used: it's the lowest level which is
+// logged with the default verbosity, and unlike the warning level, it doesn't suggest that something went wrong.
+const Level = log.NoticeLevel
+
+// EventAuthentication is the type of the events recording the decision of an authentication attempt.
+const EventAuthentication = "authentication"
+
+// Event is an audit event. It must never contain tokens, passwords or other secrets.
+type Event struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	// Username is the name of the user who tried to authenticate.
+	Username string `json:"username"`
+	// Subject is the unique identifier of the user at the provider, if it's known.
+	Subject string `json:"subject,omitempty"`
+	// Issuer is the issuer URL of the provider.
+	Issuer      string `json:"issuer"`
+	SessionMode string `json:"session_mode,omitempty"`
+	AuthMode    string `json:"auth_mode,omitempty"`
+	// Result is the access returned to authd, e.g. "granted" or "denied".
+	Result string `json:"result"`
+	// Reason is the reason of the failure, as displayed to the user.
+	Reason string `json:"reason,omitempty"`
+}
+
+var (
+	outputMu sync.Mutex
+	// output is the writer to which the audit events are written, or nil to write them to the log of the broker.
+	output io.Writer
+)
+
+// SetOutput sets the writer to which the audit events are written, one JSON object per line. If w is nil, the audit
+// events are written to the log of the broker at level [Level].
+func SetOutput(w io.Writer) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	output = w
+}
+
+// OpenFile opens the file at path to append audit events to it, creating it and its parent directories if needed.
+// The file is only readable by its owner, as the audit events identify the users.
+func OpenFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("could not create directory of audit log file: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit log file: %w", err)
+	}
+	return f, nil
+}
+
+// Log records the event. The time of the event is set to the current time if it's not set.
+func Log(ctx context.Context, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Errorf(ctx, "Could not marshal audit event: %v", err)
+		return
+	}
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	if output == nil {
+		// This logs at [Level].
+		log.Noticef(ctx, "audit: %s", line)
+		return
+	}
+	// The line is written with a single call, so that the lines of concurrent writers to the file are not mixed.
+	if _, err := output.Write(append(line, '\n')); err != nil {
+		log.Errorf(ctx, "Could not write audit event: %v", err)
+	}
+}
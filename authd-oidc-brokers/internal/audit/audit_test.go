@@ -0,0 +1,120 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/audit"
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/authd/log"
+)
+
+// The output of the audit events is global, so the tests of this package can't run in parallel.
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	audit.SetOutput(&buf)
+	t.Cleanup(func() { audit.SetOutput(nil) })
+
+	eventTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	audit.Log(context.Background(), audit.Event{
+		Time:        eventTime,
+		Event:       audit.EventAuthentication,
+		Username:    "user@example.com",
+		Subject:     "0123456789",
+		Issuer:      "https://issuer.example.com",
+		SessionMode: "login",
+		AuthMode:    "device_auth",
+		Result:      "denied",
+		Reason:      "Access denied",
+	})
+	audit.Log(context.Background(), audit.Event{
+		Event:    audit.EventAuthentication,
+		Username: "other@example.com",
+		Issuer:   "https://issuer.example.com",
+		Result:   "granted",
+	})
+
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+	require.Len(t, lines, 2, "Log should write one line per event")
+
+	want := `{"time":"2024-05-01T10:00:00Z","event":"authentication","username":"user@example.com",` +
+		`"subject":"0123456789","issuer":"https://issuer.example.com","session_mode":"login",` +
+		`"auth_mode":"device_auth","result":"denied","reason":"Access denied"}`
+	require.Equal(t, want, string(lines[0]), "Log should write the event as JSON, with the time in UTC")
+
+	var got audit.Event
+	err := json.Unmarshal(lines[1], &got)
+	require.NoError(t, err, "Log should write valid JSON")
+	require.NotZero(t, got.Time, "Log should set the time of the event if it's not set")
+	require.NotContains(t, string(lines[1]), "subject", "Log should omit the subject if it's not known")
+	require.NotContains(t, string(lines[1]), "reason", "Log should omit the reason if there is none")
+}
+
+func TestLogToBrokerLog(t *testing.T) {
+	var gotLevel log.Level
+	var gotMsg string
+	log.SetHandler(func(_ context.Context, level log.Level, format string, args ...interface{}) {
+		gotLevel = level
+		gotMsg = fmt.Sprintf(format, args...)
+	})
+	t.Cleanup(func() { log.SetHandler(nil) })
+
+	audit.Log(context.Background(), audit.Event{Event: audit.EventAuthentication, Username: "user", Result: "granted"})
+
+	require.Equal(t, audit.Level, gotLevel, "Log should log the event at the audit level")
+	require.Less(t, gotLevel, log.WarnLevel, "Log should not log the event as a warning")
+	require.Contains(t, gotMsg, `"username":"user"`, "Log should log the event")
+}
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	require.NoError(t, err, "Setup: Failed to create directory")
+	err = os.WriteFile(path, []byte("existing line\n"), 0600)
+	require.NoError(t, err, "Setup: Failed to write existing audit log file")
+
+	f, err := audit.OpenFile(path)
+	require.NoError(t, err, "OpenFile should not return an error")
+	audit.SetOutput(f)
+	t.Cleanup(func() { audit.SetOutput(nil) })
+
+	audit.Log(context.Background(), audit.Event{Event: audit.EventAuthentication, Username: "user", Result: "granted"})
+	require.NoError(t, f.Close(), "Teardown: Failed to close audit log file")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err, "Failed to read audit log file")
+	require.True(t, bytes.HasPrefix(content, []byte("existing line\n")), "OpenFile should append to the file")
+	require.Contains(t, string(content), `"username":"user"`, "The event should be written to the file")
+}
+
+func TestOpenFileCreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "audit.log")
+
+	f, err := audit.OpenFile(path)
+	require.NoError(t, err, "OpenFile should not return an error")
+	require.NoError(t, f.Close(), "Teardown: Failed to close audit log file")
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err, "OpenFile should create the file")
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm(), "The audit log file should only be readable by its owner")
+
+	fi, err = os.Stat(filepath.Dir(path))
+	require.NoError(t, err, "OpenFile should create the parent directory")
+	require.Equal(t, os.FileMode(0700), fi.Mode().Perm(), "The parent directory should only be accessible by its owner")
+}
+
+func TestOpenFileErrors(t *testing.T) {
+	parent := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(parent, nil, 0600)
+	require.NoError(t, err, "Setup: Failed to write file")
+
+	_, err = audit.OpenFile(filepath.Join(parent, "audit.log"))
+	require.Error(t, err, "OpenFile should return an error if the parent directory can't be created")
+}
@@ -0,0 +1,37 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/audit"
+)
+
+// auditAuthentication records the decision of an authentication attempt of the session in the audit log. Only the
+// final decisions are recorded, i.e. the attempts which were granted, denied or have to be retried.
+func auditAuthentication(session *session, issuer, access string, response isAuthenticatedDataResponse) {
+	if access != AuthGranted && access != AuthDenied && access != AuthRetry {
+		return
+	}
+
+	e := audit.Event{
+		Event:       audit.EventAuthentication,
+		Username:    session.username,
+		Issuer:      issuer,
+		SessionMode: session.mode,
+		AuthMode:    session.selectedMode,
+		Result:      access,
+	}
+	if session.authInfo != nil {
+		e.Subject = session.authInfo.UserInfo.UUID
+	}
+	// Only the user info and the message displayed to the user are taken from the response, so that the claims
+	// returned in diagnostic sessions don't end up in the audit log.
+	switch r := response.(type) {
+	case userInfoMessage:
+		e.Subject = r.UserInfo.UUID
+	case errorMessage:
+		e.Reason = r.Message
+	}
+
+	audit.Log(context.Background(), e)
+}
@@ -0,0 +1,92 @@
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/audit"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// The output of the audit events is global, so this test can't run in parallel with the tests which authenticate.
+func TestAuditAuthentication(t *testing.T) {
+	const issuer = "https://issuer.example.com"
+	authInfo := &token.AuthCachedInfo{
+		Token:      &oauth2.Token{AccessToken: "secret-access-token", RefreshToken: "secret-refresh-token"},
+		RawIDToken: "secret-id-token",
+		UserInfo:   info.User{Name: "user@example.com", UUID: "subject-from-session"},
+	}
+
+	tests := map[string]struct {
+		access   string
+		response isAuthenticatedDataResponse
+		authInfo *token.AuthCachedInfo
+
+		wantEvent *audit.Event
+	}{
+		"Granted_records_the_subject_of_the_user_info": {
+			access: AuthGranted,
+			response: userInfoMessage{
+				UserInfo: info.User{Name: "user@example.com", UUID: "subject-from-user-info"},
+				Claims:   map[string]any{"secret_claim": "secret-claim-value"},
+			},
+			wantEvent: &audit.Event{Subject: "subject-from-user-info", Result: AuthGranted},
+		},
+		"Denied_records_the_reason": {
+			access:    AuthDenied,
+			response:  errorMessage{Message: "Access denied"},
+			wantEvent: &audit.Event{Result: AuthDenied, Reason: "Access denied"},
+		},
+		"Retry_records_the_subject_of_the_session": {
+			access:    AuthRetry,
+			response:  errorMessage{Message: "Incorrect password, please try again."},
+			authInfo:  authInfo,
+			wantEvent: &audit.Event{Subject: "subject-from-session", Result: AuthRetry, Reason: "Incorrect password, please try again."},
+		},
+
+		"Next_is_not_recorded":      {access: AuthNext, authInfo: authInfo},
+		"Cancelled_is_not_recorded": {access: AuthCancelled, response: errorMessage{Message: "Authentication request cancelled"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			audit.SetOutput(&buf)
+			t.Cleanup(func() { audit.SetOutput(nil) })
+
+			s := &session{
+				username:     "user@example.com",
+				mode:         "login",
+				selectedMode: "password",
+				authInfo:     tc.authInfo,
+			}
+			auditAuthentication(s, issuer, tc.access, tc.response)
+
+			if tc.wantEvent == nil {
+				require.Empty(t, buf.String(), "auditAuthentication should not record the event")
+				return
+			}
+
+			for _, secret := range []string{"secret-access-token", "secret-refresh-token", "secret-id-token", "secret-claim"} {
+				require.NotContains(t, buf.String(), secret, "Audit events must not contain secrets")
+			}
+
+			var got audit.Event
+			err := json.Unmarshal(buf.Bytes(), &got)
+			require.NoError(t, err, "auditAuthentication should record a single JSON event")
+			require.NotZero(t, got.Time, "The time of the event should be set")
+
+			want := *tc.wantEvent
+			want.Time = got.Time
+			want.Event = audit.EventAuthentication
+			want.Username = "user@example.com"
+			want.Issuer = issuer
+			want.SessionMode = "login"
+			want.AuthMode = "password"
+			require.Equal(t, want, got, "auditAuthentication should record the event")
+		})
+	}
+}
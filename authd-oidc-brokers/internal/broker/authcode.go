@@ -0,0 +1,138 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/ubuntu/authd/log"
+	"golang.org/x/oauth2"
+)
+
+// oidcAuthModes returns the OIDC authentication modes supported by the provider, in the order of preference of the
+// provider, restricted to the ones allowed by the broker configuration.
+func (b *Broker) oidcAuthModes() []string {
+	cfg := b.config()
+	return oidcAuthModes(b.provider, cfg.authFlow, cfg.redirectURI)
+}
+
+// oidcAuthModes returns the OIDC authentication modes supported by the provider which use the authentication flow,
+// or all of them if authFlow is empty. The authorization code mode is only returned if a redirect URI is set.
+func oidcAuthModes(p providers.Provider, authFlow, redirectURI string) []string {
+	return slices.DeleteFunc(slices.Clone(p.SupportedOIDCAuthModes()), func(mode string) bool {
+		switch mode {
+		case authmodes.Device, authmodes.DeviceQr:
+			return authFlow != "" && authFlow != authFlowDevice
+		case authmodes.AuthCode:
+			return redirectURI == "" || (authFlow != "" && authFlow != authFlowAuthCode)
+		}
+		return authFlow != ""
+	})
+}
+
+// authCodeLayout returns the UI layout of the authorization code authentication mode, which asks the user to open the
+// authorization URL in a browser and to enter the code (or the whole URL) they are redirected to.
+func (b *Broker) authCodeLayout(session *session) map[string]string {
+	session.authCodeState = rand.Text()
+	session.codeVerifier = oauth2.GenerateVerifier()
+	opts := append(slices.Clone(b.provider.AuthOptions()), oauth2.S256ChallengeOption(session.codeVerifier))
+	if _, ok := b.provider.(providers.OAuth2Provider); !ok {
+		session.nonce = rand.Text()
+		opts = append(opts, oidc.Nonce(session.nonce))
+	}
+	authURL := session.oauth2Config.AuthCodeURL(session.authCodeState, opts...)
+
+	label := fmt.Sprintf("Open %s in a browser and log in, then enter the code or the URL of the page you are redirected to", authURL)
+
+	return map[string]string{
+		"type":  "form",
+		"label": label,
+		"entry": "chars",
+	}
+}
+
+// authCodeAuth exchanges the authorization code entered by the user for a token.
+func (b *Broker) authCodeAuth(ctx context.Context, session *session, secret string) (string, isAuthenticatedDataResponse) {
+	if session.authCodeState == "" {
+		log.Error(context.Background(), "authorization code state is not set")
+		return AuthDenied, unexpectedErrMsg("authorization code state is not set")
+	}
+
+	if msg, throttled := b.throttledMsg(session); throttled {
+		return AuthDenied, msg
+	}
+
+	code, err := parseAuthCode(secret, session.authCodeState)
+	if err != nil {
+		log.Noticef(context.Background(), "Invalid authorization code for user %q: %v", session.username, err)
+		b.recordAuthResult(session, false)
+		return AuthRetry, errorMessage{Message: "Invalid authorization code, please try again."}
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, b.config().requestTimeout)
+	defer cancel()
+
+	log.Debug(ctx, "Exchanging authorization code for token...")
+	opts := append(slices.Clone(b.provider.AuthOptions()), oauth2.VerifierOption(session.codeVerifier))
+	t, err := session.oauth2Config.Exchange(b.withHTTPClient(timeoutCtx), code, opts...)
+	// A timeout is not an authentication failure, so it's not recorded as a failed attempt.
+	if err != nil && isTimeoutError(err) {
+		log.Errorf(context.Background(), "Timed out exchanging authorization code for token: %s", err)
+		return AuthRetry, errorMessage{Message: providerTimeoutMsg}
+	}
+	if err != nil {
+		log.Errorf(context.Background(), "Error exchanging authorization code for token: %s", err)
+		b.recordAuthResult(session, false)
+		return AuthRetry, errorMessage{Message: "Error retrieving access token. Please try again."}
+	}
+	log.Debug(ctx, "Exchanged authorization code for token.")
+	b.recordAuthResult(session, true)
+
+	// The code can only be redeemed once.
+	session.authCodeState = ""
+	session.codeVerifier = ""
+
+	if t.RefreshToken == "" {
+		log.Warningf(context.Background(), "No refresh token returned for user during authorization code authentication. You might have to add the 'offline_access' scope to the 'extra_scopes' setting.")
+	}
+
+	return b.authenticateWithToken(ctx, session, t)
+}
+
+// parseAuthCode returns the authorization code from the input of the user, which is either the code itself or the URL
+// the user was redirected to. In the latter case, the state parameter must match the one of the authorization request.
+func parseAuthCode(input, wantState string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", fmt.Errorf("empty authorization code")
+	}
+
+	if !strings.Contains(input, "?") {
+		// Not a URL, so the input is the code itself.
+		return input, nil
+	}
+
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect URL: %v", err)
+	}
+
+	query := u.Query()
+	if e := query.Get("error"); e != "" {
+		return "", fmt.Errorf("authorization failed: %s: %s", e, query.Get("error_description"))
+	}
+	if state := query.Get("state"); state != wantState {
+		return "", fmt.Errorf("state %q does not match the one of the authorization request", state)
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("no code in redirect URL")
+	}
+	return code, nil
+}
@@ -11,6 +11,10 @@ const (
 	// DeviceQr is the ID of the device authentication method when QrCode rendering is enabled.
 	DeviceQr = "device_auth_qr"
 
+	// AuthCode is the ID of the authorization code authentication method, in which the user opens the authorization URL
+	// in a browser and enters the resulting authorization code.
+	AuthCode = "auth_code"
+
 	// NewPassword is the ID of the new password configuration method.
 	NewPassword = "newpassword"
 )
@@ -21,6 +25,7 @@ var (
 		Password:    "Local Password Authentication",
 		Device:      "Device Authentication",
 		DeviceQr:    "Device Authentication",
+		AuthCode:    "Browser Authentication",
 		NewPassword: "Define your local password",
 	}
 )
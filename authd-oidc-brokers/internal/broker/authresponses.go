@@ -9,6 +9,8 @@ type isAuthenticatedDataResponse interface {
 // userInfoMessage represents the user information message that is returned to authd.
 type userInfoMessage struct {
 	UserInfo info.User `json:"userinfo"`
+	// Claims are the claims of the ID token, which are only returned in diagnostic sessions.
+	Claims map[string]any `json:"claims,omitempty"`
 }
 
 func (userInfoMessage) isAuthenticatedDataResponse() {}
@@ -10,10 +10,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/authmodes"
@@ -27,6 +30,8 @@ import (
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/msentraid/himmelblau"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/ubuntu/authd/log"
 	"github.com/ubuntu/decorate"
@@ -34,24 +39,42 @@ import (
 )
 
 const (
-	maxAuthAttempts    = 3
-	maxRequestDuration = 5 * time.Second
+	maxAuthAttempts = 3
+
+	// accessDeniedByLocalPolicyMsg is the message returned if the user authenticated successfully with the provider,
+	// but is not allowed to access the machine by the broker configuration.
+	accessDeniedByLocalPolicyMsg = "Access denied by local policy: user not allowed in broker configuration"
+	// providerTimeoutMsg is the message returned if a request to the provider did not complete within the request
+	// timeout.
+	providerTimeoutMsg = "The identity provider timed out. Please try again later."
 )
 
 // Config is the configuration for the broker.
 type Config struct {
 	ConfigFile string
 	DataDir    string
+	// ProviderID is the ID of the additional provider of the config file to use, or empty for the one of the [oidc]
+	// section.
+	ProviderID string
 
 	userConfig
 }
 
+var (
+	// errAudienceMismatch is returned if the ID token was not issued for the client.
+	errAudienceMismatch = errors.New("audience mismatch")
+	// errNonceMismatch is returned if the ID token was not obtained with the authentication request of the session.
+	errNonceMismatch = errors.New("nonce mismatch")
+)
+
 // Broker is the real implementation of the broker to track sessions and process oidc calls.
 type Broker struct {
-	cfg Config
+	// settings is the configuration of the broker, which is replaced as a whole when it's reloaded.
+	settings atomic.Pointer[settings]
 
 	provider providers.Provider
-	oidcCfg  oidc.Config
+	// authThrottler rejects authentication attempts of users with too many failed attempts.
+	authThrottler *authThrottler
 
 	currentSessions   map[string]session
 	currentSessionsMu sync.RWMutex
@@ -59,6 +82,26 @@ type Broker struct {
 	privateKey *rsa.PrivateKey
 }
 
+// settings are the configuration of the broker and the values derived from it.
+type settings struct {
+	Config
+
+	oidcCfg oidc.Config
+	// httpClient is the client used for the requests to the OIDC provider.
+	httpClient *http.Client
+	// scopes are the OIDC scopes requested for all sessions.
+	scopes []string
+	// parsedUsernameTemplate is the template from which the local username is derived, or nil to use the provider's one.
+	parsedUsernameTemplate *template.Template
+	// parsedHomeDirTemplate is the template from which the home directory is derived, or nil to use
+	// <home_base_dir>/<username>.
+	parsedHomeDirTemplate *template.Template
+	// metadataCache caches the metadata of the OIDC provider, or is nil if caching is disabled.
+	metadataCache *metadataCache
+	// keySet caches the signing keys of the OIDC provider.
+	keySet *keySet
+}
+
 type session struct {
 	username string
 	lang     string
@@ -79,7 +122,12 @@ type session struct {
 
 	// Data to pass from one request to another.
 	deviceAuthResponse *oauth2.DeviceAuthResponse
+	authCodeState      string
 	authInfo           *token.AuthCachedInfo
+	// nonce is sent in the authentication request and must be contained in the ID token obtained with it.
+	nonce string
+	// codeVerifier is the PKCE code verifier of the authorization request, which is sent with the authorization code.
+	codeVerifier string
 
 	isAuthenticating *isAuthenticatedCtx
 }
@@ -100,8 +148,15 @@ type Option func(*option)
 func New(cfg Config, args ...Option) (b *Broker, err error) {
 	p := providers.CurrentProvider()
 
+	if cfg.ProviderID != "" {
+		p, err = providerFromConfig(cfg.ConfigFile, cfg.ProviderID)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse config file '%s': %v", cfg.ConfigFile, err)
+		}
+	}
+
 	if cfg.ConfigFile != "" {
-		cfg.userConfig, err = parseConfigFromPath(cfg.ConfigFile, p)
+		cfg.userConfig, err = parseConfigFromPath(cfg.ConfigFile, cfg.ProviderID, p)
 		if err != nil {
 			return nil, fmt.Errorf("could not parse config file '%s': %v", cfg.ConfigFile, err)
 		}
@@ -114,6 +169,32 @@ func New(cfg Config, args ...Option) (b *Broker, err error) {
 		arg(&opts)
 	}
 
+	s, err := newSettings(cfg, opts.provider)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate a new private key for the broker.
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Error(context.Background(), err.Error())
+		return nil, errors.New("failed to generate broker private key")
+	}
+
+	b = &Broker{
+		provider:      opts.provider,
+		authThrottler: newAuthThrottler(s.maxFailedAttempts, s.failedAttemptsWindow),
+		privateKey:    privateKey,
+
+		currentSessions:   make(map[string]session),
+		currentSessionsMu: sync.RWMutex{},
+	}
+	b.settings.Store(s)
+	return b, nil
+}
+
+// newSettings validates the configuration and returns the settings derived from it.
+func newSettings(cfg Config, p providers.Provider) (s *settings, err error) {
 	if cfg.DataDir == "" {
 		err = errors.Join(err, errors.New("cache path is required and was not provided"))
 	}
@@ -123,6 +204,24 @@ func New(cfg Config, args ...Option) (b *Broker, err error) {
 	if cfg.clientID == "" {
 		err = errors.Join(err, errors.New("client ID is required and was not provided"))
 	}
+	if len(oidcAuthModes(p, cfg.authFlow, cfg.redirectURI)) == 0 {
+		err = errors.Join(err, fmt.Errorf("the provider does not support the %q authentication flow", cfg.authFlow))
+	}
+	usernameTemplate, tmplErr := parseUsernameTemplate(cfg.usernameTemplate)
+	if tmplErr != nil {
+		err = errors.Join(err, tmplErr)
+	}
+	homeDirTemplate, tmplErr := parseHomeDirTemplate(cfg.homeDirTemplate)
+	if tmplErr != nil {
+		err = errors.Join(err, tmplErr)
+	}
+	if cfg.requestTimeout == 0 {
+		cfg.requestTimeout = defaultRequestTimeout
+	}
+	httpClient, clientErr := newHTTPClient(cfg.proxyURL, cfg.caFile, cfg.requestTimeout)
+	if clientErr != nil {
+		err = errors.Join(err, clientErr)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -130,35 +229,95 @@ func New(cfg Config, args ...Option) (b *Broker, err error) {
 	if cfg.homeBaseDir == "" {
 		cfg.homeBaseDir = "/home"
 	}
-
-	// Generate a new private key for the broker.
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		log.Error(context.Background(), err.Error())
-		return nil, errors.New("failed to generate broker private key")
+	if cfg.failedAttemptsWindow == 0 {
+		cfg.failedAttemptsWindow = defaultFailedAttemptsWindow
 	}
 
 	clientID := cfg.clientID
-	if opts.provider.SupportsDeviceRegistration() && cfg.registerDevice {
+	if p.SupportsDeviceRegistration() && cfg.registerDevice {
 		clientID = consts.MicrosoftBrokerAppID
 	}
 
-	b = &Broker{
-		cfg:        cfg,
-		provider:   opts.provider,
-		oidcCfg:    oidc.Config{ClientID: clientID},
-		privateKey: privateKey,
+	scopes := slices.Concat(consts.DefaultScopes, p.AdditionalScopes())
+	if p.SupportsDeviceRegistration() && cfg.registerDevice {
+		scopes = consts.MicrosoftBrokerAppScopes
+	}
 
-		currentSessions:   make(map[string]session),
-		currentSessionsMu: sync.RWMutex{},
+	var cache *metadataCache
+	if cfg.metadataCacheTTL > 0 {
+		cache = newMetadataCache(cfg.metadataCacheTTL)
 	}
-	return b, nil
+
+	return &settings{
+		Config: cfg,
+		// The expiry is checked by verifyTokenTimes and the audience by verifyAudience instead, to allow for the
+		// configured clock skew and to also check the authorized party.
+		oidcCfg:                oidc.Config{ClientID: clientID, SkipExpiryCheck: true, SkipClientIDCheck: true},
+		httpClient:             httpClient,
+		scopes:                 mergeScopes(scopes, cfg.extraScopes),
+		parsedUsernameTemplate: usernameTemplate,
+		parsedHomeDirTemplate:  homeDirTemplate,
+		metadataCache:          cache,
+		keySet:                 newKeySet(httpClient, cfg.issuerURL, cfg.metadataCacheTTL),
+	}, nil
+}
+
+// Reload re-reads the configuration file and replaces the configuration of the broker with it. The new configuration
+// is validated first, so if it's invalid, an error is returned and the current configuration stays active.
+//
+// Sessions which are already started keep the OAuth 2.0 configuration they were started with. The limits of failed
+// authentication attempts are only applied on restart, to not lose track of the failed attempts.
+func (b *Broker) Reload() (err error) {
+	defer decorate.OnError(&err, "could not reload configuration")
+
+	current := b.config()
+	if current.ConfigFile == "" {
+		return errors.New("the broker was not started with a configuration file")
+	}
+
+	// The implementation of the provider is kept, even if the type of an additional provider was changed.
+	cfg := Config{ConfigFile: current.ConfigFile, DataDir: current.DataDir, ProviderID: current.ProviderID}
+	cfg.userConfig, err = parseConfigFromPath(cfg.ConfigFile, cfg.ProviderID, b.provider)
+	if err != nil {
+		return fmt.Errorf("could not parse config file '%s': %v", cfg.ConfigFile, err)
+	}
+
+	s, err := newSettings(cfg, b.provider)
+	if err != nil {
+		return err
+	}
+
+	b.settings.Store(s)
+	log.Infof(context.Background(), "Reloaded configuration from %s", cfg.ConfigFile)
+	return nil
+}
+
+// config returns the current configuration of the broker. Callers should only call it once per operation, so that
+// they use a consistent configuration even if it's reloaded concurrently.
+func (b *Broker) config() *settings {
+	return b.settings.Load()
+}
+
+// mergeScopes returns the base scopes followed by the extra scopes which are not already part of them.
+// The order of the scopes is preserved and empty or duplicate extra scopes are ignored.
+func mergeScopes(base, extra []string) []string {
+	merged := slices.Clone(base)
+	for _, scope := range extra {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || slices.Contains(merged, scope) {
+			continue
+		}
+		merged = append(merged, scope)
+	}
+	return merged
 }
 
 // NewSession creates a new session for the user.
 func (b *Broker) NewSession(username, lang, mode string) (sessionID, encryptionKey string, err error) {
 	defer decorate.OnError(&err, "could not create new session for user %q", username)
 
+	cfg := b.config()
+
 	sessionID = uuid.New().String()
 	s := session{
 		username: username,
@@ -173,36 +332,37 @@ func (b *Broker) NewSession(username, lang, mode string) (sessionID, encryptionK
 		return "", "", err
 	}
 
-	_, issuer, _ := strings.Cut(b.cfg.issuerURL, "://")
-	issuer = strings.ReplaceAll(issuer, "/", "_")
-	issuer = strings.ReplaceAll(issuer, ":", "_")
-	s.userDataDir = filepath.Join(b.cfg.DataDir, issuer, username)
-	// The token is stored in $DATA_DIR/$ISSUER/$USERNAME/token.json.
-	s.tokenPath = filepath.Join(s.userDataDir, "token.json")
+	s.userDataDir = b.userDataDir(username)
+	s.tokenPath = tokenPath(s.userDataDir)
 	// The password is stored in $DATA_DIR/$ISSUER/$USERNAME/password.
 	s.passwordPath = filepath.Join(s.userDataDir, "password")
 
-	// Construct an OIDC provider via OIDC discovery.
-	s.oidcServer, err = b.connectToOIDCServer(context.Background())
+	// Construct an OIDC provider via OIDC discovery. Providers which don't support OIDC provide their OAuth 2.0
+	// endpoints themselves, so we only check that they are reachable.
+	var endpoint oauth2.Endpoint
+	if p, ok := b.provider.(providers.OAuth2Provider); ok {
+		endpoint = p.Endpoint(cfg.issuerURL)
+		err = b.checkProviderIsReachable(context.Background(), endpoint.TokenURL)
+	} else if s.oidcServer, err = b.connectToOIDCServer(context.Background()); err == nil {
+		endpoint = s.oidcServer.Endpoint()
+	}
+	if err != nil && mode == sessionmode.Diagnostic {
+		// Diagnostic sessions are about the connection to the provider, so they can't be offline.
+		return "", "", fmt.Errorf("could not connect to the provider: %v", err)
+	}
 	if err != nil {
 		log.Noticef(context.Background(), "Could not connect to the provider: %v. Starting session in offline mode.", err)
 		s.isOffline = true
 		s.providerConnectionError = err
 	}
 
-	scopes := append(consts.DefaultScopes, b.provider.AdditionalScopes()...)
-	if b.provider.SupportsDeviceRegistration() && b.cfg.registerDevice {
-		scopes = consts.MicrosoftBrokerAppScopes
-	}
-	// Append extra scopes from config
-	scopes = append(scopes, b.cfg.extraScopes...)
-
-	if s.oidcServer != nil {
+	if !s.isOffline {
 		s.oauth2Config = oauth2.Config{
-			ClientID:     b.oidcCfg.ClientID,
-			ClientSecret: b.cfg.clientSecret,
-			Endpoint:     s.oidcServer.Endpoint(),
-			Scopes:       scopes,
+			ClientID:     cfg.oidcCfg.ClientID,
+			ClientSecret: cfg.clientSecret,
+			Endpoint:     endpoint,
+			RedirectURL:  cfg.redirectURI,
+			Scopes:       cfg.scopes,
 		}
 	}
 
@@ -213,11 +373,14 @@ func (b *Broker) NewSession(username, lang, mode string) (sessionID, encryptionK
 	return sessionID, base64.StdEncoding.EncodeToString(pubASN1), nil
 }
 
+// connectToOIDCServer returns the OIDC provider of the issuer, from the metadata cache if it's enabled.
 func (b *Broker) connectToOIDCServer(ctx context.Context) (*oidc.Provider, error) {
-	ctx, cancel := context.WithTimeout(ctx, maxRequestDuration)
-	defer cancel()
+	if c := b.config().metadataCache; c != nil {
+		return c.get(ctx, b)
+	}
 
-	return oidc.NewProvider(ctx, b.cfg.issuerURL)
+	p, _, err := b.discoverOIDCServer(ctx)
+	return p, err
 }
 
 // GetAuthenticationModes returns the authentication modes available for the user.
@@ -287,12 +450,21 @@ func (b *Broker) availableAuthModes(session session) (availableModes []string, e
 		}
 		return []string{authmodes.Password}, nil
 
+	case sessionmode.Diagnostic:
+		// Session is for testing the configuration, which requires authenticating with the provider.
+		for _, mode := range b.oidcAuthModes() {
+			if b.authModeIsAvailable(session, mode) {
+				availableModes = append(availableModes, mode)
+			}
+		}
+		return availableModes, nil
+
 	default:
 		// Session is for login. Check which auth modes are available.
 		// The order of the modes is important, because authd picks the first supported one.
 		// Password authentication should be the first option if available, to avoid performing device authentication
 		// when it's not necessary.
-		modes := append([]string{authmodes.Password}, b.provider.SupportedOIDCAuthModes()...)
+		modes := append([]string{authmodes.Password}, b.oidcAuthModes()...)
 		for _, mode := range modes {
 			if b.authModeIsAvailable(session, mode) {
 				availableModes = append(availableModes, mode)
@@ -303,6 +475,7 @@ func (b *Broker) availableAuthModes(session session) (availableModes []string, e
 }
 
 func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
+	cfg := b.config()
 	switch authMode {
 	case authmodes.Password:
 		if !tokenExists(session) {
@@ -321,6 +494,15 @@ func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
 			return false
 		}
 
+		if authInfo.IsStale(cfg.tokenCacheTTL) {
+			// Evict the stale token, so that the user has to authenticate with the provider again.
+			log.Noticef(context.Background(), "Token of user %q was obtained more than %s ago, so local password authentication is not available", session.username, cfg.tokenCacheTTL)
+			if err := token.RemoveAuthInfo(session.tokenPath); err != nil {
+				log.Warningf(context.Background(), "Could not remove stale token: %v", err)
+			}
+			return false
+		}
+
 		if !b.provider.SupportsDeviceRegistration() {
 			// If the provider does not support device registration,
 			// we can always use the token for local password authentication.
@@ -341,12 +523,12 @@ func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
 			return false
 		}
 
-		if b.cfg.registerDevice && !isTokenForDeviceRegistration {
+		if cfg.registerDevice && !isTokenForDeviceRegistration {
 			// TODO: We might want to display a message to the user in this case
 			log.Noticef(context.Background(), "Token exists for user %q, but it cannot be used for device registration, so local password authentication is not available", session.username)
 			return false
 		}
-		if !b.cfg.registerDevice && isTokenForDeviceRegistration {
+		if !cfg.registerDevice && isTokenForDeviceRegistration {
 			// TODO: We might want to display a message to the user in this case
 			log.Noticef(context.Background(), "Token exists for user %q, but it requires device registration, so local password authentication is not available", session.username)
 			return false
@@ -356,11 +538,11 @@ func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
 	case authmodes.NewPassword:
 		return true
 	case authmodes.Device, authmodes.DeviceQr:
-		if session.oidcServer == nil {
+		if session.oauth2Config.Endpoint.TokenURL == "" {
 			log.Debugf(context.Background(), "OIDC server is not initialized, so device authentication is not available")
 			return false
 		}
-		if session.oidcServer.Endpoint().DeviceAuthURL == "" {
+		if session.oauth2Config.Endpoint.DeviceAuthURL == "" {
 			log.Debugf(context.Background(), "OIDC server does not support device authentication, so device authentication is not available")
 			return false
 		}
@@ -369,6 +551,20 @@ func (b *Broker) authModeIsAvailable(session session, authMode string) bool {
 			return false
 		}
 		return true
+	case authmodes.AuthCode:
+		if cfg.redirectURI == "" {
+			log.Debugf(context.Background(), "No %s is configured, so authorization code authentication is not available", redirectURIKey)
+			return false
+		}
+		if session.oauth2Config.Endpoint.AuthURL == "" {
+			log.Debugf(context.Background(), "OIDC server is not initialized, so authorization code authentication is not available")
+			return false
+		}
+		if session.isOffline {
+			log.Noticef(context.Background(), "Session is in offline mode, so authorization code authentication is not available")
+			return false
+		}
+		return true
 	}
 	return false
 }
@@ -391,37 +587,39 @@ func passwordFileExists(session session) bool {
 
 func (b *Broker) authModesSupportedByUI(supportedUILayouts []map[string]string) (supportedModes []string) {
 	for _, layout := range supportedUILayouts {
-		mode := b.supportedAuthModeFromLayout(layout)
-		if mode != "" {
-			supportedModes = append(supportedModes, mode)
-		}
+		supportedModes = append(supportedModes, b.supportedAuthModesFromLayout(layout)...)
 	}
 	return supportedModes
 }
 
-func (b *Broker) supportedAuthModeFromLayout(layout map[string]string) string {
+func (b *Broker) supportedAuthModesFromLayout(layout map[string]string) (modes []string) {
 	supportedEntries := strings.Split(strings.TrimPrefix(layout["entry"], "optional:"), ",")
 	switch layout["type"] {
 	case "qrcode":
 		if !strings.Contains(layout["wait"], "true") {
-			return ""
+			return nil
 		}
 		if layout["renders_qrcode"] == "false" {
-			return authmodes.Device
+			return []string{authmodes.Device}
 		}
-		return authmodes.DeviceQr
+		return []string{authmodes.DeviceQr}
 
 	case "form":
 		if slices.Contains(supportedEntries, "chars_password") {
-			return authmodes.Password
+			modes = append(modes, authmodes.Password)
+		}
+		// The authorization code is not secret once it has been redeemed, so it's entered in plain text, which makes
+		// it easier to paste.
+		if slices.Contains(supportedEntries, "chars") {
+			modes = append(modes, authmodes.AuthCode)
 		}
 
 	case "newpassword":
 		if slices.Contains(supportedEntries, "chars_password") {
-			return authmodes.NewPassword
+			modes = append(modes, authmodes.NewPassword)
 		}
 	}
-	return ""
+	return modes
 }
 
 // SelectAuthenticationMode selects the authentication mode for the user.
@@ -455,7 +653,7 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 	var uiLayout map[string]string
 	switch authModeID {
 	case authmodes.Device, authmodes.DeviceQr:
-		ctx, cancel := context.WithTimeout(context.Background(), maxRequestDuration)
+		ctx, cancel := context.WithTimeout(context.Background(), b.config().requestTimeout)
 		defer cancel()
 
 		var authOpts []oauth2.AuthCodeOption
@@ -478,8 +676,18 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 			authOpts = append(authOpts, oauth2.SetAuthURLParam("client_secret", secret))
 		}
 
+		// The device authorization grant doesn't define a nonce, but some providers include it in the ID token.
+		if _, ok := b.provider.(providers.OAuth2Provider); !ok {
+			session.nonce = rand.Text()
+			authOpts = append(authOpts, oidc.Nonce(session.nonce))
+		}
+
 		log.Debug(ctx, "Sending Device Authorization Request to retrieve device code...")
-		response, err := session.oauth2Config.DeviceAuth(ctx, authOpts...)
+		response, err := session.oauth2Config.DeviceAuth(b.withHTTPClient(ctx), authOpts...)
+		if err != nil && isTimeoutError(err) {
+			log.Errorf(context.Background(), "Device Authorization Request timed out: %v", err)
+			return nil, errors.New("could not generate Device Authentication code layout: identity provider timed out")
+		}
 		if err != nil {
 			return nil, fmt.Errorf("could not generate Device Authentication code layout: %v", err)
 		}
@@ -500,6 +708,9 @@ func (b *Broker) generateUILayout(session *session, authModeID string) (map[stri
 			"code":    response.UserCode,
 		}
 
+	case authmodes.AuthCode:
+		uiLayout = b.authCodeLayout(session)
+
 	case authmodes.Password:
 		uiLayout = map[string]string{
 			"type":  "form",
@@ -568,6 +779,8 @@ func (b *Broker) IsAuthenticated(sessionID, authenticationData string) (string,
 			iadResponse = errorMessage{Message: "Maximum number of authentication attempts reached"}
 		}
 	}
+	recordAuthentication(b.config().issuerURL, access)
+	auditAuthentication(&session, b.config().issuerURL, access, iadResponse)
 
 	if err = b.updateSession(sessionID, session); err != nil {
 		return AuthDenied, "{}", err
@@ -605,6 +818,8 @@ func (b *Broker) handleIsAuthenticated(ctx context.Context, session *session, au
 	switch session.selectedMode {
 	case authmodes.Device, authmodes.DeviceQr:
 		return b.deviceAuth(ctx, session)
+	case authmodes.AuthCode:
+		return b.authCodeAuth(ctx, session, secret)
 	case authmodes.Password:
 		return b.passwordAuth(ctx, session, secret)
 	case authmodes.NewPassword:
@@ -636,7 +851,12 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 	response.Interval = 1
 
 	log.Debug(ctx, "Polling to exchange device code for token...")
-	t, err := session.oauth2Config.DeviceAccessToken(expiryCtx, response, b.provider.AuthOptions()...)
+	t, err := session.oauth2Config.DeviceAccessToken(b.withHTTPClient(expiryCtx), response, b.provider.AuthOptions()...)
+	// The context also expires with the device code, which is not a timeout of the provider.
+	if err != nil && expiryCtx.Err() == nil && isTimeoutError(err) {
+		log.Errorf(context.Background(), "Timed out retrieving access token: %s", err)
+		return AuthRetry, errorMessage{Message: providerTimeoutMsg}
+	}
 	if err != nil {
 		log.Errorf(context.Background(), "Error retrieving access token: %s", err)
 		return AuthRetry, errorMessage{Message: "Error retrieving access token. Please try again."}
@@ -647,32 +867,44 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 		log.Warningf(context.Background(), "No refresh token returned for user during device authentication. You might have to add the 'offline_access' scope to the 'extra_scopes' setting.")
 	}
 
+	return b.authenticateWithToken(ctx, session, t)
+}
+
+// authenticateWithToken gets the user info and groups of the user the token obtained from the provider was issued to,
+// and stores them in the session for the next authentication step, in which the user defines the local password.
+func (b *Broker) authenticateWithToken(ctx context.Context, session *session, t *oauth2.Token) (string, isAuthenticatedDataResponse) {
+	cfg := b.config()
+	// Providers which don't support OIDC don't return an ID token.
 	rawIDToken, ok := t.Extra("id_token").(string)
-	if !ok {
+	if _, isOAuth2 := b.provider.(providers.OAuth2Provider); !ok && !isOAuth2 {
 		log.Error(context.Background(), "token response does not contain an ID token")
 		return AuthDenied, unexpectedErrMsg("token response does not contain an ID token")
 	}
 
 	authInfo := token.NewAuthCachedInfo(t, rawIDToken, b.provider)
 
+	var err error
 	authInfo.ProviderMetadata, err = b.provider.GetMetadata(session.oidcServer)
 	if err != nil {
 		log.Errorf(context.Background(), "could not get provider metadata: %s", err)
 		return AuthDenied, unexpectedErrMsg("could not get provider metadata")
 	}
 
-	authInfo.UserInfo, err = b.userInfoFromIDToken(ctx, session, rawIDToken)
+	authInfo.UserInfo, err = b.userInfoFromToken(ctx, session, t, rawIDToken, session.nonce)
 	if err != nil {
 		log.Errorf(context.Background(), "could not get user info: %s", err)
 		return AuthDenied, errorMessageForDisplay(err, "Could not get user info")
 	}
 
-	if !b.userNameIsAllowed(authInfo.UserInfo.Name) {
+	// If allowed groups are configured, the user might be allowed because of its groups, which are only known after
+	// fetching them below, so the check is done in finishAuth instead.
+	if len(cfg.allowedGroups) == 0 && !b.userNameIsAllowed(authInfo.UserInfo.Name) {
 		log.Warning(context.Background(), b.userNotAllowedLogMsg(authInfo.UserInfo.Name))
-		return AuthDenied, errorMessage{Message: "Authentication failure: user not allowed in broker configuration"}
+		return AuthDenied, errorMessage{Message: accessDeniedByLocalPolicyMsg}
 	}
 
-	if b.provider.SupportsDeviceRegistration() && b.cfg.registerDevice {
+	// Diagnostic sessions don't register the device, because they must not store anything for the user.
+	if b.provider.SupportsDeviceRegistration() && cfg.registerDevice && session.mode != sessionmode.Diagnostic {
 		// Load existing device registration data if there is any, to avoid re-registering the device.
 		var deviceRegistrationData []byte
 		oldAuthInfo, err := token.LoadAuthInfo(session.tokenPath)
@@ -683,7 +915,7 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 		var cleanup func()
 		authInfo.DeviceRegistrationData, cleanup, err = b.provider.MaybeRegisterDevice(ctx, t,
 			session.username,
-			b.cfg.issuerURL,
+			cfg.issuerURL,
 			deviceRegistrationData,
 		)
 		if err != nil {
@@ -707,6 +939,11 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 		return AuthDenied, errorMessageForDisplay(err, "Failed to retrieve groups from Microsoft Graph API")
 	}
 
+	// Diagnostic sessions don't create a local password, so that nothing is stored for the user.
+	if session.mode == sessionmode.Diagnostic {
+		return b.finishAuth(session, authInfo)
+	}
+
 	// Store the auth info in the session so that we can use it when handling the
 	// next IsAuthenticated call for the new password mode.
 	session.authInfo = authInfo
@@ -716,11 +953,17 @@ func (b *Broker) deviceAuth(ctx context.Context, session *session) (string, isAu
 }
 
 func (b *Broker) passwordAuth(ctx context.Context, session *session, secret string) (string, isAuthenticatedDataResponse) {
+	cfg := b.config()
+	if msg, throttled := b.throttledMsg(session); throttled {
+		return AuthDenied, msg
+	}
+
 	ok, err := password.CheckPassword(secret, session.passwordPath)
 	if err != nil {
 		log.Error(context.Background(), err.Error())
 		return AuthDenied, unexpectedErrMsg("could not check password")
 	}
+	b.recordAuthResult(session, ok)
 	if !ok {
 		log.Noticef(context.Background(), "Authentication failure: incorrect local password for user %q", session.username)
 		return AuthRetry, errorMessage{Message: "Incorrect password, please try again."}
@@ -742,7 +985,19 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 		return AuthNext, nil
 	}
 
-	if b.cfg.forceProviderAuthentication && session.isOffline {
+	// The session lifetime takes precedence over the offline grace period: once it has elapsed, the user must
+	// authenticate with the provider, even if the cached token could still be used offline.
+	if authInfo.LoginExpired(cfg.maxSessionLifetime) {
+		if session.isOffline {
+			log.Noticef(context.Background(), "Login denied: the session lifetime of user %q has elapsed and the identity provider is not reachable", session.username)
+			return AuthDenied, errorMessage{Message: "Session expired: the identity provider is not reachable. Please try again with a working network connection."}
+		}
+		log.Noticef(context.Background(), "Session lifetime of user %q has elapsed, new authentication with the identity provider required", session.username)
+		session.nextAuthModes = b.oidcAuthModes()
+		return AuthNext, errorMessage{Message: "Session expired, please authenticate again with the identity provider."}
+	}
+
+	if cfg.forceProviderAuthentication && session.isOffline {
 		log.Error(context.Background(), "Remote authentication failed: force_provider_authentication is enabled, but the identity provider is not reachable")
 		return AuthDenied, errorMessage{Message: "Remote authentication failed: identity provider is not reachable"}
 	}
@@ -757,12 +1012,21 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 		return AuthDenied, errorMessage{Message: "This device is disabled in Microsoft Entra ID. Please contact your administrator or try again with a working network connection."}
 	}
 
+	if session.isOffline && authInfo.IsStale(cfg.offlineGracePeriod) {
+		log.Noticef(context.Background(), "Login denied: the identity provider is not reachable and the cached credentials of user %q are older than %s", session.username, cfg.offlineGracePeriod)
+		return AuthDenied, errorMessage{Message: "Cached credentials expired: the identity provider is not reachable. Please try again with a working network connection."}
+	}
+
+	if session.isOffline {
+		log.Infof(context.Background(), "The identity provider is not reachable, using the cached credentials of user %q", session.username)
+	}
+
 	// Refresh the token if we're online even if the token has not expired
-	if b.cfg.forceProviderAuthentication || !session.isOffline {
+	if cfg.forceProviderAuthentication || !session.isOffline {
 		// Check if we have a refresh token before attempting to refresh
 		if authInfo.Token.RefreshToken == "" {
 			log.Warningf(context.Background(), "No refresh token available for user %q", session.username)
-			session.nextAuthModes = []string{authmodes.Device, authmodes.DeviceQr}
+			session.nextAuthModes = b.oidcAuthModes()
 			return AuthNext, errorMessage{Message: "Remote authentication failed: No refresh token. Please contact your administrator."}
 		}
 
@@ -773,7 +1037,7 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 		if errors.As(err, &retrieveErr) {
 			if b.provider.IsTokenExpiredError(retrieveErr) {
 				log.Noticef(context.Background(), "Refresh token expired for user %q, new device authentication required", session.username)
-				session.nextAuthModes = []string{authmodes.Device, authmodes.DeviceQr}
+				session.nextAuthModes = b.oidcAuthModes()
 				return AuthNext, errorMessage{Message: "Refresh token expired, please authenticate again using device authentication."}
 			}
 			if b.provider.IsUserDisabledError(retrieveErr) {
@@ -790,19 +1054,23 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 				return AuthDenied, errorMessage{Message: "This user is disabled in Microsoft Entra ID, please contact your administrator."}
 			}
 		}
+		if err != nil && isTimeoutError(err) {
+			log.Errorf(context.Background(), "Timed out refreshing token: %s", err)
+			return AuthDenied, errorMessage{Message: providerTimeoutMsg}
+		}
 		if err != nil {
 			log.Errorf(context.Background(), "Failed to refresh token: %s", err)
-			return AuthDenied, errorMessage{Message: "Failed to refresh token"}
+			return AuthDenied, errorMessageForDisplay(err, "Failed to refresh token")
 		}
 	}
 
 	// If device registration is enabled, ensure that the device is registered.
-	if b.provider.SupportsDeviceRegistration() && !session.isOffline && b.cfg.registerDevice {
+	if b.provider.SupportsDeviceRegistration() && !session.isOffline && cfg.registerDevice {
 		var cleanup func()
 		authInfo.DeviceRegistrationData, cleanup, err = b.provider.MaybeRegisterDevice(ctx,
 			authInfo.Token,
 			session.username,
-			b.cfg.issuerURL,
+			cfg.issuerURL,
 			authInfo.DeviceRegistrationData,
 		)
 		if err != nil {
@@ -852,7 +1120,7 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 			return AuthDenied, unexpectedErrMsg("failed to store token")
 		}
 
-		session.nextAuthModes = []string{authmodes.Device, authmodes.DeviceQr}
+		session.nextAuthModes = b.oidcAuthModes()
 		msg := "Authentication failed due to a token issue. Please try again using device authentication."
 		return AuthNext, errorMessage{Message: msg}
 	}
@@ -867,8 +1135,9 @@ func (b *Broker) passwordAuth(ctx context.Context, session *session, secret stri
 }
 
 func (b *Broker) finishAuth(session *session, authInfo *token.AuthCachedInfo) (string, isAuthenticatedDataResponse) {
-	if b.cfg.shouldRegisterOwner() {
-		if err := b.cfg.registerOwner(b.cfg.ConfigFile, authInfo.UserInfo.Name); err != nil {
+	cfg := b.config()
+	if cfg.shouldRegisterOwner() && session.mode != sessionmode.Diagnostic {
+		if err := cfg.registerOwner(cfg.ConfigFile, authInfo.UserInfo.Name); err != nil {
 			// The user is not allowed if we fail to create the owner-autoregistration file.
 			// Otherwise the owner might change if the broker is restarted.
 			log.Errorf(context.Background(), "Failed to assign the owner role: %v", err)
@@ -876,25 +1145,40 @@ func (b *Broker) finishAuth(session *session, authInfo *token.AuthCachedInfo) (s
 		}
 	}
 
-	if !b.userNameIsAllowed(authInfo.UserInfo.Name) {
+	if !b.userIsAllowed(authInfo) {
 		log.Warning(context.Background(), b.userNotAllowedLogMsg(authInfo.UserInfo.Name))
-		return AuthDenied, errorMessage{Message: "Authentication failure: user not allowed in broker configuration"}
+		return AuthDenied, errorMessage{Message: accessDeniedByLocalPolicyMsg}
+	}
+
+	// Add the local groups which the group claims of the user are mapped to.
+	for _, name := range b.mappedGroups(authInfo.RawIDToken) {
+		if slices.ContainsFunc(authInfo.UserInfo.Groups, func(g info.Group) bool { return g.Name == name }) {
+			continue
+		}
+		log.Debugf(context.Background(), "Adding mapped group %q", name)
+		authInfo.UserInfo.Groups = append(authInfo.UserInfo.Groups, info.Group{Name: name})
 	}
 
 	// Add extra groups to the user info.
-	for _, name := range b.cfg.extraGroups {
+	for _, name := range cfg.extraGroups {
 		log.Debugf(context.Background(), "Adding extra group %q", name)
 		authInfo.UserInfo.Groups = append(authInfo.UserInfo.Groups, info.Group{Name: name})
 	}
 
 	if b.isOwner(authInfo.UserInfo.Name) {
 		// Add the owner extra groups to the user info.
-		for _, name := range b.cfg.ownerExtraGroups {
+		for _, name := range cfg.ownerExtraGroups {
 			log.Debugf(context.Background(), "Adding owner extra group %q", name)
 			authInfo.UserInfo.Groups = append(authInfo.UserInfo.Groups, info.Group{Name: name})
 		}
 	}
 
+	if session.mode == sessionmode.Diagnostic {
+		// The claims are returned in diagnostic sessions, so that the configuration of the claim mappings can be
+		// checked.
+		return AuthGranted, userInfoMessage{UserInfo: authInfo.UserInfo, Claims: idTokenClaims(authInfo.RawIDToken)}
+	}
+
 	if session.isOffline {
 		return AuthGranted, userInfoMessage{UserInfo: authInfo.UserInfo}
 	}
@@ -930,17 +1214,40 @@ func (b *Broker) newPassword(session *session, secret string) (string, isAuthent
 
 // userNameIsAllowed checks whether the user's username is allowed to access the machine.
 func (b *Broker) userNameIsAllowed(userName string) bool {
-	return b.cfg.userNameIsAllowed(b.provider.NormalizeUsername(userName))
+	return b.config().userNameIsAllowed(b.provider.NormalizeUsername(userName))
 }
 
 // isOwner returns true if the user is the owner of the machine.
 func (b *Broker) isOwner(userName string) bool {
-	return b.cfg.owner == b.provider.NormalizeUsername(userName)
+	return b.config().owner == b.provider.NormalizeUsername(userName)
+}
+
+// userIsAllowed checks whether the user is allowed to access the machine, either because of its username or because
+// it's a member of one of the allowed groups, according to the provider or to the groups claim of the ID token.
+func (b *Broker) userIsAllowed(authInfo *token.AuthCachedInfo) bool {
+	cfg := b.config()
+	if b.userNameIsAllowed(authInfo.UserInfo.Name) {
+		return true
+	}
+	if len(cfg.allowedGroups) == 0 {
+		return false
+	}
+
+	for _, group := range authInfo.UserInfo.Groups {
+		if cfg.groupIsAllowed(group.Name) {
+			return true
+		}
+	}
+	return slices.ContainsFunc(b.groupClaimValues(authInfo.RawIDToken), cfg.groupIsAllowed)
 }
 
 func (b *Broker) userNotAllowedLogMsg(userName string) string {
+	cfg := b.config()
 	logMsg := fmt.Sprintf("User %q is not in the list of allowed users.", userName)
-	logMsg += fmt.Sprintf("\nYou can add the user to allowed_users in %s", b.cfg.ConfigFile)
+	if len(cfg.allowedGroups) > 0 {
+		logMsg = fmt.Sprintf("User %q is neither in the list of allowed users nor a member of any allowed group.", userName)
+	}
+	logMsg += fmt.Sprintf("\nYou can add the user to allowed_users in %s", cfg.ConfigFile)
 	return logMsg
 }
 
@@ -1006,8 +1313,9 @@ func (b *Broker) CancelIsAuthenticated(sessionID string) {
 // UserPreCheck checks if the user is valid and can be allowed to authenticate.
 // It returns the user info in JSON format if the user is valid, or an empty string if the user is not allowed.
 func (b *Broker) UserPreCheck(username string) (string, error) {
+	cfg := b.config()
 	found := false
-	for _, suffix := range b.cfg.allowedSSHSuffixes {
+	for _, suffix := range cfg.allowedSSHSuffixes {
 		if suffix == "" {
 			continue
 		}
@@ -1025,7 +1333,12 @@ func (b *Broker) UserPreCheck(username string) (string, error) {
 		return "", nil
 	}
 
-	u := info.NewUser(username, filepath.Join(b.cfg.homeBaseDir, username), "", "", "", nil)
+	home, err := b.homeDir(username)
+	if err != nil {
+		return "", err
+	}
+
+	u := info.NewUser(username, home, "", "", "", nil)
 	encoded, err := json.Marshal(u)
 	if err != nil {
 		return "", fmt.Errorf("could not marshal user info: %v", err)
@@ -1033,6 +1346,54 @@ func (b *Broker) UserPreCheck(username string) (string, error) {
 	return string(encoded), nil
 }
 
+// Logout revokes the token of the user at the provider and removes the cached token, so that the user has to
+// authenticate with the provider again on the next login.
+// If the provider does not advertise a revocation endpoint, the cached token is only removed.
+// The cached token is removed even if the revocation fails, in which case the error is returned.
+func (b *Broker) Logout(username string) (err error) {
+	defer decorate.OnError(&err, "could not log out user %q", username)
+
+	if username == "" || username == "." || username == ".." || strings.ContainsRune(username, filepath.Separator) {
+		return errors.New("invalid username")
+	}
+
+	path := tokenPath(b.userDataDir(username))
+	exists, err := fileutils.FileExists(path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		log.Debugf(context.Background(), "No cached token for user %q, nothing to revoke", username)
+		return nil
+	}
+
+	authInfo, err := token.LoadAuthInfo(path)
+	if err != nil {
+		log.Warningf(context.Background(), "Could not load the cached token of user %q, removing it without revoking it: %v", username, err)
+		return token.RemoveAuthInfo(path)
+	}
+
+	revokeErr := b.revokeToken(context.Background(), authInfo.Token)
+	if err := token.RemoveAuthInfo(path); err != nil {
+		return errors.Join(revokeErr, err)
+	}
+	return revokeErr
+}
+
+// userDataDir returns the directory in which the data of the user is stored, i.e. $DATA_DIR/$ISSUER/$USERNAME.
+func (b *Broker) userDataDir(username string) string {
+	cfg := b.config()
+	_, issuer, _ := strings.Cut(cfg.issuerURL, "://")
+	issuer = strings.ReplaceAll(issuer, "/", "_")
+	issuer = strings.ReplaceAll(issuer, ":", "_")
+	return filepath.Join(cfg.DataDir, issuer, username)
+}
+
+// tokenPath returns the path of the token in the user data directory, i.e. $DATA_DIR/$ISSUER/$USERNAME/token.json.
+func tokenPath(userDataDir string) string {
+	return filepath.Join(userDataDir, "token.json")
+}
+
 // getSession returns the session information for the specified session ID or an error if the session is not active.
 func (b *Broker) getSession(sessionID string) (session, error) {
 	b.currentSessionsMu.RLock()
@@ -1058,12 +1419,13 @@ func (b *Broker) updateSession(sessionID string, session session) error {
 
 // refreshToken refreshes the OAuth2 token and returns the updated AuthCachedInfo.
 func (b *Broker) refreshToken(ctx context.Context, session *session, oldToken *token.AuthCachedInfo) (*token.AuthCachedInfo, error) {
-	timeoutCtx, cancel := context.WithTimeout(ctx, maxRequestDuration)
+	timeoutCtx, cancel := context.WithTimeout(ctx, b.config().requestTimeout)
 	defer cancel()
 	// set cached token expiry time to one hour in the past
 	// this makes sure the token is refreshed even if it has not 'actually' expired
 	oldToken.Token.Expiry = time.Now().Add(-time.Hour)
-	oauthToken, err := session.oauth2Config.TokenSource(timeoutCtx, oldToken.Token).Token()
+	oauthToken, err := session.oauth2Config.TokenSource(b.withHTTPClient(timeoutCtx), oldToken.Token).Token()
+	recordTokenRefresh(b.config().issuerURL, err)
 	if err != nil {
 		return nil, err
 	}
@@ -1078,8 +1440,11 @@ func (b *Broker) refreshToken(ctx context.Context, session *session, oldToken *t
 	t := token.NewAuthCachedInfo(oauthToken, rawIDToken, b.provider)
 	t.ProviderMetadata = oldToken.ProviderMetadata
 	t.DeviceRegistrationData = oldToken.DeviceRegistrationData
+	// Refreshing the token is not an interactive authentication, so the lifetime of the session is not extended.
+	t.LoggedInAt = oldToken.LoggedInAt
 
-	t.UserInfo, err = b.userInfoFromIDToken(ctx, session, rawIDToken)
+	// Refreshed ID tokens are not obtained with an authentication request, so there is no nonce to check.
+	t.UserInfo, err = b.userInfoFromToken(ctx, session, oauthToken, rawIDToken, "")
 	if err != nil {
 		return nil, err
 	}
@@ -1089,40 +1454,357 @@ func (b *Broker) refreshToken(ctx context.Context, session *session, oldToken *t
 	return t, nil
 }
 
-// userInfoFromIDToken verifies and parses the raw ID token and returns the user info from it.
+// userInfoFromToken returns the user info of the user the token was issued to. For OIDC providers, it is parsed from
+// the ID token, which must contain the nonce if it's not empty, while providers which don't support OIDC fetch it
+// using the access token.
+func (b *Broker) userInfoFromToken(ctx context.Context, session *session, t *oauth2.Token, rawIDToken, nonce string) (info.User, error) {
+	cfg := b.config()
+	p, ok := b.provider.(providers.OAuth2Provider)
+	if !ok {
+		return b.userInfoFromIDToken(ctx, session, rawIDToken, nonce)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, cfg.requestTimeout)
+	defer cancel()
+	claims, err := p.UserClaims(b.withHTTPClient(timeoutCtx), cfg.issuerURL, t)
+	if err != nil {
+		return info.User{}, fmt.Errorf("could not get user claims: %w", err)
+	}
+	return b.userInfoFromClaims(session, claims)
+}
+
+// userInfoFromIDToken verifies and parses the raw ID token and returns the user info from it. If nonce is not empty,
+// the ID token must contain it.
 // Note that verifying the ID token requires a working network connection to the provider's JWKs endpoint,
 // so make sure to only call this function if the session is online.
-func (b *Broker) userInfoFromIDToken(ctx context.Context, session *session, rawIDToken string) (info.User, error) {
-	idToken, err := session.oidcServer.Verifier(&b.oidcCfg).Verify(ctx, rawIDToken)
+func (b *Broker) userInfoFromIDToken(ctx context.Context, session *session, rawIDToken, nonce string) (info.User, error) {
+	cfg := b.config()
+	verifier, err := b.idTokenVerifier(cfg, session.oidcServer)
+	if err != nil {
+		return info.User{}, err
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
 	if err != nil {
 		return info.User{}, fmt.Errorf("could not verify token: %v", err)
 	}
 
-	userInfo, err := b.provider.GetUserInfo(idToken)
+	var claims struct {
+		NotBefore       *float64 `json:"nbf"`
+		AuthorizedParty string   `json:"azp"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return info.User{}, fmt.Errorf("could not verify token: failed to get ID token claims: %v", err)
+	}
+
+	if err := verifyAudience(cfg.oidcCfg.ClientID, idToken.Audience, claims.AuthorizedParty); err != nil {
+		log.Errorf(context.Background(), "Could not verify token: %v", err)
+		return info.User{}, &providerErrors.ForDisplayError{Message: "Authentication failure: the ID token was not issued for this client", Err: err}
+	}
+	// Providers are not required to support a nonce in the device authorization grant, so it's only required in the
+	// authorization code flow.
+	if err := verifyNonce(nonce, idToken.Nonce, session.selectedMode == authmodes.AuthCode); err != nil {
+		log.Errorf(context.Background(), "Could not verify token: %v", err)
+		return info.User{}, &providerErrors.ForDisplayError{Message: "Authentication failure: the ID token does not match the authentication request", Err: err}
+	}
+	var notBefore time.Time
+	if claims.NotBefore != nil {
+		notBefore = time.Unix(int64(*claims.NotBefore), 0)
+	}
+	if err := verifyTokenTimes(time.Now(), cfg.clockSkew, idToken.Expiry, notBefore, idToken.IssuedAt); err != nil {
+		return info.User{}, fmt.Errorf("could not verify token: %v", err)
+	}
+
+	return b.userInfoFromClaims(session, idToken)
+}
+
+// userInfoFromClaims returns the user info from the claims of the ID token, or of the user for providers which don't
+// support OIDC, and checks that the user is allowed by the broker configuration.
+func (b *Broker) userInfoFromClaims(session *session, claims info.Claimer) (info.User, error) {
+	cfg := b.config()
+	userInfo, err := b.provider.GetUserInfo(claims)
 	if err != nil {
 		return info.User{}, err
 	}
 
+	if err = b.checkEmailDomain(claims); err != nil {
+		return info.User{}, err
+	}
+
+	if err = b.checkHostedDomain(claims); err != nil {
+		return info.User{}, err
+	}
+
+	if err = b.checkOrgs(claims); err != nil {
+		return info.User{}, err
+	}
+
+	if cfg.parsedUsernameTemplate != nil {
+		username, err := usernameFromTemplate(cfg.parsedUsernameTemplate, claims)
+		if err != nil {
+			log.Errorf(context.Background(), "Could not derive the username from the ID token: %v", err)
+			return info.User{}, &providerErrors.ForDisplayError{Message: "Authentication failure: could not derive a valid username from the ID token"}
+		}
+		// The home directory defaults to the username if it was not provided by the claims.
+		if userInfo.Home == userInfo.Name {
+			userInfo.Home = username
+		}
+		userInfo.Name = username
+	}
+
 	if err = b.provider.VerifyUsername(session.username, userInfo.Name); err != nil {
 		return info.User{}, fmt.Errorf("username verification failed: %w", err)
 	}
 
 	// This means that home was not provided by the claims, so we need to set it to the broker default.
 	if !filepath.IsAbs(userInfo.Home) {
-		userInfo.Home = filepath.Join(b.cfg.homeBaseDir, userInfo.Home)
+		if cfg.parsedHomeDirTemplate == nil {
+			userInfo.Home = filepath.Join(cfg.homeBaseDir, userInfo.Home)
+		} else if userInfo.Home, err = b.homeDir(userInfo.Name); err != nil {
+			log.Errorf(context.Background(), "Could not derive the home directory: %v", err)
+			return info.User{}, &providerErrors.ForDisplayError{Message: "Authentication failure: could not derive a valid home directory"}
+		}
 	}
 
 	return userInfo, nil
 }
 
+// verifyTokenTimes checks that the ID token is not expired, and that its not-before and issued-at times are not in the
+// future. The clock skew is allowed in both directions. Zero values of notBefore and issuedAt are ignored.
+func verifyTokenTimes(now time.Time, clockSkew time.Duration, expiry, notBefore, issuedAt time.Time) error {
+	if expiry.Before(now.Add(-clockSkew)) {
+		return &oidc.TokenExpiredError{Expiry: expiry}
+	}
+	if !notBefore.IsZero() && notBefore.After(now.Add(clockSkew)) {
+		return fmt.Errorf("current time %v is before the nbf (not before) time %v", now, notBefore)
+	}
+	if !issuedAt.IsZero() && issuedAt.After(now.Add(clockSkew)) {
+		return fmt.Errorf("current time %v is before the iat (issued at) time %v", now, issuedAt)
+	}
+	return nil
+}
+
+// idTokenVerifier returns the verifier of the ID tokens of the provider, which uses the signing keys cached by the
+// broker instead of fetching them for each session.
+func (b *Broker) idTokenVerifier(cfg *settings, p *oidc.Provider) (*oidc.IDTokenVerifier, error) {
+	var metadata struct {
+		Issuer     string   `json:"issuer"`
+		JWKSURL    string   `json:"jwks_uri"`
+		Algorithms []string `json:"id_token_signing_alg_values_supported"`
+	}
+	if err := p.Claims(&metadata); err != nil {
+		return nil, fmt.Errorf("could not parse provider metadata: %v", err)
+	}
+	cfg.keySet.setURL(metadata.JWKSURL)
+
+	oidcCfg := cfg.oidcCfg
+	for _, alg := range metadata.Algorithms {
+		if slices.Contains(signingAlgorithms, jose.SignatureAlgorithm(alg)) {
+			oidcCfg.SupportedSigningAlgs = append(oidcCfg.SupportedSigningAlgs, alg)
+		}
+	}
+	return oidc.NewVerifier(metadata.Issuer, cfg.keySet, &oidcCfg), nil
+}
+
+// verifyAudience checks that the ID token was issued for the client, i.e. that the client ID is one of the audiences of
+// the token and that the authorized party, which must be set if there are several audiences, is the client.
+func verifyAudience(clientID string, audience []string, authorizedParty string) error {
+	if !slices.Contains(audience, clientID) {
+		return fmt.Errorf("%w: expected %q, got %q", errAudienceMismatch, clientID, audience)
+	}
+	if authorizedParty == "" && len(audience) > 1 {
+		return fmt.Errorf("%w: the token has several audiences but no azp (authorized party) claim", errAudienceMismatch)
+	}
+	if authorizedParty != "" && authorizedParty != clientID {
+		return fmt.Errorf("%w: expected azp (authorized party) %q, got %q", errAudienceMismatch, clientID, authorizedParty)
+	}
+	return nil
+}
+
+// verifyNonce checks that the nonce of the ID token is the one sent in the authentication request. An ID token without
+// nonce is only accepted if the nonce is not required. Nothing is checked if no nonce was sent.
+func verifyNonce(want, got string, required bool) error {
+	if want == "" {
+		return nil
+	}
+	if got == "" && !required {
+		return nil
+	}
+	if got != want {
+		return fmt.Errorf("%w: the nonce of the ID token does not match the one of the authentication request", errNonceMismatch)
+	}
+	return nil
+}
+
+// checkOrgs returns an error if allowed_orgs is set in the broker configuration and the user is not a member of any of
+// the allowed organizations according to the "orgs" claim.
+func (b *Broker) checkOrgs(claimer info.Claimer) error {
+	cfg := b.config()
+	if len(cfg.allowedOrgs) == 0 {
+		return nil
+	}
+
+	var claims struct {
+		Orgs []string `json:"orgs"`
+	}
+	if err := claimer.Claims(&claims); err != nil {
+		return fmt.Errorf("failed to get user claims: %v", err)
+	}
+
+	if !slices.ContainsFunc(claims.Orgs, cfg.orgIsAllowed) {
+		log.Warningf(context.Background(), "The user is not a member of any of the allowed organizations.\nYou can change allowed_orgs in %s", cfg.ConfigFile)
+		return &providerErrors.ForDisplayError{Message: "Authentication failure: user is not a member of an allowed organization"}
+	}
+
+	return nil
+}
+
+// checkEmailDomain returns an error if the domain of the email claim of the ID token is not allowed by the broker
+// configuration.
+func (b *Broker) checkEmailDomain(idToken info.Claimer) error {
+	cfg := b.config()
+	if len(cfg.allowedEmailDomains) == 0 {
+		return nil
+	}
+
+	var claims struct {
+		Email string `json:"email"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return fmt.Errorf("failed to get ID token claims: %v", err)
+	}
+
+	if claims.Email == "" {
+		log.Warningf(context.Background(), "The ID token does not contain an email claim, but allowed_email_domains is set in %s", cfg.ConfigFile)
+		return &providerErrors.ForDisplayError{Message: "Authentication failure: email claim is missing in the ID token"}
+	}
+
+	if !cfg.emailDomainIsAllowed(claims.Email) {
+		log.Warningf(context.Background(), "The email domain of %q is not in the list of allowed email domains.\nYou can add it to allowed_email_domains in %s", claims.Email, cfg.ConfigFile)
+		return &providerErrors.ForDisplayError{Message: "Authentication failure: email domain not allowed in broker configuration"}
+	}
+
+	return nil
+}
+
+// checkHostedDomain returns an error if allowed_hosted_domains is set in the broker configuration and the hd (hosted
+// domain) claim of the ID token is not one of them. Google only sets the hd claim for Google Workspace accounts, so
+// personal accounts are rejected too.
+func (b *Broker) checkHostedDomain(idToken info.Claimer) error {
+	cfg := b.config()
+	if len(cfg.allowedHostedDomains) == 0 {
+		return nil
+	}
+
+	var claims struct {
+		HostedDomain string `json:"hd"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return fmt.Errorf("failed to get ID token claims: %v", err)
+	}
+
+	if claims.HostedDomain == "" {
+		log.Warningf(context.Background(), "The ID token does not contain an hd claim, but allowed_hosted_domains is set in %s", cfg.ConfigFile)
+		return &providerErrors.ForDisplayError{Message: "Authentication failure: hosted domain claim is missing in the ID token"}
+	}
+
+	if !cfg.hostedDomainIsAllowed(claims.HostedDomain) {
+		log.Warningf(context.Background(), "The hosted domain %q is not in the list of allowed hosted domains.\nYou can add it to allowed_hosted_domains in %s", claims.HostedDomain, cfg.ConfigFile)
+		return &providerErrors.ForDisplayError{Message: "Authentication failure: hosted domain not allowed in broker configuration"}
+	}
+
+	return nil
+}
+
+// mappedGroups returns the local groups which the values of the groups claim of the ID token are mapped to by the
+// broker configuration. It returns nil if the claim is absent.
+func (b *Broker) mappedGroups(rawIDToken string) []string {
+	cfg := b.config()
+	if len(cfg.groupMapping) == 0 {
+		return nil
+	}
+
+	var groups []string
+	for _, value := range b.groupClaimValues(rawIDToken) {
+		for _, name := range cfg.groupMapping[value] {
+			if !slices.Contains(groups, name) {
+				groups = append(groups, name)
+			}
+		}
+	}
+	return groups
+}
+
+// groupClaimValues returns the values of the groups claim of the ID token. It returns nil if the claim is absent.
+// The ID token is not verified again, because it was already verified when it was obtained.
+func (b *Broker) groupClaimValues(rawIDToken string) []string {
+	claims := idTokenClaims(rawIDToken)
+	if claims == nil {
+		return nil
+	}
+
+	groupsClaim := b.config().groupsClaim
+	if groupsClaim == "" {
+		groupsClaim = defaultGroupsClaim
+	}
+	return claimValues(claims, groupsClaim)
+}
+
+// idTokenClaims returns the claims of the ID token, or nil if there is no ID token or it can't be parsed.
+// The ID token is not verified again, because it was already verified when it was obtained.
+func idTokenClaims(rawIDToken string) map[string]any {
+	// Providers which don't support OIDC don't have an ID token.
+	if rawIDToken == "" {
+		return nil
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(rawIDToken, claims); err != nil {
+		log.Warningf(context.Background(), "Could not parse the claims of the ID token: %v", err)
+		return nil
+	}
+	return claims
+}
+
+// claimValues returns the string values of the claim at the given dot-separated path (e.g. "realm_access.roles").
+// The claim can either be a string or an array of strings. It returns nil if the claim is absent.
+func claimValues(claims map[string]any, path string) []string {
+	var claim any = claims
+	for _, key := range strings.Split(path, ".") {
+		nested, ok := claim.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if claim, ok = nested[key]; !ok {
+			return nil
+		}
+	}
+
+	switch claim := claim.(type) {
+	case string:
+		return []string{claim}
+	case []any:
+		var values []string
+		for _, v := range claim {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
 func (b *Broker) getGroups(ctx context.Context, session *session, t *token.AuthCachedInfo) ([]info.Group, error) {
 	if session.isOffline {
 		return nil, errors.New("session is in offline mode")
 	}
 
-	return b.provider.GetGroups(ctx,
-		b.cfg.clientID,
-		b.cfg.issuerURL,
+	cfg := b.config()
+
+	return b.provider.GetGroups(b.withHTTPClient(ctx),
+		cfg.clientID,
+		cfg.issuerURL,
 		t.Token,
 		t.ProviderMetadata,
 		t.DeviceRegistrationData,
@@ -2,11 +2,18 @@ package broker_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,11 +22,13 @@ import (
 	"github.com/canonical/authd/authd-oidc-brokers/internal/broker/sessionmode"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/password"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils/golden"
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/authd/log"
+	"golang.org/x/oauth2"
 	"gopkg.in/yaml.v3"
 )
 
@@ -29,18 +38,32 @@ func TestNew(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		issuer   string
-		clientID string
-		dataDir  string
+		issuer           string
+		clientID         string
+		dataDir          string
+		usernameTemplate string
+		homeDirTemplate  string
+		proxy            string
+		caFile           string
+		authFlow         string
+		redirectURI      string
 
 		wantErr bool
 	}{
 		"Successfully_create_new_broker":                              {},
+		"Successfully_create_new_broker_with_auth_code_flow":          {authFlow: "auth_code", redirectURI: "http://localhost:8080/callback"},
 		"Successfully_create_new_even_if_can_not_connect_to_provider": {issuer: "https://notavailable"},
-
-		"Error_if_issuer_is_not_provided":   {issuer: "-", wantErr: true},
-		"Error_if_clientID_is_not_provided": {clientID: "-", wantErr: true},
-		"Error_if_dataDir_is_not_provided":  {dataDir: "-", wantErr: true},
+		"Successfully_create_new_broker_with_username_template":       {usernameTemplate: "{{ .Email | splitDomain }}"},
+		"Successfully_create_new_broker_with_home_dir_template":       {homeDirTemplate: "/home/{{ .Provider }}/{{ .Username }}"},
+
+		"Error_if_issuer_is_not_provided":       {issuer: "-", wantErr: true},
+		"Error_if_clientID_is_not_provided":     {clientID: "-", wantErr: true},
+		"Error_if_dataDir_is_not_provided":      {dataDir: "-", wantErr: true},
+		"Error_if_username_template_is_invalid": {usernameTemplate: "{{ .Email", wantErr: true},
+		"Error_if_home_dir_template_is_invalid": {homeDirTemplate: "{{ .Username", wantErr: true},
+		"Error_if_proxy_is_invalid":             {proxy: "ftp://proxy.example.com", wantErr: true},
+		"Error_if_CA_file_does_not_exist":       {caFile: "/does/not/exist.pem", wantErr: true},
+		"Error_if_auth_flow_has_no_auth_modes":  {authFlow: "auth_code", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -68,6 +91,12 @@ func TestNew(t *testing.T) {
 			bCfg := &broker.Config{DataDir: tc.dataDir}
 			bCfg.SetIssuerURL(tc.issuer)
 			bCfg.SetClientID(tc.clientID)
+			bCfg.SetUsernameTemplate(tc.usernameTemplate)
+			bCfg.SetHomeDirTemplate(tc.homeDirTemplate)
+			bCfg.SetProxyURL(tc.proxy)
+			bCfg.SetCAFile(tc.caFile)
+			bCfg.SetAuthFlow(tc.authFlow)
+			bCfg.SetRedirectURI(tc.redirectURI)
 			b, err := broker.New(*bCfg)
 			if tc.wantErr {
 				require.Error(t, err, "New should have returned an error")
@@ -79,15 +108,311 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestScopes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		extraScopes []string
+
+		wantScopes []string
+	}{
+		"Default_scopes_if_no_extra_scopes_are_configured": {wantScopes: providerScopes()},
+		"Extra_scopes_are_appended_to_default_scopes": {
+			extraScopes: []string{"groups", "offline_access"},
+			wantScopes:  providerScopes("groups", "offline_access"),
+		},
+		"Extra_scopes_already_in_default_scopes_are_ignored": {
+			extraScopes: []string{"email", "groups", "openid"},
+			wantScopes:  providerScopes("groups"),
+		},
+		"Duplicate_and_empty_extra_scopes_are_ignored": {
+			extraScopes: []string{"groups", "", " groups ", "offline_access"},
+			wantScopes:  providerScopes("groups", "offline_access"),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bCfg := &broker.Config{DataDir: t.TempDir()}
+			bCfg.SetIssuerURL(defaultIssuerURL)
+			bCfg.SetClientID("test-client-id")
+			bCfg.SetExtraScopes(tc.extraScopes)
+			b, err := broker.New(*bCfg)
+			require.NoError(t, err, "New should not have returned an error")
+
+			require.Equal(t, tc.wantScopes, b.Scopes(), "Scopes should be the default scopes merged with the extra scopes")
+		})
+	}
+}
+
+// providerScopes returns the default scopes of the provider the broker is built for, followed by the given extra scopes.
+func providerScopes(extra ...string) []string {
+	return slices.Concat(consts.DefaultScopes, providers.CurrentProvider().AdditionalScopes(), extra)
+}
+
+func TestNewWithAdditionalProvider(t *testing.T) {
+	t.Parallel()
+
+	const mainConfig = `[oidc]
+issuer = %[1]s
+client_id = test-client-id
+extra_scopes = groups
+`
+
+	tests := map[string]struct {
+		providerConfig string
+
+		wantScopes []string
+		wantErr    bool
+	}{
+		"Successfully_create_broker_of_additional_provider": {
+			providerConfig: "[provider.other]\nissuer = %[1]s\nclient_id = other-client-id\nextra_scopes = offline_access\n",
+			wantScopes:     providerScopes("offline_access"),
+		},
+		"Successfully_create_broker_of_additional_provider_with_type": {
+			providerConfig: "[provider.other]\ntype = oidc\nissuer = %[1]s\nclient_id = other-client-id\n",
+			wantScopes:     []string{"openid", "profile", "email"},
+		},
+
+		"Error_if_provider_has_no_section":   {providerConfig: "[provider.another]\nissuer = %[1]s\nclient_id = other-client-id\n", wantErr: true},
+		"Error_if_provider_type_is_unknown":  {providerConfig: "[provider.other]\ntype = unknown\nissuer = %[1]s\nclient_id = other-client-id\n", wantErr: true},
+		"Error_if_provider_has_no_client_ID": {providerConfig: "[provider.other]\nissuer = %[1]s\n", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bCfg := broker.Config{DataDir: t.TempDir(), ProviderID: "other"}
+			bCfg.ConfigFile = filepath.Join(t.TempDir(), "broker.conf")
+			err := os.WriteFile(bCfg.ConfigFile, []byte(fmt.Sprintf(mainConfig+tc.providerConfig, defaultIssuerURL)), 0600)
+			require.NoError(t, err, "Setup: Failed to write config file")
+
+			b, err := broker.New(bCfg)
+			if tc.wantErr {
+				require.Error(t, err, "New should have returned an error")
+				return
+			}
+			require.NoError(t, err, "New should not have returned an error")
+			require.Equal(t, tc.wantScopes, b.Scopes(), "Scopes should be the ones of the additional provider")
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	const initialConfig = `[oidc]
+issuer = %s
+client_id = test-client-id
+extra_scopes = groups
+`
+
+	tests := map[string]struct {
+		newConfig    string
+		removeConfig bool
+		noConfigFile bool
+
+		wantScopes []string
+		wantErr    bool
+	}{
+		"Successfully_reload_new_configuration": {
+			newConfig:  "[oidc]\nissuer = %s\nclient_id = test-client-id\nextra_scopes = offline_access\n",
+			wantScopes: providerScopes("offline_access"),
+		},
+
+		"Error_and_keep_current_configuration_if_new_one_is_invalid": {
+			newConfig:  "[oidc]\nissuer = %s\nclient_id = test-client-id\n[users]\nusername_template = {{ .Email\n",
+			wantScopes: providerScopes("groups"),
+			wantErr:    true,
+		},
+		"Error_and_keep_current_configuration_if_new_one_misses_required_keys": {
+			newConfig:  "[oidc]\nissuer = %s\n",
+			wantScopes: providerScopes("groups"),
+			wantErr:    true,
+		},
+		"Error_and_keep_current_configuration_if_config_file_was_removed": {
+			removeConfig: true,
+			wantScopes:   providerScopes("groups"),
+			wantErr:      true,
+		},
+		"Error_if_broker_was_started_without_config_file": {
+			noConfigFile: true,
+			wantScopes:   providerScopes(),
+			wantErr:      true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bCfg := broker.Config{DataDir: t.TempDir()}
+			if tc.noConfigFile {
+				bCfg.SetIssuerURL(defaultIssuerURL)
+				bCfg.SetClientID("test-client-id")
+			} else {
+				bCfg.ConfigFile = filepath.Join(t.TempDir(), "broker.conf")
+				err := os.WriteFile(bCfg.ConfigFile, []byte(fmt.Sprintf(initialConfig, defaultIssuerURL)), 0600)
+				require.NoError(t, err, "Setup: Failed to write config file")
+			}
+			b, err := broker.New(bCfg)
+			require.NoError(t, err, "Setup: New should not have returned an error")
+
+			if tc.newConfig != "" {
+				err = os.WriteFile(bCfg.ConfigFile, []byte(fmt.Sprintf(tc.newConfig, defaultIssuerURL)), 0600)
+				require.NoError(t, err, "Setup: Failed to update config file")
+			}
+			if tc.removeConfig {
+				require.NoError(t, os.Remove(bCfg.ConfigFile), "Setup: Failed to remove config file")
+			}
+
+			err = b.Reload()
+			if tc.wantErr {
+				require.Error(t, err, "Reload should have returned an error")
+			} else {
+				require.NoError(t, err, "Reload should not have returned an error")
+			}
+
+			require.Equal(t, tc.wantScopes, b.Scopes(), "Scopes should match the active configuration")
+		})
+	}
+}
+
+func TestVerifyTokenTimes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	skew := 2 * time.Minute
+
+	tests := map[string]struct {
+		clockSkew time.Duration
+		expiry    time.Time
+		notBefore time.Time
+		issuedAt  time.Time
+
+		wantErr bool
+	}{
+		"Valid_token":                              {expiry: now.Add(time.Hour), notBefore: now.Add(-time.Hour), issuedAt: now.Add(-time.Hour)},
+		"Valid_token_without_nbf_and_iat":          {expiry: now.Add(time.Hour)},
+		"Expired_token_within_clock_skew":          {clockSkew: skew, expiry: now.Add(-time.Minute)},
+		"Token_not_yet_valid_within_clock_skew":    {clockSkew: skew, expiry: now.Add(time.Hour), notBefore: now.Add(time.Minute)},
+		"Token_issued_in_future_within_clock_skew": {clockSkew: skew, expiry: now.Add(time.Hour), issuedAt: now.Add(time.Minute)},
+
+		"Error_if_token_is_expired":                      {expiry: now.Add(-time.Second), wantErr: true},
+		"Error_if_token_expired_beyond_clock_skew":       {clockSkew: skew, expiry: now.Add(-3 * time.Minute), wantErr: true},
+		"Error_if_token_not_yet_valid_beyond_clock_skew": {clockSkew: skew, expiry: now.Add(time.Hour), notBefore: now.Add(3 * time.Minute), wantErr: true},
+		"Error_if_token_issued_in_future_beyond_clock_skew": {
+			clockSkew: skew, expiry: now.Add(time.Hour), issuedAt: now.Add(3 * time.Minute), wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := broker.VerifyTokenTimes(now, tc.clockSkew, tc.expiry, tc.notBefore, tc.issuedAt)
+			if tc.wantErr {
+				require.Error(t, err, "VerifyTokenTimes should have returned an error")
+				return
+			}
+			require.NoError(t, err, "VerifyTokenTimes should not have returned an error")
+		})
+	}
+}
+
+func TestVerifyAudience(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		audience        []string
+		authorizedParty string
+
+		wantErr bool
+	}{
+		"Valid_audience":                              {audience: []string{"client-id"}},
+		"Valid_audience_and_authorized_party":         {audience: []string{"client-id"}, authorizedParty: "client-id"},
+		"Valid_audiences_with_authorized_party":       {audience: []string{"other-client-id", "client-id"}, authorizedParty: "client-id"},
+		"Error_if_client_is_not_an_audience":          {audience: []string{"other-client-id"}, wantErr: true},
+		"Error_if_audience_is_empty":                  {wantErr: true},
+		"Error_if_authorized_party_is_another_client": {audience: []string{"client-id"}, authorizedParty: "other-client-id", wantErr: true},
+		"Error_if_authorized_party_is_missing_with_several_audiences": {
+			audience: []string{"client-id", "other-client-id"}, wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := broker.VerifyAudience("client-id", tc.audience, tc.authorizedParty)
+			if tc.wantErr {
+				require.Error(t, err, "VerifyAudience should have returned an error")
+				return
+			}
+			require.NoError(t, err, "VerifyAudience should not have returned an error")
+		})
+	}
+}
+
+func TestVerifyNonce(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		want     string
+		got      string
+		required bool
+
+		wantErr bool
+	}{
+		"Valid_nonce":                            {want: "nonce", got: "nonce", required: true},
+		"Missing_nonce_if_not_required":          {want: "nonce"},
+		"No_nonce_sent":                          {got: "nonce", required: true},
+		"Error_if_nonce_does_not_match":          {want: "nonce", got: "other-nonce", wantErr: true},
+		"Error_if_required_nonce_is_missing":     {want: "nonce", required: true, wantErr: true},
+		"Error_if_required_nonce_does_not_match": {want: "nonce", got: "other-nonce", required: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := broker.VerifyNonce(tc.want, tc.got, tc.required)
+			if tc.wantErr {
+				require.Error(t, err, "VerifyNonce should have returned an error")
+				return
+			}
+			require.NoError(t, err, "VerifyNonce should not have returned an error")
+		})
+	}
+}
+
+func TestS256CodeChallenge(t *testing.T) {
+	t.Parallel()
+
+	// The example of RFC 7636, appendix B.
+	cfg := oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://example.com/authorize"}}
+	authURL, err := url.Parse(cfg.AuthCodeURL("state", oauth2.S256ChallengeOption("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")))
+	require.NoError(t, err, "Setup: the authorization URL should be valid")
+	require.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", authURL.Query().Get("code_challenge"), "The authorization URL should contain the challenge of the RFC example")
+	require.Equal(t, "S256", authURL.Query().Get("code_challenge_method"), "The authorization URL should use the S256 method")
+}
+
 func TestNewSession(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
 		customHandlers map[string]testutils.EndpointHandler
+		proxy          string
+		sessionMode    string
 
 		wantOffline bool
+		wantProxied bool
+		wantErr     bool
 	}{
-		"Successfully_create_new_session": {},
+		"Successfully_create_new_session":                          {},
+		"Successfully_create_new_session_through_configured_proxy": {proxy: "forwarding", wantProxied: true},
+		"Creates_new_session_in_offline_mode_if_proxy_is_not_available": {
+			proxy:       "unavailable",
+			wantOffline: true,
+			wantProxied: true,
+		},
 		"Creates_new_session_in_offline_mode_if_provider_is_not_available": {
 			customHandlers: map[string]testutils.EndpointHandler{
 				"/.well-known/openid-configuration": testutils.UnavailableHandler(),
@@ -96,26 +421,70 @@ func TestNewSession(t *testing.T) {
 		},
 		"Creates_new_session_in_offline_mode_if_provider_connection_times_out": {
 			customHandlers: map[string]testutils.EndpointHandler{
-				"/.well-known/openid-configuration": testutils.HangingHandler(broker.MaxRequestDuration + 1),
+				"/.well-known/openid-configuration": testutils.HangingHandler(testRequestTimeout + time.Second),
 			},
 			wantOffline: true,
 		},
+
+		"Error_if_provider_is_not_available_and_session_is_for_diagnostic": {
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/.well-known/openid-configuration": testutils.UnavailableHandler(),
+			},
+			sessionMode: sessionmode.Diagnostic,
+			wantErr:     true,
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			if tc.sessionMode == "" {
+				tc.sessionMode = sessionmode.Login
+			}
+
+			var proxyURL string
+			var proxied atomic.Bool
+			if tc.proxy != "" {
+				proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					proxied.Store(true)
+					if tc.proxy == "unavailable" {
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					r.RequestURI = ""
+					resp, err := http.DefaultTransport.RoundTrip(r)
+					if err != nil {
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					defer resp.Body.Close()
+					for k, v := range resp.Header {
+						w.Header()[k] = v
+					}
+					w.WriteHeader(resp.StatusCode)
+					_, _ = io.Copy(w, resp.Body)
+				}))
+				t.Cleanup(proxy.Close)
+				proxyURL = proxy.URL
+			}
+
 			b := newBrokerForTests(t, &brokerForTestConfig{
 				customHandlers: tc.customHandlers,
+				proxyURL:       proxyURL,
 			})
 
-			id, _, err := b.NewSession("test-user", "lang", sessionmode.Login)
+			id, _, err := b.NewSession("test-user", "lang", tc.sessionMode)
+			if tc.wantErr {
+				require.Error(t, err, "NewSession should have returned an error")
+				return
+			}
 			require.NoError(t, err, "NewSession should not have returned an error")
 
 			gotOffline, err := b.IsOffline(id)
 			require.NoError(t, err, "Session should have been created")
 
 			require.Equal(t, tc.wantOffline, gotOffline, "Session should have been created in the expected mode")
+			require.Equal(t, tc.wantProxied, proxied.Load(), "Requests should only be sent through the configured proxy")
 		})
 	}
 }
@@ -125,6 +494,10 @@ var supportedUILayouts = map[string]map[string]string{
 		"type":  "form",
 		"entry": "chars_password",
 	},
+	"form-with-chars": {
+		"type":  "form",
+		"entry": "optional:chars,chars_password",
+	},
 	"form-without-entry": {
 		"type": "form",
 	},
@@ -171,9 +544,13 @@ func TestGetAuthenticationModes(t *testing.T) {
 		deviceAuthUnsupported              bool
 		registerDevice                     bool
 		providerSupportsDeviceRegistration bool
+		tokenCacheTTL                      time.Duration
+		authFlow                           string
+		redirectURI                        string
 
-		wantErr   bool
-		wantModes []string
+		wantErr     bool
+		wantModes   []string
+		wantEvicted bool
 	}{
 		// === Authentication session ===
 		"Get_only_device_auth_qr_if_there_is_no_token": {
@@ -193,6 +570,44 @@ func TestGetAuthenticationModes(t *testing.T) {
 			noPasswordFile: true,
 			wantModes:      []string{authmodes.DeviceQr},
 		},
+		"Get_password_and_device_auth_qr_if_token_is_not_stale": {
+			token:         &tokenOptions{obtainedAt: time.Now().Add(-time.Hour)},
+			tokenCacheTTL: 2 * time.Hour,
+			wantModes:     []string{authmodes.Password, authmodes.DeviceQr},
+		},
+		"Get_password_and_device_auth_qr_if_it_is_unknown_when_token_was_obtained": {
+			token:         &tokenOptions{},
+			tokenCacheTTL: time.Hour,
+			wantModes:     []string{authmodes.Password, authmodes.DeviceQr},
+		},
+		"Get_device_auth_qr_and_auth_code_if_redirect_uri_is_set": {
+			supportedLayouts: []string{"form-with-chars", "qrcode", "newpassword"},
+			redirectURI:      "http://localhost:8080/callback",
+			wantModes:        []string{authmodes.DeviceQr, authmodes.AuthCode},
+		},
+		"Get_password_and_auth_code_if_token_exists_and_auth_flow_is_auth_code": {
+			supportedLayouts: []string{"form-with-chars", "qrcode", "newpassword"},
+			token:            &tokenOptions{},
+			authFlow:         "auth_code",
+			redirectURI:      "http://localhost:8080/callback",
+			wantModes:        []string{authmodes.Password, authmodes.AuthCode},
+		},
+		"Get_only_device_auth_qr_if_auth_flow_is_device": {
+			supportedLayouts: []string{"form-with-chars", "qrcode", "newpassword"},
+			authFlow:         "device",
+			redirectURI:      "http://localhost:8080/callback",
+			wantModes:        []string{authmodes.DeviceQr},
+		},
+		"Get_only_device_auth_qr_if_redirect_uri_is_not_set": {
+			supportedLayouts: []string{"form-with-chars", "qrcode", "newpassword"},
+			wantModes:        []string{authmodes.DeviceQr},
+		},
+		"Get_only_device_auth_qr_and_evict_token_if_token_is_stale": {
+			token:         &tokenOptions{obtainedAt: time.Now().Add(-2 * time.Hour)},
+			tokenCacheTTL: time.Hour,
+			wantModes:     []string{authmodes.DeviceQr},
+			wantEvicted:   true,
+		},
 
 		// --- Next auth mode ---
 		"Get_only_newpassword_if_next_auth_mode_is_newpassword": {
@@ -299,6 +714,13 @@ func TestGetAuthenticationModes(t *testing.T) {
 			wantModes:   []string{authmodes.Password},
 		},
 
+		// === Diagnostic session ===
+		"Get_only_device_auth_qr_if_token_exists_and_session_is_for_diagnostic": {
+			sessionMode: sessionmode.Diagnostic,
+			token:       &tokenOptions{},
+			wantModes:   []string{authmodes.DeviceQr},
+		},
+
 		// === Errors ===
 		// --- General errors ---
 		"Error_if_there_is_no_session": {
@@ -349,6 +771,9 @@ func TestGetAuthenticationModes(t *testing.T) {
 			cfg := &brokerForTestConfig{
 				registerDevice:             tc.registerDevice,
 				supportsDeviceRegistration: tc.providerSupportsDeviceRegistration,
+				tokenCacheTTL:              tc.tokenCacheTTL,
+				authFlow:                   tc.authFlow,
+				redirectURI:                tc.redirectURI,
 			}
 			if tc.providerAddress == "" {
 				// Use the default provider URL if no address is provided.
@@ -408,6 +833,11 @@ func TestGetAuthenticationModes(t *testing.T) {
 			}
 			require.Equal(t, tc.wantModes, modeIDs, "GetAuthenticationModes should have returned the expected modes")
 
+			if tc.token != nil {
+				_, err = os.Stat(b.TokenPathForSession(sessionID))
+				require.Equal(t, tc.wantEvicted, errors.Is(err, os.ErrNotExist), "Stale token should have been evicted")
+			}
+
 			golden.CheckOrUpdateYAML(t, modes)
 		})
 	}
@@ -462,14 +892,14 @@ func TestSelectAuthenticationMode(t *testing.T) {
 		},
 		"Error_when_selecting_device_auth_qr_but_request_times_out": {modeName: authmodes.DeviceQr, wantErr: true,
 			customHandlers: map[string]testutils.EndpointHandler{
-				"/device_auth": testutils.HangingHandler(broker.MaxRequestDuration + 1),
+				"/device_auth": testutils.HangingHandler(testRequestTimeout + time.Second),
 			},
 		},
 		"Error_when_selecting_device_auth_but_request_times_out": {
 			supportedLayouts: supportedLayoutsWithoutQrCode,
 			modeName:         authmodes.Device,
 			customHandlers: map[string]testutils.EndpointHandler{
-				"/device_auth": testutils.HangingHandler(broker.MaxRequestDuration + 1),
+				"/device_auth": testutils.HangingHandler(testRequestTimeout + time.Second),
 			},
 			wantErr: true,
 		},
@@ -521,6 +951,105 @@ func TestSelectAuthenticationMode(t *testing.T) {
 	}
 }
 
+func TestAuthCodeAuthentication(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input          string
+		customHandlers map[string]testutils.EndpointHandler
+		// nonce is the nonce of the ID token. If empty, it's the one of the authorization request, if "-", the ID token
+		// does not contain a nonce.
+		nonce string
+		// codeChallenge is the PKCE code challenge checked by the token endpoint. If empty, it's the one of the
+		// authorization request.
+		codeChallenge string
+
+		wantAccess string
+	}{
+		"Successfully_authenticate_with_code":         {input: "some-code", wantAccess: broker.AuthNext},
+		"Successfully_authenticate_with_redirect_url": {input: "http://localhost:8080/callback?code=some-code&state=<STATE>", wantAccess: broker.AuthNext},
+
+		"Retry_if_input_is_empty":                       {input: "  ", wantAccess: broker.AuthRetry},
+		"Retry_if_state_of_redirect_url_does_not_match": {input: "http://localhost:8080/callback?code=some-code&state=other", wantAccess: broker.AuthRetry},
+		"Retry_if_redirect_url_contains_an_error":       {input: "http://localhost:8080/callback?error=access_denied&state=<STATE>", wantAccess: broker.AuthRetry},
+		"Retry_if_code_can_not_be_exchanged_for_token": {
+			input: "some-code",
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.UnavailableHandler(),
+			},
+			wantAccess: broker.AuthRetry,
+		},
+		"Retry_if_code_exchange_times_out": {
+			input: "some-code",
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.HangingHandler(testRequestTimeout + time.Second),
+			},
+			wantAccess: broker.AuthRetry,
+		},
+		"Retry_if_code_verifier_does_not_match_code_challenge": {input: "some-code", codeChallenge: "other-challenge", wantAccess: broker.AuthRetry},
+
+		"Deny_if_nonce_does_not_match": {input: "some-code", nonce: "other-nonce", wantAccess: broker.AuthDenied},
+		"Deny_if_nonce_is_missing":     {input: "some-code", nonce: "-", wantAccess: broker.AuthDenied},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tokenOpts := &testutils.TokenHandlerOptions{}
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				redirectURI:         "http://localhost:8080/callback",
+				allUsersAllowed:     true,
+				customHandlers:      tc.customHandlers,
+				tokenHandlerOptions: tokenOpts,
+			})
+
+			sessionID, key := newSessionForTests(t, b, "", "")
+			layouts := []map[string]string{supportedUILayouts["form-with-chars"], supportedUILayouts["newpassword"]}
+			_, err := b.GetAuthenticationModes(sessionID, layouts)
+			require.NoError(t, err, "Setup: GetAuthenticationModes should not have returned an error")
+
+			layout, err := b.SelectAuthenticationMode(sessionID, authmodes.AuthCode)
+			require.NoError(t, err, "Setup: SelectAuthenticationMode should not have returned an error")
+			require.Equal(t, "chars", layout["entry"], "The authorization code should be entered in plain text")
+
+			// The label contains the authorization URL, e.g. "Open <URL> in a browser [...]".
+			rawURL, _, found := strings.Cut(strings.TrimPrefix(layout["label"], "Open "), " ")
+			require.True(t, found, "The label should contain the authorization URL")
+			authURL, err := url.Parse(rawURL)
+			require.NoError(t, err, "The authorization URL should be valid")
+			query := authURL.Query()
+			require.Equal(t, "/auth", authURL.Path, "The authorization URL should be the authorization endpoint")
+			require.Equal(t, "code", query.Get("response_type"), "The authorization URL should request a code")
+			require.Equal(t, "http://localhost:8080/callback", query.Get("redirect_uri"), "The authorization URL should contain the redirect URI")
+			require.NotEmpty(t, query.Get("state"), "The authorization URL should contain a state")
+			require.NotEmpty(t, query.Get("nonce"), "The authorization URL should contain a nonce")
+			require.NotEmpty(t, query.Get("code_challenge"), "The authorization URL should contain a PKCE code challenge")
+			require.Equal(t, "S256", query.Get("code_challenge_method"), "The PKCE code challenge should use the S256 method")
+
+			if tc.codeChallenge == "" {
+				tc.codeChallenge = query.Get("code_challenge")
+			}
+			tokenOpts.SetCodeChallenge(tc.codeChallenge)
+
+			switch tc.nonce {
+			case "":
+				tokenOpts.AddIDTokenClaims(map[string]interface{}{"nonce": query.Get("nonce")})
+			case "-":
+			default:
+				tokenOpts.AddIDTokenClaims(map[string]interface{}{"nonce": tc.nonce})
+			}
+
+			input := strings.ReplaceAll(tc.input, "<STATE>", url.QueryEscape(query.Get("state")))
+			authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, input, key))
+			access, data, err := b.IsAuthenticated(sessionID, authData)
+			require.NoError(t, err, "IsAuthenticated should not have returned an error")
+			require.Equal(t, tc.wantAccess, access, "IsAuthenticated should have returned the expected access")
+
+			golden.CheckOrUpdateYAML(t, isAuthenticatedResponse{Access: access, Data: data, Err: fmt.Sprint(err)})
+		})
+	}
+}
+
 type isAuthenticatedResponse struct {
 	Access string
 	Data   string
@@ -543,6 +1072,8 @@ func TestIsAuthenticated(t *testing.T) {
 		ownerExtraGroups                   []string
 		providerSupportsDeviceRegistration bool
 		registerDevice                     bool
+		offlineGracePeriod                 time.Duration
+		maxSessionLifetime                 time.Duration
 
 		firstMode                string
 		firstSecret              string
@@ -567,6 +1098,15 @@ func TestIsAuthenticated(t *testing.T) {
 	}{
 		"Successfully_authenticate_user_with_device_auth_and_newpassword": {firstSecret: "-", wantSecondCall: true},
 		"Successfully_authenticate_user_with_password":                    {firstMode: authmodes.Password, token: &tokenOptions{}},
+		"Successfully_authenticate_user_with_device_auth_in_diagnostic_session": {
+			sessionMode: sessionmode.Diagnostic,
+			firstSecret: "-",
+			// Use a fixed address, so that the issuer in the returned claims is stable.
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.TokenHandler("http://127.0.0.1:31320", nil),
+			},
+			address: "127.0.0.1:31320",
+		},
 
 		"Authenticating_with_qrcode_reacquires_token":          {firstSecret: "-", wantSecondCall: true, token: &tokenOptions{}},
 		"Authenticating_with_password_refreshes_expired_token": {firstMode: authmodes.Password, token: &tokenOptions{expired: true}},
@@ -720,7 +1260,7 @@ func TestIsAuthenticated(t *testing.T) {
 		"Error_when_token_is_expired_and_refreshing_token_fails": {firstMode: authmodes.Password, token: &tokenOptions{expired: true, noRefreshToken: true}},
 		"Error_when_mode_is_password_and_token_refresh_times_out": {firstMode: authmodes.Password, token: &tokenOptions{expired: true},
 			customHandlers: map[string]testutils.EndpointHandler{
-				"/token": testutils.HangingHandler(broker.MaxRequestDuration + 1),
+				"/token": testutils.HangingHandler(testRequestTimeout + time.Second),
 			},
 		},
 
@@ -734,9 +1274,33 @@ func TestIsAuthenticated(t *testing.T) {
 				"/token": testutils.UnavailableHandler(),
 			},
 		},
+		"Error_when_mode_is_qrcode_and_ID_token_is_for_another_client": {
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.TokenHandler("http://127.0.0.1:31317", &testutils.TokenHandlerOptions{
+					IDTokenClaims: []map[string]interface{}{{"aud": "other-client-id"}},
+				}),
+			},
+			address: "127.0.0.1:31317",
+		},
+		"Error_when_mode_is_qrcode_and_ID_token_is_for_another_authorized_party": {
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.TokenHandler("http://127.0.0.1:31318", &testutils.TokenHandlerOptions{
+					IDTokenClaims: []map[string]interface{}{{"azp": "other-client-id"}},
+				}),
+			},
+			address: "127.0.0.1:31318",
+		},
+		"Error_when_mode_is_qrcode_and_nonce_of_ID_token_does_not_match": {
+			customHandlers: map[string]testutils.EndpointHandler{
+				"/token": testutils.TokenHandler("http://127.0.0.1:31319", &testutils.TokenHandlerOptions{
+					IDTokenClaims: []map[string]interface{}{{"nonce": "other-nonce"}},
+				}),
+			},
+			address: "127.0.0.1:31319",
+		},
 		"Error_when_mode_is_qrcode_and_can_not_get_token_due_to_timeout": {
 			customHandlers: map[string]testutils.EndpointHandler{
-				"/token": testutils.HangingHandler(broker.MaxRequestDuration + 1),
+				"/token": testutils.HangingHandler(testRequestTimeout + time.Second),
 			},
 		},
 		"Error_when_mode_is_link_code_and_link_expires": {
@@ -753,7 +1317,7 @@ func TestIsAuthenticated(t *testing.T) {
 		"Error_when_mode_is_link_code_and_can_not_get_token_due_to_timeout": {
 			firstMode: authmodes.Device,
 			customHandlers: map[string]testutils.EndpointHandler{
-				"/token": testutils.HangingHandler(broker.MaxRequestDuration + 1),
+				"/token": testutils.HangingHandler(testRequestTimeout + time.Second),
 			},
 		},
 		"Error_when_empty_secret_is_provided_for_local_password":  {firstSecret: "-", wantSecondCall: true, secondSecret: "-"},
@@ -766,6 +1330,36 @@ func TestIsAuthenticated(t *testing.T) {
 			forceProviderAuthentication: true,
 			sessionOffline:              true,
 		},
+		"Authenticating_with_password_when_session_is_offline_and_within_grace_period": {
+			firstMode:          authmodes.Password,
+			token:              &tokenOptions{obtainedAt: time.Now().Add(-time.Hour)},
+			sessionOffline:     true,
+			offlineGracePeriod: 24 * time.Hour,
+		},
+		"Error_when_session_is_offline_and_grace_period_expired": {
+			firstMode:          authmodes.Password,
+			token:              &tokenOptions{obtainedAt: time.Now().Add(-48 * time.Hour)},
+			sessionOffline:     true,
+			offlineGracePeriod: 24 * time.Hour,
+		},
+		"Authenticating_with_password_when_session_lifetime_has_not_elapsed": {
+			firstMode:          authmodes.Password,
+			token:              &tokenOptions{loggedInAt: time.Now().Add(-time.Hour)},
+			maxSessionLifetime: 24 * time.Hour,
+		},
+		"Next_auth_modes_when_session_lifetime_has_elapsed": {
+			firstMode:          authmodes.Password,
+			token:              &tokenOptions{loggedInAt: time.Now().Add(-48 * time.Hour)},
+			maxSessionLifetime: 24 * time.Hour,
+			wantNextAuthModes:  []string{authmodes.Device, authmodes.DeviceQr},
+		},
+		"Error_when_session_lifetime_has_elapsed_and_session_is_offline_within_grace_period": {
+			firstMode:          authmodes.Password,
+			token:              &tokenOptions{obtainedAt: time.Now().Add(-time.Hour), loggedInAt: time.Now().Add(-48 * time.Hour)},
+			sessionOffline:     true,
+			offlineGracePeriod: 72 * time.Hour,
+			maxSessionLifetime: 24 * time.Hour,
+		},
 		"Error_when_user_is_disabled_and_session_is_offline": {
 			firstMode:      authmodes.Password,
 			token:          &tokenOptions{userIsDisabled: true},
@@ -809,6 +1403,8 @@ func TestIsAuthenticated(t *testing.T) {
 				ownerExtraGroups:            tc.ownerExtraGroups,
 				supportsDeviceRegistration:  tc.providerSupportsDeviceRegistration,
 				registerDevice:              tc.registerDevice,
+				offlineGracePeriod:          tc.offlineGracePeriod,
+				maxSessionLifetime:          tc.maxSessionLifetime,
 			}
 			if tc.customHandlers == nil {
 				// Use the default provider URL if no custom handlers are provided.
@@ -1144,11 +1740,14 @@ func TestIsAuthenticatedAllowedUsersConfig(t *testing.T) {
 
 	idTokenClaims := []map[string]interface{}{}
 	for _, uname := range allUsers {
-		idTokenClaims = append(idTokenClaims, map[string]interface{}{"sub": "user", "name": "user", "email": uname})
+		idTokenClaims = append(idTokenClaims, map[string]interface{}{
+			"sub": "user", "name": "user", "email": uname, "groups": []string{"claim-group-" + uname},
+		})
 	}
 
 	tests := map[string]struct {
 		allowedUsers          map[string]struct{}
+		allowedGroups         []string
 		owner                 string
 		ownerAllowed          bool
 		allUsersAllowed       bool
@@ -1204,6 +1803,30 @@ func TestIsAuthenticatedAllowedUsersConfig(t *testing.T) {
 			wantAllowedUsers:   []string{u3},
 			wantUnallowedUsers: []string{u1, u2},
 		},
+		"Users_in_allowed_provider_groups_are_allowed": {
+			allowedGroups:    []string{"remote-test-group"},
+			wantAllowedUsers: allUsers,
+		},
+		"Users_in_allowed_claim_groups_are_allowed": {
+			allowedGroups:      []string{"claim-group-" + u1, "claim-group-" + u3},
+			wantAllowedUsers:   []string{u1, u3},
+			wantUnallowedUsers: []string{u2},
+		},
+		"Allowed_groups_are_case_insensitive": {
+			allowedGroups:      []string{"CLAIM-GROUP-" + u1},
+			wantAllowedUsers:   []string{u1},
+			wantUnallowedUsers: []string{u2, u3},
+		},
+		"Specific_users_and_allowed_groups": {
+			allowedUsers:       map[string]struct{}{u1: {}},
+			allowedGroups:      []string{"claim-group-" + u2},
+			wantAllowedUsers:   []string{u1, u2},
+			wantUnallowedUsers: []string{u3},
+		},
+		"No_users_allowed_if_not_in_allowed_groups": {
+			allowedGroups:      []string{"other-group"},
+			wantUnallowedUsers: allUsers,
+		},
 	}
 
 	for name, tc := range tests {
@@ -1217,6 +1840,7 @@ func TestIsAuthenticatedAllowedUsersConfig(t *testing.T) {
 			b := newBrokerForTests(t, &brokerForTestConfig{
 				Config:                broker.Config{DataDir: dataDir},
 				allowedUsers:          tc.allowedUsers,
+				allowedGroups:         tc.allowedGroups,
 				owner:                 tc.owner,
 				ownerAllowed:          tc.ownerAllowed,
 				allUsersAllowed:       tc.allUsersAllowed,
@@ -1255,27 +1879,444 @@ func TestIsAuthenticatedAllowedUsersConfig(t *testing.T) {
 	}
 }
 
-func TestCancelIsAuthenticated(t *testing.T) {
+func TestIsAuthenticatedAllowedEmailDomainsConfig(t *testing.T) {
 	t.Parallel()
 
-	b := newBrokerForTests(t, &brokerForTestConfig{
-		customHandlers: map[string]testutils.EndpointHandler{
-			"/token": testutils.HangingHandler(3 * time.Second),
-		},
-	})
-	sessionID, _ := newSessionForTests(t, b, "", "")
-
-	updateAuthModes(t, b, sessionID, authmodes.DeviceQr)
+	u1 := "u1@example.com"
+	u2 := "u2@Sub.Example.COM"
+	u3 := "u3@other.org"
+	allUsers := []string{u1, u2, u3}
 
-	stopped := make(chan struct{})
-	go func() {
-		defer close(stopped)
-		_, _, err := b.IsAuthenticated(sessionID, `{}`)
-		require.Error(t, err, "IsAuthenticated should have returned an error")
-	}()
+	idTokenClaims := []map[string]interface{}{}
+	for _, uname := range allUsers {
+		idTokenClaims = append(idTokenClaims, map[string]interface{}{"sub": "user", "name": "user", "email": uname})
+	}
 
-	// Wait for the call to hang
-	time.Sleep(50 * time.Millisecond)
+	tests := map[string]struct {
+		allowedEmailDomains []string
+
+		wantAllowedUsers   []string
+		wantUnallowedUsers []string
+	}{
+		"All_domains_allowed_if_unset": {
+			wantAllowedUsers: allUsers,
+		},
+		"Only_exact_domain_allowed": {
+			allowedEmailDomains: []string{"example.com"},
+			wantAllowedUsers:    []string{u1},
+			wantUnallowedUsers:  []string{u2, u3},
+		},
+		"Domains_are_case_insensitive": {
+			allowedEmailDomains: []string{"sub.example.com"},
+			wantAllowedUsers:    []string{u2},
+			wantUnallowedUsers:  []string{u1, u3},
+		},
+		"Domain_with_leading_dot_allows_subdomains": {
+			allowedEmailDomains: []string{".example.com"},
+			wantAllowedUsers:    []string{u1, u2},
+			wantUnallowedUsers:  []string{u3},
+		},
+		"Multiple_domains_allowed": {
+			allowedEmailDomains: []string{"example.com", "other.org"},
+			wantAllowedUsers:    []string{u1, u3},
+			wantUnallowedUsers:  []string{u2},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			outDir := t.TempDir()
+			dataDir := filepath.Join(outDir, "data")
+			err := os.Mkdir(dataDir, 0700)
+			require.NoError(t, err, "Setup: Mkdir should not have returned an error")
+
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				Config:              broker.Config{DataDir: dataDir},
+				allUsersAllowed:     true,
+				allowedEmailDomains: tc.allowedEmailDomains,
+				tokenHandlerOptions: &testutils.TokenHandlerOptions{
+					IDTokenClaims: idTokenClaims,
+				},
+			})
+
+			for _, u := range allUsers {
+				sessionID, key := newSessionForTests(t, b, u, "")
+				token := tokenOptions{username: u}
+				generateAndStoreCachedInfo(t, token, b.TokenPathForSession(sessionID))
+				err = password.HashAndStorePassword("password", b.PasswordFilepathForSession(sessionID))
+				require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+
+				updateAuthModes(t, b, sessionID, authmodes.Password)
+
+				secret := encryptSecret(t, "password", key)
+				authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, secret)
+
+				access, data, err := b.IsAuthenticated(sessionID, authData)
+				require.True(t, json.Valid([]byte(data)), "IsAuthenticated returned data must be a valid JSON")
+				require.NoError(t, err)
+				if slices.Contains(tc.wantAllowedUsers, u) {
+					require.Equal(t, broker.AuthGranted, access, "authentication failed")
+					continue
+				}
+				if slices.Contains(tc.wantUnallowedUsers, u) {
+					require.Equal(t, broker.AuthDenied, access, "authentication should have been denied")
+					require.Contains(t, data, "email domain not allowed", "IsAuthenticated should return a clear error")
+					continue
+				}
+				t.Fatalf("user %s is not in the allowed or unallowed users list", u)
+			}
+		})
+	}
+}
+
+func TestIsAuthenticatedAllowedHostedDomainsConfig(t *testing.T) {
+	t.Parallel()
+
+	u1 := "u1@example.com"
+	u2 := "u2@example.org"
+	u3 := "u3@gmail.com"
+	allUsers := []string{u1, u2, u3}
+
+	// The hd claim is only set for Google Workspace accounts, so u3 has none.
+	hostedDomains := map[string]string{u1: "Example.COM", u2: "example.org"}
+	idTokenClaims := []map[string]interface{}{}
+	for _, uname := range allUsers {
+		claims := map[string]interface{}{"sub": "user", "name": "user", "email": uname}
+		if hd, ok := hostedDomains[uname]; ok {
+			claims["hd"] = hd
+		}
+		idTokenClaims = append(idTokenClaims, claims)
+	}
+
+	tests := map[string]struct {
+		allowedHostedDomains []string
+
+		wantAllowedUsers   []string
+		wantUnallowedUsers map[string]string
+	}{
+		"All_hosted_domains_allowed_if_unset": {
+			wantAllowedUsers: allUsers,
+		},
+		"Only_matching_hosted_domain_allowed": {
+			allowedHostedDomains: []string{"example.com"},
+			wantAllowedUsers:     []string{u1},
+			wantUnallowedUsers:   map[string]string{u2: "hosted domain not allowed", u3: "hosted domain claim is missing"},
+		},
+		"Multiple_hosted_domains_allowed": {
+			allowedHostedDomains: []string{"example.com", "example.org"},
+			wantAllowedUsers:     []string{u1, u2},
+			wantUnallowedUsers:   map[string]string{u3: "hosted domain claim is missing"},
+		},
+		"Subdomains_are_not_allowed": {
+			allowedHostedDomains: []string{"sub.example.org"},
+			wantUnallowedUsers:   map[string]string{u1: "hosted domain not allowed", u2: "hosted domain not allowed", u3: "hosted domain claim is missing"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			outDir := t.TempDir()
+			dataDir := filepath.Join(outDir, "data")
+			err := os.Mkdir(dataDir, 0700)
+			require.NoError(t, err, "Setup: Mkdir should not have returned an error")
+
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				Config:               broker.Config{DataDir: dataDir},
+				allUsersAllowed:      true,
+				allowedHostedDomains: tc.allowedHostedDomains,
+				tokenHandlerOptions: &testutils.TokenHandlerOptions{
+					IDTokenClaims: idTokenClaims,
+				},
+			})
+
+			for _, u := range allUsers {
+				sessionID, key := newSessionForTests(t, b, u, "")
+				token := tokenOptions{username: u}
+				generateAndStoreCachedInfo(t, token, b.TokenPathForSession(sessionID))
+				err = password.HashAndStorePassword("password", b.PasswordFilepathForSession(sessionID))
+				require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+
+				updateAuthModes(t, b, sessionID, authmodes.Password)
+
+				secret := encryptSecret(t, "password", key)
+				authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, secret)
+
+				access, data, err := b.IsAuthenticated(sessionID, authData)
+				require.True(t, json.Valid([]byte(data)), "IsAuthenticated returned data must be a valid JSON")
+				require.NoError(t, err)
+				if slices.Contains(tc.wantAllowedUsers, u) {
+					require.Equal(t, broker.AuthGranted, access, "authentication failed")
+					continue
+				}
+				if wantMsg, ok := tc.wantUnallowedUsers[u]; ok {
+					require.Equal(t, broker.AuthDenied, access, "authentication should have been denied")
+					require.Contains(t, data, wantMsg, "IsAuthenticated should return a clear error")
+					continue
+				}
+				t.Fatalf("user %s is not in the allowed or unallowed users list", u)
+			}
+		})
+	}
+}
+
+func TestIsAuthenticatedThrottling(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, &brokerForTestConfig{
+		allUsersAllowed:   true,
+		maxFailedAttempts: 2,
+		tokenHandlerOptions: &testutils.TokenHandlerOptions{
+			// Only the user who is not throttled refreshes the token.
+			IDTokenClaims: []map[string]interface{}{{"sub": "user", "name": "user", "email": "user2@example.com"}},
+		},
+	})
+
+	authenticate := func(username, secret string) (string, string) {
+		t.Helper()
+
+		sessionID, key := newSessionForTests(t, b, username, "")
+		generateAndStoreCachedInfo(t, tokenOptions{username: username}, b.TokenPathForSession(sessionID))
+		err := password.HashAndStorePassword("password", b.PasswordFilepathForSession(sessionID))
+		require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+		updateAuthModes(t, b, sessionID, authmodes.Password)
+
+		authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, encryptSecret(t, secret, key))
+		access, data, err := b.IsAuthenticated(sessionID, authData)
+		require.NoError(t, err, "IsAuthenticated should not have returned an error")
+		return access, data
+	}
+
+	access, _ := authenticate("user1@example.com", "wrong")
+	require.Equal(t, broker.AuthRetry, access, "First failed attempt should not be throttled")
+	access, _ = authenticate("user1@example.com", "wrong")
+	require.Equal(t, broker.AuthRetry, access, "Second failed attempt should not be throttled")
+
+	access, data := authenticate("user1@example.com", "password")
+	require.Equal(t, broker.AuthDenied, access, "Authentication should be rejected after too many failed attempts")
+	require.Contains(t, data, "Too many failed authentication attempts", "IsAuthenticated should return a clear error")
+
+	access, _ = authenticate("user2@example.com", "password")
+	require.Equal(t, broker.AuthGranted, access, "Other users should not be throttled")
+}
+
+func TestIsAuthenticatedAllowedOrgsConfig(t *testing.T) {
+	t.Parallel()
+
+	u1 := "u1"
+	u2 := "u2"
+	u3 := "u3"
+	allUsers := []string{u1, u2, u3}
+
+	userOrgs := map[string][]string{
+		u1: {"org-a"},
+		u2: {"Org-B", "org-c"},
+	}
+	idTokenClaims := []map[string]interface{}{}
+	for _, uname := range allUsers {
+		idTokenClaims = append(idTokenClaims, map[string]interface{}{"sub": "user", "name": "user", "email": uname, "orgs": userOrgs[uname]})
+	}
+
+	tests := map[string]struct {
+		allowedOrgs []string
+
+		wantAllowedUsers   []string
+		wantUnallowedUsers []string
+	}{
+		"All_users_allowed_if_unset": {
+			wantAllowedUsers: allUsers,
+		},
+		"Only_members_of_allowed_org_allowed": {
+			allowedOrgs:        []string{"org-a"},
+			wantAllowedUsers:   []string{u1},
+			wantUnallowedUsers: []string{u2, u3},
+		},
+		"Orgs_are_case_insensitive": {
+			allowedOrgs:        []string{"org-b"},
+			wantAllowedUsers:   []string{u2},
+			wantUnallowedUsers: []string{u1, u3},
+		},
+		"Multiple_orgs_allowed": {
+			allowedOrgs:        []string{"org-a", "org-c"},
+			wantAllowedUsers:   []string{u1, u2},
+			wantUnallowedUsers: []string{u3},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			outDir := t.TempDir()
+			dataDir := filepath.Join(outDir, "data")
+			err := os.Mkdir(dataDir, 0700)
+			require.NoError(t, err, "Setup: Mkdir should not have returned an error")
+
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				Config:          broker.Config{DataDir: dataDir},
+				allUsersAllowed: true,
+				allowedOrgs:     tc.allowedOrgs,
+				tokenHandlerOptions: &testutils.TokenHandlerOptions{
+					IDTokenClaims: idTokenClaims,
+				},
+			})
+
+			for _, u := range allUsers {
+				sessionID, key := newSessionForTests(t, b, u, "")
+				token := tokenOptions{username: u}
+				generateAndStoreCachedInfo(t, token, b.TokenPathForSession(sessionID))
+				err = password.HashAndStorePassword("password", b.PasswordFilepathForSession(sessionID))
+				require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+
+				updateAuthModes(t, b, sessionID, authmodes.Password)
+
+				secret := encryptSecret(t, "password", key)
+				authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, secret)
+
+				access, data, err := b.IsAuthenticated(sessionID, authData)
+				require.True(t, json.Valid([]byte(data)), "IsAuthenticated returned data must be a valid JSON")
+				require.NoError(t, err)
+				if slices.Contains(tc.wantAllowedUsers, u) {
+					require.Equal(t, broker.AuthGranted, access, "authentication failed")
+					continue
+				}
+				if slices.Contains(tc.wantUnallowedUsers, u) {
+					require.Equal(t, broker.AuthDenied, access, "authentication should have been denied")
+					require.Contains(t, data, "not a member of an allowed organization", "IsAuthenticated should return a clear error")
+					continue
+				}
+				t.Fatalf("user %s is not in the allowed or unallowed users list", u)
+			}
+		})
+	}
+}
+
+func TestIsAuthenticatedGroupMapping(t *testing.T) {
+	t.Parallel()
+
+	groupMapping := map[string][]string{
+		"group1": {"local-group1"},
+		"group2": {"local-group2", "local-group1"},
+	}
+	providerGroups := []string{"remote-test-group", "local-test-group"}
+
+	tests := map[string]struct {
+		groupsClaim  string
+		groupMapping map[string][]string
+		claims       map[string]interface{}
+
+		wantGroups []string
+	}{
+		"No_mapped_groups_if_mapping_is_not_set": {
+			claims:     map[string]interface{}{"groups": []string{"group1"}},
+			wantGroups: providerGroups,
+		},
+		"No_mapped_groups_if_claim_is_absent": {
+			groupMapping: groupMapping,
+			wantGroups:   providerGroups,
+		},
+		"No_mapped_groups_if_claim_values_are_not_mapped": {
+			groupMapping: groupMapping,
+			claims:       map[string]interface{}{"groups": []string{"unmapped-group"}},
+			wantGroups:   providerGroups,
+		},
+		"Map_groups_from_array_claim": {
+			groupMapping: groupMapping,
+			claims:       map[string]interface{}{"groups": []string{"group1", "unmapped-group", "group2"}},
+			wantGroups:   append(slices.Clone(providerGroups), "local-group1", "local-group2"),
+		},
+		"Map_groups_from_string_claim": {
+			groupMapping: groupMapping,
+			claims:       map[string]interface{}{"groups": "group2"},
+			wantGroups:   append(slices.Clone(providerGroups), "local-group2", "local-group1"),
+		},
+		"Map_groups_from_custom_claim": {
+			groupsClaim:  "roles",
+			groupMapping: groupMapping,
+			claims:       map[string]interface{}{"groups": []string{"group2"}, "roles": []string{"group1"}},
+			wantGroups:   append(slices.Clone(providerGroups), "local-group1"),
+		},
+		"Map_groups_from_nested_claim": {
+			groupsClaim:  "realm_access.roles",
+			groupMapping: groupMapping,
+			claims:       map[string]interface{}{"realm_access": map[string]interface{}{"roles": []string{"group1"}}},
+			wantGroups:   append(slices.Clone(providerGroups), "local-group1"),
+		},
+		"Mapped_groups_are_not_duplicated": {
+			groupMapping: map[string][]string{"group1": {"local-test-group", "local-group1"}},
+			claims:       map[string]interface{}{"groups": []string{"group1"}},
+			wantGroups:   append(slices.Clone(providerGroups), "local-group1"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			claims := map[string]interface{}{"sub": "user", "name": "user", "email": "user@example.com"}
+			for k, v := range tc.claims {
+				claims[k] = v
+			}
+
+			b := newBrokerForTests(t, &brokerForTestConfig{
+				allUsersAllowed: true,
+				groupsClaim:     tc.groupsClaim,
+				groupMapping:    tc.groupMapping,
+				tokenHandlerOptions: &testutils.TokenHandlerOptions{
+					IDTokenClaims: []map[string]interface{}{claims},
+				},
+			})
+
+			sessionID, key := newSessionForTests(t, b, "user@example.com", "")
+			generateAndStoreCachedInfo(t, tokenOptions{username: "user@example.com"}, b.TokenPathForSession(sessionID))
+			err := password.HashAndStorePassword("password", b.PasswordFilepathForSession(sessionID))
+			require.NoError(t, err, "Setup: HashAndStorePassword should not have returned an error")
+
+			updateAuthModes(t, b, sessionID, authmodes.Password)
+
+			secret := encryptSecret(t, "password", key)
+			authData := fmt.Sprintf(`{"%s":"%s"}`, broker.AuthDataSecret, secret)
+
+			access, data, err := b.IsAuthenticated(sessionID, authData)
+			require.NoError(t, err, "IsAuthenticated should not have returned an error")
+			require.Equal(t, broker.AuthGranted, access, "IsAuthenticated should have granted access")
+
+			var resp struct {
+				UserInfo info.User `json:"userinfo"`
+			}
+			err = json.Unmarshal([]byte(data), &resp)
+			require.NoError(t, err, "IsAuthenticated returned data must be valid JSON")
+
+			var gotGroups []string
+			for _, g := range resp.UserInfo.Groups {
+				gotGroups = append(gotGroups, g.Name)
+			}
+			require.Equal(t, tc.wantGroups, gotGroups, "User should be a member of the provider and mapped groups")
+		})
+	}
+}
+
+func TestCancelIsAuthenticated(t *testing.T) {
+	t.Parallel()
+
+	b := newBrokerForTests(t, &brokerForTestConfig{
+		customHandlers: map[string]testutils.EndpointHandler{
+			"/token": testutils.HangingHandler(3 * time.Second),
+		},
+	})
+	sessionID, _ := newSessionForTests(t, b, "", "")
+
+	updateAuthModes(t, b, sessionID, authmodes.DeviceQr)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		_, _, err := b.IsAuthenticated(sessionID, `{}`)
+		require.Error(t, err, "IsAuthenticated should have returned an error")
+	}()
+
+	// Wait for the call to hang
+	time.Sleep(50 * time.Millisecond)
 
 	b.CancelIsAuthenticated(sessionID)
 	<-stopped
@@ -1299,6 +2340,98 @@ func TestEndSession(t *testing.T) {
 	require.NoError(t, err, "EndSession should not have returned an error when ending an existent session")
 }
 
+func TestLogout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username             string
+		address              string
+		noToken              bool
+		token                tokenOptions
+		noRevocationEndpoint bool
+		revocationFails      bool
+		unavailableProvider  bool
+
+		wantRevokedToken string
+		wantErr          bool
+	}{
+		"Successfully_revoke_refresh_token": {wantRevokedToken: "refreshtoken"},
+		"Revoke_access_token_if_there_is_no_refresh_token": {
+			token:            tokenOptions{noRefreshToken: true},
+			wantRevokedToken: "accesstoken",
+		},
+		"Remove_token_if_provider_does_not_advertise_revocation_endpoint": {
+			address:              "127.0.0.1:31316",
+			noRevocationEndpoint: true,
+		},
+		"No_error_if_there_is_no_cached_token": {noToken: true},
+
+		"Error_when_username_is_invalid": {username: "../test-user@email.com", noToken: true, wantErr: true},
+		"Error_but_remove_token_when_revocation_fails": {
+			revocationFails: true,
+			wantErr:         true,
+		},
+		"Error_but_remove_token_when_provider_is_unreachable": {
+			unavailableProvider: true,
+			wantErr:             true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var revokedTokenMu sync.Mutex
+			var revokedToken string
+			revocationHandler := func(w http.ResponseWriter, r *http.Request) {
+				if tc.revocationFails {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				revokedTokenMu.Lock()
+				defer revokedTokenMu.Unlock()
+				revokedToken = r.PostFormValue("token")
+			}
+
+			cfg := &brokerForTestConfig{
+				listenAddress:  tc.address,
+				customHandlers: map[string]testutils.EndpointHandler{"/revoke": revocationHandler},
+			}
+			const wellKnown = "/.well-known/openid-configuration"
+			if tc.noRevocationEndpoint {
+				cfg.customHandlers[wellKnown] = testutils.OpenIDHandlerWithNoRevocationEndpoint("http://" + tc.address)
+			}
+			if tc.unavailableProvider {
+				cfg.customHandlers[wellKnown] = testutils.UnavailableHandler()
+			}
+			b := newBrokerForTests(t, cfg)
+
+			if tc.username == "" {
+				tc.username = "test-user@email.com"
+			}
+			sessionID, _ := newSessionForTests(t, b, tc.username, "")
+			tokenPath := b.TokenPathForSession(sessionID)
+			if !tc.noToken {
+				generateAndStoreCachedInfo(t, tc.token, tokenPath)
+			}
+
+			err := b.Logout(tc.username)
+			if tc.wantErr {
+				require.Error(t, err, "Logout should have returned an error")
+			} else {
+				require.NoError(t, err, "Logout should not have returned an error")
+			}
+
+			revokedTokenMu.Lock()
+			defer revokedTokenMu.Unlock()
+			require.Equal(t, tc.wantRevokedToken, revokedToken, "Logout should have revoked the expected token")
+
+			if !tc.noToken {
+				require.NoFileExists(t, tokenPath, "Logout should have removed the cached token")
+			}
+		})
+	}
+}
+
 func TestUserPreCheck(t *testing.T) {
 	t.Parallel()
 
@@ -1306,6 +2439,9 @@ func TestUserPreCheck(t *testing.T) {
 		username        string
 		allowedSuffixes []string
 		homePrefix      string
+		homeDirTemplate string
+
+		wantErr bool
 	}{
 		"Successfully_allow_username_with_matching_allowed_suffix": {
 			username:        "user@allowed",
@@ -1331,6 +2467,11 @@ func TestUserPreCheck(t *testing.T) {
 			allowedSuffixes: []string{"@allowed"},
 			homePrefix:      "/home/allowed/",
 		},
+		"Return_userinfo_with_homedir_from_template_after_precheck": {
+			username:        "user@allowed",
+			allowedSuffixes: []string{"@allowed"},
+			homeDirTemplate: "/home/{{ .Provider }}/{{ .Username }}",
+		},
 
 		"Empty_userinfo_if_username_does_not_match_allowed_suffix": {
 			username:        "user@notallowed",
@@ -1347,6 +2488,13 @@ func TestUserPreCheck(t *testing.T) {
 			username:        "user@allowed",
 			allowedSuffixes: []string{""},
 		},
+
+		"Error_if_homedir_template_evaluates_to_a_path_outside_the_home_base_dir": {
+			username:        "user@allowed",
+			allowedSuffixes: []string{"@allowed"},
+			homeDirTemplate: "/etc/{{ .Username }}",
+			wantErr:         true,
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -1355,12 +2503,19 @@ func TestUserPreCheck(t *testing.T) {
 			b := newBrokerForTests(t, &brokerForTestConfig{
 				issuerURL:          defaultIssuerURL,
 				homeBaseDir:        tc.homePrefix,
+				homeDirTemplate:    tc.homeDirTemplate,
 				allowedSSHSuffixes: tc.allowedSuffixes,
 			})
 
 			got, err := b.UserPreCheck(tc.username)
+			if tc.wantErr {
+				require.Error(t, err, "UserPreCheck should have returned an error")
+				return
+			}
 			require.NoError(t, err, "UserPreCheck should not have returned an error")
 
+			// The provider name in the home directory depends on the provider the broker is built for.
+			got = strings.ReplaceAll(got, "/home/"+consts.ProviderName+"/", "/home/{{PROVIDER}}/")
 			golden.CheckOrUpdate(t, got)
 		})
 	}
@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"context"
 	"embed"
 	"errors"
 	"fmt"
@@ -8,9 +9,15 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/ubuntu/authd/log"
 	"gopkg.in/ini.v1"
 )
 
@@ -29,20 +36,75 @@ const (
 	clientSecret = "client_secret"
 	// extraScopesKey is the key in the config file for extra OIDC scopes.
 	extraScopesKey = "extra_scopes"
+	// proxyKey is the key in the config file for the proxy used for the requests to the OIDC provider.
+	proxyKey = "proxy"
+	// caFileKey is the key in the config file for the CA bundle used to verify the certificates of the OIDC provider.
+	caFileKey = "ca_file"
+	// requestTimeoutKey is the key in the config file for the timeout of each request to the OIDC provider.
+	requestTimeoutKey = "request_timeout"
+	// defaultRequestTimeout is the timeout of each request to the OIDC provider if requestTimeoutKey is not set.
+	defaultRequestTimeout = 30 * time.Second
+	// authFlowKey is the key in the config file for the OAuth 2.0 flow used to authenticate with the provider.
+	authFlowKey = "auth_flow"
+	// authFlowDevice is the value of authFlowKey for the device authorization grant (RFC 8628).
+	authFlowDevice = "device"
+	// authFlowAuthCode is the value of authFlowKey for the authorization code grant.
+	authFlowAuthCode = "auth_code"
+	// redirectURIKey is the key in the config file for the redirect URI used in the authorization code flow.
+	redirectURIKey = "redirect_uri"
+	// maxFailedAttemptsKey is the key in the config file for the number of consecutive failed authentication attempts
+	// after which the user is throttled.
+	maxFailedAttemptsKey = "max_failed_attempts"
+	// failedAttemptsWindowKey is the key in the config file for the time after which failed authentication attempts
+	// are forgotten.
+	failedAttemptsWindowKey = "failed_attempts_window"
+	// tokenCacheTTLKey is the key in the config file for the time after which cached tokens are evicted.
+	tokenCacheTTLKey = "token_cache_ttl"
+	// metadataCacheTTLKey is the key in the config file for the time during which the discovery document and the
+	// signing keys of the OIDC provider are cached.
+	metadataCacheTTLKey = "metadata_cache_ttl"
+	// offlineGracePeriodKey is the key in the config file for the time during which the cached token can be used to
+	// log in while the OIDC provider is not reachable.
+	offlineGracePeriodKey = "offline_grace_period"
+	// maxSessionLifetimeKey is the key in the config file for the time after the last authentication with the OIDC
+	// provider after which users have to authenticate with it again, even if their token can be refreshed.
+	maxSessionLifetimeKey = "max_session_lifetime"
+	// clockSkewKey is the key in the config file for the allowed clock skew when validating ID tokens.
+	clockSkewKey = "allowed_clock_skew"
+	// defaultClockSkew is the allowed clock skew if clockSkewKey is not set.
+	defaultClockSkew = 2 * time.Minute
+	// maxClockSkew is the maximum allowed clock skew. Larger values are capped, because accepting tokens which expired
+	// long ago weakens security.
+	maxClockSkew = 10 * time.Minute
 
 	// entraIDSection is the section name in the config file for Microsoft Entra ID specific configuration.
 	entraIDSection = "msentraid"
 	// registerDeviceKey is the key in the config file for the setting that enables automatic device registration.
 	registerDeviceKey = "register_device"
 
+	// gitHubSection is the section name in the config file for GitHub specific configuration.
+	gitHubSection = "github"
+	// allowedOrgsKey is the key in the config file for the GitHub organizations whose members are allowed to log in.
+	allowedOrgsKey = "allowed_orgs"
+
+	// googleSection is the section name in the config file for Google specific configuration.
+	googleSection = "google"
+	// allowedHostedDomainsKey is the key in the config file for the Google Workspace domains whose users are allowed to
+	// log in, as set in the hd claim of the ID token.
+	allowedHostedDomainsKey = "allowed_hosted_domains"
+
 	// usersSection is the section name in the config file for the users and broker specific configuration.
 	usersSection = "users"
 	// allowedUsersKey is the key in the config file for the users that are allowed to access the machine.
 	allowedUsersKey = "allowed_users"
+	// allowedGroupsKey is the key in the config file for the groups whose members are allowed to access the machine.
+	allowedGroupsKey = "allowed_groups"
 	// ownerKey is the key in the config file for the owner of the machine.
 	ownerKey = "owner"
 	// homeDirKey is the key in the config file for the home directory prefix.
 	homeDirKey = "home_base_dir"
+	// homeDirTemplateKey is the key in the config file for the template from which the home directory is derived.
+	homeDirTemplateKey = "home_dir_template"
 	// sshSuffixesKey is the key in the config file for the SSH allowed suffixes.
 	sshSuffixesKey = "ssh_allowed_suffixes_first_auth"
 	// sshSuffixesKeyOld is the old key in the config file for the SSH allowed suffixes. It should be removed later.
@@ -51,6 +113,37 @@ const (
 	extraGroupsKey = "extra_groups"
 	// ownerExtraGroupsKey is the key in the config file for the extra groups to add to the owner.
 	ownerExtraGroupsKey = "owner_extra_groups"
+	// allowedEmailDomainsKey is the key in the config file for the email domains that are allowed to access the machine.
+	allowedEmailDomainsKey = "allowed_email_domains"
+	// usernameTemplateKey is the key in the config file for the template from which the local username is derived.
+	usernameTemplateKey = "username_template"
+	// groupsClaimKey is the key in the config file for the ID token claim which contains the groups of the user.
+	groupsClaimKey = "groups_claim"
+	// defaultGroupsClaim is the ID token claim which contains the groups of the user if groupsClaimKey is not set.
+	defaultGroupsClaim = "groups"
+
+	// providerSectionPrefix is the prefix of the section names in the config file for additional providers, which is
+	// followed by the ID of the provider (e.g. [provider.google]).
+	providerSectionPrefix = "provider."
+	// providerTypeKey is the key in the section of an additional provider for the name of its implementation.
+	providerTypeKey = "type"
+
+	// metricsSection is the section name in the config file for the metrics of the broker.
+	metricsSection = "metrics"
+	// metricsListenAddressKey is the key in the config file for the address on which the metrics are served.
+	metricsListenAddressKey = "listen_address"
+
+	// auditSection is the section name in the config file for the audit log of the broker.
+	auditSection = "audit"
+	// auditOutputKey is the key in the config file for the output of the audit events.
+	auditOutputKey = "output"
+	// AuditOutputLog is the output of the audit events which writes them to the log of the broker.
+	AuditOutputLog = "log"
+	// AuditOutputNone is the output of the audit events which disables them.
+	AuditOutputNone = "none"
+
+	// groupMappingSection is the section name in the config file for the mapping of group claim values to local groups.
+	groupMappingSection = "group_mapping"
 	// allUsersKeyword is the keyword for the `allowed_users` key that allows access to all users.
 	allUsersKeyword = "ALL"
 	// ownerUserKeyword is the keyword for the `allowed_users` key that allows access to the owner.
@@ -64,6 +157,17 @@ const (
 var (
 	//go:embed templates/20-owner-autoregistration.conf.tmpl
 	ownerAutoRegistrationConfig embed.FS
+
+	// providerIDRegexp matches the valid IDs of additional providers, which are used as element of D-Bus object paths.
+	providerIDRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+	// envVarNameRegexp matches the valid names of the environment variables which can be referenced in the config file.
+	envVarNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// interpolatedKeys are the keys of the [oidc] and additional provider sections whose values can reference
+	// environment variables with ${NAME}, e.g. to inject the client secret from a secret store. Only string settings
+	// are interpolated.
+	interpolatedKeys = []string{issuerKey, clientIDKey, clientSecret, proxyKey, caFileKey, redirectURIKey}
 )
 
 type provider interface {
@@ -78,20 +182,40 @@ type userConfig struct {
 	clientID     string
 	clientSecret string
 	issuerURL    string
+	proxyURL     string
+	caFile       string
+	authFlow     string
+	redirectURI  string
 
 	forceProviderAuthentication bool
 	registerDevice              bool
+	allowedOrgs                 []string
+	allowedHostedDomains        []string
+	requestTimeout              time.Duration
+	clockSkew                   time.Duration
+	tokenCacheTTL               time.Duration
+	metadataCacheTTL            time.Duration
+	offlineGracePeriod          time.Duration
+	maxSessionLifetime          time.Duration
+	maxFailedAttempts           int
+	failedAttemptsWindow        time.Duration
 
 	allowedUsers          map[string]struct{}
+	allowedGroups         []string
 	allUsersAllowed       bool
 	ownerAllowed          bool
 	firstUserBecomesOwner bool
 	owner                 string
 	ownerMutex            *sync.RWMutex
 	homeBaseDir           string
+	homeDirTemplate       string
 	allowedSSHSuffixes    []string
 	extraGroups           []string
 	ownerExtraGroups      []string
+	allowedEmailDomains   []string
+	usernameTemplate      string
+	groupsClaim           string
+	groupMapping          map[string][]string
 	extraScopes           []string
 
 	provider provider
@@ -142,6 +266,7 @@ func (uc *userConfig) populateUsersConfig(users *ini.Section) {
 	}
 
 	uc.homeBaseDir = users.Key(homeDirKey).String()
+	uc.homeDirTemplate = users.Key(homeDirTemplateKey).String()
 
 	suffixesKey := sshSuffixesKey
 	// If we don't have the new key, we should try reading the old one instead.
@@ -176,16 +301,147 @@ func (uc *userConfig) populateUsersConfig(users *ini.Section) {
 		uc.allowedUsers[uc.provider.NormalizeUsername(user)] = struct{}{}
 	}
 
+	for _, group := range users.Key(allowedGroupsKey).Strings(",") {
+		if group != "" {
+			uc.allowedGroups = append(uc.allowedGroups, group)
+		}
+	}
+
 	// We need to read the owner key after we call HasKey, because the key is created
 	// when we call the "Key" function and we can't distinguish between empty and unset.
 	uc.owner = uc.provider.NormalizeUsername(users.Key(ownerKey).String())
 
 	uc.extraGroups = users.Key(extraGroupsKey).Strings(",")
 	uc.ownerExtraGroups = users.Key(ownerExtraGroupsKey).Strings(",")
+
+	uc.usernameTemplate = users.Key(usernameTemplateKey).String()
+	uc.groupsClaim = users.Key(groupsClaimKey).String()
+
+	for _, domain := range users.Key(allowedEmailDomainsKey).Strings(",") {
+		if domain = normalizeDomain(domain); domain != "" && domain != "." {
+			uc.allowedEmailDomains = append(uc.allowedEmailDomains, domain)
+		}
+	}
+}
+
+func (uc *userConfig) populateGroupMappingConfig(mapping *ini.Section) {
+	if mapping == nil {
+		return
+	}
+
+	for _, key := range mapping.Keys() {
+		groups := key.Strings(",")
+		if len(groups) == 0 {
+			continue
+		}
+		if uc.groupMapping == nil {
+			uc.groupMapping = make(map[string][]string)
+		}
+		uc.groupMapping[key.Name()] = groups
+	}
+}
+
+// normalizeDomain returns the domain in lowercase and without the trailing dot of its fully qualified form.
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// loadConfigFile loads the config file and its drop-in files.
+func loadConfigFile(cfgPath string) (*ini.File, error) {
+	cfgFile, err := os.ReadFile(cfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open config file %q: %v", cfgPath, err)
+	}
+
+	dropInFiles, err := readDropInFiles(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return ini.Load(cfgFile, dropInFiles...)
+}
+
+// ProviderIDs returns the IDs of the additional providers configured in the config file, in the order of their
+// sections.
+func ProviderIDs(cfgPath string) (ids []string, err error) {
+	iniCfg, err := loadConfigFile(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, section := range iniCfg.Sections() {
+		id, ok := strings.CutPrefix(section.Name(), providerSectionPrefix)
+		if !ok {
+			continue
+		}
+		if !providerIDRegexp.MatchString(id) {
+			return nil, fmt.Errorf("invalid provider ID %q: only letters, digits and underscores are allowed", id)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// MetricsListenAddress returns the address on which the metrics of the brokers are served, as set in the config file,
+// or an empty string if the metrics are disabled.
+func MetricsListenAddress(cfgPath string) (string, error) {
+	iniCfg, err := loadConfigFile(cfgPath)
+	if err != nil {
+		return "", err
+	}
+
+	section, err := iniCfg.GetSection(metricsSection)
+	if err != nil {
+		return "", nil
+	}
+	return section.Key(metricsListenAddressKey).String(), nil
+}
+
+// AuditOutput returns the output of the audit events as set in the config file: either [AuditOutputLog],
+// [AuditOutputNone] or the absolute path of a file. It defaults to [AuditOutputLog].
+func AuditOutput(cfgPath string) (string, error) {
+	iniCfg, err := loadConfigFile(cfgPath)
+	if err != nil {
+		return "", err
+	}
+
+	section, err := iniCfg.GetSection(auditSection)
+	if err != nil {
+		return AuditOutputLog, nil
+	}
+
+	output := section.Key(auditOutputKey).String()
+	switch {
+	case output == "":
+		return AuditOutputLog, nil
+	case output == AuditOutputLog, output == AuditOutputNone:
+		return output, nil
+	case !filepath.IsAbs(output):
+		return "", fmt.Errorf("invalid audit output %q: must be %q, %q or an absolute path", output, AuditOutputLog, AuditOutputNone)
+	}
+	return output, nil
+}
+
+// providerFromConfig returns the implementation of the additional provider with the given ID, as set in its section
+// of the config file. It defaults to the provider of the broker.
+func providerFromConfig(cfgPath, providerID string) (providers.Provider, error) {
+	iniCfg, err := loadConfigFile(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	section, err := iniCfg.GetSection(providerSectionPrefix + providerID)
+	if err != nil {
+		return nil, fmt.Errorf("no section for provider %q", providerID)
+	}
+
+	return providers.ByName(section.Key(providerTypeKey).MustString(consts.ProviderName))
 }
 
 // parseConfigFromPath parses the config file and returns a map with the configuration keys and values.
-func parseConfigFromPath(cfgPath string, p provider) (userConfig, error) {
+// If providerID is not empty, the settings of the additional provider with this ID are used.
+func parseConfigFromPath(cfgPath, providerID string, p provider) (userConfig, error) {
 	cfgFile, err := os.ReadFile(cfgPath)
 	if err != nil {
 		return userConfig{}, fmt.Errorf("could not open config file %q: %v", cfgPath, err)
@@ -196,12 +452,13 @@ func parseConfigFromPath(cfgPath string, p provider) (userConfig, error) {
 		return userConfig{}, err
 	}
 
-	return parseConfig(cfgFile, dropInFiles, p)
+	return parseConfig(cfgFile, dropInFiles, providerID, p)
 }
 
 // parseConfig parses the config file and returns a userConfig struct with the configuration keys and values.
-// It also checks if the keys contain any placeholders and returns an error if they do.
-func parseConfig(cfgContent []byte, dropInContent []any, p provider) (userConfig, error) {
+// It also checks if the keys contain any placeholders and returns an error if they do, and replaces the references to
+// environment variables in the values of the interpolated keys.
+func parseConfig(cfgContent []byte, dropInContent []any, providerID string, p provider) (userConfig, error) {
 	cfg := userConfig{provider: p, ownerMutex: &sync.RWMutex{}}
 
 	iniCfg, err := ini.Load(cfgContent, dropInContent...)
@@ -221,12 +478,117 @@ func parseConfig(cfgContent []byte, dropInContent []any, p provider) (userConfig
 		return userConfig{}, fmt.Errorf("config file has invalid values, did you edit the config file?\n%w", err)
 	}
 
+	if err := interpolateEnv(iniCfg, os.LookupEnv); err != nil {
+		return userConfig{}, err
+	}
+
 	oidc := iniCfg.Section(oidcSection)
 	if oidc != nil {
 		cfg.issuerURL = oidc.Key(issuerKey).String()
 		cfg.clientID = oidc.Key(clientIDKey).String()
 		cfg.clientSecret = oidc.Key(clientSecret).String()
 		cfg.extraScopes = oidc.Key(extraScopesKey).Strings(",")
+		cfg.proxyURL = oidc.Key(proxyKey).String()
+		cfg.caFile = oidc.Key(caFileKey).String()
+		cfg.redirectURI = oidc.Key(redirectURIKey).String()
+
+		cfg.authFlow = oidc.Key(authFlowKey).String()
+		switch cfg.authFlow {
+		case "", authFlowDevice, authFlowAuthCode:
+		default:
+			return userConfig{}, fmt.Errorf("error parsing '%s': unsupported value %q, must be %q or %q", authFlowKey, cfg.authFlow, authFlowDevice, authFlowAuthCode)
+		}
+		if cfg.authFlow == authFlowAuthCode && cfg.redirectURI == "" {
+			return userConfig{}, fmt.Errorf("'%s' is required if '%s' is %q", redirectURIKey, authFlowKey, authFlowAuthCode)
+		}
+
+		cfg.requestTimeout = defaultRequestTimeout
+		if oidc.HasKey(requestTimeoutKey) {
+			cfg.requestTimeout, err = oidc.Key(requestTimeoutKey).Duration()
+			if err != nil {
+				return userConfig{}, fmt.Errorf("error parsing '%s': %w", requestTimeoutKey, err)
+			}
+			if cfg.requestTimeout <= 0 {
+				return userConfig{}, fmt.Errorf("error parsing '%s': must be positive", requestTimeoutKey)
+			}
+		}
+
+		cfg.clockSkew = defaultClockSkew
+		if oidc.HasKey(clockSkewKey) {
+			cfg.clockSkew, err = oidc.Key(clockSkewKey).Duration()
+			if err != nil {
+				return userConfig{}, fmt.Errorf("error parsing '%s': %w", clockSkewKey, err)
+			}
+			if cfg.clockSkew < 0 {
+				return userConfig{}, fmt.Errorf("error parsing '%s': clock skew must not be negative", clockSkewKey)
+			}
+			if cfg.clockSkew > maxClockSkew {
+				log.Warningf(context.Background(), "'%s' is larger than the maximum of %s, using the maximum instead", clockSkewKey, maxClockSkew)
+				cfg.clockSkew = maxClockSkew
+			}
+		}
+
+		if oidc.HasKey(tokenCacheTTLKey) {
+			cfg.tokenCacheTTL, err = oidc.Key(tokenCacheTTLKey).Duration()
+			if err != nil {
+				return userConfig{}, fmt.Errorf("error parsing '%s': %w", tokenCacheTTLKey, err)
+			}
+			if cfg.tokenCacheTTL < 0 {
+				return userConfig{}, fmt.Errorf("error parsing '%s': must not be negative", tokenCacheTTLKey)
+			}
+		}
+
+		if oidc.HasKey(metadataCacheTTLKey) {
+			cfg.metadataCacheTTL, err = oidc.Key(metadataCacheTTLKey).Duration()
+			if err != nil {
+				return userConfig{}, fmt.Errorf("error parsing '%s': %w", metadataCacheTTLKey, err)
+			}
+			if cfg.metadataCacheTTL < 0 {
+				return userConfig{}, fmt.Errorf("error parsing '%s': must not be negative", metadataCacheTTLKey)
+			}
+		}
+
+		if oidc.HasKey(offlineGracePeriodKey) {
+			cfg.offlineGracePeriod, err = oidc.Key(offlineGracePeriodKey).Duration()
+			if err != nil {
+				return userConfig{}, fmt.Errorf("error parsing '%s': %w", offlineGracePeriodKey, err)
+			}
+			if cfg.offlineGracePeriod < 0 {
+				return userConfig{}, fmt.Errorf("error parsing '%s': must not be negative", offlineGracePeriodKey)
+			}
+		}
+
+		if oidc.HasKey(maxSessionLifetimeKey) {
+			cfg.maxSessionLifetime, err = oidc.Key(maxSessionLifetimeKey).Duration()
+			if err != nil {
+				return userConfig{}, fmt.Errorf("error parsing '%s': %w", maxSessionLifetimeKey, err)
+			}
+			if cfg.maxSessionLifetime < 0 {
+				return userConfig{}, fmt.Errorf("error parsing '%s': must not be negative", maxSessionLifetimeKey)
+			}
+		}
+
+		cfg.maxFailedAttempts = defaultMaxFailedAttempts
+		if oidc.HasKey(maxFailedAttemptsKey) {
+			cfg.maxFailedAttempts, err = oidc.Key(maxFailedAttemptsKey).Int()
+			if err != nil {
+				return userConfig{}, fmt.Errorf("error parsing '%s': %w", maxFailedAttemptsKey, err)
+			}
+			if cfg.maxFailedAttempts < 0 {
+				return userConfig{}, fmt.Errorf("error parsing '%s': must not be negative", maxFailedAttemptsKey)
+			}
+		}
+
+		cfg.failedAttemptsWindow = defaultFailedAttemptsWindow
+		if oidc.HasKey(failedAttemptsWindowKey) {
+			cfg.failedAttemptsWindow, err = oidc.Key(failedAttemptsWindowKey).Duration()
+			if err != nil {
+				return userConfig{}, fmt.Errorf("error parsing '%s': %w", failedAttemptsWindowKey, err)
+			}
+			if cfg.failedAttemptsWindow <= 0 {
+				return userConfig{}, fmt.Errorf("error parsing '%s': must be positive", failedAttemptsWindowKey)
+			}
+		}
 
 		if oidc.HasKey(forceProviderAuthenticationKey) {
 			cfg.forceProviderAuthentication, err = oidc.Key(forceProviderAuthenticationKey).Bool()
@@ -236,6 +598,19 @@ func parseConfig(cfgContent []byte, dropInContent []any, p provider) (userConfig
 		}
 	}
 
+	// The settings of an additional provider which are specific to its client replace the ones of the [oidc] section.
+	// They are not inherited, so that e.g. the client secret of a provider is never sent to another one.
+	if providerID != "" {
+		section, err := iniCfg.GetSection(providerSectionPrefix + providerID)
+		if err != nil {
+			return userConfig{}, fmt.Errorf("no section for provider %q", providerID)
+		}
+		cfg.issuerURL = section.Key(issuerKey).String()
+		cfg.clientID = section.Key(clientIDKey).String()
+		cfg.clientSecret = section.Key(clientSecret).String()
+		cfg.extraScopes = section.Key(extraScopesKey).Strings(",")
+	}
+
 	entraID := iniCfg.Section(entraIDSection)
 	if entraID != nil && entraID.HasKey(registerDeviceKey) {
 		cfg.registerDevice, err = entraID.Key(registerDeviceKey).Bool()
@@ -244,11 +619,93 @@ func parseConfig(cfgContent []byte, dropInContent []any, p provider) (userConfig
 		}
 	}
 
+	gitHub := iniCfg.Section(gitHubSection)
+	if gitHub != nil {
+		for _, org := range gitHub.Key(allowedOrgsKey).Strings(",") {
+			if org != "" {
+				cfg.allowedOrgs = append(cfg.allowedOrgs, org)
+			}
+		}
+	}
+
+	google := iniCfg.Section(googleSection)
+	if google != nil {
+		for _, domain := range google.Key(allowedHostedDomainsKey).Strings(",") {
+			if domain = normalizeDomain(domain); domain != "" {
+				cfg.allowedHostedDomains = append(cfg.allowedHostedDomains, domain)
+			}
+		}
+	}
+
 	cfg.populateUsersConfig(iniCfg.Section(usersSection))
+	cfg.populateGroupMappingConfig(iniCfg.Section(groupMappingSection))
 
 	return cfg, nil
 }
 
+// interpolateEnv replaces the references to environment variables in the values of the interpolated keys of the
+// [oidc] and additional provider sections with the values returned by lookup.
+func interpolateEnv(iniCfg *ini.File, lookup func(string) (string, bool)) (err error) {
+	for _, section := range iniCfg.Sections() {
+		if section.Name() != oidcSection && !strings.HasPrefix(section.Name(), providerSectionPrefix) {
+			continue
+		}
+		for _, name := range interpolatedKeys {
+			if !section.HasKey(name) {
+				continue
+			}
+			key := section.Key(name)
+			value, expandErr := expandEnv(key.Value(), lookup)
+			if expandErr != nil {
+				err = errors.Join(err, fmt.Errorf("error parsing '%s' in section %q: %w", name, section.Name(), expandErr))
+				continue
+			}
+			key.SetValue(value)
+		}
+	}
+	return err
+}
+
+// expandEnv replaces the references ${NAME} in s with the value of the environment variable NAME as returned by
+// lookup, and $$ with a literal dollar sign. Any other dollar sign is kept as is. It returns an error if a referenced
+// variable is not set, so that e.g. an empty client secret is never used by mistake.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		s = s[i:]
+
+		switch {
+		case strings.HasPrefix(s, "$$"):
+			b.WriteByte('$')
+			s = s[2:]
+		case strings.HasPrefix(s, "${"):
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return "", errors.New("unterminated reference to an environment variable, use $$ for a literal dollar sign")
+			}
+			name := s[2:end]
+			if !envVarNameRegexp.MatchString(name) {
+				return "", fmt.Errorf("invalid environment variable name %q", name)
+			}
+			value, ok := lookup(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable %q is not set", name)
+			}
+			b.WriteString(value)
+			s = s[end+1:]
+		default:
+			b.WriteByte('$')
+			s = s[1:]
+		}
+	}
+}
+
 func (uc *userConfig) userNameIsAllowed(userName string) bool {
 	uc.ownerMutex.RLock()
 	defer uc.ownerMutex.RUnlock()
@@ -271,6 +728,64 @@ func (uc *userConfig) userNameIsAllowed(userName string) bool {
 	return uc.shouldRegisterOwner()
 }
 
+// groupIsAllowed checks whether the group is in the list of allowed groups. Group names are compared
+// case-insensitively.
+func (uc *userConfig) groupIsAllowed(group string) bool {
+	return slices.ContainsFunc(uc.allowedGroups, func(allowed string) bool {
+		return strings.EqualFold(allowed, group)
+	})
+}
+
+// orgIsAllowed checks whether the organization is in the list of allowed organizations. All organizations are allowed
+// if the list is empty. Organizations are compared case-insensitively, like GitHub logins.
+func (uc *userConfig) orgIsAllowed(org string) bool {
+	if len(uc.allowedOrgs) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(uc.allowedOrgs, func(allowed string) bool {
+		return strings.EqualFold(allowed, org)
+	})
+}
+
+// hostedDomainIsAllowed checks whether the hosted domain is in the list of allowed hosted domains. All hosted domains
+// are allowed if the list is empty. Domains are compared case-insensitively. Subdomains are not allowed, as the hd claim
+// is always the primary domain of the Google Workspace organization.
+func (uc *userConfig) hostedDomainIsAllowed(hd string) bool {
+	if len(uc.allowedHostedDomains) == 0 {
+		return true
+	}
+	return slices.Contains(uc.allowedHostedDomains, normalizeDomain(hd))
+}
+
+// emailDomainIsAllowed checks whether the domain of the email address is in the list of allowed email domains.
+// All domains are allowed if the list is empty. Domains are compared case-insensitively, and an allowed domain
+// starting with a dot (e.g. ".example.com") also allows all its subdomains.
+func (uc *userConfig) emailDomainIsAllowed(email string) bool {
+	if len(uc.allowedEmailDomains) == 0 {
+		return true
+	}
+
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	domain := normalizeDomain(email[i+1:])
+	if domain == "" {
+		return false
+	}
+
+	for _, allowed := range uc.allowedEmailDomains {
+		if domain == allowed {
+			return true
+		}
+		if strings.HasPrefix(allowed, ".") && (domain == allowed[1:] || strings.HasSuffix(domain, allowed)) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // shouldRegisterOwner returns true if the first user to log in should be registered as the owner.
 // Only call this with the ownerMutex locked.
 func (uc *userConfig) shouldRegisterOwner() bool {
@@ -10,6 +10,8 @@ import (
 	"testing"
 	"unsafe"
 
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/github"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils/golden"
 	"github.com/stretchr/testify/require"
@@ -28,10 +30,37 @@ issuer = https://issuer.url.com
 client_id = client_id
 force_provider_authentication = true
 extra_scopes = groups,offline_access, some_other_scope
+allowed_clock_skew = 30s
+token_cache_ttl = 2160h
+offline_grace_period = 168h
+max_session_lifetime = 720h
+proxy = http://proxy.example.com:3128
+ca_file = /etc/ssl/certs/internal-ca.pem
+auth_flow = auth_code
+redirect_uri = http://localhost:8080/callback
+max_failed_attempts = 3
+failed_attempts_window = 1h
+request_timeout = 10s
+metadata_cache_ttl = 1h
+
+[github]
+allowed_orgs = my-org, other-org
+
+[google]
+allowed_hosted_domains = Example.com., example.org
 
 [users]
 home_base_dir = /home
+home_dir_template = {{ .HomeBaseDir }}/{{ .Provider }}/{{ .Username }}
 allowed_ssh_suffixes = @issuer.url.com
+allowed_email_domains = Example.com., .Sub.Example.org
+allowed_groups = linux-admins, developers
+groups_claim = roles
+username_template = {{ .Email | splitDomain | lower }}
+
+[group_mapping]
+admins = sudo, lxd
+developers = docker
 `,
 
 	"invalid_boolean_value": `
@@ -39,6 +68,90 @@ allowed_ssh_suffixes = @issuer.url.com
 issuer = https://issuer.url.com
 client_id = client_id
 force_provider_authentication = invalid
+`,
+
+	"invalid_clock_skew": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+allowed_clock_skew = invalid
+`,
+
+	"negative_clock_skew": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+allowed_clock_skew = -1m
+`,
+
+	"invalid_auth_flow": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+auth_flow = invalid
+`,
+
+	"auth_code_flow_without_redirect_uri": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+auth_flow = auth_code
+`,
+
+	"negative_max_failed_attempts": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+max_failed_attempts = -1
+`,
+
+	"invalid_failed_attempts_window": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+failed_attempts_window = 0
+`,
+
+	"negative_token_cache_ttl": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+token_cache_ttl = -1h
+`,
+
+	"negative_metadata_cache_ttl": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+metadata_cache_ttl = -1h
+`,
+
+	"negative_offline_grace_period": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+offline_grace_period = -1h
+`,
+
+	"negative_max_session_lifetime": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+max_session_lifetime = -1h
+`,
+
+	"invalid_request_timeout": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+request_timeout = 0
+`,
+
+	"large_clock_skew": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+allowed_clock_skew = 1h
 `,
 
 	"singles": `
@@ -57,6 +170,20 @@ client_id = <CLIENT_ID>
 [oidc]
 issuer = https://lower-precedence-issuer.url.com
 client_id = lower_precedence_client_id
+`,
+
+	"additional_provider": `
+[oidc]
+issuer = https://issuer.url.com
+client_id = client_id
+client_secret = client_secret
+extra_scopes = offline_access
+request_timeout = 10s
+
+[provider.other]
+issuer = https://other-issuer.url.com
+client_id = other_client_id
+extra_scopes = groups
 `,
 
 	"overwrite_higher_precedence": `
@@ -73,21 +200,36 @@ func TestParseConfig(t *testing.T) {
 	tests := map[string]struct {
 		configType string
 		dropInType string
+		providerID string
 
 		wantErr bool
 	}{
 		"Successfully_parse_config_file":                      {},
 		"Successfully_parse_config_file_with_optional_values": {configType: "valid+optional"},
 		"Successfully_parse_config_with_drop_in_files":        {dropInType: "valid"},
+		"Successfully_parse_config_of_additional_provider":    {configType: "additional_provider", providerID: "other"},
 
 		"Do_not_fail_if_values_contain_a_single_template_delimiter": {configType: "singles"},
-
-		"Error_if_file_does_not_exist":             {configType: "inexistent", wantErr: true},
-		"Error_if_file_is_unreadable":              {configType: "unreadable", wantErr: true},
-		"Error_if_file_is_not_updated":             {configType: "template", wantErr: true},
-		"Error_if_drop_in_directory_is_unreadable": {dropInType: "unreadable-dir", wantErr: true},
-		"Error_if_drop_in_file_is_unreadable":      {dropInType: "unreadable-file", wantErr: true},
-		"Error_if_config_contains_invalid_values":  {configType: "invalid_boolean_value", wantErr: true},
+		"Cap_clock_skew_to_maximum":                                 {configType: "large_clock_skew"},
+
+		"Error_if_file_does_not_exist":                {configType: "inexistent", wantErr: true},
+		"Error_if_file_is_unreadable":                 {configType: "unreadable", wantErr: true},
+		"Error_if_file_is_not_updated":                {configType: "template", wantErr: true},
+		"Error_if_drop_in_directory_is_unreadable":    {dropInType: "unreadable-dir", wantErr: true},
+		"Error_if_drop_in_file_is_unreadable":         {dropInType: "unreadable-file", wantErr: true},
+		"Error_if_config_contains_invalid_values":     {configType: "invalid_boolean_value", wantErr: true},
+		"Error_if_clock_skew_is_invalid":              {configType: "invalid_clock_skew", wantErr: true},
+		"Error_if_clock_skew_is_negative":             {configType: "negative_clock_skew", wantErr: true},
+		"Error_if_max_failed_attempts_is_negative":    {configType: "negative_max_failed_attempts", wantErr: true},
+		"Error_if_failed_attempts_window_is_invalid":  {configType: "invalid_failed_attempts_window", wantErr: true},
+		"Error_if_request_timeout_is_invalid":         {configType: "invalid_request_timeout", wantErr: true},
+		"Error_if_token_cache_ttl_is_negative":        {configType: "negative_token_cache_ttl", wantErr: true},
+		"Error_if_metadata_cache_ttl_is_negative":     {configType: "negative_metadata_cache_ttl", wantErr: true},
+		"Error_if_offline_grace_period_is_negative":   {configType: "negative_offline_grace_period", wantErr: true},
+		"Error_if_max_session_lifetime_is_negative":   {configType: "negative_max_session_lifetime", wantErr: true},
+		"Error_if_auth_flow_is_invalid":               {configType: "invalid_auth_flow", wantErr: true},
+		"Error_if_auth_code_flow_has_no_redirect_uri": {configType: "auth_code_flow_without_redirect_uri", wantErr: true},
+		"Error_if_additional_provider_has_no_section": {configType: "additional_provider", providerID: "missing", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -135,7 +277,7 @@ func TestParseConfig(t *testing.T) {
 				require.NoError(t, err, "Setup: Failed to make drop-in file unreadable")
 			}
 
-			cfg, err := parseConfigFromPath(confPath, p)
+			cfg, err := parseConfigFromPath(confPath, tc.providerID, p)
 			if tc.wantErr {
 				require.Error(t, err)
 				return
@@ -308,7 +450,7 @@ func TestParseUserConfig(t *testing.T) {
 			err = os.Mkdir(dropInDir, 0700)
 			require.NoError(t, err, "Setup: Failed to create drop-in directory")
 
-			cfg, err := parseConfigFromPath(confPath, p)
+			cfg, err := parseConfigFromPath(confPath, "", p)
 
 			// convert the allowed users array to a map
 			allowedUsersMap := map[string]struct{}{}
@@ -328,6 +470,223 @@ func TestParseUserConfig(t *testing.T) {
 	}
 }
 
+func TestProviderIDs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config string
+
+		wantIDs []string
+		wantErr bool
+	}{
+		"No_additional_providers": {config: configTypes["valid"]},
+		"Additional_providers_in_the_order_of_their_sections": {
+			config:  configTypes["valid"] + "\n[provider.second]\nissuer = https://second.url.com\n[provider.first_1]\n",
+			wantIDs: []string{"second", "first_1"},
+		},
+
+		"Error_if_provider_ID_is_invalid": {config: "[provider.in/valid]\n", wantErr: true},
+		"Error_if_provider_ID_is_empty":   {config: "[provider.]\n", wantErr: true},
+		"Error_if_file_does_not_exist":    {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			confPath := filepath.Join(t.TempDir(), "broker.conf")
+			if tc.config != "" {
+				err := os.WriteFile(confPath, []byte(tc.config), 0600)
+				require.NoError(t, err, "Setup: Failed to write config file")
+			}
+
+			ids, err := ProviderIDs(confPath)
+			if tc.wantErr {
+				require.Error(t, err, "ProviderIDs should return an error")
+				return
+			}
+			require.NoError(t, err, "ProviderIDs should not return an error")
+			require.Equal(t, tc.wantIDs, ids, "ProviderIDs should return the IDs of the additional providers")
+		})
+	}
+}
+
+func TestMetricsListenAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config string
+
+		want    string
+		wantErr bool
+	}{
+		"Empty_if_metrics_section_is_absent":    {config: configTypes["valid"]},
+		"Empty_if_listen_address_is_not_set":    {config: configTypes["valid"] + "\n[metrics]\n"},
+		"Listen_address_of_the_metrics_section": {config: configTypes["valid"] + "\n[metrics]\nlisten_address = :9100\n", want: ":9100"},
+
+		"Error_if_file_does_not_exist": {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			confPath := filepath.Join(t.TempDir(), "broker.conf")
+			if tc.config != "" {
+				err := os.WriteFile(confPath, []byte(tc.config), 0600)
+				require.NoError(t, err, "Setup: Failed to write config file")
+			}
+
+			got, err := MetricsListenAddress(confPath)
+			if tc.wantErr {
+				require.Error(t, err, "MetricsListenAddress should return an error")
+				return
+			}
+			require.NoError(t, err, "MetricsListenAddress should not return an error")
+			require.Equal(t, tc.want, got, "MetricsListenAddress should return the address of the config file")
+		})
+	}
+}
+
+func TestParseConfigInterpolatesEnvironmentVariables(t *testing.T) {
+	t.Setenv("AUTHD_TEST_ISSUER", "https://issuer.url.com")
+	t.Setenv("AUTHD_TEST_CLIENT_SECRET", "s3cr3t")
+	t.Setenv("AUTHD_TEST_EMPTY", "")
+
+	tests := map[string]struct {
+		config string
+
+		wantIssuer       string
+		wantClientSecret string
+		wantHomeBaseDir  string
+		wantErr          bool
+	}{
+		"Interpolate_string_settings": {
+			config:           "[oidc]\nissuer = ${AUTHD_TEST_ISSUER}\nclient_id = client_id\nclient_secret = ${AUTHD_TEST_CLIENT_SECRET}\n",
+			wantIssuer:       "https://issuer.url.com",
+			wantClientSecret: "s3cr3t",
+		},
+		"Interpolate_settings_of_additional_provider": {
+			config:           "[oidc]\nissuer = https://other.url.com\nclient_id = client_id\n[provider.other]\nissuer = ${AUTHD_TEST_ISSUER}\nclient_id = client_id\nclient_secret = ${AUTHD_TEST_CLIENT_SECRET}\n",
+			wantIssuer:       "https://issuer.url.com",
+			wantClientSecret: "s3cr3t",
+		},
+		"Interpolate_variable_set_to_empty_value": {
+			config:     "[oidc]\nissuer = https://issuer.url.com\nclient_id = client_id\nclient_secret = ${AUTHD_TEST_EMPTY}\n",
+			wantIssuer: "https://issuer.url.com",
+		},
+		"Escaped_dollar_signs_are_literal": {
+			config:           "[oidc]\nissuer = https://issuer.url.com\nclient_id = client_id\nclient_secret = $${AUTHD_TEST_CLIENT_SECRET}$$a$b\n",
+			wantIssuer:       "https://issuer.url.com",
+			wantClientSecret: "${AUTHD_TEST_CLIENT_SECRET}$a$b",
+		},
+		"Do_not_interpolate_other_settings": {
+			config:          "[oidc]\nissuer = https://issuer.url.com\nclient_id = client_id\n[users]\nhome_base_dir = /home/${AUTHD_TEST_CLIENT_SECRET}\n",
+			wantIssuer:      "https://issuer.url.com",
+			wantHomeBaseDir: "/home/${AUTHD_TEST_CLIENT_SECRET}",
+		},
+
+		"Error_if_variable_is_not_set":            {config: "[oidc]\nissuer = https://issuer.url.com\nclient_id = client_id\nclient_secret = ${AUTHD_TEST_UNSET}\n", wantErr: true},
+		"Error_if_variable_reference_is_unclosed": {config: "[oidc]\nissuer = https://issuer.url.com\nclient_id = client_id\nclient_secret = ${AUTHD_TEST_CLIENT_SECRET\n", wantErr: true},
+		"Error_if_variable_name_is_invalid":       {config: "[oidc]\nissuer = https://issuer.url.com\nclient_id = client_id\nclient_secret = ${1INVALID}\n", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			confPath := filepath.Join(t.TempDir(), "broker.conf")
+			err := os.WriteFile(confPath, []byte(tc.config), 0600)
+			require.NoError(t, err, "Setup: Failed to write config file")
+
+			var providerID string
+			if strings.Contains(tc.config, "[provider.other]") {
+				providerID = "other"
+			}
+
+			cfg, err := parseConfigFromPath(confPath, providerID, &testutils.MockProvider{})
+			if tc.wantErr {
+				require.Error(t, err, "parseConfigFromPath should return an error")
+				require.NotContains(t, err.Error(), "s3cr3t", "The error should not contain the values of the environment variables")
+				return
+			}
+			require.NoError(t, err, "parseConfigFromPath should not return an error")
+
+			require.Equal(t, tc.wantIssuer, cfg.issuerURL, "Issuer should be interpolated")
+			require.Equal(t, tc.wantClientSecret, cfg.clientSecret, "Client secret should be interpolated")
+			require.Equal(t, tc.wantHomeBaseDir, cfg.homeBaseDir, "Home base directory should not be interpolated")
+		})
+	}
+}
+
+func TestAuditOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config string
+
+		want    string
+		wantErr bool
+	}{
+		"Log_if_audit_section_is_absent": {config: configTypes["valid"], want: AuditOutputLog},
+		"Log_if_output_is_not_set":       {config: configTypes["valid"] + "\n[audit]\n", want: AuditOutputLog},
+		"Log_if_output_is_log":           {config: configTypes["valid"] + "\n[audit]\noutput = log\n", want: AuditOutputLog},
+		"None_if_output_is_none":         {config: configTypes["valid"] + "\n[audit]\noutput = none\n", want: AuditOutputNone},
+		"File_if_output_is_a_path":       {config: configTypes["valid"] + "\n[audit]\noutput = /var/log/audit.log\n", want: "/var/log/audit.log"},
+
+		"Error_if_output_is_a_relative_path": {config: configTypes["valid"] + "\n[audit]\noutput = audit.log\n", wantErr: true},
+		"Error_if_file_does_not_exist":       {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			confPath := filepath.Join(t.TempDir(), "broker.conf")
+			if tc.config != "" {
+				err := os.WriteFile(confPath, []byte(tc.config), 0600)
+				require.NoError(t, err, "Setup: Failed to write config file")
+			}
+
+			got, err := AuditOutput(confPath)
+			if tc.wantErr {
+				require.Error(t, err, "AuditOutput should return an error")
+				return
+			}
+			require.NoError(t, err, "AuditOutput should not return an error")
+			require.Equal(t, tc.want, got, "AuditOutput should return the output of the config file")
+		})
+	}
+}
+
+func TestProviderFromConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		section string
+
+		wantType reflect.Type
+		wantErr  bool
+	}{
+		"Default_to_the_provider_of_the_broker": {section: "[provider.other]\n", wantType: reflect.TypeOf(providers.CurrentProvider())},
+		"Use_the_provider_of_the_type":          {section: "[provider.other]\ntype = github\n", wantType: reflect.TypeOf(github.New())},
+
+		"Error_if_type_is_unknown":   {section: "[provider.other]\ntype = unknown\n", wantErr: true},
+		"Error_if_section_is_absent": {section: "[provider.another]\n", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			confPath := filepath.Join(t.TempDir(), "broker.conf")
+			err := os.WriteFile(confPath, []byte(configTypes["valid"]+tc.section), 0600)
+			require.NoError(t, err, "Setup: Failed to write config file")
+
+			p, err := providerFromConfig(confPath, "other")
+			if tc.wantErr {
+				require.Error(t, err, "providerFromConfig should return an error")
+				return
+			}
+			require.NoError(t, err, "providerFromConfig should not return an error")
+			require.Equal(t, tc.wantType, reflect.TypeOf(p), "providerFromConfig should return the provider of the type")
+		})
+	}
+}
+
 func TestRegisterOwner(t *testing.T) {
 	p := &testutils.MockProvider{}
 	outDir := t.TempDir()
@@ -358,6 +717,6 @@ func TestRegisterOwner(t *testing.T) {
 func FuzzParseConfig(f *testing.F) {
 	p := &testutils.MockProvider{}
 	f.Fuzz(func(t *testing.T, a []byte) {
-		_, _ = parseConfig(a, nil, p)
+		_, _ = parseConfig(a, nil, "", p)
 	})
 }
@@ -2,6 +2,7 @@ package broker
 
 import (
 	"sync"
+	"time"
 )
 
 func (cfg *Config) Init() {
@@ -16,6 +17,46 @@ func (cfg *Config) SetIssuerURL(issuerURL string) {
 	cfg.issuerURL = issuerURL
 }
 
+func (cfg *Config) SetProxyURL(proxyURL string) {
+	cfg.proxyURL = proxyURL
+}
+
+func (cfg *Config) SetCAFile(caFile string) {
+	cfg.caFile = caFile
+}
+
+func (cfg *Config) SetAuthFlow(authFlow string) {
+	cfg.authFlow = authFlow
+}
+
+func (cfg *Config) SetRedirectURI(redirectURI string) {
+	cfg.redirectURI = redirectURI
+}
+
+func (cfg *Config) SetMaxFailedAttempts(maxFailedAttempts int) {
+	cfg.maxFailedAttempts = maxFailedAttempts
+}
+
+func (cfg *Config) SetFailedAttemptsWindow(window time.Duration) {
+	cfg.failedAttemptsWindow = window
+}
+
+func (cfg *Config) SetRequestTimeout(timeout time.Duration) {
+	cfg.requestTimeout = timeout
+}
+
+func (cfg *Config) SetTokenCacheTTL(ttl time.Duration) {
+	cfg.tokenCacheTTL = ttl
+}
+
+func (cfg *Config) SetOfflineGracePeriod(period time.Duration) {
+	cfg.offlineGracePeriod = period
+}
+
+func (cfg *Config) SetMaxSessionLifetime(lifetime time.Duration) {
+	cfg.maxSessionLifetime = lifetime
+}
+
 func (cfg *Config) SetForceProviderAuthentication(value bool) {
 	cfg.forceProviderAuthentication = value
 }
@@ -32,6 +73,10 @@ func (cfg *Config) SetAllowedUsers(allowedUsers map[string]struct{}) {
 	cfg.allowedUsers = allowedUsers
 }
 
+func (cfg *Config) SetAllowedGroups(allowedGroups []string) {
+	cfg.allowedGroups = allowedGroups
+}
+
 func (cfg *Config) SetOwner(owner string) {
 	cfg.ownerMutex.Lock()
 	defer cfg.ownerMutex.Unlock()
@@ -69,6 +114,38 @@ func (cfg *Config) SetAllowedSSHSuffixes(allowedSSHSuffixes []string) {
 	cfg.allowedSSHSuffixes = allowedSSHSuffixes
 }
 
+func (cfg *Config) SetAllowedOrgs(allowedOrgs []string) {
+	cfg.allowedOrgs = allowedOrgs
+}
+
+func (cfg *Config) SetAllowedHostedDomains(allowedHostedDomains []string) {
+	cfg.allowedHostedDomains = allowedHostedDomains
+}
+
+func (cfg *Config) SetAllowedEmailDomains(allowedEmailDomains []string) {
+	cfg.allowedEmailDomains = allowedEmailDomains
+}
+
+func (cfg *Config) SetUsernameTemplate(usernameTemplate string) {
+	cfg.usernameTemplate = usernameTemplate
+}
+
+func (cfg *Config) SetHomeDirTemplate(homeDirTemplate string) {
+	cfg.homeDirTemplate = homeDirTemplate
+}
+
+func (cfg *Config) SetGroupsClaim(groupsClaim string) {
+	cfg.groupsClaim = groupsClaim
+}
+
+func (cfg *Config) SetGroupMapping(groupMapping map[string][]string) {
+	cfg.groupMapping = groupMapping
+}
+
+func (cfg *Config) SetExtraScopes(extraScopes []string) {
+	cfg.extraScopes = extraScopes
+}
+
 func (cfg *Config) SetProvider(provider provider) {
 	cfg.provider = provider
 }
@@ -120,9 +197,14 @@ func (b *Broker) UserDataDirForSession(sessionID string) string {
 	return session.userDataDir
 }
 
+// Scopes returns the OIDC scopes requested by the broker.
+func (b *Broker) Scopes() []string {
+	return b.config().scopes
+}
+
 // DataDir returns the path to the data directory for tests.
 func (b *Broker) DataDir() string {
-	return b.cfg.DataDir
+	return b.config().DataDir
 }
 
 // GetNextAuthModes returns the next auth mode of the specified session.
@@ -170,5 +252,11 @@ func (b *Broker) IsOffline(sessionID string) (bool, error) {
 	return session.isOffline, nil
 }
 
-// MaxRequestDuration exposes the broker's maxRequestDuration for tests.
-const MaxRequestDuration = maxRequestDuration
+// VerifyTokenTimes exposes verifyTokenTimes for tests.
+var VerifyTokenTimes = verifyTokenTimes
+
+// VerifyAudience exposes verifyAudience for tests.
+var VerifyAudience = verifyAudience
+
+// VerifyNonce exposes verifyNonce for tests.
+var VerifyNonce = verifyNonce
@@ -22,12 +22,25 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// testRequestTimeout is the timeout of the requests to the provider in tests, which is shorter than the default one to
+// keep the tests fast.
+const testRequestTimeout = 5 * time.Second
+
 type brokerForTestConfig struct {
 	broker.Config
 	issuerURL                   string
+	proxyURL                    string
+	authFlow                    string
+	redirectURI                 string
+	tokenCacheTTL               time.Duration
+	offlineGracePeriod          time.Duration
+	maxSessionLifetime          time.Duration
+	maxFailedAttempts           int
+	failedAttemptsWindow        time.Duration
 	forceProviderAuthentication bool
 	registerDevice              bool
 	allowedUsers                map[string]struct{}
+	allowedGroups               []string
 	allUsersAllowed             bool
 	ownerAllowed                bool
 	firstUserBecomesOwner       bool
@@ -35,7 +48,13 @@ type brokerForTestConfig struct {
 	extraGroups                 []string
 	ownerExtraGroups            []string
 	homeBaseDir                 string
+	homeDirTemplate             string
 	allowedSSHSuffixes          []string
+	allowedEmailDomains         []string
+	allowedOrgs                 []string
+	allowedHostedDomains        []string
+	groupsClaim                 string
+	groupMapping                map[string][]string
 	provider                    providers.Provider
 
 	getGroupsFails             bool
@@ -54,9 +73,34 @@ func newBrokerForTests(t *testing.T, cfg *brokerForTestConfig) (b *broker.Broker
 	t.Helper()
 
 	cfg.Init()
+	cfg.SetRequestTimeout(testRequestTimeout)
 	if cfg.issuerURL != "" {
 		cfg.SetIssuerURL(cfg.issuerURL)
 	}
+	if cfg.proxyURL != "" {
+		cfg.SetProxyURL(cfg.proxyURL)
+	}
+	if cfg.authFlow != "" {
+		cfg.SetAuthFlow(cfg.authFlow)
+	}
+	if cfg.redirectURI != "" {
+		cfg.SetRedirectURI(cfg.redirectURI)
+	}
+	if cfg.tokenCacheTTL != 0 {
+		cfg.SetTokenCacheTTL(cfg.tokenCacheTTL)
+	}
+	if cfg.offlineGracePeriod != 0 {
+		cfg.SetOfflineGracePeriod(cfg.offlineGracePeriod)
+	}
+	if cfg.maxSessionLifetime != 0 {
+		cfg.SetMaxSessionLifetime(cfg.maxSessionLifetime)
+	}
+	if cfg.maxFailedAttempts != 0 {
+		cfg.SetMaxFailedAttempts(cfg.maxFailedAttempts)
+	}
+	if cfg.failedAttemptsWindow != 0 {
+		cfg.SetFailedAttemptsWindow(cfg.failedAttemptsWindow)
+	}
 	if cfg.forceProviderAuthentication {
 		cfg.SetForceProviderAuthentication(cfg.forceProviderAuthentication)
 	}
@@ -66,12 +110,33 @@ func newBrokerForTests(t *testing.T, cfg *brokerForTestConfig) (b *broker.Broker
 	if cfg.homeBaseDir != "" {
 		cfg.SetHomeBaseDir(cfg.homeBaseDir)
 	}
+	if cfg.homeDirTemplate != "" {
+		cfg.SetHomeDirTemplate(cfg.homeDirTemplate)
+	}
 	if cfg.allowedSSHSuffixes != nil {
 		cfg.SetAllowedSSHSuffixes(cfg.allowedSSHSuffixes)
 	}
 	if cfg.allowedUsers != nil {
 		cfg.SetAllowedUsers(cfg.allowedUsers)
 	}
+	if cfg.allowedGroups != nil {
+		cfg.SetAllowedGroups(cfg.allowedGroups)
+	}
+	if cfg.allowedEmailDomains != nil {
+		cfg.SetAllowedEmailDomains(cfg.allowedEmailDomains)
+	}
+	if cfg.allowedOrgs != nil {
+		cfg.SetAllowedOrgs(cfg.allowedOrgs)
+	}
+	if cfg.allowedHostedDomains != nil {
+		cfg.SetAllowedHostedDomains(cfg.allowedHostedDomains)
+	}
+	if cfg.groupsClaim != "" {
+		cfg.SetGroupsClaim(cfg.groupsClaim)
+	}
+	if cfg.groupMapping != nil {
+		cfg.SetGroupMapping(cfg.groupMapping)
+	}
 	if cfg.owner != "" {
 		cfg.SetOwner(cfg.owner)
 	}
@@ -206,6 +271,8 @@ type tokenOptions struct {
 	noIsForDeviceRegistration bool
 	deviceIsDisabled          bool
 	userIsDisabled            bool
+	obtainedAt                time.Time
+	loggedInAt                time.Time
 }
 
 func generateCachedInfo(t *testing.T, options tokenOptions) *token.AuthCachedInfo {
@@ -243,6 +310,8 @@ func generateCachedInfo(t *testing.T, options tokenOptions) *token.AuthCachedInf
 		},
 		DeviceIsDisabled: options.deviceIsDisabled,
 		UserIsDisabled:   options.userIsDisabled,
+		ObtainedAt:       options.obtainedAt,
+		LoggedInAt:       options.loggedInAt,
 	}
 
 	if options.expired {
@@ -0,0 +1,82 @@
+package broker
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
+)
+
+// homeDirTemplateData is the data against which the home directory template is evaluated.
+type homeDirTemplateData struct {
+	// Username is the resolved local username.
+	Username string
+	// Provider is the name of the provider, e.g. "msentraid".
+	Provider string
+	// IssuerHost is the host of the issuer URL, e.g. "login.microsoftonline.com".
+	IssuerHost string
+	// IssuerPath is the path of the issuer URL without the leading slash, which for some providers contains the
+	// tenant, e.g. "<tenant-id>/v2.0".
+	IssuerPath string
+	// HomeBaseDir is the value of the home_base_dir option.
+	HomeBaseDir string
+}
+
+// parseHomeDirTemplate parses the home directory template. It returns nil if the template is empty.
+func parseHomeDirTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	t, err := template.New("home").Funcs(usernameTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", homeDirTemplateKey, err)
+	}
+	return t, nil
+}
+
+// homeDir returns the home directory of the user, which is either derived from the home directory template or
+// <home_base_dir>/<username>.
+func (b *Broker) homeDir(username string) (string, error) {
+	cfg := b.config()
+	if cfg.parsedHomeDirTemplate == nil {
+		return filepath.Join(cfg.homeBaseDir, username), nil
+	}
+	return homeDirFromTemplate(cfg.parsedHomeDirTemplate, username, cfg.issuerURL, cfg.homeBaseDir)
+}
+
+// homeDirFromTemplate evaluates the home directory template for the user. It returns an error if the result is not
+// an absolute path strictly below the home base directory.
+func homeDirFromTemplate(t *template.Template, username, issuerURL, homeBaseDir string) (string, error) {
+	base := filepath.Clean(homeBaseDir)
+	data := homeDirTemplateData{
+		Username:    username,
+		Provider:    consts.ProviderName,
+		HomeBaseDir: base,
+	}
+	if u, err := url.Parse(issuerURL); err == nil {
+		data.IssuerHost = u.Hostname()
+		data.IssuerPath = strings.Trim(u.Path, "/")
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("could not evaluate %s: %v", homeDirTemplateKey, err)
+	}
+
+	home := strings.TrimSpace(sb.String())
+	if !filepath.IsAbs(home) {
+		return "", fmt.Errorf("%s evaluated to %q, which is not an absolute path", homeDirTemplateKey, home)
+	}
+	// Reject paths with ".." or redundant separators instead of cleaning them, to prevent path traversal.
+	if filepath.Clean(home) != home {
+		return "", fmt.Errorf("%s evaluated to %q, which is not a clean path", homeDirTemplateKey, home)
+	}
+	if rel, err := filepath.Rel(base, home); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s evaluated to %q, which is not below %s %q", homeDirTemplateKey, home, homeDirKey, base)
+	}
+	return home, nil
+}
@@ -0,0 +1,66 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHomeDirFromTemplate(t *testing.T) {
+	t.Parallel()
+
+	const issuerURL = "https://login.example.com/tenant-id/v2.0"
+
+	tests := map[string]struct {
+		template    string
+		username    string
+		homeBaseDir string
+
+		wantHome string
+		wantErr  bool
+	}{
+		"Home_from_base_dir_and_username": {template: "{{ .HomeBaseDir }}/{{ .Username }}", wantHome: "/home/user"},
+		"Home_with_provider_name":         {template: "/home/{{ .Provider }}/{{ .Username }}", wantHome: "/home/" + consts.ProviderName + "/user"},
+		"Home_with_issuer_host":           {template: "/home/{{ .IssuerHost }}/{{ .Username }}", wantHome: "/home/login.example.com/user"},
+		"Home_with_issuer_path":           {template: "/home/{{ .IssuerPath }}/{{ .Username }}", wantHome: "/home/tenant-id/v2.0/user"},
+		"Home_with_custom_base_dir": {
+			template:    "{{ .HomeBaseDir }}/{{ .Username }}",
+			homeBaseDir: "/srv/homes/",
+			wantHome:    "/srv/homes/user",
+		},
+		"Surrounding_whitespace_is_trimmed": {template: " /home/{{ .Username }}\n", wantHome: "/home/user"},
+
+		"Error_if_home_is_not_absolute":           {template: "{{ .Username }}", wantErr: true},
+		"Error_if_home_is_the_base_dir":           {template: "{{ .HomeBaseDir }}", wantErr: true},
+		"Error_if_home_is_outside_the_base_dir":   {template: "/etc/{{ .Username }}", wantErr: true},
+		"Error_if_home_has_a_prefix_of_base_dir":  {template: "/homeless/{{ .Username }}", wantErr: true},
+		"Error_if_home_traverses_out_of_base_dir": {template: "/home/{{ .Username }}", username: "../etc", wantErr: true},
+		"Error_if_home_is_not_clean":              {template: "/home//{{ .Username }}", wantErr: true},
+		"Error_if_template_fails_to_execute":      {template: "/home/{{ .Missing }}", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.username == "" {
+				tc.username = "user"
+			}
+			if tc.homeBaseDir == "" {
+				tc.homeBaseDir = "/home"
+			}
+
+			tmpl, err := parseHomeDirTemplate(tc.template)
+			require.NoError(t, err, "Setup: parseHomeDirTemplate should not have returned an error")
+			require.NotNil(t, tmpl, "Setup: parseHomeDirTemplate should have returned a template")
+
+			home, err := homeDirFromTemplate(tmpl, tc.username, issuerURL, tc.homeBaseDir)
+			if tc.wantErr {
+				require.Error(t, err, "homeDirFromTemplate should have returned an error")
+				return
+			}
+			require.NoError(t, err, "homeDirFromTemplate should not have returned an error")
+			require.Equal(t, tc.wantHome, home, "homeDirFromTemplate should have returned the expected home directory")
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package broker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// newHTTPClient returns the HTTP client used for the requests to the OIDC provider, i.e. for the discovery, the JWKS,
+// the token and the revocation endpoints. Each request fails if it does not complete within timeout.
+// If proxyURL is empty, the proxy is selected via the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+// Otherwise, all requests are sent through proxyURL and the environment variables are ignored.
+// If caFile is not empty, the certificates it contains are trusted in addition to the system ones.
+// Requests which fail with a transient server error are retried within the timeout.
+func newHTTPClient(proxyURL, caFile string, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if caFile != "" {
+		rootCAs, err := loadCABundle(caFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	}
+
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", proxyKey, proxyURL, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid %s %q: unsupported scheme %q", proxyKey, proxyURL, u.Scheme)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid %s %q: missing host", proxyKey, proxyURL)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	return &http.Client{Transport: newRetryTransport(transport), Timeout: timeout}, nil
+}
+
+// loadCABundle returns the system certificate pool with the certificates of the PEM file appended.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", caFileKey, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("invalid %s %q: no valid PEM certificates found", caFileKey, path)
+	}
+	return pool, nil
+}
+
+// checkProviderIsReachable sends a HEAD request to the URL of the provider to check that it is reachable. This is used
+// instead of OIDC discovery for providers which don't support OIDC. Any response except a server error means that the
+// provider is reachable.
+func (b *Broker) checkProviderIsReachable(ctx context.Context, providerURL string) error {
+	cfg := b.config()
+	return checkURLIsReachable(ctx, cfg.httpClient, cfg.requestTimeout, providerURL)
+}
+
+// checkURLIsReachable sends a HEAD request to providerURL with the client, which must complete within timeout.
+func checkURLIsReachable(ctx context.Context, client *http.Client, timeout time.Duration, providerURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, providerURL, nil)
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s: %s", providerURL, resp.Status)
+	}
+	return nil
+}
+
+// withHTTPClient returns a copy of ctx which makes the oidc and oauth2 packages use the HTTP client of the broker.
+func (b *Broker) withHTTPClient(ctx context.Context) context.Context {
+	return oidc.ClientContext(ctx, b.config().httpClient)
+}
+
+// isTimeoutError returns true if err is caused by a request to the OIDC provider which did not complete in time.
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,144 @@
+package broker
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		proxyURL string
+
+		wantProxy string
+		wantErr   bool
+	}{
+		"Successfully_create_client_without_proxy":     {},
+		"Successfully_create_client_with_http_proxy":   {proxyURL: "http://proxy.example.com:3128", wantProxy: "http://proxy.example.com:3128"},
+		"Successfully_create_client_with_https_proxy":  {proxyURL: "https://proxy.example.com", wantProxy: "https://proxy.example.com"},
+		"Successfully_create_client_with_socks5_proxy": {proxyURL: "socks5://proxy.example.com:1080", wantProxy: "socks5://proxy.example.com:1080"},
+		"Error_if_proxy_url_can_not_be_parsed":         {proxyURL: "http://proxy.example.com:port", wantErr: true},
+		"Error_if_proxy_url_has_an_unsupported_scheme": {proxyURL: "ftp://proxy.example.com", wantErr: true},
+		"Error_if_proxy_url_has_no_scheme":             {proxyURL: "proxy.example.com:3128", wantErr: true},
+		"Error_if_proxy_url_has_no_host":               {proxyURL: "http://", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := newHTTPClient(tc.proxyURL, "", defaultRequestTimeout)
+			if tc.wantErr {
+				require.Error(t, err, "newHTTPClient should have returned an error")
+				return
+			}
+			require.NoError(t, err, "newHTTPClient should not have returned an error")
+
+			if tc.wantProxy == "" {
+				return
+			}
+			req, err := http.NewRequest(http.MethodGet, "https://issuer.example.com", nil)
+			require.NoError(t, err, "Setup: NewRequest should not have returned an error")
+			proxy, err := client.Transport.(*retryTransport).base.(*http.Transport).Proxy(req)
+			require.NoError(t, err, "Proxy should not have returned an error")
+			require.Equal(t, tc.wantProxy, proxy.String(), "Requests should be sent through the configured proxy")
+		})
+	}
+}
+
+func TestNewHTTPClientWithCAFile(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := map[string]struct {
+		caFile string
+
+		wantErr        bool
+		wantRequestErr bool
+	}{
+		"Successfully_trust_certificates_from_CA_file": {caFile: "valid"},
+
+		"Error_if_CA_file_does_not_exist":            {caFile: "inexistent", wantErr: true},
+		"Error_if_CA_file_contains_no_certificates":  {caFile: "invalid", wantErr: true},
+		"Error_on_request_if_certificate_is_unknown": {wantRequestErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var caFile string
+			if tc.caFile != "" {
+				caFile = filepath.Join(t.TempDir(), "ca.pem")
+			}
+			switch tc.caFile {
+			case "valid":
+				err := os.WriteFile(caFile, serverCert, 0600)
+				require.NoError(t, err, "Setup: WriteFile should not have returned an error")
+			case "invalid":
+				err := os.WriteFile(caFile, []byte("not a certificate"), 0600)
+				require.NoError(t, err, "Setup: WriteFile should not have returned an error")
+			}
+
+			client, err := newHTTPClient("", caFile, defaultRequestTimeout)
+			if tc.wantErr {
+				require.Error(t, err, "newHTTPClient should have returned an error")
+				return
+			}
+			require.NoError(t, err, "newHTTPClient should not have returned an error")
+
+			resp, err := client.Get(server.URL)
+			if tc.wantRequestErr {
+				require.Error(t, err, "Request should have failed because the certificate is not trusted")
+				return
+			}
+			require.NoError(t, err, "Request should have succeeded with the trusted certificate")
+			resp.Body.Close()
+		})
+	}
+}
+
+func TestIsTimeoutError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := newHTTPClient("", "", 10*time.Millisecond)
+	require.NoError(t, err, "Setup: newHTTPClient should not have returned an error")
+	_, timeoutErr := client.Get(server.URL)
+	require.Error(t, timeoutErr, "Setup: Request should have timed out")
+
+	tests := map[string]struct {
+		err error
+
+		want bool
+	}{
+		"Request_timed_out":         {err: timeoutErr, want: true},
+		"Context_deadline_exceeded": {err: fmt.Errorf("wrapped: %w", context.DeadlineExceeded), want: true},
+
+		"Other_error":         {err: errors.New("some error")},
+		"Context_is_canceled": {err: context.Canceled},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, isTimeoutError(tc.err), "isTimeoutError should return the expected value")
+		})
+	}
+}
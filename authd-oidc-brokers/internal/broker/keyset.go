@@ -0,0 +1,207 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/ubuntu/authd/log"
+)
+
+// minKeysRefreshInterval is the minimum time between two fetches of the signing keys of the provider, so that a flood
+// of ID tokens signed with unknown keys can't overload its JWKS endpoint.
+const minKeysRefreshInterval = 30 * time.Second
+
+// keysGracePeriod is the time after the expiry of the cached signing keys during which they are still used if they
+// can't be fetched again, like the cached provider metadata.
+const keysGracePeriod = metadataCacheGracePeriod
+
+// signingAlgorithms are the algorithms of the signing keys which are supported to verify ID tokens.
+var signingAlgorithms = []jose.SignatureAlgorithm{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
+	oidc.EdDSA,
+}
+
+// errKeysRefreshedRecently is returned if the signature of an ID token can't be verified with the cached signing keys
+// and they can't be fetched again yet.
+var errKeysRefreshedRecently = errors.New("signing key not found and the signing keys were fetched less than " + minKeysRefreshInterval.String() + " ago")
+
+// keySet is the set of signing keys of the provider (JWKS) used to verify ID tokens. The keys are cached, and fetched
+// again when they expire, so that keys revoked by the provider stop being accepted, or if an ID token is signed with an
+// unknown key, e.g. because the provider rotated its keys. Fetching the keys is limited to once per
+// minKeysRefreshInterval.
+type keySet struct {
+	client *http.Client
+	// issuer is the issuer URL of the provider, with which the metrics of the fetches are labelled.
+	issuer string
+	// ttl is the time after which the keys expire, or 0 if they only expire after the max-age of the JWKS response.
+	ttl time.Duration
+
+	mu sync.Mutex
+	// url is the URL of the JWKS endpoint of the provider.
+	url  string
+	keys []jose.JSONWebKey
+	// generation is incremented each time the keys are fetched, so that callers can tell whether the keys were
+	// fetched since they got them.
+	generation  int
+	lastRefresh time.Time
+	// expiry is the time after which the keys must be fetched again, or zero if they don't expire.
+	expiry time.Time
+}
+
+// newKeySet returns an empty key set which fetches the keys of the provider of the issuer with the HTTP client. The
+// keys expire after ttl, or earlier if the max-age of the JWKS response is shorter. If ttl is 0, they expire after the
+// max-age of the response, and never if it has none.
+func newKeySet(client *http.Client, issuer string, ttl time.Duration) *keySet {
+	return &keySet{client: client, issuer: issuer, ttl: ttl}
+}
+
+// setURL sets the URL of the JWKS endpoint of the provider. The cached keys are dropped if it changed.
+func (k *keySet) setURL(url string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.url == url {
+		return
+	}
+	k.url = url
+	k.keys = nil
+	k.lastRefresh = time.Time{}
+	k.expiry = time.Time{}
+}
+
+// VerifySignature verifies the signature of the JWT with the cached keys, or with the keys fetched again if they
+// expired or none of the cached ones matches, and returns its payload. It implements oidc.KeySet.
+func (k *keySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt, signingAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT: %v", err)
+	}
+
+	k.mu.Lock()
+	keys, generation, expiry := k.keys, k.generation, k.expiry
+	k.mu.Unlock()
+
+	if keys != nil && !expiry.IsZero() && !time.Now().Before(expiry) {
+		refreshed, err := k.refresh(ctx, generation, "the cached ones expired")
+		switch {
+		case err == nil:
+			keys = refreshed
+		case errors.Is(err, errKeysRefreshedRecently):
+			// The max-age of the JWKS response is shorter than minKeysRefreshInterval.
+		case time.Now().Before(expiry.Add(keysGracePeriod)):
+			log.Warningf(context.Background(), "Could not refresh the signing keys of the provider, using the cached ones: %v", err)
+		default:
+			return nil, err
+		}
+	}
+
+	if payload, ok := verifyWithKeys(jws, keys); ok {
+		return payload, nil
+	}
+
+	keys, err = k.refresh(ctx, generation, "an ID token is signed with an unknown key")
+	if err != nil {
+		return nil, err
+	}
+	if payload, ok := verifyWithKeys(jws, keys); ok {
+		return payload, nil
+	}
+	return nil, errors.New("failed to verify signature: no matching signing key")
+}
+
+// refresh fetches the keys again, unless they were already fetched since the given generation, in which case the
+// current keys are returned. An error is returned if they were fetched less than minKeysRefreshInterval ago. The
+// reason is logged if the keys were already fetched before.
+func (k *keySet) refresh(ctx context.Context, generation int, reason string) ([]jose.JSONWebKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.generation != generation {
+		return k.keys, nil
+	}
+	if !k.lastRefresh.IsZero() && time.Since(k.lastRefresh) < minKeysRefreshInterval {
+		return nil, errKeysRefreshedRecently
+	}
+
+	// Failed attempts are rate limited too.
+	k.lastRefresh = time.Now()
+	keys, maxAge, err := k.fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch signing keys: %w", err)
+	}
+	if k.generation > 0 {
+		log.Infof(context.Background(), "Fetched the signing keys of the provider again, because %s", reason)
+	}
+	k.keys = keys
+	k.generation++
+
+	k.expiry = time.Time{}
+	if ttl := k.ttl; ttl > 0 || maxAge >= 0 {
+		if maxAge >= 0 && (ttl == 0 || maxAge < ttl) {
+			ttl = maxAge
+		}
+		k.expiry = time.Now().Add(ttl)
+	}
+	return keys, nil
+}
+
+// fetch fetches the keys from the JWKS endpoint. It also returns the max-age of the Cache-Control header of the
+// response, or -1 if it has none. Only call this with the mutex locked.
+func (k *keySet) fetch(ctx context.Context) ([]jose.JSONWebKey, time.Duration, error) {
+	defer observeDuration(jwksFetchDuration, time.Now(), k.issuer)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("%s: %s", resp.Status, body)
+	}
+
+	var keySet jose.JSONWebKeySet
+	if err := json.Unmarshal(body, &keySet); err != nil {
+		return nil, 0, fmt.Errorf("invalid JWKS: %v", err)
+	}
+
+	maxAge, ok := parseMaxAge(resp.Header.Get("Cache-Control"))
+	if !ok {
+		maxAge = -1
+	}
+	return keySet.Keys, maxAge, nil
+}
+
+// verifyWithKeys verifies the signature of the JWS with the key of its key ID, or with all keys if it has none, and
+// returns its payload.
+func verifyWithKeys(jws *jose.JSONWebSignature, keys []jose.JSONWebKey) ([]byte, bool) {
+	// JWTs with multiple signatures are not supported.
+	keyID := jws.Signatures[0].Header.KeyID
+	for _, key := range keys {
+		if keyID != "" && key.KeyID != keyID {
+			continue
+		}
+		if payload, err := jws.Verify(&key); err == nil {
+			return payload, true
+		}
+	}
+	return nil, false
+}
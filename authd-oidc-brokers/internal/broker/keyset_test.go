@@ -0,0 +1,150 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySetVerifySignature(t *testing.T) {
+	t.Parallel()
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err, "Setup: GenerateKey should not have returned an error")
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err, "Setup: GenerateKey should not have returned an error")
+
+	tests := map[string]struct {
+		// rotateKeys makes the JWKS endpoint return the new key instead of the old one after the first fetch.
+		rotateKeys bool
+		// refreshedRecently makes the last fetch of the keys happen less than minKeysRefreshInterval ago.
+		refreshedRecently bool
+		jwksUnavailable   bool
+		ttl               time.Duration
+		cacheControl      string
+		// expiredFor makes the keys expire that long before the second verification, if it's not 0.
+		expiredFor   time.Duration
+		signingKey   *rsa.PrivateKey
+		signingKeyID string
+
+		wantFetches int32
+		wantErr     bool
+		wantErrIs   error
+	}{
+		"Verify_signature_with_cached_keys":       {signingKey: oldKey, signingKeyID: "old", wantFetches: 1},
+		"Verify_signature_without_key_ID":         {signingKey: oldKey, wantFetches: 1},
+		"Fetch_keys_again_if_signing_key_rotated": {rotateKeys: true, signingKey: newKey, signingKeyID: "new", wantFetches: 2},
+		"Fetch_keys_again_if_they_expired":        {ttl: time.Hour, expiredFor: time.Second, signingKey: oldKey, signingKeyID: "old", wantFetches: 2},
+		"Fetch_keys_again_after_max-age_of_response": {
+			ttl: time.Hour, cacheControl: "max-age=0", signingKey: oldKey, signingKeyID: "old", wantFetches: 2,
+		},
+		"Do_not_fetch_keys_again_before_they_expire": {ttl: time.Hour, signingKey: oldKey, signingKeyID: "old", wantFetches: 1},
+		"Keys_do_not_expire_without_ttl_or_max-age":  {rotateKeys: true, signingKey: oldKey, signingKeyID: "old", wantFetches: 1},
+		"Use_expired_keys_if_they_can_not_be_fetched_again": {
+			jwksUnavailable: true, ttl: time.Hour, expiredFor: time.Second, signingKey: oldKey, signingKeyID: "old", wantFetches: 2,
+		},
+		"Use_expired_keys_if_they_were_fetched_recently": {
+			refreshedRecently: true, ttl: time.Hour, expiredFor: time.Second, signingKey: oldKey, signingKeyID: "old", wantFetches: 1,
+		},
+
+		"Error_if_keys_were_fetched_recently":        {rotateKeys: true, refreshedRecently: true, signingKey: newKey, signingKeyID: "new", wantFetches: 1, wantErr: true, wantErrIs: errKeysRefreshedRecently},
+		"Error_if_signing_key_is_unknown":            {signingKey: newKey, signingKeyID: "new", wantFetches: 2, wantErr: true},
+		"Error_if_signing_key_does_not_match_its_ID": {signingKey: newKey, signingKeyID: "old", wantFetches: 2, wantErr: true},
+		"Error_if_keys_can_not_be_fetched_again":     {jwksUnavailable: true, signingKey: newKey, signingKeyID: "new", wantFetches: 2, wantErr: true},
+		"Error_if_signing_key_was_revoked_and_keys_expired": {
+			rotateKeys: true, ttl: time.Hour, expiredFor: time.Second, signingKey: oldKey, signingKeyID: "old", wantFetches: 2, wantErr: true,
+		},
+		"Error_if_expired_keys_can_not_be_fetched_after_grace_period": {
+			jwksUnavailable: true, ttl: time.Hour, expiredFor: keysGracePeriod + time.Second, signingKey: oldKey, signingKeyID: "old",
+			wantFetches: 2, wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var fetches atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				n := fetches.Add(1)
+				if n > 1 && tc.jwksUnavailable {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				if tc.cacheControl != "" {
+					w.Header().Set("Cache-Control", tc.cacheControl)
+				}
+				key := jose.JSONWebKey{Key: &oldKey.PublicKey, KeyID: "old", Algorithm: string(jose.RS256), Use: "sig"}
+				if n > 1 && tc.rotateKeys {
+					key = jose.JSONWebKey{Key: &newKey.PublicKey, KeyID: "new", Algorithm: string(jose.RS256), Use: "sig"}
+				}
+				require.NoError(t, json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key}}), "Setup: Failed to write JWKS")
+			}))
+			t.Cleanup(server.Close)
+
+			k := newKeySet(server.Client(), "", tc.ttl)
+			k.setURL(server.URL)
+
+			// Fetch the initial keys with a token signed with the old key.
+			_, err := k.VerifySignature(context.Background(), signJWT(t, oldKey, "old"))
+			require.NoError(t, err, "Setup: VerifySignature should not have returned an error")
+			if !tc.refreshedRecently {
+				k.lastRefresh = time.Now().Add(-minKeysRefreshInterval)
+			}
+			if tc.expiredFor != 0 {
+				k.expiry = time.Now().Add(-tc.expiredFor)
+			}
+
+			payload, err := k.VerifySignature(context.Background(), signJWT(t, tc.signingKey, tc.signingKeyID))
+			require.Equal(t, tc.wantFetches, fetches.Load(), "The keys should be fetched the expected number of times")
+			if tc.wantErr {
+				require.Error(t, err, "VerifySignature should have returned an error")
+				if tc.wantErrIs != nil {
+					require.ErrorIs(t, err, tc.wantErrIs, "VerifySignature should have returned the expected error")
+				}
+				return
+			}
+			require.NoError(t, err, "VerifySignature should not have returned an error")
+			require.JSONEq(t, `{"sub":"test-user-id"}`, string(payload), "VerifySignature should return the payload of the JWT")
+		})
+	}
+}
+
+func TestKeySetSetURL(t *testing.T) {
+	t.Parallel()
+
+	k := newKeySet(http.DefaultClient, "", 0)
+	k.setURL("https://issuer.example.com/keys")
+	k.keys = []jose.JSONWebKey{{KeyID: "old"}}
+	k.lastRefresh = time.Now()
+	k.expiry = time.Now().Add(time.Hour)
+
+	k.setURL("https://issuer.example.com/keys")
+	require.Len(t, k.keys, 1, "The keys should be kept if the URL did not change")
+
+	k.setURL("https://other-issuer.example.com/keys")
+	require.Empty(t, k.keys, "The keys should be dropped if the URL changed")
+	require.True(t, k.lastRefresh.IsZero(), "The keys of the new URL should be fetched immediately")
+	require.True(t, k.expiry.IsZero(), "The expiry of the dropped keys should be reset")
+}
+
+// signJWT returns a JWT signed with the key, with the key ID in its header if it's not empty.
+func signJWT(t *testing.T, key *rsa.PrivateKey, keyID string) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: keyID}}, nil)
+	require.NoError(t, err, "Setup: NewSigner should not have returned an error")
+	jws, err := signer.Sign([]byte(`{"sub":"test-user-id"}`))
+	require.NoError(t, err, "Setup: Sign should not have returned an error")
+	jwt, err := jws.CompactSerialize()
+	require.NoError(t, err, "Setup: CompactSerialize should not have returned an error")
+	return jwt
+}
@@ -0,0 +1,141 @@
+package broker
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/ubuntu/authd/log"
+)
+
+// metadataCacheGracePeriod is the time after the expiry of the cached provider metadata during which it's still used
+// if it can't be refreshed, so that logins don't fail only because the discovery endpoint is temporarily unavailable.
+const metadataCacheGracePeriod = 24 * time.Hour
+
+// discoveryPath is the path of the OIDC discovery document, relative to the issuer URL.
+const discoveryPath = "/.well-known/openid-configuration"
+
+// metadataCache caches the OIDC provider of the issuer, i.e. its discovery document. The signing keys (JWKS) are
+// cached separately by the key set of the broker.
+type metadataCache struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	expiry   time.Time
+}
+
+// newMetadataCache returns a cache of the provider metadata which expires after ttl, or earlier if the max-age of the
+// discovery response is shorter.
+func newMetadataCache(ttl time.Duration) *metadataCache {
+	return &metadataCache{ttl: ttl}
+}
+
+// get returns the cached provider if it has not expired, and fetches it otherwise. If it can't be fetched, the expired
+// provider is returned during the grace period. The provider is always checked to be reachable, so that the session
+// starts in offline mode if it's not.
+func (c *metadataCache) get(ctx context.Context, b *Broker) (*oidc.Provider, error) {
+	// The lock is held while fetching the metadata, so that concurrent sessions don't fetch it all at once.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.provider != nil && time.Now().Before(c.expiry) {
+		if err := b.checkProviderIsReachable(ctx, c.provider.Endpoint().TokenURL); err != nil {
+			return nil, err
+		}
+		return c.provider, nil
+	}
+
+	p, maxAge, err := b.discoverOIDCServer(ctx)
+	if err != nil && c.provider != nil && time.Now().Before(c.expiry.Add(metadataCacheGracePeriod)) {
+		log.Warningf(context.Background(), "Could not refresh the metadata of the provider, using the cached one: %v", err)
+		if err := b.checkProviderIsReachable(ctx, c.provider.Endpoint().TokenURL); err != nil {
+			return nil, err
+		}
+		return c.provider, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := c.ttl
+	if maxAge >= 0 && maxAge < ttl {
+		ttl = maxAge
+	}
+	c.provider = p
+	c.expiry = time.Now().Add(ttl)
+	return p, nil
+}
+
+// discoverOIDCServer constructs the OIDC provider of the issuer via OIDC discovery. It also returns the max-age of the
+// Cache-Control header of the discovery response, or -1 if it has none.
+func (b *Broker) discoverOIDCServer(ctx context.Context) (*oidc.Provider, time.Duration, error) {
+	cfg := b.config()
+	ctx, cancel := context.WithTimeout(ctx, cfg.requestTimeout)
+	defer cancel()
+	defer observeDuration(discoveryDuration, time.Now(), cfg.issuerURL)
+
+	recorder := &maxAgeRecorder{base: cfg.httpClient.Transport, maxAge: -1}
+	client := &http.Client{Transport: recorder, Timeout: cfg.httpClient.Timeout}
+
+	p, err := oidc.NewProvider(oidc.ClientContext(ctx, client), cfg.issuerURL)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return p, recorder.maxAge, nil
+}
+
+// maxAgeRecorder is an HTTP transport which records the max-age of the Cache-Control header of the discovery response.
+type maxAgeRecorder struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	maxAge time.Duration
+}
+
+// RoundTrip sends the request with the base transport and records the max-age of the response if it's the discovery
+// response.
+func (r *maxAgeRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := r.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil || !strings.HasSuffix(req.URL.Path, discoveryPath) {
+		return resp, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if maxAge, ok := parseMaxAge(resp.Header.Get("Cache-Control")); ok {
+		r.maxAge = maxAge
+	}
+	return resp, nil
+}
+
+// parseMaxAge returns the max-age of the Cache-Control header, and whether it has one. The no-store and no-cache
+// directives are handled like a max-age of 0.
+func parseMaxAge(cacheControl string) (maxAge time.Duration, ok bool) {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return 0, true
+		case "max-age":
+			seconds, err := strconv.ParseUint(strings.Trim(value, `"`), 10, 32)
+			if err != nil {
+				continue
+			}
+			maxAge, ok = time.Duration(seconds)*time.Second, true
+		}
+	}
+	return maxAge, ok
+}
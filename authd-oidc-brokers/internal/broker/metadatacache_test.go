@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMaxAge(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cacheControl string
+
+		wantMaxAge time.Duration
+		wantOK     bool
+	}{
+		"Max_age":                      {cacheControl: "max-age=3600", wantMaxAge: time.Hour, wantOK: true},
+		"Max_age_with_other_directive": {cacheControl: "public, max-age=60, must-revalidate", wantMaxAge: time.Minute, wantOK: true},
+		"Quoted_max_age":               {cacheControl: `max-age="60"`, wantMaxAge: time.Minute, wantOK: true},
+		"Case_insensitive_max_age":     {cacheControl: "Max-Age=60", wantMaxAge: time.Minute, wantOK: true},
+		"No_store":                     {cacheControl: "no-store, max-age=60", wantOK: true},
+		"No_cache":                     {cacheControl: "max-age=60, no-cache", wantOK: true},
+
+		"No_header":         {},
+		"No_max_age":        {cacheControl: "public"},
+		"Invalid_max_age":   {cacheControl: "max-age=soon"},
+		"Negative_max_age":  {cacheControl: "max-age=-1"},
+		"Max_age_too_large": {cacheControl: "max-age=99999999999"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			maxAge, ok := parseMaxAge(tc.cacheControl)
+			require.Equal(t, tc.wantOK, ok, "parseMaxAge should return whether the header has a max-age")
+			require.Equal(t, tc.wantMaxAge, maxAge, "parseMaxAge should return the max-age of the header")
+		})
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		ttl          time.Duration
+		cacheControl string
+		failRefresh  bool
+
+		wantDiscoveries int32
+		wantErr         bool
+	}{
+		"Cache_metadata_until_ttl_expires":               {ttl: time.Hour, wantDiscoveries: 1},
+		"Cache_metadata_for_ttl_if_max_age_is_longer":    {ttl: time.Hour, cacheControl: "max-age=7200", wantDiscoveries: 1},
+		"Fetch_metadata_again_if_cache_is_disabled":      {wantDiscoveries: 2},
+		"Fetch_metadata_again_after_max_age_of_response": {ttl: time.Hour, cacheControl: "max-age=0", wantDiscoveries: 2},
+		// A refresh which fails is retried, so the discovery document is fetched once more per retry.
+		"Use_expired_metadata_if_it_can_not_be_refreshed": {ttl: time.Hour, cacheControl: "no-store", failRefresh: true, wantDiscoveries: 2 + defaultMaxRetries},
+
+		"Error_if_metadata_can_not_be_fetched_and_cache_is_disabled": {failRefresh: true, wantDiscoveries: 2 + defaultMaxRetries, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var discoveries atomic.Int32
+			discoveryHandler := func(w http.ResponseWriter, r *http.Request) {
+				if discoveries.Add(1) > 1 && tc.failRefresh {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				if tc.cacheControl != "" {
+					w.Header().Set("Cache-Control", tc.cacheControl)
+				}
+				testutils.DefaultOpenIDHandler("http://"+r.Host)(w, r)
+			}
+			issuerURL, cleanup := testutils.StartMockProviderServer("", nil, testutils.WithHandler(discoveryPath, discoveryHandler))
+			t.Cleanup(cleanup)
+
+			cfg := Config{DataDir: t.TempDir()}
+			cfg.Init()
+			cfg.issuerURL = issuerURL
+			cfg.clientID = "test-client-id"
+			cfg.metadataCacheTTL = tc.ttl
+			b, err := New(cfg, WithCustomProvider(&testutils.MockProvider{}))
+			require.NoError(t, err, "Setup: New should not have returned an error")
+
+			first, err := b.connectToOIDCServer(context.Background())
+			require.NoError(t, err, "Setup: connectToOIDCServer should not have returned an error")
+
+			second, err := b.connectToOIDCServer(context.Background())
+			require.Equal(t, tc.wantDiscoveries, discoveries.Load(), "The discovery document should be fetched the expected number of times")
+			if tc.wantErr {
+				require.Error(t, err, "connectToOIDCServer should have returned an error")
+				return
+			}
+			require.NoError(t, err, "connectToOIDCServer should not have returned an error")
+			require.Equal(t, first.Endpoint(), second.Endpoint(), "connectToOIDCServer should return the same provider")
+		})
+	}
+}
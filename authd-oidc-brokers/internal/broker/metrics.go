@@ -0,0 +1,53 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/metrics"
+)
+
+// The metrics of the brokers are labelled with the issuer URL of their provider, so that the brokers of the
+// additional providers can be told apart.
+var (
+	loginsAttempted = metrics.NewCounterVec("authd_oidc_logins_attempted_total",
+		"Number of authentication attempts, i.e. calls to IsAuthenticated which were not cancelled.", "issuer")
+	loginsSucceeded = metrics.NewCounterVec("authd_oidc_logins_succeeded_total",
+		"Number of authentication attempts which were granted.", "issuer")
+	loginsFailed = metrics.NewCounterVec("authd_oidc_logins_failed_total",
+		"Number of authentication attempts which were denied or have to be retried.", "issuer")
+	tokenRefreshes = metrics.NewCounterVec("authd_oidc_token_refreshes_total",
+		"Number of refreshes of the tokens of users with the provider, by result (success or failure).", "issuer", "result")
+	discoveryDuration = metrics.NewHistogramVec("authd_oidc_discovery_duration_seconds",
+		"Duration of the fetches of the discovery document of the provider.", metrics.DefaultBuckets, "issuer")
+	jwksFetchDuration = metrics.NewHistogramVec("authd_oidc_jwks_fetch_duration_seconds",
+		"Duration of the fetches of the signing keys (JWKS) of the provider.", metrics.DefaultBuckets, "issuer")
+)
+
+// recordAuthentication updates the login metrics with the result of an authentication attempt.
+func recordAuthentication(issuer, access string) {
+	if access == AuthCancelled {
+		return
+	}
+
+	loginsAttempted.Inc(issuer)
+	switch access {
+	case AuthGranted:
+		loginsSucceeded.Inc(issuer)
+	case AuthDenied, AuthRetry:
+		loginsFailed.Inc(issuer)
+	}
+}
+
+// recordTokenRefresh updates the token refresh metrics with the result of a refresh.
+func recordTokenRefresh(issuer string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	tokenRefreshes.Inc(issuer, result)
+}
+
+// observeDuration adds the time elapsed since start to the histogram.
+func observeDuration(h *metrics.HistogramVec, start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAuthentication(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		access string
+
+		wantAttempted, wantSucceeded, wantFailed float64
+	}{
+		"Granted_counts_as_success":       {access: AuthGranted, wantAttempted: 1, wantSucceeded: 1},
+		"Denied_counts_as_failure":        {access: AuthDenied, wantAttempted: 1, wantFailed: 1},
+		"Retry_counts_as_failure":         {access: AuthRetry, wantAttempted: 1, wantFailed: 1},
+		"Next_only_counts_as_attempt":     {access: AuthNext, wantAttempted: 1},
+		"Cancelled_does_not_count_at_all": {access: AuthCancelled},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The metrics are global, so each test uses its own issuer.
+			issuer := "https://" + name
+
+			recordAuthentication(issuer, tc.access)
+			require.Equal(t, tc.wantAttempted, loginsAttempted.Value(issuer), "Unexpected number of attempted logins")
+			require.Equal(t, tc.wantSucceeded, loginsSucceeded.Value(issuer), "Unexpected number of succeeded logins")
+			require.Equal(t, tc.wantFailed, loginsFailed.Value(issuer), "Unexpected number of failed logins")
+		})
+	}
+}
+
+func TestRecordTokenRefresh(t *testing.T) {
+	t.Parallel()
+
+	const issuer = "https://refresh.example.com"
+	recordTokenRefresh(issuer, nil)
+	recordTokenRefresh(issuer, nil)
+	recordTokenRefresh(issuer, errors.New("refresh failed"))
+
+	require.Equal(t, 2.0, tokenRefreshes.Value(issuer, "success"), "Unexpected number of successful refreshes")
+	require.Equal(t, 1.0, tokenRefreshes.Value(issuer, "failure"), "Unexpected number of failed refreshes")
+}
@@ -0,0 +1,119 @@
+package broker
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/ubuntu/authd/log"
+)
+
+const (
+	// defaultMaxRetries is the number of times a request to the provider is retried after a transient server error.
+	defaultMaxRetries = 2
+	// defaultRetryBaseDelay is the delay before the first retry, which is doubled for each further retry.
+	defaultRetryBaseDelay = 250 * time.Millisecond
+	// defaultRetryMaxDelay is the maximum delay before a retry.
+	defaultRetryMaxDelay = 2 * time.Second
+)
+
+// retryTransport is an HTTP transport which retries the requests to the provider which failed with a transient server
+// error, with an exponential backoff and jitter. Client errors (e.g. invalid_grant) are never retried.
+type retryTransport struct {
+	base http.RoundTripper
+
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+// newRetryTransport returns a transport which sends the requests with base and retries them with the default settings.
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+	return &retryTransport{
+		base:       base,
+		maxRetries: defaultMaxRetries,
+		baseDelay:  defaultRetryBaseDelay,
+		maxDelay:   defaultRetryMaxDelay,
+	}
+}
+
+// RoundTrip sends the request with the base transport, and retries it while the response is a transient server error,
+// the request can be sent again and the context of the request allows to wait for the next attempt.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	for attempt := 0; attempt < t.maxRetries; attempt++ {
+		if err != nil || !isRetriableStatus(resp.StatusCode) || !canRetry(req) {
+			return resp, err
+		}
+
+		// The timeout of the HTTP client is applied as the deadline of the context of the request, so checking it
+		// makes sure that the retries never take longer than the client allows.
+		delay := t.delay(attempt)
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) <= delay {
+			// There is no time left for another attempt, so the response is returned as is.
+			return resp, nil
+		}
+		log.Infof(context.Background(), "Request to %s failed with %q, retrying in %s", req.URL.Redacted(), resp.Status, delay)
+
+		// The body must be read to the end so that the connection can be reused.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		retry := req.Clone(req.Context())
+		if req.Body != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		resp, err = t.base.RoundTrip(retry)
+	}
+	return resp, err
+}
+
+// delay returns the delay before the retry after the given attempt, which is between half and the whole of the
+// exponential backoff, so that clients which failed at the same time don't retry at the same time.
+func (t *retryTransport) delay(attempt int) time.Duration {
+	backoff := min(t.baseDelay<<attempt, t.maxDelay)
+	half := backoff / 2
+	//nolint:gosec // G404 the jitter doesn't need a cryptographically secure random number.
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// isRetriableStatus returns true if the status code is a server error which is likely to be transient.
+func isRetriableStatus(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// canRetry returns true if the request can be sent again: idempotent requests can always be retried, and the requests
+// with a body (e.g. the token requests) only if their body can be read again.
+func canRetry(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		return req.Body == nil || req.GetBody != nil
+	}
+	return false
+}
+
+// sleepContext waits for the duration, or until the context is done. It returns the error of the context if it is
+// done, even if the duration elapsed at the same time.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,186 @@
+package broker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransport(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method   string
+		body     string
+		statuses []int
+		timeout  time.Duration
+		delay    time.Duration
+
+		wantStatus int
+		wantCalls  int
+	}{
+		"Request_succeeds_without_retry":          {statuses: []int{http.StatusOK}, wantStatus: http.StatusOK, wantCalls: 1},
+		"GET_is_retried_after_transient_error":    {statuses: []int{http.StatusServiceUnavailable, http.StatusOK}, wantStatus: http.StatusOK, wantCalls: 2},
+		"HEAD_is_retried_after_transient_error":   {method: http.MethodHead, statuses: []int{http.StatusBadGateway, http.StatusOK}, wantStatus: http.StatusOK, wantCalls: 2},
+		"POST_is_retried_with_the_same_body":      {method: http.MethodPost, body: "grant_type=refresh_token", statuses: []int{http.StatusInternalServerError, http.StatusGatewayTimeout, http.StatusOK}, wantStatus: http.StatusOK, wantCalls: 3},
+		"Last_response_is_returned_after_retries": {statuses: []int{http.StatusServiceUnavailable}, wantStatus: http.StatusServiceUnavailable, wantCalls: 3},
+
+		"Client_error_is_not_retried":                        {method: http.MethodPost, body: "grant_type=refresh_token", statuses: []int{http.StatusBadRequest}, wantStatus: http.StatusBadRequest, wantCalls: 1},
+		"Server_error_which_is_not_transient_is_not_retried": {statuses: []int{http.StatusNotImplemented}, wantStatus: http.StatusNotImplemented, wantCalls: 1},
+		"Non_idempotent_method_is_not_retried":               {method: http.MethodPut, statuses: []int{http.StatusServiceUnavailable}, wantStatus: http.StatusServiceUnavailable, wantCalls: 1},
+		"Request_is_not_retried_after_its_deadline":          {statuses: []int{http.StatusServiceUnavailable}, timeout: time.Second, delay: 5 * time.Second, wantStatus: http.StatusServiceUnavailable, wantCalls: 1},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var calls int
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err, "Reading the request body should not fail")
+
+				mu.Lock()
+				defer mu.Unlock()
+				bodies = append(bodies, string(body))
+				w.WriteHeader(tc.statuses[min(calls, len(tc.statuses)-1)])
+				calls++
+			}))
+			t.Cleanup(server.Close)
+
+			transport := newRetryTransport(http.DefaultTransport)
+			transport.baseDelay = 10 * time.Millisecond
+			transport.maxDelay = 20 * time.Millisecond
+			if tc.delay != 0 {
+				transport.baseDelay, transport.maxDelay = tc.delay, tc.delay
+			}
+
+			ctx := context.Background()
+			if tc.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				t.Cleanup(cancel)
+			}
+			if tc.method == "" {
+				tc.method = http.MethodGet
+			}
+			var body io.Reader
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
+			req, err := http.NewRequestWithContext(ctx, tc.method, server.URL, body)
+			require.NoError(t, err, "Setup: NewRequest should not have returned an error")
+
+			resp, err := (&http.Client{Transport: transport}).Do(req)
+			require.NoError(t, err, "Request should not have failed")
+			resp.Body.Close()
+
+			require.Equal(t, tc.wantStatus, resp.StatusCode, "Unexpected status of the response")
+			mu.Lock()
+			defer mu.Unlock()
+			require.Equal(t, tc.wantCalls, calls, "Unexpected number of requests")
+			for _, b := range bodies {
+				require.Equal(t, tc.body, b, "Each attempt should send the body of the request")
+			}
+		})
+	}
+}
+
+func TestRetryTransportStopsWhenContextIsCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cancel the request while the transport waits for the retry.
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	transport := newRetryTransport(http.DefaultTransport)
+	transport.baseDelay = time.Minute
+	transport.maxDelay = time.Minute
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err, "Setup: NewRequest should not have returned an error")
+
+	start := time.Now()
+	_, err = (&http.Client{Transport: transport}).Do(req)
+	require.ErrorIs(t, err, context.Canceled, "Request should fail with the error of the context")
+	require.Less(t, time.Since(start), 10*time.Second, "The transport should not wait for the retry")
+}
+
+func TestRetryTransportRespectsClientTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		delay time.Duration
+
+		wantCalls int
+	}{
+		"Request_is_retried_while_the_timeout_allows_it":          {delay: 400 * time.Millisecond, wantCalls: 3},
+		"Request_is_not_retried_if_the_delay_exceeds_the_timeout": {delay: 5 * time.Second, wantCalls: 1},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			t.Cleanup(server.Close)
+
+			transport := newRetryTransport(http.DefaultTransport)
+			transport.baseDelay, transport.maxDelay = tc.delay, tc.delay
+			// Each delay is between half and the whole of tc.delay, so two retries of 400ms at most always fit.
+			const timeout = time.Second
+			client := &http.Client{Transport: transport, Timeout: timeout}
+
+			start := time.Now()
+			resp, err := client.Get(server.URL)
+			require.NoError(t, err, "Request should return the last response instead of timing out")
+			resp.Body.Close()
+
+			require.Less(t, time.Since(start), timeout, "The retries should not take longer than the client timeout")
+			require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "Unexpected status of the response")
+			require.Equal(t, tc.wantCalls, int(calls.Load()), "Unexpected number of requests")
+		})
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The context is already done, so it must win even if the duration elapses immediately.
+	for range 100 {
+		require.ErrorIs(t, sleepContext(ctx, 0), context.Canceled, "sleepContext should return the error of the context")
+	}
+	require.NoError(t, sleepContext(context.Background(), time.Millisecond), "sleepContext should not fail")
+}
+
+func TestRetryTransportDelay(t *testing.T) {
+	t.Parallel()
+
+	transport := newRetryTransport(http.DefaultTransport)
+	for attempt, backoff := range []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2 * time.Second, 2 * time.Second} {
+		for range 100 {
+			d := transport.delay(attempt)
+			require.GreaterOrEqual(t, d, backoff/2, "Delay of attempt %d should be at least half of the backoff", attempt)
+			require.LessOrEqual(t, d, backoff, "Delay of attempt %d should be at most the backoff", attempt)
+		}
+	}
+}
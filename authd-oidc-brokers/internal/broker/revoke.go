@@ -0,0 +1,82 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/ubuntu/authd/log"
+	"golang.org/x/oauth2"
+)
+
+// revokeToken revokes the refresh token, or the access token if there is no refresh token, at the revocation endpoint
+// of the provider as defined in RFC 7009.
+// It returns nil without revoking the token if the provider does not advertise a revocation endpoint.
+func (b *Broker) revokeToken(ctx context.Context, t *oauth2.Token) error {
+	cfg := b.config()
+	if _, ok := b.provider.(providers.OAuth2Provider); ok {
+		log.Notice(context.Background(), "The provider does not support OIDC discovery, so the revocation endpoint is unknown, only removing the cached token")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.requestTimeout)
+	defer cancel()
+
+	provider, err := b.connectToOIDCServer(ctx)
+	if err != nil {
+		return fmt.Errorf("could not connect to the provider to revoke the token: %v", err)
+	}
+
+	var metadata struct {
+		RevocationEndpoint string `json:"revocation_endpoint"`
+	}
+	if err := provider.Claims(&metadata); err != nil {
+		return fmt.Errorf("could not get the provider metadata: %v", err)
+	}
+	if metadata.RevocationEndpoint == "" {
+		log.Notice(context.Background(), "The provider does not advertise a revocation endpoint, only removing the cached token")
+		return nil
+	}
+
+	form := url.Values{
+		"token":           {t.RefreshToken},
+		"token_type_hint": {"refresh_token"},
+	}
+	if t.RefreshToken == "" {
+		form.Set("token", t.AccessToken)
+		form.Set("token_type_hint", "access_token")
+	}
+	// Public clients identify themselves via the client_id parameter, confidential ones via HTTP basic authentication.
+	if cfg.clientSecret == "" {
+		form.Set("client_id", cfg.oidcCfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.RevocationEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("could not create revocation request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cfg.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.oidcCfg.ClientID), url.QueryEscape(cfg.clientSecret))
+	}
+
+	resp, err := cfg.httpClient.Do(req)
+	if err != nil && isTimeoutError(err) {
+		return errors.New("could not revoke token: identity provider timed out")
+	}
+	if err != nil {
+		return fmt.Errorf("could not revoke token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("could not revoke token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -12,4 +12,7 @@ const (
 	// ChangePasswordOld is the old name for the change-password session, which is now deprecated but still used by authd
 	// until all broker installations are updated.
 	ChangePasswordOld = "passwd"
+	// Diagnostic is used when the session is for testing the configuration of the broker: the user authenticates with
+	// the provider like for a login, but nothing is stored for the user.
+	Diagnostic = "diagnostic"
 )
@@ -0,0 +1,139 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ubuntu/authd/log"
+)
+
+const (
+	// defaultMaxFailedAttempts is the number of consecutive failed authentication attempts after which the user is
+	// throttled if maxFailedAttemptsKey is not set.
+	defaultMaxFailedAttempts = 5
+	// defaultFailedAttemptsWindow is the time after which failed authentication attempts are forgotten if
+	// failedAttemptsWindowKey is not set.
+	defaultFailedAttemptsWindow = 15 * time.Minute
+	// baseThrottleDelay is the time during which authentication is rejected after the maximum number of failed
+	// attempts is reached. It is doubled on each further failed attempt, up to the failed attempts window.
+	baseThrottleDelay = 30 * time.Second
+)
+
+// throttledMsg returns the error message if the user of the session is throttled because of too many failed
+// authentication attempts, and false if the user may authenticate.
+func (b *Broker) throttledMsg(session *session) (errorMessage, bool) {
+	retryAfter := b.authThrottler.retryAfter(b.provider.NormalizeUsername(session.username))
+	if retryAfter <= 0 {
+		return errorMessage{}, false
+	}
+
+	// Round up, so that users don't retry too early.
+	retryAfter = retryAfter.Truncate(time.Second) + time.Second
+	log.Noticef(context.Background(), "Rejecting authentication of user %q because of too many failed attempts, next attempt allowed in %s", session.username, retryAfter)
+	return errorMessage{Message: fmt.Sprintf("Too many failed authentication attempts. Please try again in %s.", retryAfter)}, true
+}
+
+// recordAuthResult records the result of an authentication attempt of the user of the session.
+func (b *Broker) recordAuthResult(session *session, success bool) {
+	username := b.provider.NormalizeUsername(session.username)
+	if success {
+		b.authThrottler.recordSuccess(username)
+		return
+	}
+	b.authThrottler.recordFailure(username)
+}
+
+// failedAttempts are the consecutive failed authentication attempts of a user.
+type failedAttempts struct {
+	count int
+	last  time.Time
+}
+
+// authThrottler rejects authentication attempts of users with too many consecutive failed attempts, with an
+// exponential backoff. The failed attempts are only kept in memory.
+type authThrottler struct {
+	// maxFailures is the number of consecutive failed attempts after which the user is throttled. Throttling is
+	// disabled if it is 0.
+	maxFailures int
+	// window is the time after the last failed attempt after which the failed attempts are forgotten.
+	window time.Duration
+
+	now func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]failedAttempts
+}
+
+func newAuthThrottler(maxFailures int, window time.Duration) *authThrottler {
+	return &authThrottler{
+		maxFailures: maxFailures,
+		window:      window,
+		now:         time.Now,
+		failures:    make(map[string]failedAttempts),
+	}
+}
+
+// retryAfter returns the time the user has to wait before authenticating again, or 0 if the user is not throttled.
+func (t *authThrottler) retryAfter(username string) time.Duration {
+	if t.maxFailures <= 0 {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, ok := t.failures[username]
+	if !ok {
+		return 0
+	}
+	now := t.now()
+	if now.Sub(f.last) >= t.window {
+		delete(t.failures, username)
+		return 0
+	}
+	if f.count < t.maxFailures {
+		return 0
+	}
+
+	return max(f.last.Add(t.delay(f.count)).Sub(now), 0)
+}
+
+// delay returns the time during which authentication is rejected after the given number of consecutive failed
+// attempts, which must be at least maxFailures.
+func (t *authThrottler) delay(count int) time.Duration {
+	delay := baseThrottleDelay
+	for i := t.maxFailures; i < count && delay < t.window; i++ {
+		delay *= 2
+	}
+	return min(delay, t.window)
+}
+
+// recordFailure records a failed authentication attempt of the user.
+func (t *authThrottler) recordFailure(username string) {
+	if t.maxFailures <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	f := t.failures[username]
+	if now.Sub(f.last) >= t.window {
+		f.count = 0
+	}
+	f.count++
+	f.last = now
+	t.failures[username] = f
+}
+
+// recordSuccess forgets the failed authentication attempts of the user, so that a legitimate user is not throttled
+// anymore once they successfully authenticated.
+func (t *authThrottler) recordSuccess(username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.failures, username)
+}
@@ -0,0 +1,95 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthThrottler(t *testing.T) {
+	t.Parallel()
+
+	const window = 15 * time.Minute
+
+	// attempt is a failed (or, if success is true, successful) authentication attempt after the given time since the
+	// previous one.
+	type attempt struct {
+		after   time.Duration
+		success bool
+	}
+
+	tests := map[string]struct {
+		maxFailures int
+		attempts    []attempt
+		waitAfter   time.Duration
+
+		wantRetryAfter time.Duration
+	}{
+		"Not_throttled_without_failed_attempts": {maxFailures: 3},
+		"Not_throttled_below_max_failures": {
+			maxFailures: 3,
+			attempts:    []attempt{{}, {}},
+		},
+		"Throttled_after_max_failures": {
+			maxFailures:    3,
+			attempts:       []attempt{{}, {}, {}},
+			wantRetryAfter: baseThrottleDelay,
+		},
+		"Delay_doubles_with_each_further_failure": {
+			maxFailures:    3,
+			attempts:       []attempt{{}, {}, {}, {after: baseThrottleDelay}, {after: 2 * baseThrottleDelay}},
+			wantRetryAfter: 4 * baseThrottleDelay,
+		},
+		"Delay_is_capped_to_window": {
+			maxFailures:    1,
+			attempts:       []attempt{{}, {after: time.Minute}, {after: 2 * time.Minute}, {after: 4 * time.Minute}, {after: 8 * time.Minute}, {after: 14 * time.Minute}},
+			wantRetryAfter: window,
+		},
+		"Throttling_ends_after_delay": {
+			maxFailures: 3,
+			attempts:    []attempt{{}, {}, {}},
+			waitAfter:   baseThrottleDelay,
+		},
+		"Remaining_delay_is_returned": {
+			maxFailures:    3,
+			attempts:       []attempt{{}, {}, {}},
+			waitAfter:      10 * time.Second,
+			wantRetryAfter: baseThrottleDelay - 10*time.Second,
+		},
+		"Failures_are_forgotten_after_window": {
+			maxFailures: 3,
+			attempts:    []attempt{{}, {}, {after: window}},
+		},
+		"Success_clears_failures": {
+			maxFailures: 3,
+			attempts:    []attempt{{}, {}, {success: true}, {}, {}},
+		},
+		"Not_throttled_if_disabled": {
+			maxFailures: 0,
+			attempts:    []attempt{{}, {}, {}, {}, {}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Now()
+			throttler := newAuthThrottler(tc.maxFailures, window)
+			throttler.now = func() time.Time { return now }
+
+			for _, a := range tc.attempts {
+				now = now.Add(a.after)
+				if a.success {
+					throttler.recordSuccess("user")
+					continue
+				}
+				throttler.recordFailure("user")
+			}
+			now = now.Add(tc.waitAfter)
+
+			require.Equal(t, tc.wantRetryAfter, throttler.retryAfter("user"), "retryAfter should return the expected delay")
+			require.Zero(t, throttler.retryAfter("other-user"), "Other users should not be throttled")
+		})
+	}
+}
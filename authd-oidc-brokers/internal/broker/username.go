@@ -0,0 +1,90 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+)
+
+// maxUsernameLength is the maximum length of a local username, as enforced by useradd.
+const maxUsernameLength = 32
+
+// posixUsernameRegex matches the usernames which are valid according to the default NAME_REGEX of adduser.
+var posixUsernameRegex = regexp.MustCompile(`^[a-z_][a-z0-9_.-]*\$?$`)
+
+// usernameTemplateFuncs are the functions which can be used in the username template in addition to the builtin ones.
+var usernameTemplateFuncs = template.FuncMap{
+	// splitDomain returns the part of an email address before the domain.
+	"splitDomain": func(s string) string {
+		if i := strings.LastIndex(s, "@"); i >= 0 {
+			return s[:i]
+		}
+		return s
+	},
+	"lower": strings.ToLower,
+}
+
+// usernameTemplateData is the data against which the username template is evaluated.
+type usernameTemplateData struct {
+	Email             string `json:"email"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+	Sub               string `json:"sub"`
+
+	// Claims contains all the claims of the ID token, to allow using claims without a dedicated field.
+	Claims map[string]any `json:"-"`
+}
+
+// parseUsernameTemplate parses the username template. It returns nil if the template is empty.
+func parseUsernameTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	t, err := template.New("username").Funcs(usernameTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", usernameTemplateKey, err)
+	}
+	return t, nil
+}
+
+// usernameFromTemplate evaluates the username template against the claims of the ID token and returns the resulting
+// username. It returns an error if the result is not a valid POSIX username.
+func usernameFromTemplate(t *template.Template, idToken info.Claimer) (string, error) {
+	var data usernameTemplateData
+	if err := idToken.Claims(&data); err != nil {
+		return "", fmt.Errorf("failed to get ID token claims: %v", err)
+	}
+	if err := idToken.Claims(&data.Claims); err != nil {
+		return "", fmt.Errorf("failed to get ID token claims: %v", err)
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("could not evaluate %s: %v", usernameTemplateKey, err)
+	}
+
+	username := strings.TrimSpace(sb.String())
+	if err := validatePOSIXUsername(username); err != nil {
+		return "", fmt.Errorf("%s evaluated to an invalid username %q: %w", usernameTemplateKey, username, err)
+	}
+	return username, nil
+}
+
+// validatePOSIXUsername returns an error if the username can't be used as a local username.
+func validatePOSIXUsername(username string) error {
+	if username == "" {
+		return errors.New("username is empty")
+	}
+	if len(username) > maxUsernameLength {
+		return fmt.Errorf("username is longer than %d characters", maxUsernameLength)
+	}
+	if !posixUsernameRegex.MatchString(username) {
+		return errors.New("username must start with a lowercase letter or an underscore and only contain lowercase letters, digits, underscores, dots and hyphens")
+	}
+	return nil
+}
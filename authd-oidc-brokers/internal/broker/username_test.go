@@ -0,0 +1,101 @@
+package broker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// claimsMap implements info.Claimer for tests.
+type claimsMap map[string]any
+
+func (c claimsMap) Claims(v any) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func TestUsernameFromTemplate(t *testing.T) {
+	t.Parallel()
+
+	claims := claimsMap{
+		"sub":                "1234",
+		"name":               "John Doe",
+		"email":              "John.Doe@Example.com",
+		"preferred_username": "jdoe",
+		"employee_id":        "e42",
+	}
+
+	tests := map[string]struct {
+		template string
+
+		wantUsername string
+		wantErr      bool
+	}{
+		"Username_from_preferred_username":         {template: "{{.PreferredUsername}}", wantUsername: "jdoe"},
+		"Username_from_email_without_domain":       {template: "{{ .Email | splitDomain | lower }}", wantUsername: "john.doe"},
+		"Username_from_arbitrary_claim":            {template: `{{ index .Claims "employee_id" }}`, wantUsername: "e42"},
+		"Username_from_multiple_claims":            {template: "{{.PreferredUsername}}_{{.Sub}}", wantUsername: "jdoe_1234"},
+		"Surrounding_whitespace_is_trimmed":        {template: " {{.PreferredUsername}}\n", wantUsername: "jdoe"},
+		"Username_can_end_with_dollar_sign":        {template: "{{.PreferredUsername}}$", wantUsername: "jdoe$"},
+		"Username_splitDomain_without_domain":      {template: "{{ .PreferredUsername | splitDomain }}", wantUsername: "jdoe"},
+		"Error_if_username_has_uppercase_letters":  {template: "{{ .Email | splitDomain }}", wantErr: true},
+		"Error_if_username_has_invalid_characters": {template: "{{ .Email | lower }}", wantErr: true},
+		"Error_if_username_has_spaces":             {template: "{{ .Name | lower }}", wantErr: true},
+		"Error_if_username_starts_with_a_digit":    {template: "{{ .Sub }}", wantErr: true},
+		"Error_if_username_starts_with_a_hyphen":   {template: "-{{ .PreferredUsername }}", wantErr: true},
+		"Error_if_username_is_empty":               {template: `{{ index .Claims "missing" }}`, wantErr: true},
+		"Error_if_username_is_too_long":            {template: "{{.PreferredUsername}}_abcdefghijklmnopqrstuvwxyz0123456789", wantErr: true},
+		"Error_if_template_fails_to_execute":       {template: "{{ .Email.Missing }}", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl, err := parseUsernameTemplate(tc.template)
+			require.NoError(t, err, "Setup: parseUsernameTemplate should not have returned an error")
+			require.NotNil(t, tmpl, "Setup: parseUsernameTemplate should have returned a template")
+
+			username, err := usernameFromTemplate(tmpl, claims)
+			if tc.wantErr {
+				require.Error(t, err, "usernameFromTemplate should have returned an error")
+				return
+			}
+			require.NoError(t, err, "usernameFromTemplate should not have returned an error")
+			require.Equal(t, tc.wantUsername, username, "usernameFromTemplate should have returned the expected username")
+		})
+	}
+}
+
+func TestParseUsernameTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		template string
+
+		wantNil bool
+		wantErr bool
+	}{
+		"Successfully_parse_template":            {template: "{{ .Email | splitDomain }}"},
+		"No_template_if_empty":                   {template: "", wantNil: true},
+		"No_template_if_only_whitespace":         {template: "  ", wantNil: true},
+		"Error_if_template_has_invalid_syntax":   {template: "{{ .Email", wantErr: true},
+		"Error_if_template_has_unknown_function": {template: "{{ .Email | unknown }}", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl, err := parseUsernameTemplate(tc.template)
+			if tc.wantErr {
+				require.Error(t, err, "parseUsernameTemplate should have returned an error")
+				return
+			}
+			require.NoError(t, err, "parseUsernameTemplate should not have returned an error")
+			require.Equal(t, tc.wantNil, tmpl == nil, "parseUsernameTemplate returned an unexpected template")
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// ConfigCheck is the result of a check of the configuration file.
+type ConfigCheck struct {
+	// Section is the section of the configuration file the check applies to, or empty for the whole file.
+	Section string
+	// Name describes what is checked.
+	Name string
+	// Err is the problem found by the check, or nil if it passed.
+	Err error
+}
+
+// ValidateConfig checks the configuration file at cfgPath and its drop-in files, for the [oidc] section and each
+// additional provider: that it can be parsed, that the required settings are set, that the extra scopes are valid,
+// that the templates compile and that the issuer is reachable.
+//
+// It never modifies anything and doesn't need the broker to be running, so that a configuration can be checked before
+// it is deployed. All checks are returned, the failed ones having a non-nil Err.
+func ValidateConfig(ctx context.Context, cfgPath string) []ConfigCheck {
+	if _, err := loadConfigFile(cfgPath); err != nil {
+		return []ConfigCheck{{Name: "configuration file can be read", Err: err}}
+	}
+	checks := []ConfigCheck{{Name: "configuration file can be read"}}
+
+	providerIDs, err := ProviderIDs(cfgPath)
+	checks = append(checks, ConfigCheck{Name: "provider IDs are valid", Err: err})
+
+	addr, err := MetricsListenAddress(cfgPath)
+	if err == nil && addr != "" {
+		if _, _, splitErr := net.SplitHostPort(addr); splitErr != nil {
+			err = fmt.Errorf("invalid %s %q: %v", metricsListenAddressKey, addr, splitErr)
+		}
+	}
+	checks = append(checks, ConfigCheck{Section: metricsSection, Name: "listen address is valid", Err: err})
+
+	_, err = AuditOutput(cfgPath)
+	checks = append(checks, ConfigCheck{Section: auditSection, Name: "output is valid", Err: err})
+
+	checks = append(checks, validateProviderConfig(ctx, cfgPath, "")...)
+	for _, id := range providerIDs {
+		checks = append(checks, validateProviderConfig(ctx, cfgPath, id)...)
+	}
+
+	return checks
+}
+
+// validateProviderConfig checks the settings of the provider with the given ID, or of the [oidc] section if it's empty.
+func validateProviderConfig(ctx context.Context, cfgPath, providerID string) (checks []ConfigCheck) {
+	section := oidcSection
+	if providerID != "" {
+		section = providerSectionPrefix + providerID
+	}
+	check := func(name string, err error) {
+		checks = append(checks, ConfigCheck{Section: section, Name: name, Err: err})
+	}
+
+	p := providers.CurrentProvider()
+	if providerID != "" {
+		var err error
+		if p, err = providerFromConfig(cfgPath, providerID); err != nil {
+			check("provider type is supported", err)
+			return checks
+		}
+	}
+
+	cfg, err := parseConfigFromPath(cfgPath, providerID, p)
+	check("settings can be parsed", err)
+	if err != nil {
+		// The other checks depend on the parsed settings.
+		return checks
+	}
+
+	check("required settings are set", validateRequiredSettings(cfg, p))
+	check("extra scopes are valid", validateScopes(cfg.extraScopes))
+
+	_, err = parseUsernameTemplate(cfg.usernameTemplate)
+	check("username template compiles", err)
+	_, err = parseHomeDirTemplate(cfg.homeDirTemplate)
+	check("home directory template compiles", err)
+
+	if cfg.issuerURL == "" {
+		check("issuer is reachable", errors.New("no issuer to connect to"))
+		return checks
+	}
+	check("issuer is reachable", checkIssuerIsReachable(ctx, cfg, p))
+
+	return checks
+}
+
+// validateRequiredSettings returns an error listing the required settings which are missing or invalid.
+func validateRequiredSettings(cfg userConfig, p providers.Provider) (err error) {
+	if cfg.issuerURL == "" {
+		err = errors.Join(err, fmt.Errorf("'%s' is required", issuerKey))
+	} else if u, parseErr := url.Parse(cfg.issuerURL); parseErr != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		err = errors.Join(err, fmt.Errorf("'%s' must be an absolute http or https URL, got %q", issuerKey, cfg.issuerURL))
+	}
+	if cfg.clientID == "" {
+		err = errors.Join(err, fmt.Errorf("'%s' is required", clientIDKey))
+	}
+	if len(oidcAuthModes(p, cfg.authFlow, cfg.redirectURI)) == 0 {
+		err = errors.Join(err, fmt.Errorf("the provider does not support the %q authentication flow", cfg.authFlow))
+	}
+	return err
+}
+
+// validateScopes returns an error listing the extra scopes which are empty, duplicated, or not valid scope tokens as
+// defined in RFC 6749, section 3.3 (e.g. because they are separated by spaces instead of commas).
+func validateScopes(scopes []string) (err error) {
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		switch {
+		case scope == "":
+			err = errors.Join(err, fmt.Errorf("'%s' contains an empty scope", extraScopesKey))
+			continue
+		case strings.IndexFunc(scope, func(r rune) bool { return !isScopeChar(r) }) >= 0:
+			err = errors.Join(err, fmt.Errorf("'%s' contains an invalid scope %q, scopes must be separated by commas", extraScopesKey, scope))
+		case seen[scope]:
+			err = errors.Join(err, fmt.Errorf("'%s' contains the scope %q more than once", extraScopesKey, scope))
+		}
+		seen[scope] = true
+	}
+	return err
+}
+
+// isScopeChar returns true if r is allowed in a scope token: any printable ASCII character except space, the double
+// quote and the backslash.
+func isScopeChar(r rune) bool {
+	return r > ' ' && r <= '~' && r != '"' && r != '\\'
+}
+
+// checkIssuerIsReachable checks that the discovery document of the issuer can be fetched, or for providers which don't
+// support OIDC, that their token endpoint is reachable.
+func checkIssuerIsReachable(ctx context.Context, cfg userConfig, p providers.Provider) error {
+	if cfg.requestTimeout == 0 {
+		cfg.requestTimeout = defaultRequestTimeout
+	}
+	httpClient, err := newHTTPClient(cfg.proxyURL, cfg.caFile, cfg.requestTimeout)
+	if err != nil {
+		return err
+	}
+
+	if oauth2Provider, ok := p.(providers.OAuth2Provider); ok {
+		return checkURLIsReachable(ctx, httpClient, cfg.requestTimeout, oauth2Provider.Endpoint(cfg.issuerURL).TokenURL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.requestTimeout)
+	defer cancel()
+	_, err = oidc.NewProvider(oidc.ClientContext(ctx, httpClient), cfg.issuerURL)
+	return err
+}
@@ -0,0 +1,137 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+
+	const unreachableIssuer = "http://127.0.0.1:1"
+	issuerURL, cleanup := testutils.StartMockProviderServer("", nil)
+	t.Cleanup(cleanup)
+
+	tests := map[string]struct {
+		config  string
+		issuer  string
+		noFile  bool
+		dropIns []string
+
+		wantFailed []string
+		wantChecks int
+	}{
+		"Valid_config":                       {config: "[oidc]\nissuer = %s\nclient_id = client_id\n", wantChecks: 10},
+		"Valid_config_with_optional_values":  {config: "[oidc]\nissuer = %s\nclient_id = client_id\nextra_scopes = groups, offline_access\n[users]\nusername_template = {{.preferred_username}}\nhome_dir_template = /home/{{.username}}\n[metrics]\nlisten_address = 127.0.0.1:9090\n[audit]\noutput = none\n", wantChecks: 10},
+		"Valid_config_with_a_provider":       {config: "[oidc]\nissuer = %[1]s\nclient_id = client_id\n[provider.other]\nissuer = %[1]s\nclient_id = other\n", wantChecks: 16},
+		"Valid_config_with_a_drop_in_file":   {config: "[oidc]\nissuer = %s\n", dropIns: []string{"[oidc]\nclient_id = client_id\n"}, wantChecks: 10},
+		"Issuer_is_checked_with_http_client": {config: "[oidc]\nissuer = %s\nclient_id = client_id\nca_file = /does/not/exist\n", wantFailed: []string{"[oidc] issuer is reachable"}, wantChecks: 10},
+
+		"Error_if_file_does_not_exist":       {noFile: true, wantFailed: []string{"configuration file can be read"}, wantChecks: 1},
+		"Error_if_file_is_not_valid_ini":     {config: "[oidc\n", wantFailed: []string{"configuration file can be read"}, wantChecks: 1},
+		"Error_if_settings_can_not_be_parse": {config: "[oidc]\nissuer = %s\nclient_id = client_id\nforce_provider_authentication = nope\n", wantFailed: []string{"[oidc] settings can be parsed"}, wantChecks: 5},
+		"Error_if_placeholders_are_not_set":  {config: "[oidc]\nissuer = %s\nclient_id = <CLIENT_ID>\n", wantFailed: []string{"[oidc] settings can be parsed"}, wantChecks: 5},
+		"Error_if_required_settings_are_missing": {
+			config:     "[oidc]\n",
+			wantFailed: []string{"[oidc] required settings are set", "[oidc] issuer is reachable"},
+			wantChecks: 10,
+		},
+		"Error_if_issuer_is_not_an_URL":         {config: "[oidc]\nissuer = issuer.example.com\nclient_id = client_id\n", wantFailed: []string{"[oidc] required settings are set", "[oidc] issuer is reachable"}, wantChecks: 10},
+		"Error_if_issuer_is_not_reachable":      {config: "[oidc]\nissuer = %s\nclient_id = client_id\nrequest_timeout = 5s\n", issuer: unreachableIssuer, wantFailed: []string{"[oidc] issuer is reachable"}, wantChecks: 10},
+		"Error_if_extra_scopes_are_not_valid":   {config: "[oidc]\nissuer = %s\nclient_id = client_id\nextra_scopes = groups,,groups,offline_access email\n", wantFailed: []string{"[oidc] extra scopes are valid"}, wantChecks: 10},
+		"Error_if_username_template_is_invalid": {config: "[oidc]\nissuer = %s\nclient_id = client_id\n[users]\nusername_template = {{.preferred_username\n", wantFailed: []string{"[oidc] username template compiles"}, wantChecks: 10},
+		"Error_if_home_dir_template_is_invalid": {config: "[oidc]\nissuer = %s\nclient_id = client_id\n[users]\nhome_dir_template = /home/{{.username\n", wantFailed: []string{"[oidc] home directory template compiles"}, wantChecks: 10},
+		"Error_if_metrics_address_is_invalid":   {config: "[oidc]\nissuer = %s\nclient_id = client_id\n[metrics]\nlisten_address = localhost\n", wantFailed: []string{"[metrics] listen address is valid"}, wantChecks: 10},
+		"Error_if_audit_output_is_invalid":      {config: "[oidc]\nissuer = %s\nclient_id = client_id\n[audit]\noutput = audit.log\n", wantFailed: []string{"[audit] output is valid"}, wantChecks: 10},
+		"Error_if_provider_ID_is_invalid":       {config: "[oidc]\nissuer = %s\nclient_id = client_id\n[provider.not-valid]\n", wantFailed: []string{"provider IDs are valid"}, wantChecks: 10},
+		"Error_if_provider_type_is_unsupported": {config: "[oidc]\nissuer = %s\nclient_id = client_id\n[provider.other]\ntype = unknown\n", wantFailed: []string{"[provider.other] provider type is supported"}, wantChecks: 11},
+		"Error_if_provider_settings_are_missing": {
+			config:     "[oidc]\nissuer = %s\nclient_id = client_id\n[provider.other]\n",
+			wantFailed: []string{"[provider.other] required settings are set", "[provider.other] issuer is reachable"},
+			wantChecks: 16,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			confPath := filepath.Join(dir, "broker.conf")
+			if !tc.noFile {
+				if tc.issuer == "" {
+					tc.issuer = issuerURL
+				}
+				err := os.WriteFile(confPath, []byte(fmt.Sprintf(tc.config, tc.issuer)), 0600)
+				require.NoError(t, err, "Setup: Failed to write config file")
+			}
+			if len(tc.dropIns) > 0 {
+				dropInDir := GetDropInDir(confPath)
+				err := os.Mkdir(dropInDir, 0700)
+				require.NoError(t, err, "Setup: Failed to create drop-in directory")
+				for i, content := range tc.dropIns {
+					err := os.WriteFile(filepath.Join(dropInDir, fmt.Sprintf("%02d.conf", i)), []byte(content), 0600)
+					require.NoError(t, err, "Setup: Failed to write drop-in file")
+				}
+			}
+			entriesBefore, err := os.ReadDir(dir)
+			require.NoError(t, err, "Setup: Failed to read test directory")
+
+			checks := ValidateConfig(context.Background(), confPath)
+
+			var failed []string
+			for _, c := range checks {
+				name := c.Name
+				if c.Section != "" {
+					name = fmt.Sprintf("[%s] %s", c.Section, c.Name)
+				}
+				if c.Err != nil {
+					failed = append(failed, name)
+				}
+			}
+			require.Equal(t, tc.wantFailed, failed, "ValidateConfig should fail the expected checks")
+			require.Len(t, checks, tc.wantChecks, "ValidateConfig should return the expected number of checks")
+
+			entriesAfter, err := os.ReadDir(dir)
+			require.NoError(t, err, "Failed to read test directory")
+			require.Equal(t, entriesBefore, entriesAfter, "ValidateConfig should not create or remove any file")
+		})
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		scopes []string
+
+		wantErr bool
+	}{
+		"No_scopes":              {},
+		"Valid_scopes":           {scopes: []string{"groups", "offline_access", "api://client/.default"}},
+		"Error_if_scope_empty":   {scopes: []string{"groups", ""}, wantErr: true},
+		"Error_if_scope_repeats": {scopes: []string{"groups", "groups"}, wantErr: true},
+		"Error_if_scope_has_space": {
+			scopes:  []string{"groups offline_access"},
+			wantErr: true,
+		},
+		"Error_if_scope_has_quote": {scopes: []string{`"groups"`}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateScopes(tc.scopes)
+			if tc.wantErr {
+				require.Error(t, err, "validateScopes should return an error")
+				return
+			}
+			require.NoError(t, err, "validateScopes should not return an error")
+		})
+	}
+}
@@ -0,0 +1,12 @@
+//go:build withgithub
+
+package consts
+
+const (
+	// DbusName owned by the broker for authd to contact us.
+	DbusName = "com.ubuntu.authd.GitHub"
+	// DbusObject main object path for authd to contact us.
+	DbusObject = "/com/ubuntu/authd/GitHub"
+	// ProviderName is the name of the provider, e.g. as used in the home directory template.
+	ProviderName = "github"
+)
@@ -7,4 +7,6 @@ const (
 	DbusName = "com.ubuntu.authd.Google"
 	// DbusObject main object path for authd to contact us.
 	DbusObject = "/com/ubuntu/authd/Google"
+	// ProviderName is the name of the provider, e.g. as used in the home directory template.
+	ProviderName = "google"
 )
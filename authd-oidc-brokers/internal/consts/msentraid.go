@@ -7,4 +7,6 @@ const (
 	DbusName = "com.ubuntu.authd.MSEntraID"
 	// DbusObject main object path for authd to contact us.
 	DbusObject = "/com/ubuntu/authd/MSEntraID"
+	// ProviderName is the name of the provider, e.g. as used in the home directory template.
+	ProviderName = "msentraid"
 )
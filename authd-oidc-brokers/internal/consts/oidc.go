@@ -1,4 +1,4 @@
-//go:build !withgoogle && !withmsentraid
+//go:build !withgithub && !withgoogle && !withmsentraid
 
 package consts
 
@@ -7,4 +7,6 @@ const (
 	DbusName = "com.ubuntu.authd.Oidc"
 	// DbusObject main object path for authd to contact us.
 	DbusObject = "/com/ubuntu/authd/Oidc"
+	// ProviderName is the name of the provider, e.g. as used in the home directory template.
+	ProviderName = "oidc"
 )
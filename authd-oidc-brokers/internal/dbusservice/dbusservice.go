@@ -9,6 +9,7 @@ import (
 	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
 )
 
 const intro = `
@@ -47,26 +48,44 @@ const intro = `
 			<arg type="s" direction="in" name="username"/>
 			<arg type="s" direction="out" name="userInfo"/>
 		</method>
-	</interface>` + introspect.IntrospectDataString + `</node> `
+		<method name="Logout">
+			<arg type="s" direction="in" name="username"/>
+		</method>
+		<method name="Reload"/>
+		<property name="Version" type="s" access="read"/>
+	</interface>` + introspect.IntrospectDataString + prop.IntrospectDataString + `</node> `
 
 // Service is the handler exposing our broker methods on the system bus.
 type Service struct {
-	name   string
-	broker *broker.Broker
+	name string
 
 	serve      chan struct{}
 	disconnect func()
 }
 
+// brokerObject is the D-Bus object of a broker, on which the broker methods are called.
+type brokerObject struct {
+	broker *broker.Broker
+	// uidOfSender returns the UID of the process which sent a message on the bus.
+	uidOfSender func(sender dbus.Sender) (uint32, error)
+}
+
+// ObjectPath returns the object path of the broker of the additional provider with the given ID, or the main object
+// path if the ID is empty.
+func ObjectPath(providerID string) dbus.ObjectPath {
+	if providerID == "" {
+		return dbus.ObjectPath(consts.DbusObject)
+	}
+	return dbus.ObjectPath(consts.DbusObject + "/" + providerID)
+}
+
 // New returns a new dbus service after exporting to the system bus our name.
-func New(_ context.Context, broker *broker.Broker) (s *Service, err error) {
+// The brokers are mapped by the ID of their provider, and each one is exported at the object path of its ID.
+func New(_ context.Context, brokers map[string]*broker.Broker) (s *Service, err error) {
 	name := consts.DbusName
-	object := dbus.ObjectPath(consts.DbusObject)
-	iface := "com.ubuntu.authd.Broker"
 	s = &Service{
-		name:   name,
-		broker: broker,
-		serve:  make(chan struct{}),
+		name:  name,
+		serve: make(chan struct{}),
 	}
 
 	conn, err := s.getBus()
@@ -74,11 +93,11 @@ func New(_ context.Context, broker *broker.Broker) (s *Service, err error) {
 		return nil, err
 	}
 
-	if err := conn.Export(s, object, iface); err != nil {
-		return nil, err
-	}
-	if err := conn.Export(introspect.Introspectable(fmt.Sprintf(intro, iface)), object, "org.freedesktop.DBus.Introspectable"); err != nil {
-		return nil, err
+	for id, b := range brokers {
+		if err := export(conn, ObjectPath(id), b); err != nil {
+			s.disconnect()
+			return nil, err
+		}
 	}
 
 	reply, err := conn.RequestName(consts.DbusName, dbus.NameFlagDoNotQueue)
@@ -94,6 +113,32 @@ func New(_ context.Context, broker *broker.Broker) (s *Service, err error) {
 	return s, nil
 }
 
+// export exports the broker methods and properties at the given object path.
+func export(conn *dbus.Conn, object dbus.ObjectPath, b *broker.Broker) error {
+	iface := "com.ubuntu.authd.Broker"
+
+	obj := &brokerObject{
+		broker: b,
+		uidOfSender: func(sender dbus.Sender) (uid uint32, err error) {
+			err = conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixUser", 0, string(sender)).Store(&uid)
+			return uid, err
+		},
+	}
+	if err := conn.Export(obj, object, iface); err != nil {
+		return err
+	}
+	// The properties are read-only and never change while the broker is running.
+	props := prop.Map{
+		iface: {
+			"Version": {Value: consts.Version, Writable: false, Emit: prop.EmitConst},
+		},
+	}
+	if _, err := prop.Export(conn, object, props); err != nil {
+		return err
+	}
+	return conn.Export(introspect.Introspectable(fmt.Sprintf(intro, iface)), object, "org.freedesktop.DBus.Introspectable")
+}
+
 // Addr returns the address of the service.
 func (s *Service) Addr() string {
 	return s.name
@@ -10,9 +10,9 @@ import (
 )
 
 // NewSession is the method through which the broker and the daemon will communicate once dbusInterface.NewSession is called.
-func (s *Service) NewSession(username, lang, mode string) (sessionID, encryptionKey string, dbusErr *dbus.Error) {
+func (o *brokerObject) NewSession(username, lang, mode string) (sessionID, encryptionKey string, dbusErr *dbus.Error) {
 	log.Debugf(context.Background(), "Creating new session (username=%s, lang=%s, mode=%s)", username, lang, mode)
-	sessionID, encryptionKey, err := s.broker.NewSession(username, lang, mode)
+	sessionID, encryptionKey, err := o.broker.NewSession(username, lang, mode)
 	if err != nil {
 		return "", "", dbus.MakeFailedError(err)
 	}
@@ -21,9 +21,9 @@ func (s *Service) NewSession(username, lang, mode string) (sessionID, encryption
 }
 
 // GetAuthenticationModes is the method through which the broker and the daemon will communicate once dbusInterface.GetAuthenticationModes is called.
-func (s *Service) GetAuthenticationModes(sessionID string, supportedUILayouts []map[string]string) (authenticationModes []map[string]string, dbusErr *dbus.Error) {
+func (o *brokerObject) GetAuthenticationModes(sessionID string, supportedUILayouts []map[string]string) (authenticationModes []map[string]string, dbusErr *dbus.Error) {
 	log.Debugf(context.Background(), "Getting authentication modes for session %s", sessionID)
-	authenticationModes, err := s.broker.GetAuthenticationModes(sessionID, supportedUILayouts)
+	authenticationModes, err := o.broker.GetAuthenticationModes(sessionID, supportedUILayouts)
 	if err != nil {
 		return nil, dbus.MakeFailedError(err)
 	}
@@ -32,9 +32,9 @@ func (s *Service) GetAuthenticationModes(sessionID string, supportedUILayouts []
 }
 
 // SelectAuthenticationMode is the method through which the broker and the daemon will communicate once dbusInterface.SelectAuthenticationMode is called.
-func (s *Service) SelectAuthenticationMode(sessionID, authenticationModeName string) (uiLayoutInfo map[string]string, dbusErr *dbus.Error) {
+func (o *brokerObject) SelectAuthenticationMode(sessionID, authenticationModeName string) (uiLayoutInfo map[string]string, dbusErr *dbus.Error) {
 	log.Debugf(context.Background(), "Selecting authentication mode %s for session %s", authenticationModeName, sessionID)
-	uiLayoutInfo, err := s.broker.SelectAuthenticationMode(sessionID, authenticationModeName)
+	uiLayoutInfo, err := o.broker.SelectAuthenticationMode(sessionID, authenticationModeName)
 	if err != nil {
 		return nil, dbus.MakeFailedError(err)
 	}
@@ -43,10 +43,10 @@ func (s *Service) SelectAuthenticationMode(sessionID, authenticationModeName str
 }
 
 // IsAuthenticated is the method through which the broker and the daemon will communicate once dbusInterface.IsAuthenticated is called.
-func (s *Service) IsAuthenticated(sessionID, authenticationData string) (access, data string, dbusErr *dbus.Error) {
+func (o *brokerObject) IsAuthenticated(sessionID, authenticationData string) (access, data string, dbusErr *dbus.Error) {
 	// Do *not* log authenticationData here, because it may contain the user's password in cleartext.
 	log.Debugf(context.Background(), "Handling IsAuthenticated call for session %s", sessionID)
-	access, data, err := s.broker.IsAuthenticated(sessionID, authenticationData)
+	access, data, err := o.broker.IsAuthenticated(sessionID, authenticationData)
 	if errors.Is(err, context.Canceled) {
 		return access, data, makeCanceledError()
 	}
@@ -59,9 +59,9 @@ func (s *Service) IsAuthenticated(sessionID, authenticationData string) (access,
 }
 
 // EndSession is the method through which the broker and the daemon will communicate once dbusInterface.EndSession is called.
-func (s *Service) EndSession(sessionID string) (dbusErr *dbus.Error) {
+func (o *brokerObject) EndSession(sessionID string) (dbusErr *dbus.Error) {
 	log.Debugf(context.Background(), "Ending session %s", sessionID)
-	err := s.broker.EndSession(sessionID)
+	err := o.broker.EndSession(sessionID)
 	if err != nil {
 		return dbus.MakeFailedError(err)
 	}
@@ -69,16 +69,16 @@ func (s *Service) EndSession(sessionID string) (dbusErr *dbus.Error) {
 }
 
 // CancelIsAuthenticated is the method through which the broker and the daemon will communicate once dbusInterface.CancelIsAuthenticated is called.
-func (s *Service) CancelIsAuthenticated(sessionID string) (dbusErr *dbus.Error) {
+func (o *brokerObject) CancelIsAuthenticated(sessionID string) (dbusErr *dbus.Error) {
 	log.Debugf(context.Background(), "Cancelling IsAuthenticated call for session %s", sessionID)
-	s.broker.CancelIsAuthenticated(sessionID)
+	o.broker.CancelIsAuthenticated(sessionID)
 	return nil
 }
 
 // UserPreCheck is the method through which the broker and the daemon will communicate once dbusInterface.UserPreCheck is called.
-func (s *Service) UserPreCheck(username string) (userinfo string, dbusErr *dbus.Error) {
+func (o *brokerObject) UserPreCheck(username string) (userinfo string, dbusErr *dbus.Error) {
 	log.Debugf(context.Background(), "UserPreCheck: %s", username)
-	userinfo, err := s.broker.UserPreCheck(username)
+	userinfo, err := o.broker.UserPreCheck(username)
 	if err != nil {
 		return "", dbus.MakeFailedError(err)
 	}
@@ -86,6 +86,53 @@ func (s *Service) UserPreCheck(username string) (userinfo string, dbusErr *dbus.
 	return userinfo, nil
 }
 
+// Logout is the method through which the broker and the daemon will communicate once dbusInterface.Logout is called.
+// Only root can call it, as it revokes the token of any user.
+func (o *brokerObject) Logout(sender dbus.Sender, username string) (dbusErr *dbus.Error) {
+	log.Debugf(context.Background(), "Logout: %s", username)
+	if dbusErr := o.checkSenderIsRoot(sender); dbusErr != nil {
+		return dbusErr
+	}
+	if err := o.broker.Logout(username); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// Reload is the method through which the configuration of the broker is reloaded without restarting it. If the new
+// configuration is invalid, an error is returned and the current configuration is kept. Only root can call it.
+func (o *brokerObject) Reload(sender dbus.Sender) (dbusErr *dbus.Error) {
+	log.Debug(context.Background(), "Reloading configuration")
+	if dbusErr := o.checkSenderIsRoot(sender); dbusErr != nil {
+		return dbusErr
+	}
+	if err := o.broker.Reload(); err != nil {
+		log.Warningf(context.Background(), "Keeping the current configuration: %v", err)
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// checkSenderIsRoot returns an error if the sender of the message is not root. The D-Bus policy allows anyone to call
+// the methods of the broker, so the methods which act on behalf of all users must check their caller themselves.
+func (o *brokerObject) checkSenderIsRoot(sender dbus.Sender) *dbus.Error {
+	uid, err := o.uidOfSender(sender)
+	if err != nil {
+		log.Warningf(context.Background(), "Could not get the user of D-Bus sender %s: %v", sender, err)
+		return dbus.MakeFailedError(errors.New("could not check the permissions of the caller"))
+	}
+	if uid != 0 {
+		log.Warningf(context.Background(), "Rejecting call of D-Bus sender %s: user %d is not root", sender, uid)
+		return makeAccessDeniedError()
+	}
+	return nil
+}
+
+// makeAccessDeniedError creates a dbus.Error for a call which the caller is not allowed to make.
+func makeAccessDeniedError() *dbus.Error {
+	return dbus.NewError("org.freedesktop.DBus.Error.AccessDenied", []any{"only root can call this method"})
+}
+
 // makeCanceledError creates a dbus.Error for a canceled operation.
 func makeCanceledError() *dbus.Error {
 	return &dbus.Error{Name: "com.ubuntu.authd.Canceled"}
@@ -0,0 +1,67 @@
+package dbusservice
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSenderIsRoot(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uid    uint32
+		uidErr error
+
+		wantErrName string
+	}{
+		"Root_is_allowed": {uid: 0},
+
+		"Error_if_sender_is_not_root":          {uid: 1000, wantErrName: "org.freedesktop.DBus.Error.AccessDenied"},
+		"Error_if_user_of_sender_is_not_found": {uidErr: errors.New("no such sender"), wantErrName: "org.freedesktop.DBus.Error.Failed"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			o := newTestBrokerObject(tc.uid, tc.uidErr)
+
+			dbusErr := o.checkSenderIsRoot(":1.42")
+			if tc.wantErrName == "" {
+				require.Nil(t, dbusErr, "checkSenderIsRoot should not return an error")
+				return
+			}
+			require.NotNil(t, dbusErr, "checkSenderIsRoot should return an error")
+			require.Equal(t, tc.wantErrName, dbusErr.Name, "checkSenderIsRoot should return the expected error")
+		})
+	}
+}
+
+func TestPrivilegedMethodsRejectUnprivilegedSenders(t *testing.T) {
+	t.Parallel()
+
+	// The broker is nil, so any call which is not rejected panics.
+	o := newTestBrokerObject(1000, nil)
+
+	tests := map[string]func() *dbus.Error{
+		"Logout": func() *dbus.Error { return o.Logout(":1.42", "user@example.com") },
+		"Reload": func() *dbus.Error { return o.Reload(":1.42") },
+	}
+	for name, call := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbusErr := call()
+			require.NotNil(t, dbusErr, "%s should return an error", name)
+			require.Equal(t, "org.freedesktop.DBus.Error.AccessDenied", dbusErr.Name, "%s should deny access", name)
+		})
+	}
+}
+
+// newTestBrokerObject returns a broker object without broker whose senders are all run by the user with the given UID,
+// or can't be looked up if err is not nil.
+func newTestBrokerObject(uid uint32, err error) *brokerObject {
+	return &brokerObject{uidOfSender: func(dbus.Sender) (uint32, error) { return uid, err }}
+}
@@ -0,0 +1,125 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	t.Parallel()
+
+	r := metrics.NewRegistry()
+	c := r.NewCounterVec("test_counter_total", "A test counter.", "issuer")
+	h := r.NewHistogramVec("test_duration_seconds", "A test histogram.", []float64{0.1, 1}, "issuer")
+	r.NewCounterVec("test_unused_total", "A counter without series.\nWith a line feed.")
+
+	c.Inc("https://b.example.com")
+	c.Inc("https://a.example.com")
+	c.Inc("https://a.example.com")
+	c.Inc(`quote"and\backslash`)
+	h.Observe(0.05, "https://a.example.com")
+	h.Observe(0.5, "https://a.example.com")
+	h.Observe(5, "https://a.example.com")
+
+	require.Equal(t, 2.0, c.Value("https://a.example.com"), "Value should return the value of the series")
+	require.Equal(t, 0.0, c.Value("https://unknown.example.com"), "Value should return 0 for an unknown series")
+	require.Equal(t, uint64(3), h.Count("https://a.example.com"), "Count should return the number of observations")
+
+	var buf bytes.Buffer
+	err := r.Write(&buf)
+	require.NoError(t, err, "Write should not return an error")
+
+	want := `# HELP test_counter_total A test counter.
+# TYPE test_counter_total counter
+test_counter_total{issuer="https://a.example.com"} 2
+test_counter_total{issuer="https://b.example.com"} 1
+test_counter_total{issuer="quote\"and\\backslash"} 1
+# HELP test_duration_seconds A test histogram.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{issuer="https://a.example.com",le="0.1"} 1
+test_duration_seconds_bucket{issuer="https://a.example.com",le="1"} 2
+test_duration_seconds_bucket{issuer="https://a.example.com",le="+Inf"} 3
+test_duration_seconds_sum{issuer="https://a.example.com"} 5.55
+test_duration_seconds_count{issuer="https://a.example.com"} 3
+# HELP test_unused_total A counter without series.\nWith a line feed.
+# TYPE test_unused_total counter
+`
+	require.Equal(t, want, buf.String(), "Write should write the metrics in the Prometheus text format")
+}
+
+func TestRegistryPanicsOnDuplicateMetric(t *testing.T) {
+	t.Parallel()
+
+	r := metrics.NewRegistry()
+	r.NewCounterVec("test_total", "A test counter.")
+	require.Panics(t, func() { r.NewHistogramVec("test_total", "Another metric.", metrics.DefaultBuckets) },
+		"Registering a metric with the same name should panic")
+}
+
+func TestListenAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		addr string
+
+		want    string
+		wantErr bool
+	}{
+		"Default_to_localhost_without_host": {addr: ":9100", want: "127.0.0.1:9100"},
+		"Keep_host":                         {addr: "0.0.0.0:9100", want: "0.0.0.0:9100"},
+		"Keep_IPv6_host":                    {addr: "[::1]:9100", want: "[::1]:9100"},
+
+		"Error_if_address_has_no_port": {addr: "localhost", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := metrics.ListenAddress(tc.addr)
+			if tc.wantErr {
+				require.Error(t, err, "ListenAddress should return an error")
+				return
+			}
+			require.NoError(t, err, "ListenAddress should not return an error")
+			require.Equal(t, tc.want, got, "ListenAddress should return the expected address")
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	metrics.NewCounterVec("test_serve_total", "A test counter.").Inc()
+
+	s, err := metrics.Serve(":0")
+	require.NoError(t, err, "Serve should not return an error")
+	require.Contains(t, s.Addr(), "127.0.0.1:", "The server should only listen on localhost by default")
+
+	//nolint:noctx // The request is local and the test would time out if it hangs.
+	resp, err := http.Get("http://" + s.Addr() + "/metrics")
+	require.NoError(t, err, "Getting the metrics should not fail")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close(), "Closing the body should not fail")
+	require.NoError(t, err, "Reading the metrics should not fail")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "Getting the metrics should succeed")
+	require.Contains(t, string(body), "test_serve_total 1\n", "The metrics of the default registry should be served")
+
+	err = s.Stop()
+	require.NoError(t, err, "Stop should not return an error")
+
+	//nolint:noctx // The request is local and the test would time out if it hangs.
+	_, err = http.Get("http://" + s.Addr() + "/metrics")
+	require.Error(t, err, "Getting the metrics should fail once the server is stopped")
+}
+
+func TestServeFailsIfAddressIsInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := metrics.Serve("not an address")
+	require.Error(t, err, "Serve should return an error")
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ubuntu/authd/log"
+)
+
+// shutdownTimeout is the time to wait for the ongoing requests to complete when the server is stopped.
+const shutdownTimeout = 5 * time.Second
+
+// Server serves the metrics of the default registry over HTTP on the /metrics path.
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	done     chan struct{}
+}
+
+// Serve starts serving the metrics on the given address. If the address has no host, e.g. ":9100", the server only
+// listens on localhost, so that the metrics are not exposed to the network unless a host is set explicitly.
+func Serve(addr string) (*Server, error) {
+	addr, err := ListenAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %q for metrics: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", defaultRegistry)
+	s := &Server{
+		listener: l,
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		if err := s.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf(context.Background(), "Metrics server stopped: %v", err)
+		}
+	}()
+	log.Infof(context.Background(), "Serving metrics on http://%s/metrics", l.Addr())
+
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Stop stops the server, waiting for the ongoing requests to complete for up to a few seconds.
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	<-s.done
+	return err
+}
+
+// ListenAddress returns the address to listen on for the given address, whose host defaults to localhost.
+func ListenAddress(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid metrics listen address %q: %w", addr, err)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
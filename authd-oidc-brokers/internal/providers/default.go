@@ -1,4 +1,4 @@
-//go:build !withgoogle && !withmsentraid
+//go:build !withgithub && !withgoogle && !withmsentraid
 
 package providers
 
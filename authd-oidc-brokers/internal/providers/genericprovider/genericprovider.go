@@ -90,8 +90,10 @@ func (p GenericProvider) VerifyUsername(requestedUsername, username string) erro
 }
 
 // SupportedOIDCAuthModes returns the OIDC authentication modes supported by the provider.
+// Device authentication is preferred, because it also works on headless machines. Authorization code authentication
+// is only available if a redirect URI is configured.
 func (p GenericProvider) SupportedOIDCAuthModes() []string {
-	return []string{authmodes.Device, authmodes.DeviceQr}
+	return []string{authmodes.Device, authmodes.DeviceQr, authmodes.AuthCode}
 }
 
 type claims struct {
@@ -0,0 +1,284 @@
+// Package github is the GitHub specific extension.
+//
+// GitHub does not support OpenID Connect for user authentication, so the provider implements
+// providers.OAuth2Provider: the user info is fetched from the GitHub REST API instead of being parsed from an ID token.
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	providerErrors "github.com/canonical/authd/authd-oidc-brokers/internal/providers/errors"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/ubuntu/authd/log"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// localGroupPrefix is the prefix of the teams which are mapped to local groups, like for Microsoft Entra ID.
+	localGroupPrefix = "linux-"
+	// maxPages is the maximum number of pages fetched from paginated API endpoints, to avoid looping forever.
+	maxPages = 10
+)
+
+// Provider is the GitHub provider implementation.
+type Provider struct {
+	genericprovider.GenericProvider
+}
+
+// New returns a new GitHub provider.
+func New() Provider {
+	return Provider{
+		GenericProvider: genericprovider.New(),
+	}
+}
+
+// AdditionalScopes returns the scopes required to read the email addresses and the org and team memberships of the
+// user.
+func (Provider) AdditionalScopes() []string {
+	return []string{"read:user", "user:email", "read:org"}
+}
+
+// Endpoint returns the OAuth 2.0 endpoints of GitHub, or of the GitHub Enterprise Server at issuerURL.
+func (Provider) Endpoint(issuerURL string) oauth2.Endpoint {
+	base := strings.TrimSuffix(issuerURL, "/")
+	return oauth2.Endpoint{
+		AuthURL:       base + "/login/oauth/authorize",
+		TokenURL:      base + "/login/oauth/access_token",
+		DeviceAuthURL: base + "/login/device/code",
+	}
+}
+
+// NormalizeUsername parses a username into a normalized version.
+func (Provider) NormalizeUsername(username string) string {
+	// GitHub email addresses and logins are case-insensitive, and we use the email address as the username.
+	return strings.ToLower(username)
+}
+
+// VerifyUsername checks if the requested username matches the authenticated user.
+func (p Provider) VerifyUsername(requestedUsername, username string) error {
+	if p.NormalizeUsername(requestedUsername) != p.NormalizeUsername(username) {
+		msg := fmt.Sprintf("Authentication failure: requested username %q does not match the authenticated user %q", requestedUsername, username)
+		return &providerErrors.ForDisplayError{Message: msg}
+	}
+	return nil
+}
+
+type user struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type email struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type org struct {
+	Login string `json:"login"`
+}
+
+type team struct {
+	ID           int64  `json:"id"`
+	Slug         string `json:"slug"`
+	Organization org    `json:"organization"`
+}
+
+// claims are the claims of the user, named like the standard OIDC claims, so that they can be used like the ones of
+// an ID token (e.g. in the username template).
+type claims struct {
+	Sub               string   `json:"sub"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Orgs              []string `json:"orgs"`
+}
+
+// Claims implements info.Claimer.
+func (c claims) Claims(v any) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// UserClaims fetches the user, its primary verified email address and its organizations from the GitHub API.
+// The "orgs" claim contains the logins of the organizations the user is a member of.
+func (p Provider) UserClaims(ctx context.Context, issuerURL string, token *oauth2.Token) (info.Claimer, error) {
+	client := newAPIClient(ctx, issuerURL, token)
+
+	var u user
+	if err := client.get("/user", &u); err != nil {
+		return nil, err
+	}
+	if u.ID == 0 {
+		return nil, errors.New("the GitHub API returned a user without ID")
+	}
+
+	var emails []email
+	if err := client.getAll("/user/emails", func(page json.RawMessage) (int, error) {
+		var e []email
+		err := json.Unmarshal(page, &e)
+		emails = append(emails, e...)
+		return len(e), err
+	}); err != nil {
+		return nil, err
+	}
+
+	var primaryEmail string
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			primaryEmail = e.Email
+			break
+		}
+	}
+	if primaryEmail == "" {
+		return nil, &providerErrors.ForDisplayError{Message: "Authentication failure: the GitHub account has no verified primary email address"}
+	}
+
+	var orgs []string
+	if err := client.getAll("/user/orgs", func(page json.RawMessage) (int, error) {
+		var o []org
+		err := json.Unmarshal(page, &o)
+		for _, org := range o {
+			orgs = append(orgs, org.Login)
+		}
+		return len(o), err
+	}); err != nil {
+		return nil, err
+	}
+
+	return claims{
+		Sub:               strconv.FormatInt(u.ID, 10),
+		Email:             primaryEmail,
+		EmailVerified:     true,
+		Name:              u.Name,
+		PreferredUsername: u.Login,
+		Orgs:              orgs,
+	}, nil
+}
+
+// GetGroups returns the teams of the user as groups.
+// Team names are prefixed by the organization login, e.g. "my-org-developers", because team names are only unique
+// within an organization. Like for Microsoft Entra ID, teams whose name starts with "linux-" are mapped to local
+// groups without the prefix, e.g. the team "linux-sudo" is mapped to the local group "sudo".
+func (p Provider) GetGroups(ctx context.Context, _, issuerURL string, token *oauth2.Token, _ map[string]interface{}, _ []byte) ([]info.Group, error) {
+	client := newAPIClient(ctx, issuerURL, token)
+
+	var teams []team
+	if err := client.getAll("/user/teams", func(page json.RawMessage) (int, error) {
+		var t []team
+		err := json.Unmarshal(page, &t)
+		teams = append(teams, t...)
+		return len(t), err
+	}); err != nil {
+		return nil, err
+	}
+
+	var groups []info.Group
+	for _, t := range teams {
+		// Team slugs and organization logins are lowercase and only contain characters which are valid in group names.
+		slug := strings.ToLower(t.Slug)
+		if strings.HasPrefix(slug, localGroupPrefix) {
+			// Don't set the UGID for local groups, because that's how the user manager differentiates between local
+			// and remote groups.
+			groups = append(groups, info.Group{Name: strings.TrimPrefix(slug, localGroupPrefix)})
+			continue
+		}
+
+		name := strings.ToLower(t.Organization.Login) + "-" + slug
+		groups = append(groups, info.Group{Name: name, UGID: strconv.FormatInt(t.ID, 10)})
+	}
+
+	return groups, nil
+}
+
+// IsTokenExpiredError returns true if the reason for the error is that the refresh token is expired.
+func (Provider) IsTokenExpiredError(err *oauth2.RetrieveError) bool {
+	return err.ErrorCode == "bad_refresh_token"
+}
+
+// apiClient is a minimal client for the GitHub REST API.
+type apiClient struct {
+	ctx     context.Context
+	client  *http.Client
+	baseURL string
+}
+
+// newAPIClient returns a client for the API of GitHub, or of the GitHub Enterprise Server at issuerURL, which
+// authenticates with the token. The HTTP client of ctx is used if set via oauth2.HTTPClient.
+func newAPIClient(ctx context.Context, issuerURL string, token *oauth2.Token) apiClient {
+	return apiClient{
+		ctx:     ctx,
+		client:  oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)),
+		baseURL: apiURL(issuerURL),
+	}
+}
+
+// apiURL returns the URL of the REST API for the issuer URL. GitHub Enterprise Server serves the API under /api/v3.
+func apiURL(issuerURL string) string {
+	base := strings.TrimSuffix(issuerURL, "/")
+	if u, err := url.Parse(base); err == nil && strings.EqualFold(u.Host, "github.com") {
+		return "https://api.github.com"
+	}
+	return base + "/api/v3"
+}
+
+// get fetches the API path and decodes the JSON response into v.
+func (c apiClient) get(path string, v any) error {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("could not create request for %s: %v", path, err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not fetch %s from the GitHub API: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("could not fetch %s from the GitHub API: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode %s from the GitHub API: %v", path, err)
+	}
+	return nil
+}
+
+// getAll fetches all pages of the paginated API path and calls handlePage with each of them. handlePage returns the
+// number of items in the page, and no more pages are fetched once a page is not full.
+func (c apiClient) getAll(path string, handlePage func(page json.RawMessage) (int, error)) error {
+	const perPage = 100
+	for i := 1; i <= maxPages; i++ {
+		var page json.RawMessage
+		if err := c.get(fmt.Sprintf("%s?per_page=%d&page=%d", path, perPage, i), &page); err != nil {
+			return err
+		}
+		n, err := handlePage(page)
+		if err != nil {
+			return fmt.Errorf("could not decode %s from the GitHub API: %v", path, err)
+		}
+		if n < perPage {
+			return nil
+		}
+	}
+	log.Warningf(context.Background(), "The GitHub API returned more than %d pages for %s, ignoring the remaining ones", maxPages, path)
+	return nil
+}
@@ -0,0 +1,223 @@
+package github_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/github"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestAdditionalScopes(t *testing.T) {
+	t.Parallel()
+
+	p := github.New()
+
+	require.Equal(t, []string{"read:user", "user:email", "read:org"}, p.AdditionalScopes(), "GitHub provider should request the scopes to read the user, emails and orgs")
+}
+
+func TestEndpoint(t *testing.T) {
+	t.Parallel()
+
+	p := github.New()
+
+	want := oauth2.Endpoint{
+		AuthURL:       "https://github.example.com/login/oauth/authorize",
+		TokenURL:      "https://github.example.com/login/oauth/access_token",
+		DeviceAuthURL: "https://github.example.com/login/device/code",
+	}
+	require.Equal(t, want, p.Endpoint("https://github.example.com/"), "Endpoint should return the endpoints of the issuer")
+}
+
+func TestUserClaims(t *testing.T) {
+	t.Parallel()
+
+	defaultEmails := []map[string]any{
+		{"email": "other@example.com", "primary": false, "verified": true},
+		{"email": "User@Example.com", "primary": true, "verified": true},
+	}
+
+	tests := map[string]struct {
+		user   map[string]any
+		emails []map[string]any
+		orgs   int
+
+		wantErr bool
+	}{
+		"Successfully_get_user_claims":                {},
+		"Successfully_get_user_claims_without_orgs":   {orgs: -1},
+		"Successfully_get_user_claims_with_many_orgs": {orgs: 150},
+
+		"Error_if_user_has_no_ID":                  {user: map[string]any{"login": "user"}, wantErr: true},
+		"Error_if_primary_email_is_not_verified":   {emails: []map[string]any{{"email": "user@example.com", "primary": true, "verified": false}}, wantErr: true},
+		"Error_if_user_has_no_primary_email":       {emails: []map[string]any{{"email": "user@example.com", "primary": false, "verified": true}}, wantErr: true},
+		"Error_if_the_API_returns_an_error_status": {user: map[string]any{"error": true}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.user == nil {
+				tc.user = map[string]any{"id": 42, "login": "User", "name": "The User"}
+			}
+			if tc.emails == nil {
+				tc.emails = defaultEmails
+			}
+			var orgs []map[string]any
+			for i := range tc.orgs {
+				orgs = append(orgs, map[string]any{"login": fmt.Sprintf("org-%d", i)})
+			}
+			if tc.orgs == 0 {
+				orgs = []map[string]any{{"login": "my-org"}}
+			}
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := tc.user["error"]; ok {
+					http.Error(w, "Bad credentials", http.StatusUnauthorized)
+					return
+				}
+				writeJSON(t, w, tc.user)
+			})
+			mux.HandleFunc("/api/v3/user/emails", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, page(t, r, tc.emails))
+			})
+			mux.HandleFunc("/api/v3/user/orgs", func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, page(t, r, orgs))
+			})
+			server := newAPIServer(t, mux)
+
+			claimer, err := github.New().UserClaims(context.Background(), server.URL, &oauth2.Token{AccessToken: "access-token"})
+			if tc.wantErr {
+				require.Error(t, err, "UserClaims should return an error")
+				return
+			}
+			require.NoError(t, err, "UserClaims should not return an error")
+
+			var claims struct {
+				Sub               string   `json:"sub"`
+				Email             string   `json:"email"`
+				EmailVerified     bool     `json:"email_verified"`
+				Name              string   `json:"name"`
+				PreferredUsername string   `json:"preferred_username"`
+				Orgs              []string `json:"orgs"`
+			}
+			err = claimer.Claims(&claims)
+			require.NoError(t, err, "Claims should not return an error")
+
+			require.Equal(t, "42", claims.Sub, "Sub should be the ID of the user")
+			require.Equal(t, "User@Example.com", claims.Email, "Email should be the primary verified email")
+			require.True(t, claims.EmailVerified, "Email should be verified")
+			require.Equal(t, "The User", claims.Name, "Name should be the name of the user")
+			require.Equal(t, "User", claims.PreferredUsername, "PreferredUsername should be the login of the user")
+
+			var wantOrgs []string
+			for _, o := range orgs {
+				wantOrgs = append(wantOrgs, o["login"].(string))
+			}
+			require.Equal(t, wantOrgs, claims.Orgs, "Orgs should contain all the organizations of the user")
+		})
+	}
+}
+
+func TestGetGroups(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		teams      []map[string]any
+		errorCode  int
+		wantGroups []info.Group
+		wantErr    bool
+	}{
+		"Successfully_get_groups": {
+			teams: []map[string]any{
+				{"id": 1, "slug": "developers", "organization": map[string]any{"login": "My-Org"}},
+				{"id": 2, "slug": "linux-sudo", "organization": map[string]any{"login": "my-org"}},
+			},
+			wantGroups: []info.Group{
+				{Name: "my-org-developers", UGID: "1"},
+				{Name: "sudo"},
+			},
+		},
+		"Successfully_get_groups_without_teams": {},
+
+		"Error_if_the_API_returns_an_error_status": {errorCode: http.StatusForbidden, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/v3/user/teams", func(w http.ResponseWriter, r *http.Request) {
+				if tc.errorCode != 0 {
+					http.Error(w, "Forbidden", tc.errorCode)
+					return
+				}
+				writeJSON(t, w, page(t, r, tc.teams))
+			})
+			server := newAPIServer(t, mux)
+
+			groups, err := github.New().GetGroups(context.Background(), "", server.URL, &oauth2.Token{AccessToken: "access-token"}, nil, nil)
+			if tc.wantErr {
+				require.Error(t, err, "GetGroups should return an error")
+				return
+			}
+			require.NoError(t, err, "GetGroups should not return an error")
+			require.Equal(t, tc.wantGroups, groups, "GetGroups should return the teams of the user as groups")
+		})
+	}
+}
+
+func TestIsTokenExpiredError(t *testing.T) {
+	t.Parallel()
+
+	p := github.New()
+
+	require.True(t, p.IsTokenExpiredError(&oauth2.RetrieveError{ErrorCode: "bad_refresh_token"}), "bad_refresh_token should be an expired token error")
+	require.False(t, p.IsTokenExpiredError(&oauth2.RetrieveError{ErrorCode: "invalid_request"}), "invalid_request should not be an expired token error")
+}
+
+// newAPIServer starts a server which serves the API of a GitHub Enterprise Server and checks that the requests are
+// authenticated with the access token.
+func newAPIServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			http.Error(w, "Requires authentication", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// page returns the items of the page requested via the page and per_page query parameters.
+func page[T any](t *testing.T, r *http.Request, items []T) []T {
+	t.Helper()
+
+	pageNum, err := strconv.Atoi(r.URL.Query().Get("page"))
+	require.NoError(t, err, "Setup: page query parameter should be a number")
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	require.NoError(t, err, "Setup: per_page query parameter should be a number")
+
+	start := min((pageNum-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	return append([]T{}, items[start:end]...)
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	require.NoError(t, err, "Setup: encoding the response should not fail")
+}
@@ -3,7 +3,12 @@ package providers
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/canonical/authd/authd-oidc-brokers/internal/consts"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/genericprovider"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/github"
+	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/google"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
 	"github.com/coreos/go-oidc/v3/oidc"
 	"golang.org/x/oauth2"
@@ -44,3 +49,33 @@ type Provider interface {
 	VerifyUsername(requestedUsername, authenticatedUsername string) error
 	SupportsDeviceRegistration() bool
 }
+
+// OAuth2Provider is implemented by providers which don't support OpenID Connect, like GitHub.
+// For these providers, the broker uses the OAuth 2.0 endpoints returned by Endpoint instead of OIDC discovery, and
+// the claims returned by UserClaims instead of the ones of an ID token.
+type OAuth2Provider interface {
+	Provider
+
+	// Endpoint returns the OAuth 2.0 endpoints of the provider for the configured issuer URL.
+	Endpoint(issuerURL string) oauth2.Endpoint
+	// UserClaims returns the claims of the user the token was issued to, e.g. fetched from the API of the provider.
+	UserClaims(ctx context.Context, issuerURL string, token *oauth2.Token) (info.Claimer, error)
+}
+
+// ByName returns the provider implementation with the given name, which is either the provider of the broker (see
+// CurrentProvider) or one of the providers which don't need to be enabled at build time: "oidc", "google" and "github".
+func ByName(name string) (Provider, error) {
+	if name == consts.ProviderName {
+		return CurrentProvider(), nil
+	}
+
+	switch name {
+	case "oidc":
+		return genericprovider.New(), nil
+	case "google":
+		return google.New(), nil
+	case "github":
+		return github.New(), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q", name)
+}
@@ -0,0 +1,10 @@
+//go:build withgithub
+
+package providers
+
+import "github.com/canonical/authd/authd-oidc-brokers/internal/providers/github"
+
+// CurrentProvider returns a GitHub provider implementation.
+func CurrentProvider() Provider {
+	return github.New()
+}
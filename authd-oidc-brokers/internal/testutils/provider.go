@@ -4,8 +4,10 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"math/big"
@@ -109,6 +111,7 @@ func StartMockProviderServer(address string, tokenHandlerOpts *TokenHandlerOptio
 			"/device_auth":                      DefaultDeviceAuthHandler(),
 			"/token":                            TokenHandler(server.URL, tokenHandlerOpts),
 			"/keys":                             DefaultJWKHandler(),
+			"/revoke":                           DefaultRevocationHandler(),
 		},
 	}
 	for _, arg := range args {
@@ -135,6 +138,7 @@ func DefaultOpenIDHandler(serverURL string) EndpointHandler {
 			"authorization_endpoint": "%[1]s/auth",
 			"device_authorization_endpoint": "%[1]s/device_auth",
 			"token_endpoint": "%[1]s/token",
+			"revocation_endpoint": "%[1]s/revoke",
 			"jwks_uri": "%[1]s/keys",
 			"id_token_signing_alg_values_supported": ["RS256"]
 		}`, serverURL)
@@ -166,6 +170,26 @@ func OpenIDHandlerWithNoDeviceEndpoint(serverURL string) EndpointHandler {
 	}
 }
 
+// OpenIDHandlerWithNoRevocationEndpoint returns a handler that returns an OIDC configuration without revocation endpoint.
+func OpenIDHandlerWithNoRevocationEndpoint(serverURL string) EndpointHandler {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		wellKnown := fmt.Sprintf(`{
+			"issuer": "%[1]s",
+			"authorization_endpoint": "%[1]s/auth",
+			"device_authorization_endpoint": "%[1]s/device_auth",
+			"token_endpoint": "%[1]s/token",
+			"jwks_uri": "%[1]s/keys",
+			"id_token_signing_alg_values_supported": ["RS256"]
+		}`, serverURL)
+
+		w.Header().Add("Content-Type", "application/json")
+		_, err := w.Write([]byte(wellKnown))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
 // DefaultDeviceAuthHandler returns a handler that returns a default device auth response.
 func DefaultDeviceAuthHandler() EndpointHandler {
 	return func(w http.ResponseWriter, _ *http.Request) {
@@ -191,10 +215,31 @@ type TokenHandlerOptions struct {
 	// will be added to the token, and then that element will be removed from
 	// the list.
 	IDTokenClaims []map[string]interface{}
+	// CodeChallenge is the S256 PKCE code challenge of the authorization request. If set, authorization code grants
+	// are rejected if their code verifier does not match it.
+	CodeChallenge string
 }
 
 var idTokenClaimsMutex sync.Mutex
 
+// AddIDTokenClaims adds custom claims to be added to the next ID token which does not have custom claims yet. It can
+// be called while the handler is serving requests.
+func (o *TokenHandlerOptions) AddIDTokenClaims(claims map[string]interface{}) {
+	idTokenClaimsMutex.Lock()
+	defer idTokenClaimsMutex.Unlock()
+
+	o.IDTokenClaims = append(o.IDTokenClaims, claims)
+}
+
+// SetCodeChallenge sets the PKCE code challenge which the code verifier of the authorization code grants must match. It
+// can be called while the handler is serving requests.
+func (o *TokenHandlerOptions) SetCodeChallenge(challenge string) {
+	idTokenClaimsMutex.Lock()
+	defer idTokenClaimsMutex.Unlock()
+
+	o.CodeChallenge = challenge
+}
+
 // TokenHandler returns a handler that returns a default token response.
 func TokenHandler(serverURL string, opts *TokenHandlerOptions) EndpointHandler {
 	if opts == nil {
@@ -221,6 +266,19 @@ func TokenHandler(serverURL string, opts *TokenHandlerOptions) EndpointHandler {
 			return
 		}
 
+		idTokenClaimsMutex.Lock()
+		codeChallenge := opts.CodeChallenge
+		idTokenClaimsMutex.Unlock()
+		if r.FormValue("grant_type") == "authorization_code" && codeChallenge != "" {
+			sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+			if base64.RawURLEncoding.EncodeToString(sum[:]) != codeChallenge {
+				w.Header().Add("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "invalid_grant", "error_description": "PKCE verification failed"}`))
+				return
+			}
+		}
+
 		// Mimics user going through auth process
 		time.Sleep(2 * time.Second)
 
@@ -294,6 +352,17 @@ func DefaultJWKHandler() EndpointHandler {
 	}
 }
 
+// DefaultRevocationHandler returns a handler that accepts all token revocation requests.
+func DefaultRevocationHandler() EndpointHandler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
 // UnavailableHandler returns a handler that returns a 503 Service Unavailable response.
 func UnavailableHandler() EndpointHandler {
 	return func(w http.ResponseWriter, _ *http.Request) {
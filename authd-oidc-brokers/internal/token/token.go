@@ -3,9 +3,12 @@ package token
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
@@ -22,18 +25,52 @@ type AuthCachedInfo struct {
 	DeviceRegistrationData []byte
 	DeviceIsDisabled       bool
 	UserIsDisabled         bool
+	// ObtainedAt is the time when the token was obtained from the provider. It's zero for tokens which were cached
+	// before this field was introduced.
+	ObtainedAt time.Time
+	// LoggedInAt is the time when the user last authenticated interactively with the provider (e.g. via device
+	// authentication). Unlike ObtainedAt, it's not updated when the token is refreshed. It's zero for tokens which
+	// were cached before this field was introduced.
+	LoggedInAt time.Time
 }
 
 // NewAuthCachedInfo creates a new AuthCachedInfo. It sets the provided token and rawIDToken and the provider-specific
 // extra fields which should be stored persistently.
+// The login time is set to the current time: it must be reset to the one of the previous token when the token is
+// obtained by refreshing it.
 func NewAuthCachedInfo(token *oauth2.Token, rawIDToken string, provider providers.Provider) *AuthCachedInfo {
+	now := time.Now()
 	return &AuthCachedInfo{
 		Token:       token,
 		RawIDToken:  rawIDToken,
 		ExtraFields: provider.GetExtraFields(token),
+		ObtainedAt:  now,
+		LoggedInAt:  now,
 	}
 }
 
+// IsStale returns true if the token was obtained from the provider longer than ttl ago.
+// Tokens are never stale if ttl is zero or if it's unknown when they were obtained.
+func (authInfo *AuthCachedInfo) IsStale(ttl time.Duration) bool {
+	if ttl <= 0 || authInfo.ObtainedAt.IsZero() {
+		return false
+	}
+	return time.Since(authInfo.ObtainedAt) > ttl
+}
+
+// LoginExpired returns true if the user authenticated interactively with the provider longer than lifetime ago.
+// Logins never expire if lifetime is zero. If it's unknown when the user authenticated, the login is considered
+// expired, so that the lifetime is enforced from the next interactive authentication.
+func (authInfo *AuthCachedInfo) LoginExpired(lifetime time.Duration) bool {
+	if lifetime <= 0 {
+		return false
+	}
+	if authInfo.LoggedInAt.IsZero() {
+		return true
+	}
+	return time.Since(authInfo.LoggedInAt) > lifetime
+}
+
 // CacheAuthInfo saves the token to the given path.
 func CacheAuthInfo(path string, token *AuthCachedInfo) (err error) {
 	jsonData, err := json.Marshal(token)
@@ -46,10 +83,45 @@ func CacheAuthInfo(path string, token *AuthCachedInfo) (err error) {
 		return fmt.Errorf("could not create token directory: %v", err)
 	}
 
-	if err = os.WriteFile(path, jsonData, 0600); err != nil {
+	// Write the token to a temporary file which is then renamed, so that the cached token is never left truncated.
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
 		return fmt.Errorf("could not save token: %v", err)
 	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
 
+	// CreateTemp creates the file with mode 0600, but we make sure that it stays private regardless.
+	if err = f.Chmod(0600); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not save token: %v", err)
+	}
+	if _, err = f.Write(jsonData); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not save token: %v", err)
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not save token: %v", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("could not save token: %v", err)
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("could not save token: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveAuthInfo removes the cached token at the given path. It's not an error if there is no cached token.
+func RemoveAuthInfo(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not remove token: %v", err)
+	}
 	return nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/canonical/authd/authd-oidc-brokers/internal/providers/info"
 	"github.com/canonical/authd/authd-oidc-brokers/internal/token"
@@ -78,6 +79,14 @@ func TestCacheAuthInfo(t *testing.T) {
 				return
 			}
 			require.NoError(t, err, "CacheAuthInfo should not return an error")
+
+			fi, err := os.Stat(tokenPath)
+			require.NoError(t, err, "Stat should not return an error")
+			require.Equal(t, os.FileMode(0600), fi.Mode().Perm(), "Token file should only be accessible by the owner")
+
+			entries, err := os.ReadDir(filepath.Dir(tokenPath))
+			require.NoError(t, err, "ReadDir should not return an error")
+			require.Len(t, entries, 1, "No temporary file should be left behind")
 		})
 	}
 }
@@ -125,3 +134,81 @@ func TestLoadAuthInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveAuthInfo(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fileExists bool
+	}{
+		"Successfully_remove_token":                     {fileExists: true},
+		"Successfully_remove_token_that_does_not_exist": {},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tokenPath := filepath.Join(t.TempDir(), "token.json")
+			if tc.fileExists {
+				err := token.CacheAuthInfo(tokenPath, testToken)
+				require.NoError(t, err, "Setup: CacheAuthInfo should not return an error")
+			}
+
+			err := token.RemoveAuthInfo(tokenPath)
+			require.NoError(t, err, "RemoveAuthInfo should not return an error")
+			require.NoFileExists(t, tokenPath, "Token should have been removed")
+		})
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		obtainedAt time.Time
+		ttl        time.Duration
+
+		want bool
+	}{
+		"Token_is_not_stale_if_obtained_within_ttl":      {obtainedAt: time.Now().Add(-time.Minute), ttl: time.Hour},
+		"Token_is_not_stale_if_ttl_is_zero":              {obtainedAt: time.Now().Add(-1000 * time.Hour)},
+		"Token_is_not_stale_if_obtained_time_is_unknown": {ttl: time.Hour},
+		"Token_is_stale_if_obtained_longer_than_ttl_ago": {obtainedAt: time.Now().Add(-2 * time.Hour), ttl: time.Hour, want: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			authInfo := &token.AuthCachedInfo{ObtainedAt: tc.obtainedAt}
+			require.Equal(t, tc.want, authInfo.IsStale(tc.ttl), "IsStale should return the expected value")
+		})
+	}
+}
+
+func TestLoginExpired(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		loggedInAt time.Time
+		lifetime   time.Duration
+
+		want bool
+	}{
+		"Login_is_not_expired_if_within_lifetime":           {loggedInAt: time.Now().Add(-time.Minute), lifetime: time.Hour},
+		"Login_is_not_expired_if_lifetime_is_zero":          {loggedInAt: time.Now().Add(-1000 * time.Hour)},
+		"Login_is_not_expired_if_time_unknown_and_no_limit": {},
+		"Login_is_expired_if_longer_than_lifetime_ago":      {loggedInAt: time.Now().Add(-2 * time.Hour), lifetime: time.Hour, want: true},
+		"Login_is_expired_if_login_time_is_unknown":         {lifetime: time.Hour, want: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			authInfo := &token.AuthCachedInfo{LoggedInAt: tc.loggedInAt}
+			require.Equal(t, tc.want, authInfo.LoginExpired(tc.lifetime), "LoginExpired should return the expected value")
+		})
+	}
+}
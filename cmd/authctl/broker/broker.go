@@ -0,0 +1,19 @@
+// Package broker provides utilities for checking the brokers used by authd.
+package broker
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// BrokerCmd is a command to perform broker-related operations.
+var BrokerCmd = &cobra.Command{
+	Use:   "broker",
+	Short: "Commands related to brokers",
+	Args:  cobra.NoArgs,
+	RunE:  func(cmd *cobra.Command, args []string) error { return cmd.Usage() },
+}
+
+func init() {
+	BrokerCmd.AddCommand(testLoginCmd)
+	BrokerCmd.AddCommand(inspectTokenCmd)
+}
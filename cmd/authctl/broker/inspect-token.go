@@ -0,0 +1,205 @@
+package broker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/spf13/cobra"
+)
+
+// redactedValue replaces the values of the claims which are redacted.
+const redactedValue = "REDACTED"
+
+// unredactedClaims are the claims which don't identify the user, and which are therefore not redacted.
+var unredactedClaims = []string{"iss", "aud", "azp", "exp", "iat", "nbf", "auth_time", "acr", "amr"}
+
+var (
+	// tokenFile is the file of the ID token to inspect, set via the --token flag.
+	tokenFile string
+	// redact is set via the --redact flag to redact the values of the claims which identify the user.
+	redact bool
+)
+
+// inspectTokenCmd is a command to print the claims of the ID token of a user and the groups mapped from them.
+var inspectTokenCmd = &cobra.Command{
+	Use:   "inspect-token {<user> --provider <name> | --token <file>}",
+	Short: "Print the claims of the ID token of a user and the groups mapped from them",
+	Long: `Print the claims of the ID token of a user and the groups mapped from them.
+
+With --provider, the user logs in with the broker in a diagnostic session, like
+with "authctl broker test-login", and the command prints the claims of the ID
+token issued by the provider and the groups which the broker computed from
+them. Groups marked as local are groups of the system which the user is added
+to, the others are created by authd. Nothing is stored. This requires the
+command to be run as root and the broker to return the claims in diagnostic
+sessions.
+
+With --token, the claims of the ID token read from the file are printed. The
+token is decoded but not verified. Use "-" to read the token from the standard
+input.
+
+With --redact, the values of the claims which identify the user are replaced,
+so that the output can be shared. Only the issuer, audience, validity and
+authentication method claims are kept.`,
+	Example: `  # Log in as user "alice@example.com" with the "Google" broker and print the claims and groups of the user
+  authctl broker inspect-token alice@example.com --provider Google
+
+  # Print the claims of the ID token in file "id_token", redacting the values which identify the user
+  authctl broker inspect-token --token id_token --redact`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if tokenFile != "" {
+			if provider != "" {
+				return errors.New("--provider and --token can't be used together")
+			}
+			return cobra.NoArgs(cmd, args)
+		}
+		if provider == "" {
+			return errors.New("either --provider or --token must be set")
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: completion.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var claims map[string]any
+		var groups []types.GroupInfo
+		if tokenFile != "" {
+			var err error
+			claims, err = readTokenClaims(cmd.InOrStdin(), tokenFile)
+			if err != nil {
+				return err
+			}
+		} else {
+			c, err := client.NewPAMClient()
+			if err != nil {
+				return err
+			}
+
+			userInfo, err := testLogin(cmd.Context(), c, args[0])
+			if err != nil {
+				return err
+			}
+			if userInfo.Claims == nil {
+				return fmt.Errorf("broker %q did not return the claims of the user, it might not support inspecting them", provider)
+			}
+			claims, groups = userInfo.Claims, userInfo.Groups
+		}
+
+		if redact {
+			claims = redactClaims(claims)
+		}
+
+		if output.IsJSON() {
+			return output.PrintJSON(cmd.OutOrStdout(), inspectResult(claims, groups))
+		}
+		return printClaims(cmd.OutOrStdout(), claims, groups)
+	},
+}
+
+func init() {
+	inspectTokenCmd.Flags().StringVar(&provider, "provider", "", "name or ID of the broker to log in with")
+	inspectTokenCmd.Flags().StringVar(&tokenFile, "token", "", `file of the ID token to inspect, or "-" for the standard input`)
+	inspectTokenCmd.Flags().BoolVar(&redact, "redact", false, "redact the values of the claims which identify the user")
+	_ = inspectTokenCmd.RegisterFlagCompletionFunc("provider", completion.Brokers)
+	_ = inspectTokenCmd.MarkFlagFilename("token")
+}
+
+// readTokenClaims reads the ID token from the file, or from stdin if the file is "-", and returns its claims.
+func readTokenClaims(stdin io.Reader, file string) (map[string]any, error) {
+	var raw []byte
+	var err error
+	if file == "-" {
+		raw, err = io.ReadAll(stdin)
+	} else {
+		raw, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the token: %w", err)
+	}
+
+	// The token is a JWT: the claims are the second of its base64url encoded parts.
+	parts := strings.Split(strings.TrimSpace(string(raw)), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid token: not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: could not decode the claims: %w", err)
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid token: could not parse the claims: %w", err)
+	}
+	return claims, nil
+}
+
+// redactClaims returns a copy of the claims in which the values of the claims which identify the user are replaced.
+func redactClaims(claims map[string]any) map[string]any {
+	redacted := make(map[string]any, len(claims))
+	for name, value := range claims {
+		if !slices.Contains(unredactedClaims, name) {
+			value = redactedValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+// inspectResult returns the claims and the groups in the format in which they are printed as JSON.
+func inspectResult(claims map[string]any, groups []types.GroupInfo) any {
+	type group struct {
+		Name  string `json:"name"`
+		Local bool   `json:"local"`
+	}
+	result := struct {
+		Claims map[string]any `json:"claims"`
+		Groups []group        `json:"groups,omitempty"`
+	}{Claims: claims}
+	for _, g := range groups {
+		result.Groups = append(result.Groups, group{Name: g.Name, Local: g.UGID == ""})
+	}
+	return result
+}
+
+// printClaims prints the claims, sorted by name, and the groups.
+func printClaims(w io.Writer, claims map[string]any, groups []types.GroupInfo) error {
+	names := make([]string, 0, len(claims))
+	width := 0
+	for name := range claims {
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	slices.Sort(names)
+
+	fmt.Fprintln(w, "Claims:")
+	for _, name := range names {
+		value, err := json.Marshal(claims[name])
+		if err != nil {
+			return fmt.Errorf("failed to marshal claim %q: %w", name, err)
+		}
+		fmt.Fprintf(w, "  %-*s %s\n", width+1, name+":", value)
+	}
+
+	if groups == nil {
+		return nil
+	}
+	fmt.Fprintln(w, "Groups:")
+	for _, g := range groups {
+		if g.UGID == "" {
+			fmt.Fprintf(w, "  %s (local)\n", g.Name)
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", g.Name)
+	}
+	return nil
+}
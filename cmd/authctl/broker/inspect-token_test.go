@@ -0,0 +1,89 @@
+package broker_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectTokenCommand(t *testing.T) {
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	err := os.Setenv("AUTHD_SOCKET", daemonSocket)
+	require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
+
+	idToken := filepath.Join("testdata", "id_token")
+
+	tests := map[string]struct {
+		args  []string
+		stdin string
+
+		expectedExitCode int
+	}{
+		"Print_claims_of_token": {
+			args:             []string{"inspect-token", "--token", idToken},
+			expectedExitCode: 0,
+		},
+		"Print_claims_of_token_as_JSON": {
+			args:             []string{"--output", "json", "inspect-token", "--token", idToken},
+			expectedExitCode: 0,
+		},
+		"Print_redacted_claims_of_token": {
+			args:             []string{"inspect-token", "--token", idToken, "--redact"},
+			expectedExitCode: 0,
+		},
+		"Print_claims_of_token_read_from_stdin": {
+			args:             []string{"inspect-token", "--token", "-"},
+			stdin:            "header.eyJzdWIiOiJ1c2VyMSJ9.signature\n",
+			expectedExitCode: 0,
+		},
+
+		"Error_when_neither_provider_nor_token_is_set": {
+			args:             []string{"inspect-token", "user1"},
+			expectedExitCode: 1,
+		},
+		"Error_when_provider_and_token_are_set": {
+			args:             []string{"inspect-token", "--provider", "ExampleBroker", "--token", idToken},
+			expectedExitCode: 1,
+		},
+		"Error_when_user_is_set_with_token": {
+			args:             []string{"inspect-token", "user1", "--token", idToken},
+			expectedExitCode: 1,
+		},
+		"Error_when_token_file_does_not_exist": {
+			args:             []string{"inspect-token", "--token", "/non-existent"},
+			expectedExitCode: 1,
+		},
+		"Error_when_token_is_not_a_JWT": {
+			args:             []string{"inspect-token", "--token", "-"},
+			stdin:            "not a token",
+			expectedExitCode: 1,
+		},
+		"Error_when_token_claims_are_invalid": {
+			args:             []string{"inspect-token", "--token", "-"},
+			stdin:            "header.bm90IGpzb24.signature",
+			expectedExitCode: 1,
+		},
+		"Error_when_broker_does_not_return_claims": {
+			args:             []string{"inspect-token", "user-integration-inspect-token", "--provider", "ExampleBroker"},
+			expectedExitCode: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"broker"}, tc.args...)...)
+			cmd.Stdin = strings.NewReader(tc.stdin)
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/brokers/auth"
+	"github.com/canonical/authd/internal/brokers/layouts"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/canonical/authd/internal/users/types"
+	"github.com/spf13/cobra"
+)
+
+// maxTestLoginSteps is the maximum number of authentication steps of a test login, so that a broker which keeps
+// asking for another step doesn't make the command loop forever.
+const maxTestLoginSteps = 5
+
+// provider is the name or ID of the broker to test, set via the --provider flag.
+var provider string
+
+// testLoginCmd is a command to check that a user can log in with a broker, without provisioning the user.
+var testLoginCmd = &cobra.Command{
+	Use:   "test-login <user> --provider <name>",
+	Short: "Check that a user can log in with a broker, without provisioning the user",
+	Long: `Check that a user can log in with a broker, without provisioning the user.
+
+The broker is driven through a full authentication in a diagnostic session:
+authd connects to the provider, the command prints the URL and code used to log
+in with the device authentication flow, and waits for the login to complete.
+Once the provider has issued the tokens, the claims of the user and the groups
+mapped from them are printed, so that the configuration of the scopes and claim
+mappings of the broker can be checked.
+
+Each step is printed as it runs, and the step which failed is reported in the
+error. Nothing is stored: the user is not added to the database and the tokens
+are not cached by the broker. The command must be run as root.`,
+	Example: `  # Check that user "alice@example.com" can log in with the "Google" broker
+  authctl broker test-login alice@example.com --provider Google`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := client.NewPAMClient()
+		if err != nil {
+			return err
+		}
+
+		userInfo, err := testLogin(cmd.Context(), c, args[0])
+		if err != nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			return output.PrintJSON(cmd.OutOrStdout(), userInfo)
+		}
+		printUserInfo(cmd.OutOrStdout(), userInfo)
+		return nil
+	},
+}
+
+func init() {
+	testLoginCmd.Flags().StringVar(&provider, "provider", "", "name or ID of the broker to test")
+	_ = testLoginCmd.MarkFlagRequired("provider")
+	_ = testLoginCmd.RegisterFlagCompletionFunc("provider", completion.Brokers)
+}
+
+// testLogin authenticates the user with the broker set via the --provider flag in a diagnostic session, and returns
+// the user info returned by the broker.
+func testLogin(parent context.Context, c authd.PAMClient, username string) (*types.UserInfo, error) {
+	log.Infof("Connecting to the provider of broker %q...", provider)
+	brokerID, err := findBroker(parent, c)
+	if err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+
+	ctx, cancel := client.Context(parent)
+	defer cancel()
+	sbResp, err := c.SelectBroker(ctx, &authd.SBRequest{
+		BrokerId: brokerID,
+		Username: username,
+		Lang:     os.Getenv("LANG"),
+		Mode:     authd.SessionMode_DIAGNOSTIC,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	sessionID := sbResp.GetSessionId()
+	defer func() {
+		// The session must be ended even if the context of the command was cancelled.
+		ctx, cancel := client.Context(context.Background())
+		defer cancel()
+		if _, err := c.EndSession(ctx, &authd.ESRequest{SessionId: sessionID}); err != nil {
+			log.Debugf("Could not end session %q: %v", sessionID, err)
+		}
+	}()
+
+	for range maxTestLoginSteps {
+		access, msg, err := authenticate(parent, c, sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch access {
+		case auth.Granted:
+			log.Info("Extracting the claims of the user...")
+			var userInfo types.UserInfo
+			if err := json.Unmarshal([]byte(msg), &userInfo); err != nil {
+				return nil, fmt.Errorf("claim extraction failed: invalid user info returned by authd: %w", err)
+			}
+			log.Infof("Mapped %d group(s) from the claims of the user.", len(userInfo.Groups))
+			return &userInfo, nil
+		case auth.Next:
+			log.Info("The broker requires another authentication step.")
+			continue
+		default:
+			if m := messageFromData(msg); m != "" {
+				return nil, fmt.Errorf("token exchange failed: access %s: %s", access, m)
+			}
+			return nil, fmt.Errorf("token exchange failed: access %s", access)
+		}
+	}
+
+	return nil, fmt.Errorf("token exchange failed: the broker required more than %d authentication steps, "+
+		"it might not support diagnostic sessions", maxTestLoginSteps)
+}
+
+// findBroker returns the ID of the broker set via the --provider flag, which is matched with the name of the broker,
+// case-insensitively, or with its ID.
+func findBroker(parent context.Context, c authd.PAMClient) (string, error) {
+	ctx, cancel := client.Context(parent)
+	defer cancel()
+
+	resp, err := c.AvailableBrokers(ctx, &authd.Empty{})
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, b := range resp.GetBrokersInfos() {
+		if strings.EqualFold(b.GetName(), provider) || b.GetId() == provider {
+			return b.GetId(), nil
+		}
+		names = append(names, fmt.Sprintf("%q", b.GetName()))
+	}
+	return "", fmt.Errorf("no broker named %q, available brokers: %s", provider, strings.Join(names, ", "))
+}
+
+// authenticate runs an authentication step of the session with the first authentication mode offered by the broker,
+// and returns the access and the message returned by authd.
+func authenticate(parent context.Context, c authd.PAMClient, sessionID string) (access, msg string, err error) {
+	ctx, cancel := client.Context(parent)
+	defer cancel()
+
+	required, optional := layouts.Required, layouts.Optional
+	rendersQrCode := false
+	gamResp, err := c.GetAuthenticationModes(ctx, &authd.GAMRequest{
+		SessionId: sessionID,
+		SupportedUiLayouts: []*authd.UILayout{
+			{
+				Type:          layouts.QrCode,
+				Content:       &required,
+				Code:          &optional,
+				Wait:          &layouts.RequiredWithBooleans,
+				Label:         &optional,
+				Button:        &optional,
+				RendersQrcode: &rendersQrCode,
+			},
+		},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("discovery failed: %w", err)
+	}
+	if len(gamResp.GetAuthenticationModes()) == 0 {
+		return "", "", errors.New("discovery failed: the broker offers no device authentication mode")
+	}
+	mode := gamResp.GetAuthenticationModes()[0]
+
+	samResp, err := c.SelectAuthenticationMode(ctx, &authd.SAMRequest{
+		SessionId:            sessionID,
+		AuthenticationModeId: mode.GetId(),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("could not get the authentication URL: %w", err)
+	}
+	layout := samResp.GetUiLayoutInfo()
+	if layout.GetType() != layouts.QrCode {
+		return "", "", fmt.Errorf("could not get the authentication URL: unsupported layout %q", layout.GetType())
+	}
+
+	log.Infof("%s: %s", mode.GetLabel(), layout.GetContent())
+	if layout.GetCode() != "" {
+		log.Infof("Code: %s", layout.GetCode())
+	}
+	log.Info("Waiting for the login to complete...")
+
+	// Logging in with the provider can take much longer than the timeout of the requests to the daemon.
+	waitCtx, waitCancel := context.WithCancel(parent)
+	defer waitCancel()
+	iaResp, err := c.IsAuthenticated(waitCtx, &authd.IARequest{
+		SessionId: sessionID,
+		AuthenticationData: &authd.IARequest_AuthenticationData{
+			Item: &authd.IARequest_AuthenticationData_Wait{Wait: layouts.True},
+		},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("token exchange failed: %w", err)
+	}
+	return iaResp.GetAccess(), iaResp.GetMsg(), nil
+}
+
+// messageFromData returns the message of the JSON data returned by the broker, or an empty string if it has none.
+func messageFromData(data string) string {
+	var v struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return ""
+	}
+	return v.Message
+}
+
+// printUserInfo prints the claims of the user and the groups mapped from them.
+func printUserInfo(w io.Writer, u *types.UserInfo) {
+	printField := func(name, value string) {
+		const width = len("Groups: ") + 1
+		fmt.Fprintf(w, "%-*s%s\n", width, name+":", value)
+	}
+	printField("Name", u.Name)
+	printField("Gecos", u.Gecos)
+	printField("Home", u.Dir)
+	printField("Shell", u.Shell)
+
+	var groups []string
+	for _, g := range u.Groups {
+		if g.UGID == "" {
+			groups = append(groups, g.Name+" (local)")
+			continue
+		}
+		groups = append(groups, g.Name)
+	}
+	printField("Groups", strings.Join(groups, ", "))
+}
@@ -0,0 +1,61 @@
+package group
+
+import (
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// createCmd is a command to create a group managed by authd.
+var createCmd = &cobra.Command{
+	Use:   "create <group>",
+	Short: "Create a group managed by authd",
+	Long: `Create a group managed by authd.
+
+The group name must not already be used by another group, either managed by
+authd or present on the system. If --gid is not given, a GID is generated in
+the range configured for authd. Otherwise the given GID is used, which must be
+unique and in that range. The command must be run as root.`,
+	Example: `  # Create group "staff" with a generated GID
+  authctl group create staff
+
+  # Create group "staff" with GID 30000
+  authctl group create --gid 30000 staff`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.CreateGroup(ctx, &authd.CreateGroupRequest{
+			Name: name,
+			Gid:  createGID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			return output.PrintJSON(cmd.OutOrStdout(), resp)
+		}
+
+		log.Infof("Group '%s' created with GID %d.", name, resp.Gid)
+		return nil
+	},
+}
+
+var createGID uint32
+
+func init() {
+	createCmd.Flags().Uint32Var(&createGID, "gid", 0, "GID of the new group (generated if not set)")
+}
@@ -0,0 +1,61 @@
+package group
+
+import (
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// deleteCmd is a command to delete a group managed by authd.
+var deleteCmd = &cobra.Command{
+	Use:   "delete <group>",
+	Short: "Delete a group managed by authd",
+	Long: `Delete a group managed by authd.
+
+A group that still has members is not deleted, unless --force is given. The
+primary group of a user is never deleted. The command must be run as root.
+
+Files owned by the GID of the deleted group are not updated and must be changed
+manually. Note that those files may become accessible to a different group that
+is later assigned that GID.`,
+	Example: `  # Delete group "staff"
+  authctl group delete staff
+
+  # Delete group "staff" even if it still has members
+  authctl group delete --force staff`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Groups,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.DeleteGroup(ctx, &authd.DeleteGroupRequest{
+			Name:  name,
+			Force: deleteForce,
+		})
+		if err != nil {
+			return err
+		}
+
+		if !output.IsJSON() {
+			log.Infof("Group '%s' deleted.", name)
+		}
+		return output.PrintStatus(cmd.OutOrStdout(), name, "deleted")
+	},
+}
+
+var deleteForce bool
+
+func init() {
+	deleteCmd.Flags().BoolVar(&deleteForce, "force", false, "delete the group even if it still has members")
+}
@@ -0,0 +1,58 @@
+package group_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"google.golang.org/grpc/codes"
+)
+
+func TestDeleteCommand(t *testing.T) {
+	// We can't run these tests in parallel because the daemon with the example
+	// broker which we're using here uses userslocking.Z_ForTests_OverrideLocking()
+	// which makes userslocking.WriteLock() return an error immediately when the lock
+	// is already held - unlike the normal behavior which tries to acquire the lock
+	// for 15 seconds before returning an error.
+
+	tests := map[string]struct {
+		args             []string
+		authdUnavailable bool
+
+		expectedExitCode int
+	}{
+		"Delete_group_without_members_success":        {args: []string{"delete", "groupwithoutmembers"}},
+		"Delete_group_with_members_and_force_success": {args: []string{"delete", "--force", "groupwithmembers"}},
+		"Delete_group_success_with_JSON_output":       {args: []string{"delete", "--output", "json", "groupwithoutmembers"}},
+
+		"Error_when_group_has_members":                {args: []string{"delete", "groupwithmembers"}, expectedExitCode: int(codes.Unknown)},
+		"Error_when_group_is_primary_group_of_a_user": {args: []string{"delete", "--force", "group1"}, expectedExitCode: int(codes.Unknown)},
+		"Error_when_group_does_not_exist":             {args: []string{"delete", "invalidgroup"}, expectedExitCode: int(codes.NotFound)},
+		"Error_when_no_group_is_given":                {args: []string{"delete"}, expectedExitCode: 1},
+		"Error_when_authd_is_unavailable": {
+			args:             []string{"delete", "groupwithoutmembers"},
+			authdUnavailable: true,
+			expectedExitCode: int(codes.Unavailable),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Each test case may delete a group, so we need a separate daemon for each of them.
+			daemonSocket := "/non-existent"
+			if !tc.authdUnavailable {
+				daemonSocket = testutils.StartAuthd(t, daemonPath,
+					testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+					testutils.WithPreviousDBState("groups_with_and_without_members"),
+					testutils.WithCurrentUserAsRoot,
+				)
+			}
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"group"}, tc.args...)...)
+			cmd.Env = append(cmd.Environ(), "AUTHD_SOCKET="+daemonSocket)
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
@@ -15,4 +15,7 @@ var GroupCmd = &cobra.Command{
 
 func init() {
 	GroupCmd.AddCommand(setGIDCmd)
+	GroupCmd.AddCommand(createCmd)
+	GroupCmd.AddCommand(deleteCmd)
+	GroupCmd.AddCommand(listCmd)
 }
@@ -0,0 +1,49 @@
+package group
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// listCmd is a command to list the groups managed by authd.
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the groups managed by authd",
+	Long:  `List the groups managed by authd, with their GID and number of members.`,
+	Example: `  # List all groups
+  authctl group list`,
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: completion.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.ListGroups(ctx, &authd.Empty{})
+		if err != nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			return output.PrintJSON(cmd.OutOrStdout(), resp)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tGID\tMEMBERS")
+		for _, g := range resp.Groups {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", g.Name, g.Gid, len(g.Members))
+		}
+
+		return w.Flush()
+	},
+}
@@ -1,7 +1,6 @@
 package group
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -48,12 +47,15 @@ different group that is later assigned that GID.`,
 			return fmt.Errorf("failed to parse GID %q: %w", gidStr, err)
 		}
 
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
 		client, err := client.NewUserServiceClient()
 		if err != nil {
 			return err
 		}
 
-		resp, err := client.SetGroupID(context.Background(), &authd.SetGroupIDRequest{
+		resp, err := client.SetGroupID(ctx, &authd.SetGroupIDRequest{
 			Name: name,
 			Id:   uint32(gid),
 			Lang: os.Getenv("LANG"),
@@ -2,34 +2,398 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"os"
-	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/canonical/authd/cmd/authctl/internal/log"
 	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
+const (
+	// DefaultTimeout is the default time to wait for the daemon to respond.
+	DefaultTimeout = 30 * time.Second
+	// HealthCheckTimeout is the maximum time to wait for the daemon to report that it's serving, before the first
+	// request is sent.
+	HealthCheckTimeout = 5 * time.Second
+)
+
+var (
+	// socket is the address of the authd daemon set via the --socket flag.
+	socket string
+	// timeout is the time to wait for the daemon to respond, set via the --timeout flag.
+	timeout = DefaultTimeout
+
+	// tlsCert and tlsKey are the paths of the client certificate and its key, set via the --tls-cert and --tls-key
+	// flags.
+	tlsCert, tlsKey string
+	// tlsCA is the path of the bundle of CA certificates used to verify the daemon, set via the --tls-ca flag.
+	tlsCA string
+)
+
+// socketValue implements pflag.Value for the --socket flag.
+type socketValue struct{}
+
+func (socketValue) String() string {
+	return socket
+}
+
+func (socketValue) Set(s string) error {
+	if _, err := dialTarget(s); err != nil {
+		return err
+	}
+	socket = s
+	return nil
+}
+
+func (socketValue) Type() string {
+	return "address"
+}
+
+// AddFlags adds the persistent --socket, --timeout and TLS flags to the given command.
+func AddFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().Var(socketValue{}, "socket",
+		"address of the authd daemon, either a unix socket path or a host:port (default $AUTHD_SOCKET or "+consts.DefaultSocketPath+")")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", DefaultTimeout, "time to wait for the daemon to respond, 0 to wait indefinitely")
+	cmd.PersistentFlags().StringVar(&tlsCert, "tls-cert", "", "client certificate file to authenticate to a daemon reached over TCP, requires --tls-key")
+	cmd.PersistentFlags().StringVar(&tlsKey, "tls-key", "", "key file of the client certificate")
+	cmd.PersistentFlags().StringVar(&tlsCA, "tls-ca", "", "CA certificates file to verify a daemon reached over TCP (default system CAs)")
+	cmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+	for _, name := range []string{"tls-cert", "tls-key", "tls-ca"} {
+		_ = cmd.MarkPersistentFlagFilename(name)
+	}
+}
+
+// Timeout returns the time to wait for the daemon to respond, or 0 if there is no limit.
+func Timeout() time.Duration {
+	return timeout
+}
+
+// Context returns a context for the requests to the daemon, which is cancelled when the timeout set via the
+// --timeout flag expires.
+func Context(parent context.Context) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+const (
+	// DefaultMaxRetries is the default number of times a request is retried if the daemon is unavailable.
+	DefaultMaxRetries = 3
+	// DefaultRetryBaseDelay is the default delay before the first retry. It doubles with each retry.
+	DefaultRetryBaseDelay = 100 * time.Millisecond
+)
+
+type options struct {
+	maxRetries     int
+	retryBaseDelay time.Duration
+	tlsCert        string
+	tlsKey         string
+	tlsCA          string
+}
+
+// Option is a function that allows changing some of the default behaviors of the client.
+type Option func(*options)
+
+// WithMaxRetries sets the number of times a request is retried if the daemon is unavailable.
+// 0 disables the retries.
+func WithMaxRetries(n int) Option {
+	return func(o *options) {
+		o.maxRetries = n
+	}
+}
+
+// WithRetryBaseDelay sets the delay before the first retry of a request. It doubles with each retry.
+func WithRetryBaseDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.retryBaseDelay = d
+	}
+}
+
+// WithClientCertificate sets the certificate and key files used to authenticate to the daemon with TLS.
+// It overrides the --tls-cert and --tls-key flags.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(o *options) {
+		o.tlsCert = certFile
+		o.tlsKey = keyFile
+	}
+}
+
+// WithCA sets the file of the CA certificates used to verify the daemon with TLS. It overrides the --tls-ca flag.
+func WithCA(caFile string) Option {
+	return func(o *options) {
+		o.tlsCA = caFile
+	}
+}
+
 // NewUserServiceClient creates and returns a new [authd.UserServiceClient].
-func NewUserServiceClient() (authd.UserServiceClient, error) {
-	authdSocket := os.Getenv("AUTHD_SOCKET")
+//
+// The daemon address is taken from the --socket flag, the AUTHD_SOCKET environment variable or
+// the default socket path, in that order.
+//
+// If a client certificate or a CA bundle is set, the connection to the daemon uses TLS, which is only supported if the
+// daemon is reached over TCP. Otherwise, the connection is not encrypted, which is only suitable for the local socket.
+//
+// Requests which fail because the daemon is unavailable, for example because it's restarting, are retried with
+// an exponential backoff, until the maximum number of retries is reached or the context of the request is done.
+// For streaming requests, only opening the stream is retried.
+//
+// Before the first request, the client checks once that the daemon is serving, with the gRPC health service. If it's
+// not, the request fails with codes.Unavailable and a message telling that authd is not running or not reachable,
+// unless the context of the request is done first.
+func NewUserServiceClient(args ...Option) (authd.UserServiceClient, error) {
+	conn, err := newConn(args...)
+	if err != nil {
+		return nil, err
+	}
+	return authd.NewUserServiceClient(conn), nil
+}
+
+// NewPAMClient creates and returns a new [authd.PAMClient], which is used to drive authentication sessions with the
+// brokers. It connects to the daemon like [NewUserServiceClient].
+func NewPAMClient(args ...Option) (authd.PAMClient, error) {
+	conn, err := newConn(args...)
+	if err != nil {
+		return nil, err
+	}
+	return authd.NewPAMClient(conn), nil
+}
+
+// newConn creates the connection to the daemon used by the clients.
+func newConn(args ...Option) (*grpc.ClientConn, error) {
+	opts := options{
+		maxRetries:     DefaultMaxRetries,
+		retryBaseDelay: DefaultRetryBaseDelay,
+		tlsCert:        tlsCert,
+		tlsKey:         tlsKey,
+		tlsCA:          tlsCA,
+	}
+	for _, f := range args {
+		f(&opts)
+	}
+
+	authdSocket := socket
 	if authdSocket == "" {
-		authdSocket = "unix://" + consts.DefaultSocketPath
+		authdSocket = os.Getenv("AUTHD_SOCKET")
+	}
+	if authdSocket == "" {
+		authdSocket = consts.DefaultSocketPath
 	}
 
-	// Check if the socket has a scheme, else default to "unix://"
-	schemeRegex := regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
-	if !schemeRegex.MatchString(authdSocket) {
-		authdSocket = "unix://" + authdSocket
+	target, err := dialTarget(authdSocket)
+	if err != nil {
+		return nil, err
 	}
 
-	conn, err := grpc.NewClient(authdSocket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	creds, err := transportCredentials(target, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &healthChecker{addr: authdSocket}
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(creds),
+		// Reconnect at the same pace as we retry, so that a retry is not failed immediately because the connection is
+		// still waiting for its own backoff to expire.
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  opts.retryBaseDelay,
+				Multiplier: 2,
+				Jitter:     backoff.DefaultConfig.Jitter,
+				MaxDelay:   backoff.DefaultConfig.MaxDelay,
+			},
+			MinConnectTimeout: 20 * time.Second,
+		}),
+		// The health check runs before the retry interceptors, so that it's retried like any other request.
+		grpc.WithChainUnaryInterceptor(
+			h.unaryInterceptor,
+			retryUnavailable(opts.maxRetries, opts.retryBaseDelay),
+		),
+		grpc.WithChainStreamInterceptor(
+			h.streamInterceptor,
+			retryUnavailableStream(opts.maxRetries, opts.retryBaseDelay),
+		),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to authd: %w", err)
 	}
 
-	client := authd.NewUserServiceClient(conn)
-	return client, nil
+	return conn, nil
+}
+
+// dialTarget returns the gRPC dial target for the given daemon address, which must be either a unix socket path,
+// optionally with a "unix://" scheme, or a host:port.
+func dialTarget(addr string) (string, error) {
+	if addr == "" {
+		return "", fmt.Errorf("invalid authd address %q: must be a unix socket path or a host:port", addr)
+	}
+
+	if strings.HasPrefix(addr, "unix:") {
+		return addr, nil
+	}
+	if !strings.Contains(addr, ":") {
+		return "unix://" + addr, nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return "", fmt.Errorf("invalid authd address %q: must be a unix socket path or a host:port", addr)
+	}
+	if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+		return "", fmt.Errorf("invalid authd address %q: invalid port %q", addr, port)
+	}
+
+	return addr, nil
+}
+
+// transportCredentials returns the credentials of the connection to target: TLS credentials if a client certificate
+// or a CA bundle is set in opts, insecure ones otherwise.
+func transportCredentials(target string, opts options) (credentials.TransportCredentials, error) {
+	if opts.tlsCert == "" && opts.tlsKey == "" && opts.tlsCA == "" {
+		return insecure.NewCredentials(), nil
+	}
+
+	if strings.HasPrefix(target, "unix:") {
+		return nil, errors.New("TLS can only be used when authd is reached over TCP, not with a unix socket")
+	}
+	if (opts.tlsCert == "") != (opts.tlsKey == "") {
+		return nil, errors.New("the client certificate and its key must be set together")
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(opts.tlsCert, opts.tlsKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	// Without a CA bundle, the daemon certificate is verified with the system CAs.
+	if opts.tlsCA != "" {
+		pem, err := os.ReadFile(opts.tlsCA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA certificates: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid CA certificate found in %q", opts.tlsCA)
+		}
+		config.RootCAs = pool
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// healthChecker checks once that the daemon is serving before the first request of a client is sent.
+type healthChecker struct {
+	addr string
+	once sync.Once
+	err  error
+}
+
+func (h *healthChecker) unaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	// The health check itself goes through this interceptor.
+	if method != healthgrpc.Health_Check_FullMethodName {
+		if err := h.check(ctx, cc); err != nil {
+			return err
+		}
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (h *healthChecker) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if err := h.check(ctx, cc); err != nil {
+		return nil, err
+	}
+	return streamer(ctx, desc, cc, method, opts...)
+}
+
+// check returns an error if the daemon is not serving. The daemon is only asked the first time, the result is
+// returned again on later calls.
+func (h *healthChecker) check(ctx context.Context, cc *grpc.ClientConn) error {
+	h.once.Do(func() {
+		checkCtx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+		defer cancel()
+
+		resp, err := healthgrpc.NewHealthClient(cc).Check(checkCtx, &healthgrpc.HealthCheckRequest{Service: consts.ServiceName})
+		if err == nil && resp.Status != healthgrpc.HealthCheckResponse_SERVING {
+			err = fmt.Errorf("service status is %s", resp.Status)
+		}
+		if err == nil {
+			return
+		}
+
+		log.Debugf("Health check of authd at %s failed: %v", h.addr, err)
+		// If the context of the request is done, the caller is told that it timed out or was cancelled instead.
+		if ctx.Err() != nil {
+			h.err = err
+			return
+		}
+		h.err = status.Errorf(codes.Unavailable, "authd is not running or not reachable at %s", h.addr)
+	})
+	return h.err
+}
+
+// retryUnavailable returns a unary interceptor which retries requests failing with codes.Unavailable, waiting
+// baseDelay before the first retry and doubling the delay with each retry.
+func retryUnavailable(maxRetries int, baseDelay time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return retry(ctx, maxRetries, baseDelay, func() error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// retryUnavailableStream returns a stream interceptor which retries opening streams failing with codes.Unavailable,
+// like retryUnavailable. Errors received once the stream is open are not retried, because some messages might
+// already have been handled by the caller.
+func retryUnavailableStream(maxRetries int, baseDelay time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var stream grpc.ClientStream
+		err := retry(ctx, maxRetries, baseDelay, func() (err error) {
+			stream, err = streamer(ctx, desc, cc, method, opts...)
+			return err
+		})
+		return stream, err
+	}
+}
+
+// retry calls f until it returns an error which is not codes.Unavailable or until maxRetries retries have been done,
+// waiting baseDelay before the first retry and doubling the delay with each retry.
+func retry(ctx context.Context, maxRetries int, baseDelay time.Duration, f func() error) error {
+	delay := baseDelay
+	for i := 0; ; i++ {
+		err := f()
+		if status.Code(err) != codes.Unavailable || i >= maxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			// Return the last error, which is more useful than the context error.
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
 }
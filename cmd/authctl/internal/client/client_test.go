@@ -0,0 +1,390 @@
+package client_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/internal/consts"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryWhenUnavailable(t *testing.T) {
+	tests := map[string]struct {
+		startDaemonAfter time.Duration
+		maxRetries       int
+		timeout          time.Duration
+		stream           bool
+
+		wantCode codes.Code
+	}{
+		"Succeeds_when_daemon_is_available":              {},
+		"Succeeds_when_daemon_becomes_available_in_time": {startDaemonAfter: 200 * time.Millisecond, maxRetries: 10},
+		"Stream_succeeds_when_daemon_becomes_available_in_time": {
+			startDaemonAfter: 200 * time.Millisecond, maxRetries: 10, stream: true,
+		},
+
+		"Error_when_retries_are_exhausted":        {startDaemonAfter: -1, maxRetries: 2, wantCode: codes.Unavailable},
+		"Error_when_retries_are_disabled":         {startDaemonAfter: -1, wantCode: codes.Unavailable},
+		"Error_when_context_is_done_before_retry": {startDaemonAfter: -1, maxRetries: 100, timeout: 200 * time.Millisecond, wantCode: codes.Unavailable},
+		"Error_when_stream_retries_are_exhausted": {startDaemonAfter: -1, maxRetries: 2, stream: true, wantCode: codes.Unavailable},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			socketPath := filepath.Join(t.TempDir(), "authd.sock")
+			t.Setenv("AUTHD_SOCKET", socketPath)
+
+			switch {
+			case tc.startDaemonAfter == 0:
+				startUserService(t, socketPath)
+			case tc.startDaemonAfter > 0:
+				timer := time.AfterFunc(tc.startDaemonAfter, func() { startUserService(t, socketPath) })
+				t.Cleanup(func() { timer.Stop() })
+			}
+
+			c, err := client.NewUserServiceClient(
+				client.WithMaxRetries(tc.maxRetries),
+				client.WithRetryBaseDelay(10*time.Millisecond),
+			)
+			require.NoError(t, err, "Setup: NewUserServiceClient should not fail")
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				t.Cleanup(cancel)
+			}
+
+			if tc.stream {
+				err = streamUsers(ctx, c)
+			} else {
+				_, err = c.ListUsers(ctx, &authd.Empty{})
+			}
+			if tc.wantCode == codes.OK {
+				require.NoError(t, err, "Listing users should succeed")
+				return
+			}
+			require.Equal(t, tc.wantCode, status.Code(err), "Listing users should fail with the expected code")
+		})
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	tests := map[string]struct {
+		healthStatus    healthgrpc.HealthCheckResponse_ServingStatus
+		noHealthService bool
+		noDaemon        bool
+		requests        int
+
+		wantErr bool
+	}{
+		"Succeeds_when_daemon_is_serving":                  {},
+		"Daemon_is_only_checked_once_for_several_requests": {requests: 3},
+
+		"Error_when_daemon_is_not_serving":          {healthStatus: healthgrpc.HealthCheckResponse_NOT_SERVING, wantErr: true},
+		"Error_when_daemon_has_no_health_service":   {noHealthService: true, wantErr: true},
+		"Error_when_daemon_is_not_running":          {noDaemon: true, wantErr: true},
+		"Error_is_returned_again_on_later_requests": {noDaemon: true, requests: 3, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.healthStatus == healthgrpc.HealthCheckResponse_UNKNOWN {
+				tc.healthStatus = healthgrpc.HealthCheckResponse_SERVING
+			}
+			if tc.requests == 0 {
+				tc.requests = 1
+			}
+
+			socketPath := filepath.Join(t.TempDir(), "authd.sock")
+			t.Setenv("AUTHD_SOCKET", socketPath)
+
+			hs := &countingHealthServer{status: tc.healthStatus}
+			if !tc.noDaemon {
+				l, err := net.Listen("unix", socketPath)
+				require.NoError(t, err, "Setup: could not listen on %q", socketPath)
+				server := grpc.NewServer()
+				authd.RegisterUserServiceServer(server, userService{})
+				if !tc.noHealthService {
+					healthgrpc.RegisterHealthServer(server, hs)
+				}
+				go func() { _ = server.Serve(l) }()
+				t.Cleanup(server.Stop)
+			}
+
+			c, err := client.NewUserServiceClient(client.WithMaxRetries(0))
+			require.NoError(t, err, "Setup: NewUserServiceClient should not fail")
+
+			for i := range tc.requests {
+				// Alternate unary and streaming requests, which are both checked.
+				if i%2 == 0 {
+					_, err = c.ListUsers(context.Background(), &authd.Empty{})
+				} else {
+					err = streamUsers(context.Background(), c)
+				}
+				if !tc.wantErr {
+					require.NoError(t, err, "Request %d should succeed", i)
+					continue
+				}
+				require.Equal(t, codes.Unavailable, status.Code(err), "Request %d should fail with codes.Unavailable", i)
+				require.Equal(t, fmt.Sprintf("authd is not running or not reachable at %s", socketPath), status.Convert(err).Message(),
+					"Request %d should fail with the expected message", i)
+			}
+
+			if !tc.noDaemon && !tc.noHealthService {
+				require.Equal(t, int32(1), hs.calls.Load(), "The health of the daemon should be checked exactly once")
+			}
+		})
+	}
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	otherCA := newTestCA(t, dir, "other-ca")
+	serverCert, serverKey := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	otherClientCert, otherClientKey := otherCA.issue(t, dir, "other-client", x509.ExtKeyUsageClientAuth)
+
+	invalidFile := filepath.Join(dir, "invalid")
+	err := os.WriteFile(invalidFile, []byte("not a certificate"), 0600)
+	require.NoError(t, err, "Setup: could not write invalid file")
+
+	tests := map[string]struct {
+		clientCert string
+		clientKey  string
+		caFile     string
+		unixSocket bool
+
+		wantErr  bool
+		wantCode codes.Code
+	}{
+		"Succeeds_with_client_certificate_and_CA": {clientCert: clientCert, clientKey: clientKey, caFile: ca.certFile},
+
+		"Error_when_certificate_is_given_without_key":   {clientCert: clientCert, caFile: ca.certFile, wantErr: true},
+		"Error_when_key_is_given_without_certificate":   {clientKey: clientKey, caFile: ca.certFile, wantErr: true},
+		"Error_when_TLS_is_used_with_a_unix_socket":     {clientCert: clientCert, clientKey: clientKey, caFile: ca.certFile, unixSocket: true, wantErr: true},
+		"Error_when_client_certificate_does_not_exist":  {clientCert: filepath.Join(dir, "nonexistent"), clientKey: clientKey, wantErr: true},
+		"Error_when_client_certificate_is_invalid":      {clientCert: invalidFile, clientKey: clientKey, wantErr: true},
+		"Error_when_CA_file_does_not_exist":             {caFile: filepath.Join(dir, "nonexistent"), wantErr: true},
+		"Error_when_CA_file_has_no_valid_certificate":   {caFile: invalidFile, wantErr: true},
+		"Error_when_daemon_is_not_signed_by_the_CA":     {clientCert: clientCert, clientKey: clientKey, caFile: otherCA.certFile, wantCode: codes.Unavailable},
+		"Error_when_client_certificate_is_not_accepted": {clientCert: otherClientCert, clientKey: otherClientKey, caFile: ca.certFile, wantCode: codes.Unavailable},
+		"Error_when_no_client_certificate_is_given":     {caFile: ca.certFile, wantCode: codes.Unavailable},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			addr := startTLSUserService(t, serverCert, serverKey, ca.certFile)
+			if tc.unixSocket {
+				addr = filepath.Join(t.TempDir(), "authd.sock")
+			}
+			t.Setenv("AUTHD_SOCKET", addr)
+
+			c, err := client.NewUserServiceClient(
+				client.WithMaxRetries(0),
+				client.WithClientCertificate(tc.clientCert, tc.clientKey),
+				client.WithCA(tc.caFile),
+			)
+			if tc.wantErr {
+				require.Error(t, err, "NewUserServiceClient should fail")
+				return
+			}
+			require.NoError(t, err, "NewUserServiceClient should not fail")
+
+			_, err = c.ListUsers(context.Background(), &authd.Empty{})
+			if tc.wantCode == codes.OK {
+				require.NoError(t, err, "Listing users should succeed")
+				return
+			}
+			require.Equal(t, tc.wantCode, status.Code(err), "Listing users should fail with the expected code")
+		})
+	}
+}
+
+type userService struct {
+	authd.UnimplementedUserServiceServer
+}
+
+func (userService) ListUsers(context.Context, *authd.Empty) (*authd.Users, error) {
+	return &authd.Users{}, nil
+}
+
+func (userService) StreamUsers(*authd.Empty, authd.UserService_StreamUsersServer) error {
+	return nil
+}
+
+// countingHealthServer reports a fixed status for the authd service and counts the health checks.
+type countingHealthServer struct {
+	healthgrpc.UnimplementedHealthServer
+	status healthgrpc.HealthCheckResponse_ServingStatus
+	calls  atomic.Int32
+}
+
+func (s *countingHealthServer) Check(_ context.Context, req *healthgrpc.HealthCheckRequest) (*healthgrpc.HealthCheckResponse, error) {
+	s.calls.Add(1)
+	if req.Service != consts.ServiceName {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.Service)
+	}
+	return &healthgrpc.HealthCheckResponse{Status: s.status}, nil
+}
+
+// streamUsers receives all users streamed by the daemon and returns the first error.
+func streamUsers(ctx context.Context, c authd.UserServiceClient) error {
+	stream, err := c.StreamUsers(ctx, &authd.Empty{})
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// startUserService starts a user service listening on socketPath. It can be called from another goroutine.
+func startUserService(t *testing.T, socketPath string) {
+	t.Helper()
+
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Errorf("Setup: failed to listen on %q: %v", socketPath, err)
+		return
+	}
+
+	server := grpc.NewServer()
+	registerServices(server)
+	go func() { _ = server.Serve(l) }()
+	t.Cleanup(server.Stop)
+}
+
+// startTLSUserService starts a user service listening on a local TCP port, which requires clients to present a
+// certificate signed by the CA in caFile. It returns the address of the service.
+func startTLSUserService(t *testing.T, certFile, keyFile, caFile string) string {
+	t.Helper()
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err, "Setup: could not load server certificate")
+	caPEM, err := os.ReadFile(caFile)
+	require.NoError(t, err, "Setup: could not read CA certificate")
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caPEM), "Setup: could not parse CA certificate")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "Setup: could not listen on a local port")
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	})))
+	registerServices(server)
+	go func() { _ = server.Serve(l) }()
+	t.Cleanup(server.Stop)
+
+	return l.Addr().String()
+}
+
+type testCA struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+}
+
+// newTestCA creates a self-signed CA and writes its certificate to dir.
+func newTestCA(t *testing.T, dir, name string) testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: could not generate CA key")
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "Setup: could not create CA certificate")
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "Setup: could not parse CA certificate")
+
+	certFile := filepath.Join(dir, name+".crt")
+	writePEM(t, certFile, "CERTIFICATE", der)
+
+	return testCA{cert: cert, key: key, certFile: certFile}
+}
+
+// issue creates a certificate for 127.0.0.1 signed by the CA and writes it and its key to dir.
+// It returns the paths of the certificate and key files.
+func (ca testCA) issue(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: could not generate key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err, "Setup: could not create certificate")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "Setup: could not marshal key")
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	require.NoError(t, err, "Setup: could not write %s", path)
+}
+
+// registerServices registers the user service and a health service reporting that authd is serving.
+func registerServices(server *grpc.Server) {
+	authd.RegisterUserServiceServer(server, userService{})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(consts.ServiceName, healthgrpc.HealthCheckResponse_SERVING)
+	healthgrpc.RegisterHealthServer(server, healthServer)
+}
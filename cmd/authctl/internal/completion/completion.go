@@ -13,9 +13,13 @@ import (
 
 const timeout = 5 * time.Second
 
+// clientOptions disables the retries of the client, so that completion fails fast instead of keeping the shell
+// waiting if the daemon is unavailable.
+var clientOptions = []client.Option{client.WithMaxRetries(0)}
+
 // Users returns the list of authd users for shell completion.
 func Users(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	svc, err := client.NewUserServiceClient()
+	svc, err := client.NewUserServiceClient(clientOptions...)
 	if err != nil {
 		return showError(err)
 	}
@@ -38,7 +42,7 @@ func Users(cmd *cobra.Command, args []string, toComplete string) ([]string, cobr
 
 // Groups returns the list of authd groups for shell completion.
 func Groups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	c, err := client.NewUserServiceClient()
+	c, err := client.NewUserServiceClient(clientOptions...)
 	if err != nil {
 		return showError(err)
 	}
@@ -59,6 +63,29 @@ func Groups(cmd *cobra.Command, args []string, toComplete string) ([]string, cob
 	return groupNames, cobra.ShellCompDirectiveNoFileComp
 }
 
+// Brokers returns the list of the names of the brokers for shell completion.
+func Brokers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	c, err := client.NewPAMClient(clientOptions...)
+	if err != nil {
+		return showError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+	defer cancel()
+
+	resp, err := c.AvailableBrokers(ctx, &authd.Empty{})
+	if err != nil {
+		return showError(err)
+	}
+
+	var brokerNames []string
+	for _, b := range resp.BrokersInfos {
+		brokerNames = append(brokerNames, b.Name)
+	}
+
+	return brokerNames, cobra.ShellCompDirectiveNoFileComp
+}
+
 // NoArgs returns no arguments and disables file completion.
 func NoArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return nil, cobra.ShellCompDirectiveNoFileComp
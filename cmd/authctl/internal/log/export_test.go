@@ -0,0 +1,40 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+// SetExitFunc overrides the function called by Fatal and Fatalf for the duration of the test.
+func SetExitFunc(t *testing.T, f func(code int)) {
+	t.Helper()
+
+	orig := exit
+	exit = f
+	t.Cleanup(func() { exit = orig })
+}
+
+// SetNowFunc overrides the function returning the timestamp of messages for the duration of the test.
+func SetNowFunc(t *testing.T, f func() time.Time) {
+	t.Helper()
+
+	orig := now
+	now = f
+	t.Cleanup(func() { now = orig })
+}
+
+// ResetColor removes any color override set via SetColor for the duration of the test.
+func ResetColor(t *testing.T) {
+	t.Helper()
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	orig := colorOverride
+	colorOverride = nil
+	t.Cleanup(func() {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		colorOverride = orig
+	})
+}
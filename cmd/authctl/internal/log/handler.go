@@ -0,0 +1,130 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// SlogNoticeLevel is the slog level of notices. slog doesn't have a Notice level, so we use the average between Info
+// and Warn.
+const SlogNoticeLevel = (slog.LevelInfo + slog.LevelWarn) / 2
+
+// Handler is a [slog.Handler] which prints records like the functions of this package: it drops the records below the
+// level set via SetLevel and formats the others in the format set via SetFormat, with the same colors.
+//
+// The attributes of a record are printed after its message as key=value pairs in text format, and as an object in
+// JSON format. The keys of attributes in groups are prefixed with the group names, separated by dots.
+type Handler struct {
+	attrs  []attr
+	groups []string
+}
+
+// NewHandler returns a new [Handler].
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Enabled returns true if records of the given level are printed.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return IsLevelEnabled(levelFromSlog(level))
+}
+
+// Handle prints the record.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	level := levelFromSlog(r.Level)
+	if !IsLevelEnabled(level) {
+		return nil
+	}
+
+	attrs := h.attrs
+	if r.NumAttrs() > 0 {
+		// Don't append to the attributes of the handler, which are shared with the handlers derived from it.
+		attrs = append([]attr(nil), h.attrs...)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = appendAttr(attrs, h.groups, a)
+			return true
+		})
+	}
+
+	outputWithAttrs(level, levelColor(level), r.Message, attrs)
+	return nil
+}
+
+// WithAttrs returns a new handler which prints the given attributes with each record.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := &Handler{
+		attrs:  append([]attr(nil), h.attrs...),
+		groups: h.groups,
+	}
+	for _, a := range attrs {
+		h2.attrs = appendAttr(h2.attrs, h.groups, a)
+	}
+	return h2
+}
+
+// WithGroup returns a new handler which prints the attributes of the records in the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{
+		attrs:  h.attrs,
+		groups: append(append([]string(nil), h.groups...), name),
+	}
+}
+
+// appendAttr appends a to attrs, with its key prefixed by groups. Groups are flattened.
+func appendAttr(attrs []attr, groups []string, a slog.Attr) []attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		// The attributes of groups without a key are inlined.
+		if a.Key != "" {
+			groups = append(append([]string(nil), groups...), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			attrs = appendAttr(attrs, groups, ga)
+		}
+		return attrs
+	}
+
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	return append(attrs, attr{key: key, value: a.Value.String()})
+}
+
+// levelFromSlog returns the level of this package corresponding to the given slog level.
+func levelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return DebugLevel
+	case level < SlogNoticeLevel:
+		return InfoLevel
+	case level < slog.LevelWarn:
+		return NoticeLevel
+	case level < slog.LevelError:
+		return WarningLevel
+	default:
+		return ErrorLevel
+	}
+}
+
+// levelColor returns the color of the messages of the given level.
+func levelColor(level Level) string {
+	switch level {
+	case NoticeLevel:
+		return noticeColor
+	case WarningLevel:
+		return warningColor
+	case ErrorLevel:
+		return errorColor
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,111 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerLevels(t *testing.T) {
+	tests := map[string]struct {
+		level log.Level
+		color bool
+
+		wantOutput string
+	}{
+		"Debug_level_prints_all_records": {level: log.DebugLevel, wantOutput: "debug\ninfo\nnotice\nwarning\nerror\n"},
+		"Info_level_drops_debug_records": {wantOutput: "info\nnotice\nwarning\nerror\n"},
+		"Warning_level_drops_notices":    {level: log.WarningLevel, wantOutput: "warning\nerror\n"},
+		"Records_are_colored_by_level": {
+			color:      true,
+			wantOutput: "info\n\033[0;1;39mnotice\033[0m\n\033[0;1;38:5:185mwarning\033[0m\n\033[1;31merror\033[0m\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			setOutput(t, &buf)
+			log.SetColor(tc.color)
+			setLevel(t, tc.level)
+
+			logger := slog.New(log.NewHandler())
+			ctx := context.Background()
+			logger.Debug("debug")
+			logger.Info("info")
+			logger.Log(ctx, log.SlogNoticeLevel, "notice")
+			logger.Warn("warning")
+			logger.Error("error")
+
+			require.Equal(t, tc.wantOutput, buf.String(), "Output should only contain the enabled levels")
+			require.Equal(t, tc.level <= log.DebugLevel, logger.Enabled(ctx, slog.LevelDebug),
+				"Enabled should follow the level set via SetLevel")
+		})
+	}
+}
+
+func TestHandlerAttributes(t *testing.T) {
+	tests := map[string]struct {
+		log        func(l *slog.Logger)
+		color      bool
+		jsonFormat bool
+
+		wantOutput string
+	}{
+		"Attributes_are_printed_as_key_value_pairs": {
+			log:        func(l *slog.Logger) { l.Info("msg", "user", "alice", "uid", 1000, "locked", true) },
+			wantOutput: "msg user=alice uid=1000 locked=true\n",
+		},
+		"Values_are_quoted_if_needed": {
+			log:        func(l *slog.Logger) { l.Info("msg", "gecos", "Alice Smith", "empty", "", "eq", "a=b", "nl", "a\nb") },
+			wantOutput: `msg gecos="Alice Smith" empty="" eq="a=b" nl="a\nb"` + "\n",
+		},
+		"Attributes_of_the_logger_are_printed_first": {
+			log:        func(l *slog.Logger) { l.With("cmd", "unlock").Info("msg", "user", "alice") },
+			wantOutput: "msg cmd=unlock user=alice\n",
+		},
+		"Keys_are_prefixed_with_groups": {
+			log: func(l *slog.Logger) {
+				l.With("cmd", "unlock").WithGroup("user").With("name", "alice").Info("msg", slog.Group("ids", "uid", 1000))
+			},
+			wantOutput: "msg cmd=unlock user.name=alice user.ids.uid=1000\n",
+		},
+		"Groups_without_key_are_inlined": {
+			log:        func(l *slog.Logger) { l.Info("msg", slog.Group("", "user", "alice")) },
+			wantOutput: "msg user=alice\n",
+		},
+		"Empty_attributes_and_groups_are_ignored": {
+			log:        func(l *slog.Logger) { l.WithGroup("").Info("msg", slog.Attr{}, slog.Group("empty")) },
+			wantOutput: "msg\n",
+		},
+		"Attributes_are_not_colored": {
+			log:        func(l *slog.Logger) { l.Error("msg", "user", "alice") },
+			color:      true,
+			wantOutput: "\033[1;31mmsg\033[0m user=alice\n",
+		},
+		"Attributes_are_an_object_in_JSON_format": {
+			log:        func(l *slog.Logger) { l.WithGroup("user").Info("msg", "name", "alice", "uid", 1000) },
+			jsonFormat: true,
+			wantOutput: `{"level":"info","timestamp":"2025-01-02T03:04:05Z","message":"msg","attrs":{"user.name":"alice","user.uid":"1000"}}` + "\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			setOutput(t, &buf)
+			log.SetColor(tc.color)
+			log.SetNowFunc(t, func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) })
+			if tc.jsonFormat {
+				setFormat(t, log.JSONFormat)
+			}
+
+			tc.log(slog.New(log.NewHandler()))
+
+			require.Equal(t, tc.wantOutput, buf.String(), "Output should contain the expected attributes")
+		})
+	}
+}
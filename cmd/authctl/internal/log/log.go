@@ -1,70 +1,411 @@
 // Package log provides logging functions for authctl.
+//
+// By default, messages are printed to stderr, see SetOutput. They can be buffered when many messages are printed, see
+// EnableBuffering.
+//
+// All functions are safe for concurrent use: each message is written at once, so messages printed by different
+// goroutines are never interleaved.
 package log
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
 
 	"golang.org/x/term"
 )
 
-var useColor = sync.OnceValue(func() bool {
+// Level is the level of a log message.
+type Level int32
+
+const (
+	// DebugLevel is the level of messages which are only useful when debugging.
+	DebugLevel Level = iota - 1
+	// InfoLevel is the level of informational messages. This is the default level.
+	InfoLevel
+	// NoticeLevel is the level of messages which are more significant than informational ones.
+	NoticeLevel
+	// WarningLevel is the level of warnings.
+	WarningLevel
+	// ErrorLevel is the level of errors.
+	ErrorLevel
+)
+
+// String returns the name of the level.
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case NoticeLevel:
+		return "notice"
+	case WarningLevel:
+		return "warning"
+	case ErrorLevel:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+}
+
+// Format is the format in which messages are printed.
+type Format int
+
+const (
+	// TextFormat prints messages as human-readable text, colored if the output is a terminal. This is the default format.
+	TextFormat Format = iota
+	// JSONFormat prints each message as a single-line JSON object with level, timestamp and message fields.
+	// Messages are never colored in this format.
+	JSONFormat
+)
+
+const (
+	noticeColor  = "\033[0;1;39m"
+	warningColor = "\033[0;1;38:5:185m"
+	errorColor   = "\033[1;31m"
+	resetColor   = "\033[0m"
+)
+
+var logLevel atomic.Int32
+
+// exit is called by Fatal and Fatalf. It can be overridden in tests.
+var exit = os.Exit
+
+// now returns the timestamp of messages. It can be overridden in tests.
+var now = time.Now
+
+var (
+	outputMu   sync.RWMutex
+	out        io.Writer = os.Stderr
+	format               = TextFormat
+	timestamps bool
+	prefix     string
+	// colorOverride is set by SetColor to force colored output on or off.
+	colorOverride *bool
+	useColor      = sync.OnceValue(func() bool {
+		return isColorTerminal(os.Stderr)
+	})
+)
+
+var (
+	// writeMu serializes the writes to the output, so that the lines printed by different goroutines are not
+	// interleaved, even if the output splits writes. It's also required because the buffer is not safe for concurrent
+	// use. It's only held while writing, messages are formatted before.
+	writeMu sync.Mutex
+	// buffered wraps the output while buffering is enabled.
+	buffered *bufio.Writer
+)
+
+// isColorTerminal returns true if w is a terminal and colors are not disabled via the environment.
+// Colors can be forced on via FORCE_COLOR, but NO_COLOR takes precedence.
+func isColorTerminal(w io.Writer) bool {
 	if os.Getenv("NO_COLOR") != "" {
 		return false
 	}
+	if os.Getenv("FORCE_COLOR") != "" {
+		return true
+	}
+
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// SetOutput sets the writer to which messages are printed.
+// Unless forced via SetColor, messages are colored only if w is a terminal.
+// If buffering is enabled, the buffered messages are flushed to the previous writer first.
+func SetOutput(w io.Writer) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	writeMu.Lock()
+	if buffered != nil {
+		_ = buffered.Flush()
+		buffered = bufio.NewWriter(w)
+	}
+	writeMu.Unlock()
+
+	out = w
+	useColor = sync.OnceValue(func() bool {
+		return isColorTerminal(w)
+	})
+}
+
+// EnableBuffering buffers the messages instead of writing each of them to the output, which is faster when many
+// messages are printed, for example the results of bulk operations.
+//
+// The buffered messages are written when the buffer is full, when an error is printed and when Flush or
+// DisableBuffering is called. Callers must call one of them once done, otherwise messages might be lost.
+func EnableBuffering() {
+	outputMu.RLock()
+	defer outputMu.RUnlock()
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if buffered == nil {
+		buffered = bufio.NewWriter(out)
+	}
+}
+
+// DisableBuffering writes the buffered messages to the output and stops buffering.
+func DisableBuffering() error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if buffered == nil {
+		return nil
+	}
+	err := buffered.Flush()
+	buffered = nil
+	return err
+}
+
+// Flush writes the buffered messages to the output. It does nothing if buffering is disabled.
+func Flush() error {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if buffered == nil {
+		return nil
+	}
+	return buffered.Flush()
+}
+
+// SetColor forces colored output on or off, regardless of whether the output is a terminal.
+func SetColor(enabled bool) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	colorOverride = &enabled
+}
+
+// SetFormat sets the format in which messages are printed.
+func SetFormat(f Format) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	format = f
+}
+
+// SetTimestamps enables or disables prepending an RFC3339 timestamp to each message in text format.
+// Timestamps are disabled by default.
+func SetTimestamps(enabled bool) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	timestamps = enabled
+}
+
+// SetPrefix sets a static prefix printed before each message, after the timestamp if enabled.
+// An empty prefix, the default, disables it.
+func SetPrefix(p string) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	prefix = p
+}
+
+// SetLevel sets the level below which messages are dropped.
+func SetLevel(level Level) {
+	logLevel.Store(int32(level))
+}
 
-	return term.IsTerminal(int(os.Stderr.Fd()))
-})
+// GetLevel returns the level below which messages are dropped.
+func GetLevel() Level {
+	return Level(logLevel.Load())
+}
+
+// IsLevelEnabled returns true if messages of the given level are printed.
+func IsLevelEnabled(level Level) bool {
+	return level >= GetLevel()
+}
+
+// Debug prints a message, if the debug level is enabled.
+func Debug(a ...any) {
+	output(DebugLevel, "", a...)
+}
+
+// Debugf prints a formatted message, if the debug level is enabled.
+func Debugf(format string, args ...any) {
+	outputf(DebugLevel, "", format, args...)
+}
 
-// Info prints a message to stderr.
+// Info prints a message.
 func Info(a ...any) {
-	fmt.Fprintln(os.Stderr, fmt.Sprint(a...))
+	output(InfoLevel, "", a...)
 }
 
-// Infof prints a formatted message to stderr.
+// Infof prints a formatted message.
 func Infof(format string, args ...any) {
-	Info(fmt.Sprintf(format, args...))
+	outputf(InfoLevel, "", format, args...)
 }
 
-// Notice prints a message to stderr in bold.
+// Notice prints a message in bold.
 func Notice(a ...any) {
-	if !useColor() {
-		fmt.Fprintln(os.Stderr, fmt.Sprint(a...))
-		return
-	}
-	fmt.Fprintln(os.Stderr, "\033[0;1;39m"+fmt.Sprint(a...)+"\033[0m")
+	output(NoticeLevel, noticeColor, a...)
 }
 
-// Noticef prints a formatted message to stderr in bold.
+// Noticef prints a formatted message in bold.
 func Noticef(format string, args ...any) {
-	Notice(fmt.Sprintf(format, args...))
+	outputf(NoticeLevel, noticeColor, format, args...)
 }
 
-// Warning prints a message to stderr in yellow.
+// Warning prints a message in yellow.
 func Warning(a ...any) {
-	if !useColor() {
-		fmt.Fprintln(os.Stderr, fmt.Sprint(a...))
-		return
-	}
-	fmt.Fprintln(os.Stderr, "\033[0;1;38:5:185m"+fmt.Sprint(a...)+"\033[0m")
+	output(WarningLevel, warningColor, a...)
 }
 
-// Warningf prints a formatted message to stderr in yellow.
+// Warningf prints a formatted message in yellow.
 func Warningf(format string, args ...any) {
-	Warning(fmt.Sprintf(format, args...))
+	outputf(WarningLevel, warningColor, format, args...)
 }
 
-// Error prints a message to stderr in red.
+// Error prints a message in red.
 func Error(a ...any) {
-	if !useColor() {
-		fmt.Fprintln(os.Stderr, fmt.Sprint(a...))
+	output(ErrorLevel, errorColor, a...)
+}
+
+// Errorf prints a formatted message in red.
+func Errorf(format string, args ...any) {
+	outputf(ErrorLevel, errorColor, format, args...)
+}
+
+// Fatal prints a message in red, like Error, and exits with status 1. Like any error, it flushes the buffered messages.
+func Fatal(a ...any) {
+	Error(a...)
+	exit(1)
+}
+
+// Fatalf prints a formatted message in red, like Errorf, and exits with status 1.
+func Fatalf(format string, args ...any) {
+	Errorf(format, args...)
+	exit(1)
+}
+
+func outputf(level Level, color string, format string, args ...any) {
+	// Check the level before formatting, to avoid the cost of formatting dropped messages.
+	if !IsLevelEnabled(level) {
 		return
 	}
-	fmt.Fprintln(os.Stderr, "\033[1;31m"+fmt.Sprint(a...)+"\033[0m")
+	output(level, color, fmt.Sprintf(format, args...))
 }
 
-// Errorf prints a formatted message to stderr in red.
-func Errorf(format string, args ...any) {
-	Error(fmt.Sprintf(format, args...))
+func output(level Level, color string, a ...any) {
+	if !IsLevelEnabled(level) {
+		return
+	}
+	outputWithAttrs(level, color, fmt.Sprint(a...), nil)
+}
+
+// attr is a key-value pair printed after a message, see Handler.
+type attr struct {
+	key   string
+	value string
+}
+
+// outputWithAttrs prints msg followed by attrs. In text format, the attributes are printed as key=value pairs after the
+// message, in JSON format as an object.
+func outputWithAttrs(level Level, color string, msg string, attrs []attr) {
+
+	outputMu.RLock()
+	w := out
+	f := format
+	withTimestamp := timestamps
+	p := prefix
+	withColor := useColor()
+	if colorOverride != nil {
+		withColor = *colorOverride
+	}
+	outputMu.RUnlock()
+
+	// Errors are written immediately, with any message buffered before, so that they are not lost if we exit.
+	flush := level >= ErrorLevel
+	if f == JSONFormat {
+		write(w, formatJSON(level, p, msg, attrs), flush)
+		return
+	}
+
+	// Attributes are not colored either, to make them easier to tell apart from the message.
+	var suffix strings.Builder
+	for _, a := range attrs {
+		suffix.WriteString(" " + a.key + "=" + quoteIfNeeded(a.value))
+	}
+
+	// The timestamp and prefix are never colored, only the message is.
+	var header string
+	if withTimestamp {
+		header = now().Format(time.RFC3339) + " "
+	}
+	if p != "" {
+		header += p + " "
+	}
+
+	if color == "" || !withColor {
+		write(w, header+msg+suffix.String(), flush)
+		return
+	}
+	write(w, header+color+msg+resetColor+suffix.String(), flush)
+}
+
+// quoteIfNeeded quotes s if it would be ambiguous in a key=value pair.
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"") || strings.ContainsFunc(s, func(r rune) bool { return !unicode.IsPrint(r) }) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// write prints line followed by a newline to w, or to the buffer if buffering is enabled. If flush is true, the buffer
+// is flushed afterwards.
+func write(w io.Writer, line string, flush bool) {
+	writeMu.Lock()
+	defer writeMu.Unlock()
+
+	if buffered == nil {
+		fmt.Fprintln(w, line)
+		return
+	}
+
+	// Errors are ignored, like when writing directly to the output.
+	_, _ = buffered.WriteString(line + "\n")
+	if flush {
+		_ = buffered.Flush()
+	}
+}
+
+func formatJSON(level Level, prefix, msg string, attrs []attr) string {
+	entry := struct {
+		Level     string            `json:"level"`
+		Timestamp string            `json:"timestamp"`
+		Prefix    string            `json:"prefix,omitempty"`
+		Message   string            `json:"message"`
+		Attrs     map[string]string `json:"attrs,omitempty"`
+	}{
+		Level:     level.String(),
+		Timestamp: now().Format(time.RFC3339),
+		Prefix:    prefix,
+		Message:   msg,
+	}
+	if len(attrs) > 0 {
+		entry.Attrs = make(map[string]string, len(attrs))
+		for _, a := range attrs {
+			entry.Attrs[a.key] = a.value
+		}
+	}
+
+	// Marshalling a struct of strings can't fail.
+	b, _ := json.Marshal(entry)
+	return string(b)
 }
@@ -0,0 +1,389 @@
+package log_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests in this package are not run in parallel, because the log package uses global state.
+
+func TestLevels(t *testing.T) {
+	tests := map[string]struct {
+		level log.Level
+
+		wantOutput string
+	}{
+		"Debug_level_prints_all_messages":        {level: log.DebugLevel, wantOutput: "debug\ninfo\nnotice\nwarning\nerror\n"},
+		"Info_level_drops_debug_messages":        {wantOutput: "info\nnotice\nwarning\nerror\n"},
+		"Notice_level_drops_info_messages":       {level: log.NoticeLevel, wantOutput: "notice\nwarning\nerror\n"},
+		"Warning_level_drops_notice_messages":    {level: log.WarningLevel, wantOutput: "warning\nerror\n"},
+		"Error_level_only_prints_error_messages": {level: log.ErrorLevel, wantOutput: "error\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			setOutput(t, &buf)
+			log.SetColor(false)
+			setLevel(t, tc.level)
+
+			log.Debug("debug")
+			log.Info("info")
+			log.Notice("notice")
+			log.Warning("warning")
+			log.Error("error")
+
+			require.Equal(t, tc.wantOutput, buf.String(), "Output should only contain the enabled levels")
+		})
+	}
+}
+
+func TestFormattedMessages(t *testing.T) {
+	var buf bytes.Buffer
+	setOutput(t, &buf)
+	log.SetColor(false)
+	setLevel(t, log.DebugLevel)
+
+	log.Debugf("debug %d", 1)
+	log.Infof("info %d", 2)
+	log.Noticef("notice %d", 3)
+	log.Warningf("warning %d", 4)
+	log.Errorf("error %d", 5)
+
+	require.Equal(t, "debug 1\ninfo 2\nnotice 3\nwarning 4\nerror 5\n", buf.String())
+}
+
+func TestColor(t *testing.T) {
+	tests := map[string]struct {
+		forceColor bool
+
+		wantOutput string
+	}{
+		"Color_when_forced_on": {
+			forceColor: true,
+			wantOutput: "info\n\033[0;1;39mnotice\033[0m\n\033[0;1;38:5:185mwarning\033[0m\n\033[1;31merror\033[0m\n",
+		},
+		"No_color_when_forced_off": {
+			forceColor: false,
+			wantOutput: "info\nnotice\nwarning\nerror\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			setOutput(t, &buf)
+			log.SetColor(tc.forceColor)
+
+			log.Info("info")
+			log.Notice("notice")
+			log.Warning("warning")
+			log.Error("error")
+
+			require.Equal(t, tc.wantOutput, buf.String(), "Output should be colored as expected")
+		})
+	}
+}
+
+func TestColorFromEnvironment(t *testing.T) {
+	tests := map[string]struct {
+		noColor    string
+		forceColor string
+
+		wantColor bool
+	}{
+		"No_color_when_output_is_not_a_terminal":     {},
+		"Color_when_FORCE_COLOR_is_set":              {forceColor: "1", wantColor: true},
+		"No_color_when_NO_COLOR_is_set":              {noColor: "1"},
+		"NO_COLOR_takes_precedence_over_FORCE_COLOR": {noColor: "1", forceColor: "1"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tc.noColor)
+			t.Setenv("FORCE_COLOR", tc.forceColor)
+			log.ResetColor(t)
+
+			var buf bytes.Buffer
+			setOutput(t, &buf)
+
+			log.Error("error")
+
+			want := "error\n"
+			if tc.wantColor {
+				want = "\033[1;31merror\033[0m\n"
+			}
+			require.Equal(t, want, buf.String(), "Output should be colored as expected")
+		})
+	}
+}
+
+func TestFatal(t *testing.T) {
+	tests := map[string]struct {
+		formatted bool
+		color     bool
+
+		wantOutput string
+	}{
+		"Prints_message_and_exits":                {wantOutput: "fatal 1\n"},
+		"Prints_formatted_message_and_exits":      {formatted: true, wantOutput: "fatal 1\n"},
+		"Prints_message_in_error_color_and_exits": {color: true, wantOutput: "\033[1;31mfatal 1\033[0m\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			setOutput(t, &buf)
+			log.SetColor(tc.color)
+
+			var exitCode *int
+			log.SetExitFunc(t, func(code int) { exitCode = &code })
+
+			if tc.formatted {
+				log.Fatalf("fatal %d", 1)
+			} else {
+				log.Fatal("fatal ", 1)
+			}
+
+			require.Equal(t, tc.wantOutput, buf.String(), "Fatal should print the message")
+			require.NotNil(t, exitCode, "Fatal should exit")
+			require.Equal(t, 1, *exitCode, "Fatal should exit with status 1")
+		})
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	setOutput(t, &buf)
+	setFormat(t, log.JSONFormat)
+	// Colors must be ignored in JSON format.
+	log.SetColor(true)
+	log.SetNowFunc(t, func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) })
+
+	log.Info("info")
+	log.Warningf("warning with \"quotes\" and %s", "args")
+	log.Error("error")
+
+	want := `{"level":"info","timestamp":"2025-01-02T03:04:05Z","message":"info"}
+{"level":"warning","timestamp":"2025-01-02T03:04:05Z","message":"warning with \"quotes\" and args"}
+{"level":"error","timestamp":"2025-01-02T03:04:05Z","message":"error"}
+`
+	require.Equal(t, want, buf.String(), "Output should be one JSON object per message")
+}
+
+func TestTimestampsAndPrefix(t *testing.T) {
+	tests := map[string]struct {
+		timestamps bool
+		prefix     string
+		color      bool
+		jsonFormat bool
+
+		wantOutput string
+	}{
+		"No_timestamp_nor_prefix_by_default": {wantOutput: "error\n"},
+		"Timestamp_when_enabled":             {timestamps: true, wantOutput: "2025-01-02T03:04:05Z error\n"},
+		"Prefix_when_set":                    {prefix: "[authctl]", wantOutput: "[authctl] error\n"},
+		"Timestamp_before_prefix":            {timestamps: true, prefix: "[authctl]", wantOutput: "2025-01-02T03:04:05Z [authctl] error\n"},
+		"Timestamp_and_prefix_are_not_colored": {
+			timestamps: true, prefix: "[authctl]", color: true,
+			wantOutput: "2025-01-02T03:04:05Z [authctl] \033[1;31merror\033[0m\n",
+		},
+		"Prefix_is_a_field_in_JSON_format": {
+			timestamps: true, prefix: "[authctl]", jsonFormat: true,
+			wantOutput: `{"level":"error","timestamp":"2025-01-02T03:04:05Z","prefix":"[authctl]","message":"error"}` + "\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			setOutput(t, &buf)
+			log.SetColor(tc.color)
+			log.SetNowFunc(t, func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) })
+			if tc.jsonFormat {
+				setFormat(t, log.JSONFormat)
+			}
+
+			log.SetTimestamps(tc.timestamps)
+			t.Cleanup(func() { log.SetTimestamps(false) })
+			log.SetPrefix(tc.prefix)
+			t.Cleanup(func() { log.SetPrefix("") })
+
+			log.Error("error")
+
+			require.Equal(t, tc.wantOutput, buf.String(), "Output should contain the expected timestamp and prefix")
+		})
+	}
+}
+
+func TestBuffering(t *testing.T) {
+	tests := map[string]struct {
+		flush       bool
+		disable     bool
+		error       bool
+		fatal       bool
+		jsonFormat  bool
+		setOutput   bool
+		noBuffering bool
+
+		wantOutputBefore string
+		wantOutputAfter  string
+	}{
+		"Messages_are_buffered_until_flushed":                {flush: true, wantOutputAfter: "info\nwarning\n"},
+		"Messages_are_written_when_buffering_is_disabled":    {disable: true, wantOutputAfter: "info\nwarning\n"},
+		"Messages_are_written_when_an_error_is_printed":      {error: true, wantOutputBefore: "info\nwarning\nerror\n"},
+		"Messages_are_written_when_a_fatal_error_is_printed": {fatal: true, wantOutputBefore: "info\nwarning\nfatal\n"},
+		"Messages_are_buffered_in_JSON_format": {
+			jsonFormat: true, flush: true,
+			wantOutputAfter: `{"level":"info","timestamp":"2025-01-02T03:04:05Z","message":"info"}` + "\n" +
+				`{"level":"warning","timestamp":"2025-01-02T03:04:05Z","message":"warning"}` + "\n",
+		},
+		"Messages_are_written_to_the_previous_output_when_it_changes": {setOutput: true, wantOutputBefore: "info\nwarning\n"},
+		"Messages_are_not_buffered_by_default":                        {noBuffering: true, wantOutputBefore: "info\nwarning\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			setOutput(t, &buf)
+			log.SetColor(false)
+			log.SetNowFunc(t, func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) })
+			log.SetExitFunc(t, func(int) {})
+			if tc.jsonFormat {
+				setFormat(t, log.JSONFormat)
+			}
+			if !tc.noBuffering {
+				enableBuffering(t)
+			}
+
+			log.Info("info")
+			log.Warning("warning")
+			switch {
+			case tc.error:
+				log.Error("error")
+			case tc.fatal:
+				log.Fatal("fatal")
+			case tc.setOutput:
+				log.SetOutput(&bytes.Buffer{})
+			}
+
+			require.Equal(t, tc.wantOutputBefore, buf.String(), "Unexpected output before flushing")
+
+			switch {
+			case tc.flush:
+				require.NoError(t, log.Flush(), "Flush should not fail")
+			case tc.disable:
+				require.NoError(t, log.DisableBuffering(), "DisableBuffering should not fail")
+				log.Info("after")
+				tc.wantOutputAfter += "after\n"
+			}
+
+			if tc.wantOutputAfter == "" {
+				tc.wantOutputAfter = tc.wantOutputBefore
+			}
+			require.Equal(t, tc.wantOutputAfter, buf.String(), "Unexpected output after flushing")
+		})
+	}
+}
+
+func TestConcurrentMessagesAreNotInterleaved(t *testing.T) {
+	tests := map[string]struct {
+		buffering bool
+	}{
+		"Without_buffering": {},
+		"With_buffering":    {buffering: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The writer splits each write, so that the lines would be interleaved if the writes were not serialized.
+			w := &byteByByteWriter{}
+			log.SetOutput(w)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+			log.SetColor(false)
+			if tc.buffering {
+				enableBuffering(t)
+			}
+
+			const goroutines, messages = 10, 200
+			var wg sync.WaitGroup
+			for g := range goroutines {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range messages {
+						log.Infof("goroutine %d message %d", g, i)
+					}
+				}()
+			}
+			wg.Wait()
+			require.NoError(t, log.Flush(), "Flush should not fail")
+
+			lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+			require.Len(t, lines, goroutines*messages, "All messages should be printed")
+			printed := make(map[string]bool, len(lines))
+			for _, line := range lines {
+				printed[line] = true
+			}
+			for g := range goroutines {
+				for i := range messages {
+					msg := fmt.Sprintf("goroutine %d message %d", g, i)
+					require.True(t, printed[msg], "Message %q should be printed on its own line", msg)
+				}
+			}
+		})
+	}
+}
+
+// byteByByteWriter is a writer which writes one byte at a time, yielding to other goroutines in between.
+type byteByByteWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *byteByByteWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		w.mu.Lock()
+		w.buf.WriteByte(b)
+		w.mu.Unlock()
+		runtime.Gosched()
+	}
+	return len(p), nil
+}
+
+func (w *byteByByteWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func enableBuffering(t *testing.T) {
+	t.Helper()
+
+	log.EnableBuffering()
+	t.Cleanup(func() { _ = log.DisableBuffering() })
+}
+
+func setOutput(t *testing.T, buf *bytes.Buffer) {
+	t.Helper()
+
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func setLevel(t *testing.T, level log.Level) {
+	t.Helper()
+
+	orig := log.GetLevel()
+	log.SetLevel(level)
+	t.Cleanup(func() { log.SetLevel(orig) })
+}
+
+func setFormat(t *testing.T, format log.Format) {
+	t.Helper()
+
+	log.SetFormat(format)
+	t.Cleanup(func() { log.SetFormat(log.TextFormat) })
+}
@@ -0,0 +1,188 @@
+// Package output handles the output format of authctl commands.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Format is the format in which commands print their results.
+type Format string
+
+const (
+	// Text prints results as human-readable text. This is the default format.
+	Text Format = "text"
+	// JSON prints results as JSON objects.
+	JSON Format = "json"
+)
+
+var formats = []Format{Text, JSON}
+
+// format is the output format selected via the --output flag.
+var format = Text
+
+// formatValue implements pflag.Value for the --output flag.
+type formatValue struct{}
+
+func (formatValue) String() string {
+	return string(format)
+}
+
+func (formatValue) Set(s string) error {
+	for _, f := range formats {
+		if s == string(f) {
+			format = f
+			return nil
+		}
+	}
+
+	var names []string
+	for _, f := range formats {
+		names = append(names, string(f))
+	}
+	return fmt.Errorf("must be one of: %s", strings.Join(names, ", "))
+}
+
+func (formatValue) Type() string {
+	return "format"
+}
+
+// AddFlag adds the persistent --output flag to the given command.
+func AddFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().Var(formatValue{}, "output", "output format (text, json)")
+	_ = cmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var names []string
+		for _, f := range formats {
+			names = append(names, string(f))
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// IsJSON returns true if the results should be printed as JSON.
+func IsJSON() bool {
+	return format == JSON
+}
+
+// PrintJSON prints v as indented JSON to w. Protobuf messages are marshalled using the protobuf JSON mapping,
+// with the field names of the proto definition and including fields with zero values.
+func PrintJSON(w io.Writer, v any) error {
+	b, err := marshalJSON(v, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// marshalJSON marshals v like PrintJSON, prefixing all lines but the first with prefix.
+func marshalJSON(v any, prefix string) ([]byte, error) {
+	var b []byte
+	var err error
+	if m, ok := v.(proto.Message); ok {
+		b, err = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(m)
+	} else {
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output to JSON: %w", err)
+	}
+
+	// The output of protojson is deliberately unstable, so we re-indent it to get a stable output.
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, "  "); err != nil {
+		return nil, fmt.Errorf("failed to format JSON output: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// JSONListWriter prints a JSON object with a single list field, printing the elements of the list as they are
+// added instead of holding all of them in memory. Once closed, the output is the same as the one of PrintJSON for
+// the whole object.
+type JSONListWriter struct {
+	w io.Writer
+	n int
+}
+
+// NewJSONListWriter starts printing a JSON object with a list field with the given name to w.
+// Close must be called once all elements have been added, to terminate the object.
+func NewJSONListWriter(w io.Writer, field string) (*JSONListWriter, error) {
+	name, err := json.Marshal(field)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output to JSON: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "{\n  %s: [", name); err != nil {
+		return nil, err
+	}
+	return &JSONListWriter{w: w}, nil
+}
+
+// Add prints v as the next element of the list, marshalled like PrintJSON does.
+func (l *JSONListWriter) Add(v any) error {
+	b, err := marshalJSON(v, "    ")
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n    "
+	if l.n == 0 {
+		sep = "\n    "
+	}
+	if _, err := io.WriteString(l.w, sep); err != nil {
+		return err
+	}
+	if _, err := l.w.Write(b); err != nil {
+		return err
+	}
+	l.n++
+	return nil
+}
+
+// Close terminates the list and the object.
+func (l *JSONListWriter) Close() error {
+	end := "\n  ]\n}\n"
+	if l.n == 0 {
+		end = "]\n}\n"
+	}
+	_, err := io.WriteString(l.w, end)
+	return err
+}
+
+// Status is the result of commands which don't return any data, like lock and unlock.
+type Status struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// PreviousStatus is the status of the entity before the command, if it's known.
+	PreviousStatus string `json:"previous_status,omitempty"`
+	// Error is set if the command failed for this entity.
+	Error string `json:"error,omitempty"`
+}
+
+// PrintStatus prints the status of the entity with the given name to w, if the results should be printed as JSON.
+func PrintStatus(w io.Writer, name, status string) error {
+	return PrintStatusChange(w, name, "", status)
+}
+
+// PrintStatusChange is like PrintStatus, but also prints the status of the entity before the command.
+func PrintStatusChange(w io.Writer, name, previousStatus, status string) error {
+	if !IsJSON() {
+		return nil
+	}
+	return PrintJSON(w, Status{Name: name, Status: status, PreviousStatus: previousStatus})
+}
+
+// DryRun is the result of commands run with --dry-run.
+type DryRun struct {
+	Name string `json:"name"`
+	// Action describes what the command would have done.
+	Action string `json:"action"`
+	DryRun bool   `json:"dry_run"`
+}
@@ -0,0 +1,66 @@
+// Package prompt asks the user of authctl for confirmation before destructive operations.
+//
+// By default, answers are read from stdin, see SetInput.
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"golang.org/x/term"
+)
+
+// ErrNotInteractive is returned by Confirm if the answer can't be asked for because the input is not a terminal.
+var ErrNotInteractive = errors.New("cannot ask for confirmation because the standard input is not a terminal, use --force to proceed without confirmation")
+
+var (
+	inputMu sync.Mutex
+	in      io.Reader = os.Stdin
+)
+
+// SetInput sets the reader from which answers are read.
+// Readers which are not files, e.g. in tests, are always considered interactive.
+func SetInput(r io.Reader) {
+	inputMu.Lock()
+	defer inputMu.Unlock()
+
+	in = r
+}
+
+// Confirm prints the question followed by " [y/N]" and returns true if the answer is "y" or "yes", case-insensitively.
+// Any other answer, including an empty one or the end of the input, is considered a no.
+//
+// If the input is a file which is not a terminal, it returns ErrNotInteractive without asking, so that commands don't
+// hang or act on an answer which was not given by the user.
+func Confirm(question string) (bool, error) {
+	inputMu.Lock()
+	defer inputMu.Unlock()
+
+	if !isInteractive(in) {
+		return false, ErrNotInteractive
+	}
+
+	log.Infof("%s [y/N]", question)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// isInteractive returns false if r is a file which is not a terminal.
+func isInteractive(r io.Reader) bool {
+	f, ok := r.(interface{ Fd() uintptr })
+	if !ok {
+		return true
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,69 @@
+package prompt_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/prompt"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests in this package are not run in parallel, because the prompt and log packages use global state.
+
+func TestConfirm(t *testing.T) {
+	tests := map[string]struct {
+		input           string
+		inputIsNotATerm bool
+
+		wantConfirmed bool
+		wantErr       error
+	}{
+		"Confirmed_with_y":                 {input: "y\n", wantConfirmed: true},
+		"Confirmed_with_yes":               {input: "yes\n", wantConfirmed: true},
+		"Confirmed_with_uppercase_yes":     {input: "YES\n", wantConfirmed: true},
+		"Confirmed_with_surrounding_space": {input: "  y  \n", wantConfirmed: true},
+		"Confirmed_without_newline":        {input: "y", wantConfirmed: true},
+
+		"Not_confirmed_with_n":          {input: "n\n"},
+		"Not_confirmed_with_other":      {input: "maybe\n"},
+		"Not_confirmed_with_empty_line": {input: "\n"},
+		"Not_confirmed_without_input":   {},
+
+		"Error_when_input_is_not_a_terminal": {input: "y\n", inputIsNotATerm: true, wantErr: prompt.ErrNotInteractive},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			log.SetOutput(&out)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			var in io.Reader = strings.NewReader(tc.input)
+			if tc.inputIsNotATerm {
+				path := filepath.Join(t.TempDir(), "answer")
+				err := os.WriteFile(path, []byte(tc.input), 0600)
+				require.NoError(t, err, "Setup: could not write answer file")
+				f, err := os.Open(path)
+				require.NoError(t, err, "Setup: could not open answer file")
+				t.Cleanup(func() { _ = f.Close() })
+				in = f
+			}
+			prompt.SetInput(in)
+			t.Cleanup(func() { prompt.SetInput(os.Stdin) })
+
+			confirmed, err := prompt.Confirm("Do it?")
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr, "Confirm should return the expected error")
+				require.Empty(t, out.String(), "The question should not be asked if the input is not a terminal")
+				return
+			}
+			require.NoError(t, err, "Confirm should not return an error")
+			require.Equal(t, tc.wantConfirmed, confirmed, "Unexpected confirmation")
+			require.Equal(t, "Do it? [y/N]\n", out.String(), "The question should be printed")
+		})
+	}
+}
@@ -2,8 +2,10 @@
 package main
 
 import (
+	"log/slog"
 	"os"
 
+	"github.com/canonical/authd/cmd/authctl/internal/client"
 	"github.com/canonical/authd/cmd/authctl/internal/log"
 	"github.com/canonical/authd/cmd/authctl/root"
 	"google.golang.org/grpc/codes"
@@ -11,6 +13,9 @@ import (
 )
 
 func main() {
+	// Print the messages logged with slog, including by the packages shared with the daemon, like ours.
+	slog.SetDefault(slog.New(log.NewHandler()))
+
 	if err := root.RootCmd.Execute(); err != nil {
 		s, ok := status.FromError(err)
 		if !ok {
@@ -23,6 +28,8 @@ func main() {
 		switch s.Code() {
 		case codes.PermissionDenied:
 			log.Errorf("Permission denied: %s", s.Message())
+		case codes.DeadlineExceeded:
+			log.Errorf("Error: the daemon did not respond within %s", client.Timeout())
 		default:
 			log.Errorf("Error: %s", s.Message())
 		}
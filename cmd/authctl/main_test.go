@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/canonical/authd/internal/testutils"
+	"github.com/canonical/authd/internal/testutils/golden"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 )
 
 var authctlPath string
+var daemonPath string
 
 func TestRootCommand(t *testing.T) {
 	t.Parallel()
@@ -23,8 +29,12 @@ func TestRootCommand(t *testing.T) {
 		"Help_flag":                  {args: []string{"--help"}, expectedExitCode: 0},
 		"Completion_command":         {args: []string{"completion"}, expectedExitCode: 0},
 
-		"Error_on_invalid_command": {args: []string{"invalid-command"}, expectedExitCode: 1},
-		"Error_on_invalid_flag":    {args: []string{"--invalid-flag"}, expectedExitCode: 1},
+		"Error_on_completion_for_unsupported_shell": {args: []string{"completion", "tcsh"}, expectedExitCode: 1},
+
+		"Error_on_invalid_command":       {args: []string{"invalid-command"}, expectedExitCode: 1},
+		"Error_on_invalid_flag":          {args: []string{"--invalid-flag"}, expectedExitCode: 1},
+		"Error_on_invalid_output_format": {args: []string{"user", "list", "--output", "yaml"}, expectedExitCode: 1},
+		"Error_on_invalid_socket":        {args: []string{"user", "list", "--socket", "localhost:invalid"}, expectedExitCode: 1},
 	}
 
 	for name, tc := range tests {
@@ -38,6 +48,90 @@ func TestRootCommand(t *testing.T) {
 	}
 }
 
+func TestCompletionCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		shell string
+
+		wantInstallPath string
+	}{
+		"Bash": {shell: "bash", wantInstallPath: filepath.Join("data", "bash-completion", "completions", "authctl")},
+		"Zsh":  {shell: "zsh", wantInstallPath: filepath.Join("data", "zsh", "site-functions", "_authctl")},
+		"Fish": {shell: "fish", wantInstallPath: filepath.Join("config", "fish", "completions", "authctl.fish")},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			out, err := exec.Command(authctlPath, "completion", tc.shell).Output()
+			require.NoError(t, err, "completion should succeed")
+			// The scripts must call back into authctl to complete dynamic arguments like user names.
+			require.Contains(t, string(out), "__complete", "Script should use dynamic completion")
+
+			tempDir := t.TempDir()
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, "completion", tc.shell, "--install")
+			cmd.Env = append(os.Environ(),
+				"XDG_DATA_HOME="+filepath.Join(tempDir, "data"),
+				"XDG_CONFIG_HOME="+filepath.Join(tempDir, "config"),
+			)
+			err = cmd.Run()
+			require.NoError(t, err, "completion --install should succeed")
+
+			installed, err := os.ReadFile(filepath.Join(tempDir, tc.wantInstallPath))
+			require.NoError(t, err, "Script should be installed to the per-user completion directory")
+			require.Equal(t, string(out), string(installed), "Installed script should be the generated one")
+		})
+	}
+}
+
+func TestStatusCommand(t *testing.T) {
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithPreviousDBState("one_user_and_group"),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	tests := map[string]struct {
+		args   []string
+		socket string
+
+		expectedExitCode int
+	}{
+		"Show_daemon_status":         {},
+		"Show_daemon_status_as_JSON": {args: []string{"--output", "json"}},
+
+		"Error_when_authd_is_unavailable": {socket: "/non-existent", expectedExitCode: int(codes.Unavailable)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			socket := daemonSocket
+			if tc.socket != "" {
+				socket = tc.socket
+			}
+
+			args := append([]string{"status", "--socket", socket}, tc.args...)
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, args...)
+			out, err := cmd.CombinedOutput()
+			if tc.expectedExitCode == 0 {
+				require.NoError(t, err, "status failed unexpectedly: %s", out)
+			}
+			require.Equal(t, tc.expectedExitCode, cmd.ProcessState.ExitCode(), "Unexpected exit code: %s", out)
+
+			// The uptime depends on how long the daemon has been running, so we don't compare it.
+			got := uptimeRegex.ReplaceAllString(string(out), "${1}<uptime>")
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
+var uptimeRegex = regexp.MustCompile(`(Uptime: +|"uptime_seconds": )[^\n,]+`)
+
 func TestMain(m *testing.M) {
 	var cleanup func()
 	var err error
@@ -48,5 +142,13 @@ func TestMain(m *testing.M) {
 	}
 	defer cleanup()
 
+	var daemonCleanup func()
+	daemonPath, daemonCleanup, err = testutils.BuildAuthdWithExampleBroker()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Setup: %v\n", err)
+		os.Exit(1)
+	}
+	defer daemonCleanup()
+
 	m.Run()
 }
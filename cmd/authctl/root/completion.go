@@ -0,0 +1,111 @@
+package root
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/internal/fileutils"
+	"github.com/spf13/cobra"
+)
+
+// completionCmd is a command to generate or install the shell completion scripts of authctl.
+var completionCmd = &cobra.Command{
+	Use:   "completion <bash|zsh|fish>",
+	Short: "Generate the shell completion script for authctl",
+	Long: `Generate the shell completion script for authctl and print it to the standard output.
+
+With --install, the script is instead written to the per-user completion
+directory of the shell:
+
+  bash: $XDG_DATA_HOME/bash-completion/completions/authctl
+  zsh:  $XDG_DATA_HOME/zsh/site-functions/_authctl
+  fish: $XDG_CONFIG_HOME/fish/completions/authctl.fish
+
+If XDG_DATA_HOME or XDG_CONFIG_HOME are not set, they default to ~/.local/share
+and ~/.config respectively. For zsh, the directory must be added to the fpath
+before compinit is called, for example in ~/.zshrc.`,
+	Example: `  # Load the completion for bash in the current shell
+  source <(authctl completion bash)
+
+  # Install the completion for fish
+  authctl completion fish --install`,
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return cmd.Usage()
+		}
+		shell := args[0]
+
+		var script bytes.Buffer
+		var err error
+		switch shell {
+		case "bash":
+			err = cmd.Root().GenBashCompletionV2(&script, true)
+		case "zsh":
+			err = cmd.Root().GenZshCompletion(&script)
+		case "fish":
+			err = cmd.Root().GenFishCompletion(&script, true)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to generate %s completion: %w", shell, err)
+		}
+
+		if !installCompletion {
+			_, err = cmd.OutOrStdout().Write(script.Bytes())
+			return err
+		}
+
+		path, err := completionInstallPath(shell)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to create completion directory: %w", err)
+		}
+		if err := fileutils.WriteFileAtomic(path, script.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("failed to install %s completion: %w", shell, err)
+		}
+
+		log.Infof("Installed %s completion to %s", shell, path)
+		return nil
+	},
+}
+
+var installCompletion bool
+
+func init() {
+	completionCmd.Flags().BoolVar(&installCompletion, "install", false, "write the script to the per-user completion directory of the shell")
+}
+
+// completionInstallPath returns the path of the per-user completion script for the given shell.
+func completionInstallPath(shell string) (string, error) {
+	baseDir := func(env, fallback string) (string, error) {
+		if dir := os.Getenv(env); dir != "" {
+			return dir, nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get the completion directory: %w", err)
+		}
+		return filepath.Join(home, fallback), nil
+	}
+
+	switch shell {
+	case "bash":
+		dir, err := baseDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+		return filepath.Join(dir, "bash-completion", "completions", "authctl"), err
+	case "zsh":
+		dir, err := baseDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+		return filepath.Join(dir, "zsh", "site-functions", "_authctl"), err
+	case "fish":
+		dir, err := baseDir("XDG_CONFIG_HOME", ".config")
+		return filepath.Join(dir, "fish", "completions", "authctl.fish"), err
+	default:
+		return "", errors.New("unsupported shell: " + shell)
+	}
+}
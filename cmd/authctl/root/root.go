@@ -2,7 +2,10 @@
 package root
 
 import (
+	"github.com/canonical/authd/cmd/authctl/broker"
 	"github.com/canonical/authd/cmd/authctl/group"
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
 	"github.com/canonical/authd/cmd/authctl/user"
 	"github.com/spf13/cobra"
 )
@@ -17,7 +20,8 @@ var RootCmd = &cobra.Command{
 		cmd.SilenceUsage = true
 	},
 	CompletionOptions: cobra.CompletionOptions{
-		HiddenDefaultCmd: true,
+		// We provide our own completion command, which can also install the scripts.
+		DisableDefaultCmd: true,
 	},
 	// Avoid the "Auto generated by spf13/cobra" line in the generated markdown docs
 	DisableAutoGenTag: true,
@@ -33,6 +37,12 @@ func init() {
 	// commands at the end.
 	cobra.EnableCommandSorting = false
 
+	output.AddFlag(RootCmd)
+	client.AddFlags(RootCmd)
+
 	RootCmd.AddCommand(user.UserCmd)
 	RootCmd.AddCommand(group.GroupCmd)
+	RootCmd.AddCommand(broker.BrokerCmd)
+	RootCmd.AddCommand(statusCmd)
+	RootCmd.AddCommand(completionCmd)
 }
@@ -0,0 +1,56 @@
+package root
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd is a command to check that the daemon is running and show its status.
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the authd daemon",
+	Long: `Show the version and uptime of the authd daemon, the number of users it
+manages, the default broker and the available brokers.
+
+The default broker is the first configured broker, or the local broker if no
+other broker is configured.
+
+The command exits with a non-zero status if the daemon can't be reached.`,
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: completion.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		status, err := client.GetDaemonStatus(ctx, &authd.Empty{})
+		if err != nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			return output.PrintJSON(cmd.OutOrStdout(), status)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 1, ' ', 0)
+		fmt.Fprintf(w, "Version:\t%s\n", status.Version)
+		fmt.Fprintf(w, "Uptime:\t%s\n", time.Duration(status.UptimeSeconds)*time.Second)
+		fmt.Fprintf(w, "Users:\t%d\n", status.NumUsers)
+		fmt.Fprintf(w, "Default broker:\t%s\n", status.DefaultBroker)
+		fmt.Fprintf(w, "Available brokers:\t%s\n", strings.Join(status.Brokers, ", "))
+
+		return w.Flush()
+	},
+}
@@ -0,0 +1,124 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/cmd/authctl/internal/prompt"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// deleteCmd is a command to delete a user managed by authd.
+var deleteCmd = &cobra.Command{
+	Use:   "delete <user>",
+	Short: "Delete a user managed by authd",
+	Long: `Delete a user managed by authd.
+
+The user is removed from the authd database and from the local groups they
+were added to. Their private group is removed too, unless it has other members.
+The command must be run as root and fails if the user has running processes.
+
+By default, the user's home directory is left in place. With --remove-home, it
+is removed as well, unless it is not owned by the user.
+
+The command asks for confirmation before deleting the user, unless --force is
+given. --force is required if the standard input is not a terminal. If the
+user logs in again, they are recreated with a new UID.
+
+With --dry-run, the command prints what would be deleted without asking for
+confirmation and without deleting anything.`,
+	Example: `  # Delete user "alice", keeping their home directory
+  authctl user delete alice
+
+  # Delete user "alice" and their home directory without asking for confirmation
+  authctl user delete --remove-home --force alice
+
+  # Print what would be deleted, without deleting anything
+  authctl user delete --remove-home --dry-run alice`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		if dryRun {
+			return printDryRun(cmd, name, func(u *authd.User) (string, error) {
+				action := fmt.Sprintf("delete user '%s' (UID %d)", u.Name, u.Uid)
+				if deleteRemoveHome {
+					action += fmt.Sprintf(" and remove their home directory %s", u.Homedir)
+				}
+				return action, nil
+			})
+		}
+
+		if !deleteForce {
+			confirmed, err := confirmDelete(name)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				return errors.New("aborted: user was not deleted")
+			}
+		}
+
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.DeleteUser(ctx, &authd.DeleteUserRequest{
+			Name:       name,
+			RemoveHome: deleteRemoveHome,
+			Lang:       os.Getenv("LANG"),
+		})
+		if resp == nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			if jsonErr := output.PrintJSON(cmd.OutOrStdout(), resp); jsonErr != nil {
+				return jsonErr
+			}
+			return err
+		}
+
+		log.Infof("User '%s' deleted.", name)
+		if resp.HomeDirRemoved {
+			log.Info("Removed the user's home directory.")
+		}
+
+		// Print any warnings returned by the server.
+		for _, warning := range resp.Warnings {
+			log.Warning(warning)
+		}
+
+		return err
+	},
+}
+
+var (
+	deleteRemoveHome bool
+	deleteForce      bool
+)
+
+func init() {
+	deleteCmd.Flags().BoolVar(&deleteRemoveHome, "remove-home", false, "also remove the home directory of the user")
+	deleteCmd.Flags().BoolVar(&deleteForce, "force", false, "do not ask for confirmation")
+}
+
+// confirmDelete asks the user for confirmation before deleting the user with the given name.
+func confirmDelete(name string) (bool, error) {
+	question := fmt.Sprintf("Delete user '%s'", name)
+	if deleteRemoveHome {
+		question += " and their home directory"
+	}
+	return prompt.Confirm(question + "?")
+}
@@ -0,0 +1,51 @@
+package user_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"google.golang.org/grpc/codes"
+)
+
+func TestUserDeleteCommand(t *testing.T) {
+	// We can't run these tests in parallel because the daemon with the example
+	// broker which we're using here uses userslocking.Z_ForTests_OverrideLocking()
+	// which makes userslocking.WriteLock() return an error immediately when the lock
+	// is already held - unlike the normal behavior which tries to acquire the lock
+	// for 15 seconds before returning an error.
+
+	tests := map[string]struct {
+		args []string
+
+		expectedExitCode int
+	}{
+		"Delete_user_success":                  {args: []string{"delete", "--force", "user1@example.com"}},
+		"Delete_user_success_with_JSON_output": {args: []string{"delete", "--force", "user1@example.com", "--output", "json"}},
+		"Delete_user_and_home_directory":       {args: []string{"delete", "--force", "--remove-home", "user1@example.com"}},
+
+		"Error_when_not_forced_and_stdin_is_not_a_terminal": {args: []string{"delete", "user1@example.com"}, expectedExitCode: 1},
+		"Error_when_no_user_is_given":                       {args: []string{"delete", "--force"}, expectedExitCode: 1},
+		"Error_when_user_does_not_exist":                    {args: []string{"delete", "--force", "invaliduser"}, expectedExitCode: int(codes.NotFound)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Each test case deletes the user, so we need a separate daemon for each of them.
+			daemonSocket := testutils.StartAuthd(t, daemonPath,
+				testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+				testutils.WithPreviousDBState("one_user_and_group"),
+				testutils.WithCurrentUserAsRoot,
+			)
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			cmd.Env = append(cmd.Environ(), "AUTHD_SOCKET="+daemonSocket)
+			// The standard input is not a terminal, so the answer must be ignored unless --force is given.
+			cmd.Stdin = strings.NewReader("y\n")
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package user
+
+import (
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// dryRun is set via the persistent --dry-run flag of the user command. Commands which change users must check it
+// before sending their request, and call printDryRun instead.
+var dryRun bool
+
+// printDryRun resolves the user with the given name and prints the action returned by describe, without doing it.
+// describe returns an error if the action is not valid for the user.
+func printDryRun(cmd *cobra.Command, name string, describe func(u *authd.User) (string, error)) error {
+	ctx, cancel := client.Context(cmd.Context())
+	defer cancel()
+
+	client, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	u, err := client.GetUserByName(ctx, &authd.GetUserByNameRequest{Name: name})
+	if err != nil {
+		return err
+	}
+
+	action, err := describe(u)
+	if err != nil {
+		return err
+	}
+
+	if output.IsJSON() {
+		return output.PrintJSON(cmd.OutOrStdout(), output.DryRun{Name: u.Name, Action: action, DryRun: true})
+	}
+
+	log.Infof("Dry run: would %s.", action)
+	return nil
+}
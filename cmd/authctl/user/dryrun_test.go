@@ -0,0 +1,78 @@
+package user_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"google.golang.org/grpc/codes"
+)
+
+func TestDryRun(t *testing.T) {
+	// The commands are run with --dry-run, so they don't change the users and can share the same daemon.
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithPreviousDBState("two_users_one_locked"),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	tests := map[string]struct {
+		args  []string
+		stdin string
+
+		expectedExitCode int
+	}{
+		"Delete_user":                        {args: []string{"delete", "--dry-run", "user1@example.com"}},
+		"Delete_user_and_home_directory":     {args: []string{"delete", "--dry-run", "--remove-home", "user1@example.com"}},
+		"Delete_user_with_JSON_output":       {args: []string{"delete", "--dry-run", "user1@example.com", "--output", "json"}},
+		"Set_user_home":                      {args: []string{"set-home", "--dry-run", "user1@example.com", "/home/user1-new"}},
+		"Set_user_home_and_move_its_content": {args: []string{"set-home", "--dry-run", "--move", "user1@example.com", "/home/user1-new/"}},
+		"Set_user_home_to_the_same_value":    {args: []string{"set-home", "--dry-run", "user1@example.com", "/home/user1@example.com"}},
+		"Set_user_shell":                     {args: []string{"set-shell", "--dry-run", "user1@example.com", "/bin/sh"}},
+		"Set_user_shell_to_the_same_value":   {args: []string{"set-shell", "--dry-run", "user1@example.com", "/bin/bash"}},
+		"Set_user_uid":                       {args: []string{"set-uid", "--dry-run", "user1@example.com", "123456"}},
+		"Lock_user":                          {args: []string{"lock", "--dry-run", "user1@example.com"}},
+		"Lock_already_locked_user":           {args: []string{"lock", "--dry-run", "user2-with-a-longer-name@example.com"}},
+		"Unlock_user":                        {args: []string{"unlock", "--dry-run", "user2-with-a-longer-name@example.com"}},
+		"Unlock_users_from_stdin": {
+			args:  []string{"unlock", "--dry-run", "--from-file", "-"},
+			stdin: "user1@example.com\nuser2-with-a-longer-name@example.com\n",
+		},
+
+		"Error_when_user_does_not_exist": {
+			args:             []string{"delete", "--dry-run", "invaliduser"},
+			expectedExitCode: int(codes.NotFound),
+		},
+		"Error_when_home_is_not_absolute": {
+			args:             []string{"set-home", "--dry-run", "user1@example.com", "home/user1"},
+			expectedExitCode: 1,
+		},
+		"Error_when_shell_is_not_absolute": {
+			args:             []string{"set-shell", "--dry-run", "user1@example.com", "bash"},
+			expectedExitCode: 1,
+		},
+		"Error_when_uid_is_invalid": {
+			args:             []string{"set-uid", "--dry-run", "user1@example.com", "invaliduid"},
+			expectedExitCode: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			cmd.Env = append(cmd.Environ(), "AUTHD_SOCKET="+daemonSocket)
+			cmd.Stdin = strings.NewReader(tc.stdin)
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+
+	t.Run("Users_are_not_changed", func(t *testing.T) {
+		//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+		cmd := exec.Command(authctlPath, "user", "list")
+		cmd.Env = append(cmd.Environ(), "AUTHD_SOCKET="+daemonSocket)
+		testutils.CheckCommand(t, cmd, 0)
+	})
+}
@@ -0,0 +1,185 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// listColumnWidths are the widths of the columns of the user list when it's not aligned to its content.
+// Values which are longer than their column are printed in full, followed by the column separator.
+var listColumnWidths = []int{32, 8, 8, 40, 16, 0}
+
+// listCmd is a command to list the users managed by authd.
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the users managed by authd",
+	Long: `List the users managed by authd, with their UID, GID, home directory, shell and whether they are locked.
+
+Users are printed as they are received from authd, so the columns have a fixed
+width. With --align, all users are received before printing them, so that the
+columns can be aligned to their content. This uses more memory for long lists.`,
+	Example: `  # List all users
+  authctl user list
+
+  # List only the locked users
+  authctl user list --locked`,
+	Args:              cobra.NoArgs,
+	ValidArgsFunction: completion.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		stream, err := client.StreamUsers(ctx, &authd.Empty{})
+		if err != nil {
+			return err
+		}
+
+		var p userPrinter
+		switch {
+		case output.IsJSON():
+			p, err = newJSONUserPrinter(cmd.OutOrStdout())
+		case listAlign:
+			p = newAlignedUserPrinter(cmd.OutOrStdout())
+		default:
+			p, err = newFixedWidthUserPrinter(cmd.OutOrStdout())
+		}
+		if err != nil {
+			return err
+		}
+
+		for {
+			u, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if listLockedOnly && !u.Locked {
+				continue
+			}
+			if err := p.print(u); err != nil {
+				return err
+			}
+		}
+
+		return p.close()
+	},
+}
+
+var (
+	listLockedOnly bool
+	listAlign      bool
+)
+
+func init() {
+	listCmd.Flags().BoolVar(&listLockedOnly, "locked", false, "only list locked users")
+	listCmd.Flags().BoolVar(&listAlign, "align", false, "align the columns to their content, printing the users once all have been received")
+}
+
+// userPrinter prints the users of the list as they are received.
+type userPrinter interface {
+	print(u *authd.User) error
+	close() error
+}
+
+// listHeader is the header of the user list in text format.
+var listHeader = []string{"NAME", "UID", "GID", "HOME", "SHELL", "LOCKED"}
+
+func userRow(u *authd.User) []string {
+	return []string{u.Name, fmt.Sprint(u.Uid), fmt.Sprint(u.Gid), u.Homedir, u.Shell, fmt.Sprint(u.Locked)}
+}
+
+// fixedWidthUserPrinter prints each user as soon as it is received, with columns of fixed width.
+type fixedWidthUserPrinter struct {
+	w io.Writer
+}
+
+func newFixedWidthUserPrinter(w io.Writer) (*fixedWidthUserPrinter, error) {
+	p := &fixedWidthUserPrinter{w: w}
+	if err := p.printRow(listHeader); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *fixedWidthUserPrinter) print(u *authd.User) error {
+	return p.printRow(userRow(u))
+}
+
+func (p *fixedWidthUserPrinter) printRow(cells []string) error {
+	var b strings.Builder
+	for i, cell := range cells {
+		b.WriteString(cell)
+		if i == len(cells)-1 {
+			break
+		}
+		// Pad the cell to the width of the column, keeping at least two spaces between columns.
+		b.WriteString(strings.Repeat(" ", max(listColumnWidths[i]-len(cell), 0)+2))
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(p.w, b.String())
+	return err
+}
+
+func (p *fixedWidthUserPrinter) close() error {
+	return nil
+}
+
+// alignedUserPrinter buffers all users and prints them once the list is complete, with columns aligned to their
+// content.
+type alignedUserPrinter struct {
+	tw *tabwriter.Writer
+}
+
+func newAlignedUserPrinter(w io.Writer) *alignedUserPrinter {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(listHeader, "\t"))
+	return &alignedUserPrinter{tw: tw}
+}
+
+func (p *alignedUserPrinter) print(u *authd.User) error {
+	_, err := fmt.Fprintln(p.tw, strings.Join(userRow(u), "\t"))
+	return err
+}
+
+func (p *alignedUserPrinter) close() error {
+	return p.tw.Flush()
+}
+
+// jsonUserPrinter prints each user as soon as it is received, as an element of the users list of a JSON object.
+type jsonUserPrinter struct {
+	lw *output.JSONListWriter
+}
+
+func newJSONUserPrinter(w io.Writer) (*jsonUserPrinter, error) {
+	lw, err := output.NewJSONListWriter(w, "users")
+	if err != nil {
+		return nil, err
+	}
+	return &jsonUserPrinter{lw: lw}, nil
+}
+
+func (p *jsonUserPrinter) print(u *authd.User) error {
+	return p.lw.Add(u)
+}
+
+func (p *jsonUserPrinter) close() error {
+	return p.lw.Close()
+}
@@ -0,0 +1,99 @@
+package user_test
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestUserListCommand(t *testing.T) {
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithPreviousDBState("two_users_one_locked"),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	err := os.Setenv("AUTHD_SOCKET", daemonSocket)
+	require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
+
+	tests := map[string]struct {
+		args               []string
+		authdUnavailable   bool
+		authdNotResponding bool
+
+		expectedExitCode int
+	}{
+		"List_all_users":         {args: []string{"list"}, expectedExitCode: 0},
+		"List_only_locked_users": {args: []string{"list", "--locked"}, expectedExitCode: 0},
+		"List_users_aligned":     {args: []string{"list", "--align"}, expectedExitCode: 0},
+		"List_users_as_JSON":     {args: []string{"list", "--output", "json"}, expectedExitCode: 0},
+		"List_users_with_socket_flag_overriding_the_environment": {
+			args:             []string{"list", "--socket", daemonSocket},
+			authdUnavailable: true,
+			expectedExitCode: 0,
+		},
+
+		"Error_on_extra_argument": {args: []string{"list", "user1@example.com"}, expectedExitCode: 1},
+		"Error_when_authd_is_unavailable": {
+			args:             []string{"list"},
+			authdUnavailable: true,
+			expectedExitCode: int(codes.Unavailable),
+		},
+		"Error_when_authd_does_not_respond_in_time": {
+			args:               []string{"list", "--timeout", "1s"},
+			authdNotResponding: true,
+			expectedExitCode:   int(codes.DeadlineExceeded),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.authdUnavailable {
+				origValue := os.Getenv("AUTHD_SOCKET")
+				err := os.Setenv("AUTHD_SOCKET", "/non-existent")
+				require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
+				t.Cleanup(func() {
+					err := os.Setenv("AUTHD_SOCKET", origValue)
+					require.NoError(t, err, "Failed to restore AUTHD_SOCKET environment variable")
+				})
+			}
+
+			args := tc.args
+			if tc.authdNotResponding {
+				args = append(args, "--socket", unresponsiveSocket(t))
+			}
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, args...)...)
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
+
+// unresponsiveSocket returns the path of a unix socket which accepts connections but never responds.
+func unresponsiveSocket(t *testing.T) string {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "authd.sock")
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err, "Setup: failed to listen on unix socket")
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+
+	return socketPath
+}
@@ -1,32 +1,74 @@
 package user
 
 import (
-	"context"
+	"fmt"
 
 	"github.com/canonical/authd/cmd/authctl/internal/client"
 	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/spf13/cobra"
 )
 
 // lockCmd is a command to lock (disable) a user.
 var lockCmd = &cobra.Command{
-	Use:               "lock <user>",
-	Short:             "Lock (disable) a user managed by authd",
-	Long:              `Lock a user so that they cannot log in.`,
+	Use:   "lock <user>",
+	Short: "Lock (disable) a user managed by authd",
+	Long: `Lock a user so that they cannot log in.
+
+Nothing is printed on success, unless --verbose is given. With --output json,
+the lock state of the user before the command is printed as well.
+
+With --dry-run, the command prints what would be changed without changing
+anything.`,
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: completion.Users,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if dryRun {
+			return printDryRun(cmd, args[0], func(u *authd.User) (string, error) {
+				if u.Locked {
+					return fmt.Sprintf("leave user '%s' locked, it is already locked", u.Name), nil
+				}
+				return fmt.Sprintf("lock user '%s'", u.Name), nil
+			})
+		}
+
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
 		client, err := client.NewUserServiceClient()
 		if err != nil {
 			return err
 		}
 
-		_, err = client.LockUser(context.Background(), &authd.LockUserRequest{Name: args[0]})
+		resp, err := client.LockUser(ctx, &authd.LockUserRequest{Name: args[0]})
 		if err != nil {
 			return err
 		}
 
-		return nil
+		if lockVerbose && !output.IsJSON() {
+			if resp.WasLocked {
+				log.Infof("User '%s' was already locked.", args[0])
+			} else {
+				log.Infof("User '%s' locked.", args[0])
+			}
+		}
+
+		return output.PrintStatusChange(cmd.OutOrStdout(), args[0], lockStatus(resp.WasLocked), "locked")
 	},
 }
+
+var lockVerbose bool
+
+func init() {
+	lockCmd.Flags().BoolVarP(&lockVerbose, "verbose", "v", false, "print whether the user was already locked")
+}
+
+// lockStatus returns the status of a user with the given lock state, as printed in the results of lock and unlock.
+func lockStatus(locked bool) string {
+	if locked {
+		return "locked"
+	}
+	return "unlocked"
+}
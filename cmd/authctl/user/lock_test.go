@@ -1,33 +1,29 @@
 package user_test
 
 import (
-	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
 
 	"github.com/canonical/authd/internal/testutils"
-	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 )
 
 func TestUserLockCommand(t *testing.T) {
 	t.Parallel()
 
-	daemonSocket := testutils.StartAuthd(t, daemonPath,
-		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
-		testutils.WithPreviousDBState("one_user_and_group"),
-		testutils.WithCurrentUserAsRoot,
-	)
-
-	err := os.Setenv("AUTHD_SOCKET", daemonSocket)
-	require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
-
 	tests := map[string]struct {
 		args             []string
 		expectedExitCode int
 	}{
-		"Lock_user_success": {args: []string{"lock", "user1@example.com"}, expectedExitCode: 0},
+		"Lock_user_success":                            {args: []string{"lock", "user1@example.com"}, expectedExitCode: 0},
+		"Lock_user_success_with_JSON_output":           {args: []string{"lock", "user1@example.com", "--output", "json"}, expectedExitCode: 0},
+		"Lock_user_success_with_verbose_output":        {args: []string{"lock", "--verbose", "user1@example.com"}, expectedExitCode: 0},
+		"Lock_already_locked_user_with_verbose_output": {args: []string{"lock", "--verbose", "user2-with-a-longer-name@example.com"}, expectedExitCode: 0},
+		"Lock_already_locked_user_with_JSON_output": {
+			args:             []string{"lock", "user2-with-a-longer-name@example.com", "--output", "json"},
+			expectedExitCode: 0,
+		},
 
 		"Error_locking_invalid_user": {args: []string{"lock", "invaliduser"}, expectedExitCode: int(codes.NotFound)},
 	}
@@ -36,8 +32,16 @@ func TestUserLockCommand(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			// The output depends on the lock state of the user, so we need a separate daemon for each test case.
+			daemonSocket := testutils.StartAuthd(t, daemonPath,
+				testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+				testutils.WithPreviousDBState("two_users_one_locked"),
+				testutils.WithCurrentUserAsRoot,
+			)
+
 			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
 			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			cmd.Env = append(cmd.Environ(), "AUTHD_SOCKET="+daemonSocket)
 			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
 		})
 	}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/cmd/authctl/internal/prompt"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// setHomeCmd is a command to set the home directory of a user managed by authd.
+var setHomeCmd = &cobra.Command{
+	Use:   "set-home <user> <home>",
+	Short: "Set the home directory of a user managed by authd",
+	Long: `Set the home directory of a user managed by authd to the specified path.
+
+The new home directory must be an absolute path under /home. The command must
+be run as root.
+
+By default, only the user record is updated. With --move, the content of the
+current home directory is moved to the new one, which must not exist or be
+empty. The user record is only updated once the content has been moved. If the
+new home directory is on a different filesystem, the content is copied and the
+previous home directory is removed afterwards. The command asks for
+confirmation before moving the content, unless --force is given. --force is
+required if the standard input is not a terminal.
+
+The new home directory is kept when the user logs in again.
+
+With --dry-run, the command prints what would be changed without asking for
+confirmation and without changing anything. Only the existence of the user and
+the format of the path are checked, so the change can still be refused.`,
+	Example: `  # Set the home directory of user "alice" to /home/alice-new
+  authctl user set-home alice /home/alice-new
+
+  # Set the home directory of user "alice" and move its content without asking for confirmation
+  authctl user set-home --move --force alice /home/alice-new`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: setHomeCompletionFunc,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		home := args[1]
+
+		if dryRun {
+			return printDryRun(cmd, name, func(u *authd.User) (string, error) {
+				if !filepath.IsAbs(home) {
+					return "", fmt.Errorf("home directory %q is not an absolute path", home)
+				}
+				home := filepath.Clean(home)
+				if home == u.Homedir {
+					return fmt.Sprintf("leave the home directory of user '%s' unchanged, it is already %s", u.Name, home), nil
+				}
+				action := fmt.Sprintf("set the home directory of user '%s' from %s to %s", u.Name, u.Homedir, home)
+				if setHomeMove {
+					action += " and move its content"
+				}
+				return action, nil
+			})
+		}
+
+		if setHomeMove && !setHomeForce {
+			confirmed, err := prompt.Confirm(fmt.Sprintf("Move the home directory of user '%s' to %s?", name, home))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				return errors.New("aborted: home directory was not changed")
+			}
+		}
+
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.SetUserHome(ctx, &authd.SetUserHomeRequest{
+			Name: name,
+			Home: home,
+			Move: setHomeMove,
+			Lang: os.Getenv("LANG"),
+		})
+		if err != nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			return output.PrintJSON(cmd.OutOrStdout(), resp)
+		}
+
+		log.Infof("Home directory of user '%s' set to %s.", name, home)
+		if resp.HomeDirMoved {
+			log.Info("Moved the content of the previous home directory.")
+		}
+
+		// Print any warnings returned by the server.
+		for _, warning := range resp.Warnings {
+			log.Warning(warning)
+		}
+
+		return nil
+	},
+}
+
+var (
+	setHomeMove  bool
+	setHomeForce bool
+)
+
+func init() {
+	setHomeCmd.Flags().BoolVar(&setHomeMove, "move", false, "move the content of the current home directory to the new one")
+	setHomeCmd.Flags().BoolVar(&setHomeForce, "force", false, "do not ask for confirmation before moving the content")
+}
+
+func setHomeCompletionFunc(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completion.Users(cmd, args, toComplete)
+	}
+	if len(args) == 1 {
+		// Complete the home directory with directory names.
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
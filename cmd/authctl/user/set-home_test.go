@@ -0,0 +1,80 @@
+package user_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSetHomeCommand(t *testing.T) {
+	// We can't run these tests in parallel because the daemon with the example
+	// broker which we're using here uses userslocking.Z_ForTests_OverrideLocking()
+	// which makes userslocking.WriteLock() return an error immediately when the lock
+	// is already held - unlike the normal behavior which tries to acquire the lock
+	// for 15 seconds before returning an error.
+
+	tests := map[string]struct {
+		args             []string
+		authdUnavailable bool
+
+		expectedExitCode int
+	}{
+		"Set_user_home_success": {
+			args: []string{"set-home", "user1@example.com", "/home/user1-new"},
+		},
+		"Set_user_home_success_with_JSON_output": {
+			args: []string{"set-home", "user1@example.com", "/home/user1-new", "--output", "json"},
+		},
+		"Set_user_home_with_move_warns_if_home_does_not_exist": {
+			args: []string{"set-home", "--move", "--force", "user1@example.com", "/home/user1-new"},
+		},
+
+		"Error_when_user_does_not_exist": {
+			args:             []string{"set-home", "invaliduser", "/home/invaliduser"},
+			expectedExitCode: int(codes.NotFound),
+		},
+		"Error_when_home_is_not_absolute": {
+			args:             []string{"set-home", "user1@example.com", "home/user1"},
+			expectedExitCode: int(codes.Unknown),
+		},
+		"Error_when_home_is_not_under_allowed_base": {
+			args:             []string{"set-home", "user1@example.com", "/srv/user1"},
+			expectedExitCode: int(codes.Unknown),
+		},
+		"Error_when_move_is_not_forced_and_stdin_is_not_a_terminal": {
+			args:             []string{"set-home", "--move", "user1@example.com", "/home/user1-new"},
+			expectedExitCode: 1,
+		},
+		"Error_when_no_home_is_given": {
+			args:             []string{"set-home", "user1@example.com"},
+			expectedExitCode: 1,
+		},
+		"Error_when_authd_is_unavailable": {
+			args:             []string{"set-home", "user1@example.com", "/home/user1-new"},
+			authdUnavailable: true,
+			expectedExitCode: int(codes.Unavailable),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Each test case changes the home directory of the user, so we need a separate daemon for each of them.
+			daemonSocket := "/non-existent"
+			if !tc.authdUnavailable {
+				daemonSocket = testutils.StartAuthd(t, daemonPath,
+					testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+					testutils.WithPreviousDBState("one_user_and_group"),
+					testutils.WithCurrentUserAsRoot,
+				)
+			}
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			cmd.Env = append(cmd.Environ(), "AUTHD_SOCKET="+daemonSocket)
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package user
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+)
+
+// setShellCmd is a command to set the login shell of a user managed by authd.
+var setShellCmd = &cobra.Command{
+	Use:   "set-shell <user> <shell>",
+	Short: "Set the login shell of a user managed by authd",
+	Long: `Set the login shell of a user managed by authd to the specified value.
+
+The shell must be the absolute path of an existing executable file and, unless
+--force is given, it must be listed in /etc/shells. The command must be run as
+root.
+
+The new shell is kept when the user logs in again.
+
+With --dry-run, the command prints what would be changed without changing
+anything. Only the existence of the user and the format of the path are
+checked, so the change can still be refused.`,
+	Example: `  # Set the login shell of user "alice" to /bin/bash
+  authctl user set-shell alice /bin/bash`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: setShellCompletionFunc,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		shell := args[1]
+
+		if dryRun {
+			return printDryRun(cmd, name, func(u *authd.User) (string, error) {
+				if !filepath.IsAbs(shell) {
+					return "", fmt.Errorf("shell %q is not an absolute path", shell)
+				}
+				if shell == u.Shell {
+					return fmt.Sprintf("leave the shell of user '%s' unchanged, it is already %s", u.Name, shell), nil
+				}
+				return fmt.Sprintf("set the shell of user '%s' from %s to %s", u.Name, u.Shell, shell), nil
+			})
+		}
+
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.SetUserShell(ctx, &authd.SetUserShellRequest{
+			Name:  name,
+			Shell: shell,
+			Force: setShellForce,
+		})
+		if err != nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			return output.PrintStatus(cmd.OutOrStdout(), name, "shell set")
+		}
+
+		log.Infof("Shell of user '%s' set to %s.", name, shell)
+		return nil
+	},
+}
+
+var setShellForce bool
+
+func init() {
+	setShellCmd.Flags().BoolVar(&setShellForce, "force", false, "set the shell even if it is not listed in /etc/shells")
+}
+
+func setShellCompletionFunc(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completion.Users(cmd, args, toComplete)
+	}
+	if len(args) == 1 {
+		// Complete the shell with file names.
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,93 @@
+package user_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSetShellCommand(t *testing.T) {
+	// We can't run these tests in parallel because the daemon with the example
+	// broker which we're using here uses userslocking.Z_ForTests_OverrideLocking()
+	// which makes userslocking.WriteLock() return an error immediately when the lock
+	// is already held - unlike the normal behavior which tries to acquire the lock
+	// for 15 seconds before returning an error.
+
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithPreviousDBState("one_user_and_group"),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	err := os.Setenv("AUTHD_SOCKET", daemonSocket)
+	require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
+
+	tests := map[string]struct {
+		args             []string
+		authdUnavailable bool
+
+		expectedExitCode int
+	}{
+		"Set_user_shell_success": {
+			args:             []string{"set-shell", "user1@example.com", "/bin/bash"},
+			expectedExitCode: 0,
+		},
+		"Set_user_shell_success_with_JSON_output": {
+			args:             []string{"set-shell", "user1@example.com", "/bin/sh", "--output", "json"},
+			expectedExitCode: 0,
+		},
+		"Set_user_shell_not_listed_in_etc_shells_with_force": {
+			args:             []string{"set-shell", "--force", "user1@example.com", "/usr/bin/env"},
+			expectedExitCode: 0,
+		},
+
+		"Error_when_user_does_not_exist": {
+			args:             []string{"set-shell", "invaliduser", "/bin/bash"},
+			expectedExitCode: int(codes.NotFound),
+		},
+		"Error_when_shell_is_not_absolute": {
+			args:             []string{"set-shell", "user1@example.com", "bash"},
+			expectedExitCode: int(codes.Unknown),
+		},
+		"Error_when_shell_does_not_exist": {
+			args:             []string{"set-shell", "user1@example.com", "/non-existent/shell"},
+			expectedExitCode: int(codes.Unknown),
+		},
+		"Error_when_shell_is_not_listed_in_etc_shells": {
+			args:             []string{"set-shell", "user1@example.com", "/usr/bin/env"},
+			expectedExitCode: int(codes.Unknown),
+		},
+		"Error_when_no_shell_is_given": {
+			args:             []string{"set-shell", "user1@example.com"},
+			expectedExitCode: 1,
+		},
+		"Error_when_authd_is_unavailable": {
+			args:             []string{"set-shell", "user1@example.com", "/bin/bash"},
+			authdUnavailable: true,
+			expectedExitCode: int(codes.Unavailable),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.authdUnavailable {
+				origValue := os.Getenv("AUTHD_SOCKET")
+				err := os.Setenv("AUTHD_SOCKET", "/non-existent")
+				require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
+				t.Cleanup(func() {
+					err := os.Setenv("AUTHD_SOCKET", origValue)
+					require.NoError(t, err, "Failed to restore AUTHD_SOCKET environment variable")
+				})
+			}
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
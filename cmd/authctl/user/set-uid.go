@@ -1,7 +1,6 @@
 package user
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -10,6 +9,7 @@ import (
 	"github.com/canonical/authd/cmd/authctl/internal/client"
 	"github.com/canonical/authd/cmd/authctl/internal/completion"
 	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/spf13/cobra"
 )
@@ -28,7 +28,11 @@ that the user owns, will automatically be updated to the new UID.
 Files outside the user's home directory are not updated and must be changed
 manually. Note that changing a UID can be unsafe if files on the system are
 still owned by the original UID: those files may become accessible to a
-different account that is later assigned that UID.`,
+different account that is later assigned that UID.
+
+With --dry-run, the command prints what would be changed without changing
+anything. Only the existence of the user and the format of the UID are
+checked, so the change can still be refused, for example if the UID is in use.`,
 	Example: `  # Set the UID of user "alice" to 15000
   authctl user set-uid alice 15000`,
 	Args:              cobra.ExactArgs(2),
@@ -46,12 +50,24 @@ different account that is later assigned that UID.`,
 			return fmt.Errorf("failed to parse UID %q: %w", uidStr, err)
 		}
 
+		if dryRun {
+			return printDryRun(cmd, name, func(u *authd.User) (string, error) {
+				if uint32(uid) == u.Uid {
+					return fmt.Sprintf("leave the UID of user '%s' unchanged, it is already %d", u.Name, uid), nil
+				}
+				return fmt.Sprintf("set the UID of user '%s' from %d to %d", u.Name, u.Uid, uid), nil
+			})
+		}
+
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
 		client, err := client.NewUserServiceClient()
 		if err != nil {
 			return err
 		}
 
-		resp, err := client.SetUserID(context.Background(), &authd.SetUserIDRequest{
+		resp, err := client.SetUserID(ctx, &authd.SetUserIDRequest{
 			Name: name,
 			Id:   uint32(uid),
 			Lang: os.Getenv("LANG"),
@@ -60,6 +76,13 @@ different account that is later assigned that UID.`,
 			return err
 		}
 
+		if output.IsJSON() {
+			if jsonErr := output.PrintJSON(cmd.OutOrStdout(), resp); jsonErr != nil {
+				return jsonErr
+			}
+			return err
+		}
+
 		if resp.IdChanged {
 			log.Infof("UID of user '%s' set to %d.", name, uid)
 			if resp.HomeDirOwnerChanged {
@@ -39,6 +39,10 @@ func TestSetUIDCommand(t *testing.T) {
 			args:             []string{"set-uid", "user1@example.com", "123456"},
 			expectedExitCode: 0,
 		},
+		"Set_user_uid_success_with_JSON_output": {
+			args:             []string{"set-uid", "user1@example.com", "123457", "--output", "json"},
+			expectedExitCode: 0,
+		},
 
 		"Error_when_user_does_not_exist": {
 			args:             []string{"set-uid", "invaliduser", "123456"},
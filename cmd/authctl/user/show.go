@@ -0,0 +1,64 @@
+package user
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/canonical/authd/cmd/authctl/internal/client"
+	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/proto/authd"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// showCmd is a command to show the details of a user managed by authd.
+var showCmd = &cobra.Command{
+	Use:   "show <user>",
+	Short: "Show the details of a user managed by authd",
+	Long:  `Show the name, UID, GID, gecos, home directory, shell, lock state and broker of a user managed by authd.`,
+	Example: `  # Show the details of user "alice"
+  authctl user show alice`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completion.Users,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
+		client, err := client.NewUserServiceClient()
+		if err != nil {
+			return err
+		}
+
+		user, err := client.GetUserByName(ctx, &authd.GetUserByNameRequest{Name: args[0]})
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("no such authd user: %q", args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		if output.IsJSON() {
+			return output.PrintJSON(cmd.OutOrStdout(), user)
+		}
+
+		w := cmd.OutOrStdout()
+		printField := func(name, value string) {
+			const width = len("Locked: ") + 1
+			// Indent the continuation lines of multi-line values, like the gecos, to align them with the first one.
+			value = strings.ReplaceAll(value, "\n", "\n"+strings.Repeat(" ", width))
+			fmt.Fprintf(w, "%-*s%s\n", width, name+":", value)
+		}
+		printField("Name", user.Name)
+		printField("UID", fmt.Sprint(user.Uid))
+		printField("GID", fmt.Sprint(user.Gid))
+		printField("Gecos", user.Gecos)
+		printField("Home", user.Homedir)
+		printField("Shell", user.Shell)
+		printField("Locked", fmt.Sprint(user.Locked))
+		printField("Broker", user.Broker)
+
+		return nil
+	},
+}
@@ -0,0 +1,41 @@
+package user_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserShowCommand(t *testing.T) {
+	daemonSocket := testutils.StartAuthd(t, daemonPath,
+		testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+		testutils.WithPreviousDBState("two_users_one_locked"),
+		testutils.WithCurrentUserAsRoot,
+	)
+
+	err := os.Setenv("AUTHD_SOCKET", daemonSocket)
+	require.NoError(t, err, "Failed to set AUTHD_SOCKET environment variable")
+
+	tests := map[string]struct {
+		args             []string
+		expectedExitCode int
+	}{
+		"Show_user":                      {args: []string{"show", "user1@example.com"}, expectedExitCode: 0},
+		"Show_locked_user":               {args: []string{"show", "user2-with-a-longer-name@example.com"}, expectedExitCode: 0},
+		"Show_user_as_JSON":              {args: []string{"show", "user1@example.com", "--output", "json"}, expectedExitCode: 0},
+		"Error_when_user_does_not_exist": {args: []string{"show", "invaliduser"}, expectedExitCode: 1},
+		"Error_when_no_user_is_given":    {args: []string{"show"}, expectedExitCode: 1},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
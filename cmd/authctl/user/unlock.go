@@ -2,31 +2,194 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 
 	"github.com/canonical/authd/cmd/authctl/internal/client"
 	"github.com/canonical/authd/cmd/authctl/internal/completion"
+	"github.com/canonical/authd/cmd/authctl/internal/log"
+	"github.com/canonical/authd/cmd/authctl/internal/output"
+	"github.com/canonical/authd/internal/fileutils"
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
 )
 
 // unlockCmd is a command to unlock (enable) a user.
 var unlockCmd = &cobra.Command{
-	Use:               "unlock <user>",
-	Short:             "Unlock (enable) a user managed by authd",
-	Long:              `Unlock a locked user so that they can log in again.`,
-	Args:              cobra.ExactArgs(1),
+	Use:   "unlock <user>",
+	Short: "Unlock (enable) a user managed by authd",
+	Long: `Unlock a locked user so that they can log in again.
+
+With --from-file, the users to unlock are read from the given file, or from
+the standard input if the file is "-", one user name per line. Empty lines and
+lines starting with "#" are ignored. All users are processed even if unlocking
+some of them fails, and the result for each user is printed at the end.
+
+Nothing is printed on success when unlocking a single user, unless --verbose
+is given. With --output json, the lock state of the users before the command is
+printed as well.
+
+With --dry-run, the command prints what would be changed without changing
+anything. With --from-file, the result for each user tells whether it would be
+unlocked.`,
+	Example: `  # Unlock user "alice"
+  authctl user unlock alice
+
+  # Unlock the users listed in users.txt
+  authctl user unlock --from-file users.txt`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if unlockFromFile != "" && len(args) > 0 {
+			return errors.New("no user must be specified when using --from-file")
+		}
+		if unlockFromFile != "" {
+			return nil
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	ValidArgsFunction: completion.Users,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if unlockFromFile != "" {
+			return unlockUsersFromFile(cmd, unlockFromFile)
+		}
+
+		if dryRun {
+			return printDryRun(cmd, args[0], func(u *authd.User) (string, error) {
+				if !u.Locked {
+					return fmt.Sprintf("leave user '%s' unlocked, it is already unlocked", u.Name), nil
+				}
+				return fmt.Sprintf("unlock user '%s'", u.Name), nil
+			})
+		}
+
+		ctx, cancel := client.Context(cmd.Context())
+		defer cancel()
+
 		client, err := client.NewUserServiceClient()
 		if err != nil {
 			return err
 		}
 
-		_, err = client.UnlockUser(context.Background(), &authd.UnlockUserRequest{Name: args[0]})
+		resp, err := client.UnlockUser(ctx, &authd.UnlockUserRequest{Name: args[0]})
 		if err != nil {
 			return err
 		}
 
-		return nil
+		if unlockVerbose && !output.IsJSON() {
+			if resp.WasLocked {
+				log.Infof("User '%s' unlocked.", args[0])
+			} else {
+				log.Infof("User '%s' was already unlocked.", args[0])
+			}
+		}
+
+		return output.PrintStatusChange(cmd.OutOrStdout(), args[0], lockStatus(resp.WasLocked), "unlocked")
 	},
 }
+
+var (
+	unlockFromFile string
+	unlockVerbose  bool
+)
+
+func init() {
+	unlockCmd.Flags().StringVar(&unlockFromFile, "from-file", "", `read the users to unlock from the given file, one per line ("-" for the standard input)`)
+	unlockCmd.Flags().BoolVarP(&unlockVerbose, "verbose", "v", false, "print whether the user was already unlocked")
+}
+
+// unlockUsersFromFile unlocks all users listed in the given file and prints the result for each user.
+func unlockUsersFromFile(cmd *cobra.Command, path string) error {
+	var r io.Reader = cmd.InOrStdin()
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open users file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	names, err := fileutils.ReadLinesFrom(r)
+	if err != nil {
+		return fmt.Errorf("failed to read users file: %w", err)
+	}
+
+	svc, err := client.NewUserServiceClient()
+	if err != nil {
+		return err
+	}
+
+	var results []output.Status
+	var failed int
+	for _, name := range names {
+		ctx, cancel := client.Context(cmd.Context())
+		wasLocked, err := unlockUser(ctx, svc, name)
+		cancel()
+
+		if err != nil {
+			failed++
+			msg := err.Error()
+			if s, ok := status.FromError(err); ok {
+				msg = s.Message()
+			}
+			results = append(results, output.Status{Name: name, Status: "failed", Error: msg})
+			continue
+		}
+		newStatus := "unlocked"
+		if dryRun {
+			newStatus = "would be unlocked"
+		}
+		results = append(results, output.Status{Name: name, Status: newStatus, PreviousStatus: lockStatus(wasLocked)})
+	}
+
+	if output.IsJSON() {
+		if results == nil {
+			results = []output.Status{}
+		}
+		if err := output.PrintJSON(cmd.OutOrStdout(), results); err != nil {
+			return err
+		}
+	} else {
+		// The file can list many users, so the results are not written one by one.
+		log.EnableBuffering()
+		for _, res := range results {
+			if res.Error != "" {
+				log.Errorf("%s: %s: %s", res.Name, res.Status, res.Error)
+				continue
+			}
+			if res.PreviousStatus == "unlocked" {
+				log.Infof("%s: already unlocked", res.Name)
+				continue
+			}
+			log.Infof("%s: %s", res.Name, res.Status)
+		}
+		if err := log.DisableBuffering(); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to unlock %d of %d users", failed, len(names))
+	}
+	return nil
+}
+
+// unlockUser unlocks the user with the given name and returns whether it was locked before. With --dry-run, the user is
+// only resolved.
+func unlockUser(ctx context.Context, svc authd.UserServiceClient, name string) (wasLocked bool, err error) {
+	if dryRun {
+		u, err := svc.GetUserByName(ctx, &authd.GetUserByNameRequest{Name: name})
+		if err != nil {
+			return false, err
+		}
+		return u.Locked, nil
+	}
+
+	resp, err := svc.UnlockUser(ctx, &authd.UnlockUserRequest{Name: name})
+	if err != nil {
+		return false, err
+	}
+	return resp.WasLocked, nil
+}
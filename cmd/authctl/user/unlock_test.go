@@ -0,0 +1,75 @@
+package user_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/authd/internal/testutils"
+	"google.golang.org/grpc/codes"
+)
+
+func TestUserUnlockCommand(t *testing.T) {
+	usersFile := filepath.Join("testdata", "users-to-unlock.txt")
+
+	tests := map[string]struct {
+		args  []string
+		stdin string
+
+		expectedExitCode int
+	}{
+		"Unlock_user_success": {args: []string{"unlock", "user2-with-a-longer-name@example.com"}, expectedExitCode: 0},
+		"Unlock_user_success_with_JSON_output": {
+			args:             []string{"unlock", "user2-with-a-longer-name@example.com", "--output", "json"},
+			expectedExitCode: 0,
+		},
+		"Unlock_user_success_with_verbose_output": {
+			args:             []string{"unlock", "--verbose", "user2-with-a-longer-name@example.com"},
+			expectedExitCode: 0,
+		},
+		"Unlock_already_unlocked_user_with_verbose_output": {args: []string{"unlock", "-v", "user1@example.com"}, expectedExitCode: 0},
+		"Unlock_already_unlocked_user_with_JSON_output":    {args: []string{"unlock", "user1@example.com", "--output", "json"}, expectedExitCode: 0},
+		"Unlock_users_from_stdin": {
+			args:             []string{"unlock", "--from-file", "-"},
+			stdin:            "user1@example.com\n\n# comment\nuser2-with-a-longer-name@example.com\n",
+			expectedExitCode: 0,
+		},
+		"Unlock_users_from_stdin_with_JSON_output": {
+			args:             []string{"unlock", "--from-file", "-", "--output", "json"},
+			stdin:            "user1@example.com\nuser2-with-a-longer-name@example.com\n",
+			expectedExitCode: 0,
+		},
+
+		"Error_unlocking_invalid_user": {args: []string{"unlock", "invaliduser"}, expectedExitCode: int(codes.NotFound)},
+		"Error_when_unlocking_users_from_file_with_invalid_user": {
+			args:             []string{"unlock", "--from-file", usersFile},
+			expectedExitCode: 1,
+		},
+		"Error_when_users_file_does_not_exist": {
+			args:             []string{"unlock", "--from-file", "does-not-exist"},
+			expectedExitCode: 1,
+		},
+		"Error_when_both_user_and_file_are_given": {
+			args:             []string{"unlock", "user1@example.com", "--from-file", usersFile},
+			expectedExitCode: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The output depends on the lock state of the users, so we need a separate daemon for each test case.
+			daemonSocket := testutils.StartAuthd(t, daemonPath,
+				testutils.WithGroupFile(filepath.Join("testdata", "empty.group")),
+				testutils.WithPreviousDBState("two_users_one_locked"),
+				testutils.WithCurrentUserAsRoot,
+			)
+
+			//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+			cmd := exec.Command(authctlPath, append([]string{"user"}, tc.args...)...)
+			cmd.Env = append(cmd.Environ(), "AUTHD_SOCKET="+daemonSocket)
+			cmd.Stdin = strings.NewReader(tc.stdin)
+			testutils.CheckCommand(t, cmd, tc.expectedExitCode)
+		})
+	}
+}
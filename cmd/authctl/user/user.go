@@ -14,7 +14,15 @@ var UserCmd = &cobra.Command{
 }
 
 func init() {
+	UserCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false,
+		"check that the user exists and the arguments are valid, and print what would be done without doing it")
+
 	UserCmd.AddCommand(lockCmd)
 	UserCmd.AddCommand(unlockCmd)
 	UserCmd.AddCommand(setUIDCmd)
+	UserCmd.AddCommand(setShellCmd)
+	UserCmd.AddCommand(setHomeCmd)
+	UserCmd.AddCommand(deleteCmd)
+	UserCmd.AddCommand(listCmd)
+	UserCmd.AddCommand(showCmd)
 }
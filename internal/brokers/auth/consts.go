@@ -24,4 +24,7 @@ const (
 	// SessionModeChangePassword is used when the session is for changing the user password.
 	// TODO: We can change this to "change-password" once all broker installations are updated to use the new name.
 	SessionModeChangePassword = "passwd"
+	// SessionModeDiagnostic is used when the session is for testing the configuration of the broker with a full
+	// authentication, without updating the user in the database.
+	SessionModeDiagnostic = "diagnostic"
 )
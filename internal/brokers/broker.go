@@ -190,6 +190,13 @@ func (b Broker) IsAuthenticated(ctx context.Context, sessionID, authenticationDa
 			return "", "", err
 		}
 
+		// The claims of the user are optional.
+		if rawClaims, err := unmarshalAndGetKey(data, "claims"); err == nil {
+			if err := json.Unmarshal(rawClaims, &info.Claims); err != nil {
+				return "", "", fmt.Errorf("claims returned by the broker are not a JSON object: %v", err)
+			}
+		}
+
 		if err = validateUserInfo(info); err != nil {
 			return "", "", err
 		}
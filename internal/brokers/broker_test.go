@@ -221,6 +221,7 @@ func TestIsAuthenticated(t *testing.T) {
 		"No_error_when_broker_returns_userinfo_with_empty_gecos":           {sessionID: "ia_info_empty_gecos"},
 		"No_error_when_broker_returns_userinfo_with_group_with_empty_UGID": {sessionID: "ia_info_empty_ugid"},
 		"No_error_when_broker_returns_userinfo_with_mismatching_username":  {sessionID: "ia_info_mismatching_user_name"},
+		"No_error_when_broker_returns_claims":                              {sessionID: "ia_info_with_claims"},
 
 		// broker errors
 		"Error_when_authenticating":                                           {sessionID: "ia_error"},
@@ -228,6 +229,7 @@ func TestIsAuthenticated(t *testing.T) {
 		"Error_when_broker_returns_invalid_data":                              {sessionID: "ia_invalid_data"},
 		"Error_when_broker_returns_invalid_access":                            {sessionID: "ia_invalid_access"},
 		"Error_when_broker_returns_invalid_userinfo":                          {sessionID: "ia_invalid_userinfo"},
+		"Error_when_broker_returns_invalid_claims":                            {sessionID: "ia_invalid_claims"},
 		"Error_when_broker_returns_userinfo_with_empty_username":              {sessionID: "ia_info_empty_user_name"},
 		"Error_when_broker_returns_userinfo_with_empty_group_name":            {sessionID: "ia_info_empty_group_name"},
 		"Error_when_broker_returns_userinfo_with_invalid_homedir":             {sessionID: "ia_info_invalid_home"},
@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/canonical/authd/internal/brokers/auth"
 	"github.com/canonical/authd/internal/decorate"
 	"github.com/canonical/authd/log"
 	"github.com/godbus/dbus/v5"
@@ -27,6 +28,8 @@ type Manager struct {
 
 	transactionsToBroker   map[string]*Broker
 	transactionsToBrokerMu sync.RWMutex
+	// diagnosticSessions are the IDs of the sessions in diagnostic mode. It's protected by transactionsToBrokerMu.
+	diagnosticSessions map[string]struct{}
 
 	cleanup func()
 }
@@ -112,6 +115,7 @@ func NewManager(ctx context.Context, brokersConfPath string, configuredBrokers [
 
 		usersToBroker:        make(map[string]*Broker),
 		transactionsToBroker: make(map[string]*Broker),
+		diagnosticSessions:   make(map[string]struct{}),
 
 		cleanup: cleanup,
 	}, nil
@@ -180,9 +184,21 @@ func (m *Manager) NewSession(brokerID, username, lang, mode string) (sessionID s
 	log.Debugf(context.Background(), "%s: New %s session for %q",
 		sessionID, mode, username)
 	m.transactionsToBroker[sessionID] = broker
+	if mode == auth.SessionModeDiagnostic {
+		m.diagnosticSessions[sessionID] = struct{}{}
+	}
 	return sessionID, encryptionKey, nil
 }
 
+// IsDiagnosticSession returns true if the session was started in diagnostic mode.
+func (m *Manager) IsDiagnosticSession(sessionID string) bool {
+	m.transactionsToBrokerMu.RLock()
+	defer m.transactionsToBrokerMu.RUnlock()
+
+	_, ok := m.diagnosticSessions[sessionID]
+	return ok
+}
+
 // EndSession signals the end of the session to the broker associated with the sessionID and then removes the
 // session -> broker mapping.
 func (m *Manager) EndSession(sessionID string) error {
@@ -199,6 +215,7 @@ func (m *Manager) EndSession(sessionID string) error {
 	log.Debugf(context.Background(), "%s: End session %q",
 		sessionID, m.transactionsToBroker[sessionID].Name)
 	delete(m.transactionsToBroker, sessionID)
+	delete(m.diagnosticSessions, sessionID)
 	m.transactionsToBrokerMu.Unlock()
 	return nil
 }
@@ -0,0 +1,12 @@
+package fileutils
+
+// MoveFileWithRename moves a file like MoveFile, but uses the given function instead of Lrename to try renaming it.
+func MoveFileWithRename(oldPath, newPath string, rename func(oldPath, newPath string) error) error {
+	return moveFile(oldPath, newPath, rename)
+}
+
+// CopyFileSparseWithSeek copies a file like CopyFileSparse, but uses the given function instead of unix.Seek to seek
+// the data and holes of the source.
+func CopyFileSparseWithSeek(srcPath, destPath string, seek func(fd int, offset int64, whence int) (int64, error)) error {
+	return copyFileSparse(srcPath, destPath, seek)
+}
@@ -2,12 +2,23 @@
 package fileutils
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
@@ -21,32 +32,186 @@ func FileExists(path string) (bool, error) {
 	return !errors.Is(err, os.ErrNotExist), nil
 }
 
+// Lexists checks if a file exists at the given path, without following symlinks.
+// Unlike FileExists, it returns true for a dangling symlink.
+func Lexists(path string) (bool, error) {
+	_, err := os.Lstat(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return !errors.Is(err, os.ErrNotExist), nil
+}
+
 // IsDirEmpty checks if the specified directory is empty.
 func IsDirEmpty(path string) (bool, error) {
+	return IsDirEmptyExcept(path, nil)
+}
+
+// IsDirEmptyExcept checks if the specified directory is empty, or only contains entries whose names are in ignore
+// (e.g. the dotfiles copied from /etc/skel).
+func IsDirEmptyExcept(path string, ignore []string) (bool, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return false, err
 	}
 	defer f.Close()
 
-	_, err = f.Readdirnames(1)
-	if errors.Is(err, io.EOF) {
-		return true, nil
+	for {
+		// Only read a single entry if there is nothing to ignore, to return as early as possible for large directories.
+		names, err := f.Readdirnames(len(ignore) + 1)
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		for _, name := range names {
+			if !slices.Contains(ignore, name) {
+				return false, nil
+			}
+		}
 	}
-	return false, err
 }
 
-// Touch creates an empty file at the given path, if it doesn't already exist.
+// Touch creates an empty file at the given path with mode 0600, if it doesn't already exist.
 func Touch(path string) error {
-	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o600)
-	if err != nil && !errors.Is(err, os.ErrExist) {
+	return TouchMode(path, 0o600)
+}
+
+type touchOptions struct {
+	enforceMode bool
+}
+
+// TouchOption represents an optional function to override TouchMode default values.
+type TouchOption func(*touchOptions)
+
+// WithEnforcedMode makes TouchMode apply the mode to the file even if it already exists.
+func WithEnforcedMode() TouchOption {
+	return func(o *touchOptions) {
+		o.enforceMode = true
+	}
+}
+
+// TouchMode creates an empty file at the given path with the given mode (which is not subject to the umask), if it
+// doesn't already exist. The mode of an existing file is left unchanged, unless WithEnforcedMode is passed.
+func TouchMode(path string, mode os.FileMode, args ...TouchOption) error {
+	var opts touchOptions
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE|os.O_EXCL, mode)
+	created := err == nil
+	if errors.Is(err, os.ErrExist) {
+		// Open the existing file, which also fails as expected if it is a directory.
+		file, err = os.OpenFile(path, os.O_RDONLY|os.O_CREATE, mode)
+	}
+	if err != nil {
 		return err
 	}
+
+	if created || opts.enforceMode {
+		if err := file.Chmod(mode); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+
 	return file.Close()
 }
 
+// TouchUpdate creates an empty file at the given path like Touch, or, if it already exists, sets its access and
+// modification times to the current time, like the touch command does.
+func TouchUpdate(path string) error {
+	if err := Touch(path); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
+
+// SameFile checks if both paths (after following symlinks) refer to the same file, e.g. because one is a hardlink or a
+// symlink to the other. It returns false if either path doesn't exist.
+func SameFile(pathA, pathB string) (bool, error) {
+	infoA, err := os.Stat(pathA)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	infoB, err := os.Stat(pathB)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return os.SameFile(infoA, infoB), nil
+}
+
+// EnsureDir creates the directory at the given path and any missing parents, and makes sure that it has the given
+// mode (which, unlike with os.MkdirAll, is not subject to the umask) and is owned by uid and gid.
+// The mode and ownership are also enforced if the directory already exists.
+//
+// Pass -1 as uid or gid to leave the owner or group unchanged.
+func EnsureDir(path string, mode os.FileMode, uid, gid int) error {
+	if err := os.MkdirAll(path, mode); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("failed to change ownership of %q: %w", path, err)
+	}
+
+	return nil
+}
+
+// Preallocate allocates disk space for the first size bytes of f, without changing its size, which reduces the
+// fragmentation of large files written sequentially. It's a no-op if the filesystem doesn't support preallocation.
+func Preallocate(f *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_KEEP_SIZE, 0, size)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to preallocate %d bytes for %q: %w", size, f.Name(), err)
+	}
+	return nil
+}
+
+// copyBufferSize is the size of the chunks in which CopyFile copies files.
+const copyBufferSize = 32 * 1024
+
 // CopyFile copies a file from a source to a destination path, preserving the file mode.
 func CopyFile(srcPath, destPath string) error {
+	return copyFile(srcPath, destPath, copyBufferSize, true, false)
+}
+
+// CopyFileContext copies a file from a source to a destination path, preserving the file mode.
+// The context is checked between each chunk which is copied. If it is done before the copy is complete, the context
+// error is returned and the destination is left untouched: the file is copied to a temporary file in the same
+// directory, which is only renamed to the destination path once the copy is complete.
+func CopyFileContext(ctx context.Context, srcPath, destPath string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	src, err := os.Open(srcPath)
 	if err != nil {
 		return err
@@ -58,134 +223,1458 @@ func CopyFile(srcPath, destPath string) error {
 		return err
 	}
 
-	dst, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileInfo.Mode())
+	dir := filepath.Dir(destPath)
+	dst, cleanup, err := CreateTempInDir(dir, "."+filepath.Base(destPath)+".tmp*")
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
 
-	if _, err := io.Copy(dst, src); err != nil {
+	if err := dst.Chmod(fileInfo.Mode()); err != nil {
+		return err
+	}
+	if err := copyContent(ctx, src, dst, copyBufferSize, true); err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
 		return err
 	}
 
-	return dst.Sync()
-}
-
-// SymlinkResolutionError is the error returned when symlink resolution fails.
-type SymlinkResolutionError struct {
-	msg string
-	err error
-}
+	if err := Lrename(dst.Name(), destPath); err != nil {
+		return err
+	}
 
-func (e SymlinkResolutionError) Error() string {
-	return fmt.Sprintf("%s: %v", e.msg, e.err)
+	return syncDir(dir)
 }
 
-func (e SymlinkResolutionError) Unwrap() error {
-	return e.err
+// CopyFileBuffered copies a file like CopyFile, but in chunks of bufSize bytes instead of the default size. Larger
+// buffers can improve the throughput for large files on storage with a high latency, like NFS.
+// The disk space of the destination is preallocated with Preallocate before copying.
+func CopyFileBuffered(srcPath, destPath string, bufSize int) error {
+	if bufSize <= 0 {
+		return fmt.Errorf("CopyFileBuffered: the buffer size must be positive, got %d", bufSize)
+	}
+	return copyFile(srcPath, destPath, bufSize, true, true)
 }
 
-// Is makes this error insensitive to the internal values.
-func (e SymlinkResolutionError) Is(target error) bool {
-	return target == SymlinkResolutionError{}
+// CopyFileNoSync copies a file like CopyFile, but doesn't sync the destination file to disk, which is much faster when
+// copying many small files.
+//
+// The caller is responsible for the durability of the copy: until the destination file (or the whole filesystem, e.g.
+// with syncfs) and its parent directory have been synced, the copy may be lost or incomplete after a crash.
+func CopyFileNoSync(srcPath, destPath string) error {
+	return copyFile(srcPath, destPath, copyBufferSize, false, false)
 }
 
-// Lrename renames a file or directory, resolving symlinks in the destination path.
-// If the symlink resolution fails, it returns a SymlinkResolutionError.
-func Lrename(oldPath, newPath string) error {
-	// Resolve the destination path if it's a symlink.
-	fi, err := os.Lstat(newPath)
-	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
-		return os.Rename(oldPath, newPath)
+func copyFile(srcPath, destPath string, bufSize int, sync, preallocate bool) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
 	}
+	defer src.Close()
 
-	newPath, err = filepath.EvalSymlinks(newPath)
+	fileInfo, err := src.Stat()
 	if err != nil {
-		return SymlinkResolutionError{msg: "failed to resolve symlinks in Lrename", err: err}
+		return err
 	}
 
-	return os.Rename(oldPath, newPath)
-}
+	// Truncating the destination would otherwise wipe the content of the source.
+	if destInfo, err := os.Stat(destPath); err == nil && os.SameFile(fileInfo, destInfo) {
+		return fmt.Errorf("can't copy %q to %q: they are the same file", srcPath, destPath)
+	}
 
-// LockDir creates a lock file in the specified directory and acquires an exclusive lock on it.
-// It blocks until the lock is available and returns an unlock function to release the lock.
-func LockDir(dir string) (func() error, error) {
-	lockPath := filepath.Join(dir, ".lock")
-	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	dst, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileInfo.Mode())
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer dst.Close()
 
-	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
-		_ = f.Close()
-		return nil, err
+	if preallocate {
+		if err := Preallocate(dst, fileInfo.Size()); err != nil {
+			return err
+		}
 	}
 
-	unlock := func() error {
-		if err := unix.Flock(int(f.Fd()), unix.LOCK_UN); err != nil {
-			_ = f.Close()
+	return copyContent(context.Background(), src, dst, bufSize, sync)
+}
+
+// copyContent copies the content of src to dst in chunks of bufSize bytes, checking the context between each chunk.
+// If sync is true, dst is synced to disk once the copy is complete, otherwise it is closed.
+func copyContent(ctx context.Context, src, dst *os.File, bufSize int, sync bool) error {
+	buf := make([]byte, bufSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
 			return err
 		}
-		return f.Close()
 	}
 
-	return unlock, nil
+	if !sync {
+		return dst.Close()
+	}
+	return dst.Sync()
 }
 
-// ChownUIDArgs is used to specify the UID to change ownership from and to.
-type ChownUIDArgs struct {
-	FromUID uint32
-	ToUID   uint32
+// CopyFileSparse copies a file like CopyFile, but preserves the holes of sparse files: only the regions of the source
+// which contain data are copied, using SEEK_DATA and SEEK_HOLE, and the holes are left unallocated in the destination.
+// If the filesystem of the source doesn't support seeking holes, the whole file is copied like with CopyFile.
+func CopyFileSparse(srcPath, destPath string) error {
+	return copyFileSparse(srcPath, destPath, unix.Seek)
 }
 
-// ChownGIDArgs is used to specify the GID to change group ownership from and to.
-type ChownGIDArgs struct {
-	FromGID uint32
-	ToGID   uint32
-}
+func copyFileSparse(srcPath, destPath string, seek func(fd int, offset int64, whence int) (int64, error)) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
 
-// ChownRecursiveFrom changes ownership of files and directories under the
-// specified root directory from the current UID/GID (fromUID, fromGID) to the
-// new UID/GID (toUID, toGID).
-//
-// It mirrors the behavior of chown_tree from shadow-utils:
-// https://github.com/shadow-maint/shadow/blob/e7ccd3df6845c184d155a2dd573f52d239c94337/lib/chowndir.c#L129-L141
-//
-// Symlinks are not followed.
-//
-// If uidArgs/gidArgs is nil, change of ownership for UID/GID is skipped.
-// If both uidArgs and gidArgs are nil, an error is returned.
-func ChownRecursiveFrom(root string, uidArgs *ChownUIDArgs, gidArgs *ChownGIDArgs) error {
-	if uidArgs == nil && gidArgs == nil {
-		return fmt.Errorf("ChownRecursiveFrom: at least one of uidArgs or gidArgs must be non-nil")
+	fileInfo, err := src.Stat()
+	if err != nil {
+		return err
 	}
 
-	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
+	// Truncating the destination would otherwise wipe the content of the source.
+	if destInfo, err := os.Stat(destPath); err == nil && os.SameFile(fileInfo, destInfo) {
+		return fmt.Errorf("can't copy %q to %q: they are the same file", srcPath, destPath)
+	}
+
+	dst, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileInfo.Mode())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	err = copyDataRegions(src, dst, fileInfo.Size(), seek)
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EOPNOTSUPP) {
+		// The filesystem doesn't support seeking holes, so the whole file is copied.
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		// The data regions are written at their offset, so the offset of the destination is still at its start.
+		if err := dst.Truncate(0); err != nil {
 			return err
 		}
+		_, err = io.Copy(dst, src)
+	}
+	if err != nil {
+		return err
+	}
 
-		info, err := d.Info()
+	return dst.Sync()
+}
+
+// copyDataRegions copies the regions of src which contain data to the same offsets in dst, and extends dst to size,
+// so that the holes of src are holes in dst too.
+func copyDataRegions(src, dst *os.File, size int64, seek func(fd int, offset int64, whence int) (int64, error)) error {
+	fd := int(src.Fd())
+	for offset := int64(0); offset < size; {
+		start, err := seek(fd, offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// There is no data after offset, the rest of the file is a hole.
+			break
+		}
 		if err != nil {
 			return err
 		}
-		stat, ok := info.Sys().(*syscall.Stat_t)
-		if !ok {
-			return fmt.Errorf("failed to get raw stat for %q", path)
+		end, err := seek(fd, start, unix.SEEK_HOLE)
+		if err != nil {
+			return err
 		}
 
-		if uidArgs != nil && stat.Uid == uidArgs.FromUID {
-			if err := os.Lchown(path, int(uidArgs.ToUID), -1); err != nil {
-				return fmt.Errorf("failed to change ownership: %w", err)
-			}
+		if _, err := io.Copy(io.NewOffsetWriter(dst, start), io.NewSectionReader(src, start, end-start)); err != nil {
+			return err
+		}
+		offset = end
+	}
+
+	return dst.Truncate(size)
+}
+
+// CopyFileWithMeta copies a file like CopyFile and additionally preserves the access and modification times of the
+// source. If preserveOwner is true, the owner and group of the source are preserved too.
+//
+// If the ownership can't be changed, for example because the process lacks the privileges to do so, the returned
+// error wraps the underlying error, so callers can check for os.ErrPermission and decide to ignore it.
+func CopyFileWithMeta(srcPath, destPath string, preserveOwner bool) error {
+	// Stat the source before copying it, because reading it may update its access time.
+	fileInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("failed to get raw stat for %q", srcPath)
+	}
+
+	if err := CopyFile(srcPath, destPath); err != nil {
+		return err
+	}
+
+	if preserveOwner {
+		if err := os.Lchown(destPath, int(stat.Uid), int(stat.Gid)); err != nil {
+			return fmt.Errorf("failed to preserve ownership of %q: %w", destPath, err)
+		}
+	}
+
+	atime := time.Unix(stat.Atim.Unix())
+	if err := os.Chtimes(destPath, atime, fileInfo.ModTime()); err != nil {
+		return fmt.Errorf("failed to preserve timestamps of %q: %w", destPath, err)
+	}
+
+	return nil
+}
+
+// FileChecksum streams the content of the file at the given path through h and returns the hex-encoded digest.
+func FileChecksum(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 digest of the file at the given path.
+func FileSHA256(path string) (string, error) {
+	return FileChecksum(path, sha256.New())
+}
+
+// maxLineLength is the maximum length of a line read by ReadLines and ReadLinesFrom.
+const maxLineLength = 1024 * 1024
+
+// ReadLines returns the lines of the file at the given path, like ReadLinesFrom.
+func ReadLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines, err := ReadLinesFrom(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+	return lines, nil
+}
+
+// ReadLinesFrom returns the lines read from r, with leading and trailing whitespace trimmed.
+// Empty lines and comment lines (starting with '#') are skipped. Lines longer than 1 MiB are rejected with an error.
+func ReadLinesFrom(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
 
-		if gidArgs != nil && stat.Gid == gidArgs.FromGID {
-			if err := os.Lchown(path, -1, int(gidArgs.ToGID)); err != nil {
-				return fmt.Errorf("failed to change group ownership: %w", err)
+// defaultMaxWalkDepth is the maximum depth below the root directory up to which the recursive helpers of this package
+// walk directory trees.
+const defaultMaxWalkDepth = 1024
+
+// ErrMaxDepthExceeded is returned by WalkDirLimited if the directory tree is deeper than the maximum depth.
+var ErrMaxDepthExceeded = errors.New("maximum directory depth exceeded")
+
+// WalkDirLimited walks the directory tree at root like filepath.WalkDir, but returns an error wrapping
+// ErrMaxDepthExceeded, without calling fn, when it encounters an entry which is more than maxDepth levels below root.
+// The direct children of root are at depth 1.
+func WalkDirLimited(root string, maxDepth int, fn fs.WalkDirFunc) error {
+	if maxDepth < 0 {
+		return fmt.Errorf("WalkDirLimited: the maximum depth must not be negative, got %d", maxDepth)
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if path != root {
+			relPath, relErr := filepath.Rel(root, path)
+			if relErr != nil {
+				return relErr
+			}
+			if depth := strings.Count(relPath, string(os.PathSeparator)) + 1; depth > maxDepth {
+				return fmt.Errorf("%w: %q is more than %d levels below %q", ErrMaxDepthExceeded, path, maxDepth, root)
 			}
 		}
 
-		return nil
+		return fn(path, d, err)
 	})
 }
+
+// DiskUsage walks the directory tree at root and returns the sum of the apparent sizes of the regular files in it, and
+// the number of entries (files, directories, symlinks, ...) it contains, not counting root itself.
+// Symlinks are counted as entries, but are not followed. Trees deeper than 1024 levels are rejected with an error
+// wrapping ErrMaxDepthExceeded.
+func DiskUsage(root string) (bytes int64, files int64, err error) {
+	err = WalkDirLimited(root, defaultMaxWalkDepth, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		files++
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		bytes += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return bytes, files, nil
+}
+
+// DirsEqual compares the directory trees at a and b, and returns whether they are equal and the paths, relative to the
+// roots, of the entries which differ, in lexical order. Entries differ if they only exist in one of the trees, if they
+// have different types or owners, if they are regular files with different sizes or if they are symlinks with different
+// targets. Symlinks are not followed. If compareContent is true, the contents of regular files of the same size are
+// compared with their checksums too, which is expensive for large trees.
+//
+// Modes and timestamps are not compared. Trees deeper than 1024 levels are rejected with an error wrapping
+// ErrMaxDepthExceeded.
+func DirsEqual(a, b string, compareContent bool) (equal bool, diffs []string, err error) {
+	entriesA, err := treeEntries(a)
+	if err != nil {
+		return false, nil, err
+	}
+	entriesB, err := treeEntries(b)
+	if err != nil {
+		return false, nil, err
+	}
+
+	for relPath, entryA := range entriesA {
+		entryB, ok := entriesB[relPath]
+		if !ok || entryA != entryB {
+			diffs = append(diffs, relPath)
+			continue
+		}
+		if !compareContent || !entryA.typ.IsRegular() {
+			continue
+		}
+
+		sumA, err := FileSHA256(filepath.Join(a, relPath))
+		if err != nil {
+			return false, nil, err
+		}
+		sumB, err := FileSHA256(filepath.Join(b, relPath))
+		if err != nil {
+			return false, nil, err
+		}
+		if sumA != sumB {
+			diffs = append(diffs, relPath)
+		}
+	}
+	for relPath := range entriesB {
+		if _, ok := entriesA[relPath]; !ok {
+			diffs = append(diffs, relPath)
+		}
+	}
+
+	slices.Sort(diffs)
+	return len(diffs) == 0, diffs, nil
+}
+
+// treeEntry is what DirsEqual compares of an entry of a directory tree, apart from the content of regular files.
+type treeEntry struct {
+	typ os.FileMode
+	uid uint32
+	gid uint32
+	// size is the size of regular files.
+	size int64
+	// target is the target of symlinks.
+	target string
+}
+
+// treeEntries returns the entries of the directory tree at root, including root itself, by path relative to root.
+func treeEntries(root string) (map[string]treeEntry, error) {
+	entries := make(map[string]treeEntry)
+	err := WalkDirLimited(root, defaultMaxWalkDepth, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("failed to get raw stat for %q", path)
+		}
+
+		entry := treeEntry{typ: d.Type(), uid: stat.Uid, gid: stat.Gid}
+		switch {
+		case d.Type().IsRegular():
+			entry.size = info.Size()
+		case d.Type()&os.ModeSymlink != 0:
+			if entry.target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		entries[relPath] = entry
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+type copyDirOptions struct {
+	preserveOwnership bool
+}
+
+// CopyDirOption represents an optional function to override CopyDir default values.
+type CopyDirOption func(*copyDirOptions)
+
+// WithPreservedOwnership makes CopyDir give each copied entry the owner and group of its source, instead of leaving it
+// owned by the current user. This requires the privileges to change the ownership of files.
+func WithPreservedOwnership() CopyDirOption {
+	return func(o *copyDirOptions) {
+		o.preserveOwnership = true
+	}
+}
+
+// CopyDir recursively copies the directory tree at srcDir to destDir, preserving the mode of files and directories.
+// Symlinks are recreated as symlinks (even if they are dangling) instead of being followed.
+//
+// If destDir already exists, it must be an empty directory.
+//
+// Special files (sockets, devices, named pipes, ...) are skipped. If any were found, an error listing them is returned
+// after the rest of the tree has been copied.
+func CopyDir(srcDir, destDir string, args ...CopyDirOption) error {
+	var opts copyDirOptions
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	empty, err := IsDirEmpty(destDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil && !empty {
+		return fmt.Errorf("destination directory %q is not empty", destDir)
+	}
+
+	type dirMode struct {
+		path string
+		mode os.FileMode
+	}
+	var dirs []dirMode
+	var skipped []string
+
+	err = WalkDirLimited(srcDir, defaultMaxWalkDepth, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		destPath := filepath.Join(destDir, relPath)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			// Create the directory with permissions which allow us to populate it, the
+			// original mode is applied once all its content has been copied.
+			if err := os.Mkdir(destPath, 0o700); err != nil && !errors.Is(err, os.ErrExist) {
+				return err
+			}
+			dirs = append(dirs, dirMode{path: destPath, mode: mode})
+		case mode&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(target, destPath); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			if err := CopyFile(path, destPath); err != nil {
+				return err
+			}
+		default:
+			skipped = append(skipped, path)
+			return nil
+		}
+
+		if !opts.preserveOwnership {
+			return nil
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("failed to get raw stat for %q", path)
+		}
+		if err := os.Lchown(destPath, int(stat.Uid), int(stat.Gid)); err != nil {
+			return fmt.Errorf("failed to preserve ownership of %q: %w", destPath, err)
+		}
+		// Changing the owner clears the setuid and setgid bits of files, so the mode of the source is applied again.
+		// Directories get their mode once their content has been copied.
+		if mode := info.Mode(); mode.IsRegular() && mode&(os.ModeSetuid|os.ModeSetgid) != 0 {
+			return os.Chmod(destPath, mode)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Apply the directory modes in reverse order, so that children are handled before their parents.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+	}
+
+	if len(skipped) > 0 {
+		return fmt.Errorf("skipped special files: %s", strings.Join(skipped, ", "))
+	}
+
+	return nil
+}
+
+// WriteFileAtomic writes data to the file at the given path, so that the file either keeps its previous content or
+// contains all of the new content, even if the process crashes in the middle of the write.
+//
+// The data is written to a temporary file in the same directory, which is then renamed to the target path. Both the
+// temporary file and the parent directory are synced to make sure the content and the rename are durable.
+func WriteFileAtomic(path string, data []byte, mode os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+
+	f, cleanup, err := CreateTempInDir(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := Lrename(tmpPath, path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// CreateTempInDir creates a new temporary file with mode 0600 in the directory dir, like os.CreateTemp, so that it is
+// on the same filesystem as the files in dir and can be renamed over them. Symlinks in dir are resolved first. If the
+// symlink resolution fails, it returns a SymlinkResolutionError.
+//
+// The returned cleanup function closes and removes the temporary file, it should be called if the caller fails to
+// do something with the file (e.g. renaming it to its final path).
+func CreateTempInDir(dir, pattern string) (f *os.File, cleanup func(), err error) {
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, nil, SymlinkResolutionError{msg: "failed to resolve symlinks in CreateTempInDir", err: err}
+	}
+
+	f, err = os.CreateTemp(resolvedDir, pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup = func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+	return f, cleanup, nil
+}
+
+// syncDir calls fsync on the given directory, to make sure that changes to its entries are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// RemoveDir removes the directory at path and its content like SafeRemoveAll, but only if the directory is owned by
+// expectUID, so that the directory of another user isn't removed, e.g. after a UID change. Otherwise, it returns an
+// UnexpectedOwnerError. It's not an error if path doesn't exist.
+func RemoveDir(path string, expectUID uint32) error {
+	if path == "" {
+		return errors.New("refusing to remove an empty path")
+	}
+
+	fileInfo, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Nothing to remove.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("refusing to remove %q: it is not a directory", path)
+	}
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("failed to get raw stat for %q", path)
+	}
+	if stat.Uid != expectUID {
+		return UnexpectedOwnerError{Path: path, ExpectedUID: expectUID, UID: stat.Uid}
+	}
+
+	return SafeRemoveAll(path)
+}
+
+// SafeRemoveAll removes path and any children it contains like os.RemoveAll, but refuses to remove paths which are
+// empty, or which resolve to the filesystem root or to a top-level directory like /home, /etc or /usr.
+func SafeRemoveAll(path string) error {
+	if path == "" {
+		return errors.New("refusing to remove an empty path")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := checkSafeToRemove(path, absPath); err != nil {
+		return err
+	}
+
+	resolvedPath, err := filepath.EvalSymlinks(absPath)
+	if errors.Is(err, os.ErrNotExist) {
+		// Nothing to remove.
+		return nil
+	}
+	if err != nil {
+		return SymlinkResolutionError{msg: "failed to resolve symlinks in SafeRemoveAll", err: err}
+	}
+	if err := checkSafeToRemove(path, resolvedPath); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(path)
+}
+
+// checkSafeToRemove returns an error if the absolute path is the filesystem root or a top-level directory.
+func checkSafeToRemove(path, absPath string) error {
+	if absPath == "/" {
+		return fmt.Errorf("refusing to remove %q: it is the filesystem root", path)
+	}
+	if filepath.Dir(absPath) == "/" {
+		return fmt.Errorf("refusing to remove %q: %q is a top-level directory", path, absPath)
+	}
+	return nil
+}
+
+// UnexpectedOwnerError is returned by RemoveDir if the directory is not owned by the expected user.
+type UnexpectedOwnerError struct {
+	Path        string
+	ExpectedUID uint32
+	UID         uint32
+}
+
+func (e UnexpectedOwnerError) Error() string {
+	return fmt.Sprintf("refusing to remove %q: it is not owned by UID %d (current owner: %d)", e.Path, e.ExpectedUID, e.UID)
+}
+
+// SymlinkResolutionError is the error returned when symlink resolution fails.
+type SymlinkResolutionError struct {
+	msg string
+	err error
+}
+
+func (e SymlinkResolutionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.msg, e.err)
+}
+
+func (e SymlinkResolutionError) Unwrap() error {
+	return e.err
+}
+
+// Is makes this error insensitive to the internal values.
+func (e SymlinkResolutionError) Is(target error) bool {
+	return target == SymlinkResolutionError{}
+}
+
+type renameOptions struct {
+	resolveSource bool
+}
+
+// RenameOption represents an optional function to override Lrename default values.
+type RenameOption func(*renameOptions)
+
+// WithResolvedSource makes Lrename resolve symlinks in the source path too, so that if it is a symlink, its target is
+// renamed instead of the symlink itself.
+func WithResolvedSource() RenameOption {
+	return func(o *renameOptions) {
+		o.resolveSource = true
+	}
+}
+
+// Lrename renames a file or directory, resolving symlinks in the destination path.
+// By default, a symlink in the source path is renamed itself, pass WithResolvedSource to rename its target instead.
+// If the symlink resolution fails, it returns a SymlinkResolutionError.
+func Lrename(oldPath, newPath string, args ...RenameOption) error {
+	var opts renameOptions
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	if opts.resolveSource {
+		var err error
+		oldPath, err = filepath.EvalSymlinks(oldPath)
+		if err != nil {
+			return SymlinkResolutionError{msg: "failed to resolve symlinks in the source path of Lrename", err: err}
+		}
+	}
+
+	// Resolve the destination path if it's a symlink.
+	fi, err := os.Lstat(newPath)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return os.Rename(oldPath, newPath)
+	}
+
+	newPath, err = filepath.EvalSymlinks(newPath)
+	if err != nil {
+		return SymlinkResolutionError{msg: "failed to resolve symlinks in Lrename", err: err}
+	}
+
+	return os.Rename(oldPath, newPath)
+}
+
+// MoveFile moves a file like Lrename, but also works if the source and destination are on different filesystems, in
+// which case the file is copied to the destination, preserving its mode, and the source is removed once the copy has
+// been synced to disk.
+//
+// Only regular files can be moved across filesystems. If the copy fails, the source is left in place.
+func MoveFile(oldPath, newPath string) error {
+	return moveFile(oldPath, newPath, func(oldPath, newPath string) error { return Lrename(oldPath, newPath) })
+}
+
+func moveFile(oldPath, newPath string, rename func(oldPath, newPath string) error) error {
+	err := rename(oldPath, newPath)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	fileInfo, err := os.Lstat(oldPath)
+	if err != nil {
+		return err
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return fmt.Errorf("can't move %q across filesystems: not a regular file", oldPath)
+	}
+
+	if err := CopyFile(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to copy %q across filesystems: %w", oldPath, err)
+	}
+	// The mode is only applied by CopyFile if the destination is created, and is subject to the umask.
+	if err := os.Chmod(newPath, fileInfo.Mode()); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(newPath)); err != nil {
+		return err
+	}
+
+	return os.Remove(oldPath)
+}
+
+// lockRetryInterval is the interval between attempts to acquire a directory lock in LockDirContext.
+const lockRetryInterval = 50 * time.Millisecond
+
+// defaultLockFileName is the name of the lock file used by LockDir and friends.
+const defaultLockFileName = ".lock"
+
+// LockDir creates a lock file in the specified directory and acquires an exclusive lock on it.
+// It blocks until the lock is available and returns an unlock function to release the lock.
+func LockDir(dir string) (func() error, error) {
+	return LockDirContext(context.Background(), dir)
+}
+
+// LockDirContext creates a lock file in the specified directory and acquires an exclusive lock on it.
+// It blocks until the lock is available or the context is done, and returns an unlock function to release the lock.
+func LockDirContext(ctx context.Context, dir string) (func() error, error) {
+	return lockDir(ctx, dir, defaultLockFileName, unix.LOCK_EX)
+}
+
+// LockDirNamed acquires an exclusive lock like LockDir, but on the lock file with the given name in the specified
+// directory, so that different subsystems can hold independent locks on the same directory.
+// The name must not contain any path separator.
+func LockDirNamed(dir, name string) (func() error, error) {
+	return lockDir(context.Background(), dir, name, unix.LOCK_EX)
+}
+
+// RLockDir creates a lock file in the specified directory and acquires a shared lock on it, which can be held by
+// multiple readers at the same time but not while an exclusive lock (see LockDir) is held.
+// It blocks until the lock is available and returns an unlock function to release the lock.
+//
+// Upgrading a shared lock to an exclusive one is not supported: the shared lock must be released before calling
+// LockDir.
+func RLockDir(dir string) (func() error, error) {
+	return lockDir(context.Background(), dir, defaultLockFileName, unix.LOCK_SH)
+}
+
+// lockDir acquires a lock of the given type (unix.LOCK_EX or unix.LOCK_SH) on the lock file with the given name in
+// the specified directory, retrying until the lock is available or the context is done.
+func lockDir(ctx context.Context, dir, name string, how int) (func() error, error) {
+	f, err := openLockFile(dir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err := unix.Flock(int(f.Fd()), how|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, unix.EWOULDBLOCK) {
+			_ = f.Close()
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to lock directory %q: %w", dir, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	return unlockFunc(f), nil
+}
+
+// TryLockDir creates a lock file in the specified directory and tries to acquire an exclusive lock on it without
+// blocking. If the lock is held by someone else, it returns acquired=false and a nil error.
+// The returned unlock function is only valid when the lock was acquired.
+func TryLockDir(dir string) (unlock func() error, acquired bool, err error) {
+	f, err := openLockFile(dir, defaultLockFileName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return unlockFunc(f), true, nil
+}
+
+// openLockFile opens the lock file with the given name in the specified directory, creating it if it doesn't exist.
+func openLockFile(dir, name string) (*os.File, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, os.PathSeparator) {
+		return nil, fmt.Errorf("invalid lock file name %q", name)
+	}
+
+	lockPath := filepath.Join(dir, name)
+	return os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+}
+
+// unlockFunc returns a function which releases the lock held on f and closes it.
+func unlockFunc(f *os.File) func() error {
+	return func() error {
+		if err := unix.Flock(int(f.Fd()), unix.LOCK_UN); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// ChownUIDArgs is used to specify the UID to change ownership from and to.
+type ChownUIDArgs struct {
+	FromUID uint32
+	ToUID   uint32
+}
+
+// ChownGIDArgs is used to specify the GID to change group ownership from and to.
+type ChownGIDArgs struct {
+	FromGID uint32
+	ToGID   uint32
+}
+
+type chownOptions struct {
+	dryRun      bool
+	progress    func(path string, changed bool)
+	journalPath string
+}
+
+// ChownOption represents an optional function to override ChownRecursiveFrom default values.
+type ChownOption func(*chownOptions)
+
+// WithProgress sets a function which is called for every entry visited by ChownRecursiveFrom, with changed set to
+// true if the ownership of the entry was (or, in dry-run mode, would be) changed.
+// The function is called from the same goroutine as the walk.
+func WithProgress(fn func(path string, changed bool)) ChownOption {
+	return func(o *chownOptions) {
+		o.progress = fn
+	}
+}
+
+// CanChown returns true if the current process is allowed to change the
+// ownership of the files under the specified root directory, i.e. if it has
+// the CAP_CHOWN capability in its effective set. This is checked even when
+// running as root, because root can run without it (e.g. in a systemd unit
+// with a restricted CapabilityBoundingSet). Checking this before a recursive
+// change of ownership avoids leaving the tree with mixed ownership because the
+// first Lchown failed halfway through the walk.
+//
+// An error is returned if root can't be accessed.
+func CanChown(root string) (bool, error) {
+	if _, err := os.Lstat(root); err != nil {
+		return false, err
+	}
+
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return false, fmt.Errorf("failed to get capabilities: %w", err)
+	}
+
+	return data[0].Effective&(1<<unix.CAP_CHOWN) != 0, nil
+}
+
+// WithJournal makes ChownRecursiveFrom record the prior UID and GID of each entry to the journal file at path before
+// changing its ownership, so that the change can be undone with ChownRollback. The records are appended to the file,
+// which is created if needed, and each record is synced to disk before the ownership of the entry is changed, so that
+// the journal is complete even if the process is interrupted. It has no effect in dry-run mode.
+func WithJournal(path string) ChownOption {
+	return func(o *chownOptions) {
+		o.journalPath = path
+	}
+}
+
+// ChownRecursiveFrom changes ownership of files and directories under the
+// specified root directory from the current UID/GID (fromUID, fromGID) to the
+// new UID/GID (toUID, toGID).
+//
+// It mirrors the behavior of chown_tree from shadow-utils:
+// https://github.com/shadow-maint/shadow/blob/e7ccd3df6845c184d155a2dd573f52d239c94337/lib/chowndir.c#L129-L141
+//
+// Symlinks are not followed. Directory trees deeper than 1024 levels are
+// rejected with an error wrapping ErrMaxDepthExceeded.
+//
+// The walk is confined to the device of root: entries on other devices (e.g.
+// mount points below root) are skipped, and if there are any, a
+// SkippedEntriesError listing them is returned after the rest of the tree has
+// been handled.
+//
+// If uidArgs/gidArgs is nil, change of ownership for UID/GID is skipped.
+// If both uidArgs and gidArgs are nil, an error is returned.
+func ChownRecursiveFrom(root string, uidArgs *ChownUIDArgs, gidArgs *ChownGIDArgs, args ...ChownOption) error {
+	_, err := chownRecursiveFrom(root, uidArgs, gidArgs, false, args...)
+	return err
+}
+
+// ChgrpRecursiveFrom changes the group ownership of files and directories
+// under the specified root directory from fromGID to toGID, like
+// ChownRecursiveFrom with nil uidArgs. The owners are not changed.
+//
+// If fromGID and toGID are equal, there is nothing to change, so it returns
+// nil without walking the tree.
+func ChgrpRecursiveFrom(root string, fromGID, toGID uint32) error {
+	if fromGID == toGID {
+		return nil
+	}
+	return ChownRecursiveFrom(root, nil, &ChownGIDArgs{FromGID: fromGID, ToGID: toGID})
+}
+
+// ChownRecursiveFromDryRun returns the paths under the specified root
+// directory whose ownership would be changed by ChownRecursiveFrom with the
+// same arguments, without changing anything.
+func ChownRecursiveFromDryRun(root string, uidArgs *ChownUIDArgs, gidArgs *ChownGIDArgs, args ...ChownOption) ([]string, error) {
+	return chownRecursiveFrom(root, uidArgs, gidArgs, true, args...)
+}
+
+func chownRecursiveFrom(root string, uidArgs *ChownUIDArgs, gidArgs *ChownGIDArgs, dryRun bool, args ...ChownOption) ([]string, error) {
+	if uidArgs == nil && gidArgs == nil {
+		return nil, fmt.Errorf("ChownRecursiveFrom: at least one of uidArgs or gidArgs must be non-nil")
+	}
+
+	opts := chownOptions{dryRun: dryRun}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	devFilter, err := newSameDeviceFilter(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var journal *chownJournal
+	if opts.journalPath != "" && !opts.dryRun {
+		journal, err = openChownJournal(opts.journalPath)
+		if err != nil {
+			return nil, err
+		}
+		defer journal.close()
+	}
+
+	var changed []string
+	err = WalkDirLimited(root, defaultMaxWalkDepth, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if skip, err := devFilter.skip(path, info); skip {
+			return err
+		}
+
+		if journal != nil {
+			if err := journal.record(path, info, uidArgs, gidArgs); err != nil {
+				return err
+			}
+		}
+
+		matches, err := chownEntry(path, info, uidArgs, gidArgs, opts.dryRun)
+		if err != nil {
+			return err
+		}
+		if matches {
+			changed = append(changed, path)
+		}
+
+		if opts.progress != nil {
+			opts.progress(path, matches)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if journal != nil {
+		if err := journal.close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return changed, devFilter.err()
+}
+
+// chownJournal records the prior ownership of the entries changed by ChownRecursiveFrom.
+// Each record is a line made of the UID, the GID and the quoted path of the entry, separated by spaces.
+type chownJournal struct {
+	f *os.File
+}
+
+func openChownJournal(path string) (*chownJournal, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chown journal: %w", err)
+	}
+	return &chownJournal{f: f}, nil
+}
+
+// record appends the ownership of the entry at path, described by info, to the journal if it is about to be changed,
+// and syncs the journal to disk.
+func (j *chownJournal) record(path string, info os.FileInfo, uidArgs *ChownUIDArgs, gidArgs *ChownGIDArgs) error {
+	matches, err := chownEntry(path, info, uidArgs, gidArgs, true)
+	if err != nil || !matches {
+		return err
+	}
+
+	//nolint:forcetypeassert // chownEntry already checked that it's a syscall.Stat_t.
+	stat := info.Sys().(*syscall.Stat_t)
+	if _, err := fmt.Fprintf(j.f, "%d %d %s\n", stat.Uid, stat.Gid, strconv.Quote(path)); err != nil {
+		return fmt.Errorf("failed to write to chown journal: %w", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync chown journal: %w", err)
+	}
+	return nil
+}
+
+// close closes the journal. It can be called multiple times.
+func (j *chownJournal) close() error {
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// parseChownJournalRecord parses a record written by chownJournal.record.
+func parseChownJournalRecord(record string) (uid, gid uint32, path string, err error) {
+	// The quoted path can contain spaces, so it is the rest of the line.
+	fields := strings.SplitN(record, " ", 3)
+	if len(fields) != 3 {
+		return 0, 0, "", errors.New("expected UID, GID and path")
+	}
+	u, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	g, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	path, err = strconv.Unquote(fields[2])
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return uint32(u), uint32(g), path, nil
+}
+
+// ChownRollback restores the ownership of the entries recorded in the journal
+// file written by ChownRecursiveFrom with WithJournal. The records are applied
+// from the last to the first one, so that the oldest ownership of an entry
+// recorded several times wins.
+//
+// An incomplete last record, written when the process was interrupted, is
+// ignored: the ownership of its entry was not changed yet. Entries which don't
+// exist anymore are skipped.
+func ChownRollback(journalPath string) error {
+	content, err := os.ReadFile(journalPath)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	// The last element is empty if the journal is complete, and an incomplete record otherwise.
+	lines = lines[:len(lines)-1]
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		uid, gid, path, err := parseChownJournalRecord(lines[i])
+		if err != nil {
+			return fmt.Errorf("invalid record %q in chown journal: %w", lines[i], err)
+		}
+
+		if err := os.Lchown(path, int(uid), int(gid)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to restore ownership: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ChownRecursiveFromParallel changes ownership of files and directories under
+// the specified root directory like ChownRecursiveFrom, but the entries are
+// checked and changed by a pool of workers, which is faster on filesystems with
+// a high latency like NFS.
+//
+// The first error stops the walk and is returned.
+func ChownRecursiveFromParallel(root string, uidArgs *ChownUIDArgs, gidArgs *ChownGIDArgs, workers int) error {
+	if uidArgs == nil && gidArgs == nil {
+		return fmt.Errorf("ChownRecursiveFromParallel: at least one of uidArgs or gidArgs must be non-nil")
+	}
+	if workers < 1 {
+		return fmt.Errorf("ChownRecursiveFromParallel: the number of workers must be positive, got %d", workers)
+	}
+
+	devFilter, err := newSameDeviceFilter(root)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var firstErr error
+	var errOnce sync.Once
+	setErr := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if ctx.Err() != nil {
+					// Keep draining the channel, so that the walk doesn't block.
+					continue
+				}
+
+				info, err := os.Lstat(path)
+				if err != nil {
+					setErr(err)
+					continue
+				}
+				if skip, _ := devFilter.skip(path, info); skip {
+					continue
+				}
+				if _, err := chownEntry(path, info, uidArgs, gidArgs, false); err != nil {
+					setErr(err)
+				}
+			}
+		}()
+	}
+
+	err = WalkDirLimited(root, defaultMaxWalkDepth, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Check the device of directories before sending them to the workers, to not descend into them if they are
+		// on another device. Other entries are checked by the workers.
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if skip, err := devFilter.skip(path, info); skip {
+				return err
+			}
+		}
+
+		select {
+		case paths <- path:
+			return nil
+		case <-ctx.Done():
+			return filepath.SkipAll
+		}
+	})
+	close(paths)
+	wg.Wait()
+
+	if err != nil {
+		setErr(err)
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return devFilter.err()
+}
+
+// ChownRecursiveMap changes ownership of files and directories under the
+// specified root directory according to the uidMap and gidMap mapping tables:
+// the UID/GID of each entry is changed to the value it is mapped to, if any.
+// IDs which are not in the maps are left unchanged, so a single walk can
+// migrate several UIDs and GIDs at once.
+//
+// The walk behaves like the one of ChownRecursiveFrom: symlinks are not
+// followed, and entries on other devices than root are skipped.
+//
+// If both maps are empty, an error is returned.
+func ChownRecursiveMap(root string, uidMap, gidMap map[uint32]uint32) error {
+	if len(uidMap) == 0 && len(gidMap) == 0 {
+		return fmt.Errorf("ChownRecursiveMap: at least one of uidMap or gidMap must be non-empty")
+	}
+
+	devFilter, err := newSameDeviceFilter(root)
+	if err != nil {
+		return err
+	}
+
+	err = WalkDirLimited(root, defaultMaxWalkDepth, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if skip, err := devFilter.skip(path, info); skip {
+			return err
+		}
+
+		return chownEntryMap(path, info, uidMap, gidMap)
+	})
+	if err != nil {
+		return err
+	}
+
+	return devFilter.err()
+}
+
+// sameDeviceFilter confines a walk to the device of its root, so that a mount point (e.g. a bind mount crafted by the
+// owner of a home directory) can't redirect the walk outside of the tree of the root.
+// It is safe for concurrent use.
+type sameDeviceFilter struct {
+	root string
+	dev  uint64
+
+	mu      sync.Mutex
+	skipped []string
+}
+
+func newSameDeviceFilter(root string) (*sameDeviceFilter, error) {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("failed to get raw stat for %q", root)
+	}
+
+	//nolint:unconvert // The type of Dev depends on the architecture.
+	return &sameDeviceFilter{root: root, dev: uint64(stat.Dev)}, nil
+}
+
+// skip returns true if the entry at path, described by info, is on another device than the root and must be skipped.
+// In that case, the returned error is filepath.SkipDir if the entry is a directory, so that the walk doesn't descend
+// into it, and nil otherwise.
+func (f *sameDeviceFilter) skip(path string, info os.FileInfo) (bool, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	//nolint:unconvert // The type of Dev depends on the architecture.
+	if !ok || uint64(stat.Dev) == f.dev {
+		return false, nil
+	}
+
+	f.mu.Lock()
+	f.skipped = append(f.skipped, path)
+	f.mu.Unlock()
+
+	if info.IsDir() {
+		return true, filepath.SkipDir
+	}
+	return true, nil
+}
+
+// err returns a SkippedEntriesError listing the skipped entries, if any.
+func (f *sameDeviceFilter) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.skipped) == 0 {
+		return nil
+	}
+	return SkippedEntriesError{Root: f.root, Paths: slices.Clone(f.skipped)}
+}
+
+// SkippedEntriesError is returned by the recursive ownership changes if entries on other devices than the root were
+// skipped. The rest of the tree has been handled, so callers may treat it as a warning.
+type SkippedEntriesError struct {
+	Root  string
+	Paths []string
+}
+
+func (e SkippedEntriesError) Error() string {
+	return fmt.Sprintf("skipped entries on other devices than %q: %s", e.Root, strings.Join(e.Paths, ", "))
+}
+
+// chownEntry changes the ownership of the entry at path, described by info, if
+// its current UID/GID matches uidArgs/gidArgs. It returns whether the entry
+// matched, so that in dry-run mode, the caller knows what would be changed.
+func chownEntry(path string, info os.FileInfo, uidArgs *ChownUIDArgs, gidArgs *ChownGIDArgs, dryRun bool) (bool, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, fmt.Errorf("failed to get raw stat for %q", path)
+	}
+
+	uidMatches := uidArgs != nil && stat.Uid == uidArgs.FromUID
+	gidMatches := gidArgs != nil && stat.Gid == gidArgs.FromGID
+
+	if uidMatches && !dryRun {
+		if err := os.Lchown(path, int(uidArgs.ToUID), -1); err != nil {
+			return false, fmt.Errorf("failed to change ownership: %w", err)
+		}
+	}
+
+	if gidMatches && !dryRun {
+		if err := os.Lchown(path, -1, int(gidArgs.ToGID)); err != nil {
+			return false, fmt.Errorf("failed to change group ownership: %w", err)
+		}
+	}
+
+	return uidMatches || gidMatches, nil
+}
+
+// chownEntryMap changes the UID/GID of the entry at path, described by info,
+// to the values they are mapped to in uidMap/gidMap. Unmapped IDs are left
+// unchanged.
+func chownEntryMap(path string, info os.FileInfo, uidMap, gidMap map[uint32]uint32) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("failed to get raw stat for %q", path)
+	}
+
+	uid, gid := -1, -1
+	if toUID, ok := uidMap[stat.Uid]; ok {
+		uid = int(toUID)
+	}
+	if toGID, ok := gidMap[stat.Gid]; ok {
+		gid = int(toGID)
+	}
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+
+	if err := os.Lchown(path, uid, gid); err != nil {
+		return fmt.Errorf("failed to change ownership: %w", err)
+	}
+	return nil
+}
@@ -1,11 +1,18 @@
 package fileutils_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -15,6 +22,7 @@ import (
 	"github.com/canonical/authd/internal/testutils/golden"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 // errAny represents any error type, for testing purposes.
@@ -63,6 +71,68 @@ func TestFileExists(t *testing.T) {
 	}
 }
 
+func TestLexists(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fileExists                 bool
+		symlinkExists              bool
+		symlinkIsDangling          bool
+		parentDirIsFile            bool
+		parentDirIsDanglingSymlink bool
+
+		wantExists bool
+		wantError  bool
+	}{
+		"Returns_true_when_file_exists":                             {fileExists: true, wantExists: true},
+		"Returns_true_when_symlink_exists":                          {symlinkExists: true, wantExists: true},
+		"Returns_true_when_symlink_is_dangling":                     {symlinkExists: true, symlinkIsDangling: true, wantExists: true},
+		"Returns_false_when_file_does_not_exist":                    {fileExists: false, wantExists: false},
+		"Returns_false_when_parent_directory_is_a_dangling_symlink": {parentDirIsDanglingSymlink: true, wantExists: false},
+
+		"Error_when_parent_directory_is_a_file": {parentDirIsFile: true, wantError: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "file")
+			if tc.fileExists {
+				err := fileutils.Touch(path)
+				require.NoError(t, err, "Touch should not return an error")
+			}
+			if tc.symlinkExists {
+				target := filepath.Join(tempDir, "target")
+				if !tc.symlinkIsDangling {
+					err := fileutils.Touch(target)
+					require.NoError(t, err, "Touch should not return an error")
+				}
+				err := os.Symlink(target, path)
+				require.NoError(t, err, "Symlink should not return an error")
+			}
+			if tc.parentDirIsFile {
+				path = filepath.Join(tempDir, "file", "file")
+				err := fileutils.Touch(filepath.Join(tempDir, "file"))
+				require.NoError(t, err, "Touch should not return an error")
+			}
+			if tc.parentDirIsDanglingSymlink {
+				err := os.Symlink(filepath.Join(tempDir, "nonexistent"), filepath.Join(tempDir, "dir"))
+				require.NoError(t, err, "Symlink should not return an error")
+				path = filepath.Join(tempDir, "dir", "file")
+			}
+
+			exists, err := fileutils.Lexists(path)
+			if tc.wantError {
+				require.Error(t, err, "Lexists should return an error")
+			} else {
+				require.NoError(t, err, "Lexists should not return an error")
+			}
+			require.Equal(t, tc.wantExists, exists, "Lexists should return the expected result")
+		})
+	}
+}
+
 func TestIsDirEmpty(t *testing.T) {
 	t.Parallel()
 
@@ -114,6 +184,56 @@ func TestIsDirEmpty(t *testing.T) {
 	}
 }
 
+func TestIsDirEmptyExcept(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		entries      []string
+		ignore       []string
+		doesNotExist bool
+
+		wantEmpty bool
+		wantError bool
+	}{
+		"Returns_true_when_directory_is_empty":                         {wantEmpty: true},
+		"Returns_true_when_directory_is_empty_with_ignored_names":      {ignore: []string{".bashrc"}, wantEmpty: true},
+		"Returns_true_when_directory_only_contains_ignored_entries":    {entries: []string{".bashrc", ".profile"}, ignore: []string{".bashrc", ".profile", ".bash_logout"}, wantEmpty: true},
+		"Returns_false_when_directory_contains_other_entries":          {entries: []string{".bashrc", "file"}, ignore: []string{".bashrc"}},
+		"Returns_false_when_directory_contains_entries_without_ignore": {entries: []string{".bashrc"}},
+		"Returns_false_when_directory_contains_many_ignored_entries_and_another_one": {
+			entries: []string{"a", "b", "c", "d", "e"},
+			ignore:  []string{"a", "b", "c", "d"},
+		},
+
+		"Error_when_directory_does_not_exist": {doesNotExist: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "dir")
+
+			if !tc.doesNotExist {
+				err := os.Mkdir(path, 0o700)
+				require.NoError(t, err, "Setup: Mkdir should not return an error")
+			}
+			for _, entry := range tc.entries {
+				err := fileutils.Touch(filepath.Join(path, entry))
+				require.NoError(t, err, "Setup: Touch should not return an error")
+			}
+
+			empty, err := fileutils.IsDirEmptyExcept(path, tc.ignore)
+			if tc.wantError {
+				require.Error(t, err, "IsDirEmptyExcept should return an error")
+			} else {
+				require.NoError(t, err, "IsDirEmptyExcept should not return an error")
+			}
+			require.Equal(t, tc.wantEmpty, empty, "IsDirEmptyExcept should return the expected result")
+		})
+	}
+}
+
 func TestTouch(t *testing.T) {
 	t.Parallel()
 
@@ -164,6 +284,243 @@ func TestTouch(t *testing.T) {
 	}
 }
 
+func TestTouchMode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		existingMode os.FileMode
+		enforceMode  bool
+		fileIsDir    bool
+
+		wantMode  os.FileMode
+		wantError bool
+	}{
+		"Creates_file_with_the_mode_when_it_does_not_exist":        {wantMode: 0o644},
+		"Does_not_change_the_mode_of_an_existing_file":             {existingMode: 0o600, wantMode: 0o600},
+		"Changes_the_mode_of_an_existing_file_if_mode_is_enforced": {existingMode: 0o600, enforceMode: true, wantMode: 0o644},
+		"Creates_file_with_the_mode_if_mode_is_enforced":           {enforceMode: true, wantMode: 0o644},
+
+		"Returns_error_when_file_is_a_directory": {fileIsDir: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "file")
+
+			if tc.existingMode != 0 {
+				err := os.WriteFile(path, []byte("content"), tc.existingMode)
+				require.NoError(t, err, "Setup: WriteFile should not return an error")
+			}
+
+			if tc.fileIsDir {
+				err := os.Mkdir(path, 0o700)
+				require.NoError(t, err, "Setup: Mkdir should not return an error")
+			}
+
+			var opts []fileutils.TouchOption
+			if tc.enforceMode {
+				opts = append(opts, fileutils.WithEnforcedMode())
+			}
+
+			err := fileutils.TouchMode(path, 0o644, opts...)
+			if tc.wantError {
+				require.Error(t, err, "TouchMode should return an error")
+				return
+			}
+			require.NoError(t, err, "TouchMode should not return an error")
+
+			fileInfo, err := os.Stat(path)
+			require.NoError(t, err, "Stat should not return an error")
+			require.Equal(t, tc.wantMode, fileInfo.Mode(), "File mode should match")
+
+			if tc.existingMode != 0 {
+				content, err := os.ReadFile(path)
+				require.NoError(t, err, "ReadFile should not return an error")
+				require.Equal(t, "content", string(content), "Content of the existing file should be preserved")
+			}
+		})
+	}
+}
+
+func TestTouchUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fileExists         bool
+		fileIsDir          bool
+		parentDoesNotExist bool
+
+		wantError bool
+	}{
+		"Creates_file_when_it_does_not_exist":         {},
+		"Updates_timestamps_when_file_already_exists": {fileExists: true},
+
+		"Returns_error_when_file_is_a_directory":             {fileIsDir: true, wantError: true},
+		"Returns_error_when_parent_directory_does_not_exist": {parentDoesNotExist: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "file")
+
+			oldTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			if tc.fileExists {
+				err := os.WriteFile(path, []byte("content"), 0o600)
+				require.NoError(t, err, "Setup: WriteFile should not return an error")
+				err = os.Chtimes(path, oldTime, oldTime)
+				require.NoError(t, err, "Setup: Chtimes should not return an error")
+			}
+
+			if tc.fileIsDir {
+				path = filepath.Join(tempDir, "dir")
+				err := os.Mkdir(path, 0o700)
+				require.NoError(t, err, "Setup: Mkdir should not return an error")
+			}
+
+			if tc.parentDoesNotExist {
+				path = filepath.Join(tempDir, "dir", "file")
+			}
+
+			err := fileutils.TouchUpdate(path)
+			if tc.wantError {
+				require.Error(t, err, "TouchUpdate should return an error")
+				return
+			}
+			require.NoError(t, err, "TouchUpdate should not return an error")
+
+			fileInfo, err := os.Stat(path)
+			require.NoError(t, err, "Stat should not return an error")
+			require.True(t, fileInfo.ModTime().After(oldTime), "Modification time should be updated")
+
+			if tc.fileExists {
+				content, err := os.ReadFile(path)
+				require.NoError(t, err, "ReadFile should not return an error")
+				require.Equal(t, "content", string(content), "Content of the existing file should be preserved")
+			}
+		})
+	}
+}
+
+func TestSameFile(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		pathB string
+
+		want bool
+	}{
+		"Same_path_is_the_same_file":           {pathB: "file", want: true},
+		"Hardlink_is_the_same_file":            {pathB: "hardlink", want: true},
+		"Symlink_to_the_file_is_the_same_file": {pathB: "symlink", want: true},
+
+		"Different_file_is_not_the_same_file":            {pathB: "other"},
+		"File_which_does_not_exist_is_not_the_same_file": {pathB: "nonexistent"},
+		"Dangling_symlink_is_not_the_same_file":          {pathB: "dangling"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "file")
+
+			err := os.WriteFile(path, []byte("content"), 0o600)
+			require.NoError(t, err, "Setup: WriteFile should not return an error")
+			err = os.WriteFile(filepath.Join(tempDir, "other"), []byte("content"), 0o600)
+			require.NoError(t, err, "Setup: WriteFile should not return an error")
+			err = os.Link(path, filepath.Join(tempDir, "hardlink"))
+			require.NoError(t, err, "Setup: Link should not return an error")
+			err = os.Symlink("file", filepath.Join(tempDir, "symlink"))
+			require.NoError(t, err, "Setup: Symlink should not return an error")
+			err = os.Symlink("nonexistent", filepath.Join(tempDir, "dangling"))
+			require.NoError(t, err, "Setup: Symlink should not return an error")
+
+			got, err := fileutils.SameFile(path, filepath.Join(tempDir, tc.pathB))
+			require.NoError(t, err, "SameFile should not return an error")
+			require.Equal(t, tc.want, got, "SameFile should return the expected result")
+
+			got, err = fileutils.SameFile(filepath.Join(tempDir, tc.pathB), path)
+			require.NoError(t, err, "SameFile should not return an error")
+			require.Equal(t, tc.want, got, "SameFile should be symmetric")
+		})
+	}
+}
+
+func TestEnsureDir(t *testing.T) {
+	t.Parallel()
+
+	uid, gid := os.Getuid(), os.Getgid()
+
+	tests := map[string]struct {
+		dirExists    bool
+		existingMode os.FileMode
+		pathIsFile   bool
+		mode         os.FileMode
+		uid          int
+		gid          int
+
+		wantError bool
+	}{
+		"Creates_directory_and_parents":            {mode: 0o700, uid: -1, gid: -1},
+		"Sets_mode_regardless_of_umask":            {mode: 0o777, uid: -1, gid: -1},
+		"Enforces_mode_on_existing_directory":      {dirExists: true, existingMode: 0o755, mode: 0o700, uid: -1, gid: -1},
+		"Sets_ownership":                           {mode: 0o700, uid: uid, gid: gid},
+		"Sets_only_the_owner":                      {mode: 0o700, uid: uid, gid: -1},
+		"Sets_only_the_group":                      {mode: 0o700, uid: -1, gid: gid},
+		"Enforces_ownership_on_existing_directory": {dirExists: true, existingMode: 0o700, mode: 0o750, uid: uid, gid: gid},
+
+		"Error_when_path_is_a_file": {pathIsFile: true, mode: 0o700, uid: -1, gid: -1, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "parent", "dir")
+
+			if tc.dirExists {
+				err := os.MkdirAll(path, tc.existingMode)
+				require.NoError(t, err, "MkdirAll should not return an error")
+				err = os.Chmod(path, tc.existingMode)
+				require.NoError(t, err, "Chmod should not return an error")
+			}
+
+			if tc.pathIsFile {
+				path = filepath.Join(tempDir, "file")
+				err := fileutils.Touch(path)
+				require.NoError(t, err, "Touch should not return an error")
+			}
+
+			err := fileutils.EnsureDir(path, tc.mode, tc.uid, tc.gid)
+			if tc.wantError {
+				require.Error(t, err, "EnsureDir should return an error")
+				return
+			}
+			require.NoError(t, err, "EnsureDir should not return an error")
+
+			fileInfo, err := os.Stat(path)
+			require.NoError(t, err, "Stat should not return an error")
+			require.True(t, fileInfo.IsDir(), "%q should be a directory", path)
+			require.Equal(t, tc.mode, fileInfo.Mode().Perm(), "Directory mode does not match")
+
+			stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+			require.True(t, ok, "File should have a syscall.Stat_t")
+			//nolint:gosec // G115 UIDs and GIDs are never negative
+			require.Equal(t, uint32(uid), stat.Uid, "Directory owner does not match")
+			//nolint:gosec // G115 UIDs and GIDs are never negative
+			require.Equal(t, uint32(gid), stat.Gid, "Directory group does not match")
+		})
+	}
+}
+
 func TestCopyFile(t *testing.T) {
 	t.Parallel()
 
@@ -171,6 +528,7 @@ func TestCopyFile(t *testing.T) {
 		sourceDoesNotExist bool
 		destExists         bool
 		destIsDir          bool
+		destIsSource       bool
 		parentDoesNotExist bool
 		fileMode           os.FileMode
 
@@ -184,6 +542,7 @@ func TestCopyFile(t *testing.T) {
 		"Returns_error_when_source_does_not_exists":          {sourceDoesNotExist: true, destIsDir: true, wantError: true},
 		"Returns_error_when_file_is_a_directory":             {destIsDir: true, wantError: true},
 		"Returns_error_when_parent_directory_does_not_exist": {parentDoesNotExist: true, wantError: true},
+		"Returns_error_when_destination_is_the_source":       {destIsSource: true, wantError: true},
 	}
 
 	for name, tc := range tests {
@@ -194,6 +553,11 @@ func TestCopyFile(t *testing.T) {
 			srcPath := filepath.Join(tempDir, "file")
 			destPath := filepath.Join(tempDir, "dest")
 
+			if tc.destIsSource {
+				err := os.Symlink("file", destPath)
+				require.NoError(t, err, "Symlink should not return an error")
+			}
+
 			if tc.destExists && !tc.destIsDir {
 				err := os.WriteFile(srcPath, []byte(uuid.NewString()), 0o600)
 				require.NoError(t, err, "WriteFile should not return an error")
@@ -231,7 +595,13 @@ func TestCopyFile(t *testing.T) {
 
 				exists, err := fileutils.FileExists(destPath)
 				require.NoError(t, err, "FileExists should not return an error")
-				require.Equal(t, tc.destExists || tc.destIsDir, exists, "File should exist")
+				require.Equal(t, tc.destExists || tc.destIsDir || tc.destIsSource, exists, "File should exist")
+
+				if tc.destIsSource {
+					content, err := os.ReadFile(srcPath)
+					require.NoError(t, err, "ReadFile should not return an error")
+					require.Equal(t, wantContent, string(content), "Source content should be preserved")
+				}
 				return
 			}
 
@@ -257,30 +627,21 @@ func TestCopyFile(t *testing.T) {
 	}
 }
 
-func TestLrename(t *testing.T) {
+func TestCopyFileContext(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		sourceDoesNotExist     bool
-		destIsFile             bool
-		destIsSymlink          bool
-		destIsDanglingSymlink  bool
-		destIsDir              bool
-		destIsUnreadable       bool
-		destParentDoesNotExist bool
+		cancelContext bool
+		destExists    bool
 
 		wantError error
 	}{
-		"Successfully_rename_file_if_destination_does_not_exist": {},
-		"Successfully_rename_file_if_destination_is_a_file":      {destIsFile: true},
-		"Successfully_rename_file_if_destination_is_a_symlink":   {destIsSymlink: true},
-		"Successfully_rename_file_if_destination_is_unreadable":  {destIsFile: true, destIsUnreadable: true},
+		"Copies_file_when_context_is_not_done": {},
 
-		"Error_when_source_does_not_exist":                       {sourceDoesNotExist: true, wantError: errAny},
-		"Error_when_destination_is_a_directory":                  {destIsDir: true, wantError: errAny},
-		"Error_when_destination_parent_directory_does_not_exist": {destParentDoesNotExist: true, wantError: errAny},
-		"Error_when_destination_is_a_dangling_symlink":           {destIsDanglingSymlink: true, wantError: fileutils.SymlinkResolutionError{}},
-		"Error_unwrap_when_destination_is_a_dangling_symlink":    {destIsDanglingSymlink: true, wantError: os.ErrNotExist},
+		"Copies_file_over_existing_destination": {destExists: true},
+
+		"Error_and_does_not_create_destination_when_context_is_cancelled": {cancelContext: true, wantError: context.Canceled},
+		"Error_and_keeps_existing_destination_when_context_is_cancelled":  {cancelContext: true, destExists: true, wantError: context.Canceled},
 	}
 
 	for name, tc := range tests {
@@ -288,44 +649,1242 @@ func TestLrename(t *testing.T) {
 			t.Parallel()
 
 			tempDir := t.TempDir()
-			srcPath := filepath.Join(tempDir, "source")
+			srcPath := filepath.Join(tempDir, "file")
 			destPath := filepath.Join(tempDir, "dest")
 
-			if !tc.sourceDoesNotExist {
-				err := os.WriteFile(srcPath, []byte("test content"), 0o600)
-				require.NoError(t, err, "WriteFile should not return an error")
-			}
+			wantContent := uuid.NewString()
+			err := os.WriteFile(srcPath, []byte(wantContent), 0o600)
+			require.NoError(t, err, "WriteFile should not return an error")
 
-			if tc.destIsFile {
+			if tc.destExists {
 				err := os.WriteFile(destPath, []byte("existing content"), 0o600)
 				require.NoError(t, err, "WriteFile should not return an error")
 			}
 
-			if tc.destIsSymlink {
-				symlinkTarget := filepath.Join(tempDir, "symlink_target")
-				err := os.WriteFile(symlinkTarget, []byte("symlink content"), 0o600)
-				require.NoError(t, err, "WriteFile should not return an error")
-
-				err = os.Symlink(symlinkTarget, destPath)
-				require.NoError(t, err, "Symlink should not return an error")
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelContext {
+				cancel()
 			}
 
-			if tc.destIsDanglingSymlink {
-				err := os.Symlink("nonexistent_target", destPath)
-				require.NoError(t, err, "Symlink should not return an error")
+			err = fileutils.CopyFileContext(ctx, srcPath, destPath)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError, "CopyFileContext should return the expected error")
+
+				if tc.destExists {
+					content, err := os.ReadFile(destPath)
+					require.NoError(t, err, "ReadFile should not return an error")
+					require.Equal(t, "existing content", string(content), "Existing destination should be left untouched")
+				} else {
+					exists, err := fileutils.FileExists(destPath)
+					require.NoError(t, err, "FileExists should not return an error")
+					require.False(t, exists, "Destination file should not be created")
+				}
+				requireNoTempFiles(t, tempDir, srcPath, destPath)
+				return
 			}
+			require.NoError(t, err, "CopyFileContext should not return an error")
+			requireNoTempFiles(t, tempDir, srcPath, destPath)
 
-			if tc.destIsDir {
-				err := os.Mkdir(destPath, 0o700)
-				require.NoError(t, err, "Mkdir should not return an error")
-			}
+			content, err := os.ReadFile(destPath)
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.Equal(t, wantContent, string(content), "File contents does not match")
+		})
+	}
+}
 
-			if tc.destIsUnreadable {
-				err := os.Chmod(destPath, 0o000)
-				require.NoError(t, err, "Chmod should not return an error")
-				// Restore permissions after test
-				defer func() {
-					//nolint:gosec // G302 Permissions 0700 are not insecure for a directory
+func TestPreallocate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		size       int64
+		closedFile bool
+
+		wantError bool
+	}{
+		"Preallocates_space_without_changing_the_size": {size: 1024 * 1024},
+		"Does_nothing_if_size_is_zero":                 {},
+		"Does_nothing_if_size_is_negative":             {size: -1},
+
+		"Error_when_file_is_closed": {size: 1024, closedFile: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+			require.NoError(t, err, "Setup: Create should not return an error")
+			if tc.closedFile {
+				require.NoError(t, f.Close(), "Setup: Close should not return an error")
+			} else {
+				t.Cleanup(func() { f.Close() })
+			}
+
+			err = fileutils.Preallocate(f, tc.size)
+			if tc.wantError {
+				require.Error(t, err, "Preallocate should return an error")
+				return
+			}
+			require.NoError(t, err, "Preallocate should not return an error")
+
+			fileInfo, err := f.Stat()
+			require.NoError(t, err, "Stat should not return an error")
+			require.Zero(t, fileInfo.Size(), "Preallocate should not change the size of the file")
+			if tc.size > 0 {
+				blocks := fileInfo.Sys().(*syscall.Stat_t).Blocks
+				require.GreaterOrEqual(t, blocks*512, tc.size, "Preallocate should allocate the disk space")
+			}
+		})
+	}
+}
+
+func TestCopyFileBuffered(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		bufSize int
+		size    int
+
+		wantError bool
+	}{
+		"Copies_file_smaller_than_the_buffer":           {bufSize: 1024, size: 100},
+		"Copies_file_larger_than_the_buffer":            {bufSize: 7, size: 100},
+		"Copies_file_which_is_a_multiple_of_the_buffer": {bufSize: 10, size: 100},
+		"Copies_empty_file":                             {bufSize: 10},
+
+		"Error_when_buffer_size_is_zero":     {bufSize: 0, size: 100, wantError: true},
+		"Error_when_buffer_size_is_negative": {bufSize: -1, size: 100, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			srcPath := filepath.Join(tempDir, "file")
+			destPath := filepath.Join(tempDir, "dest")
+
+			wantContent := []byte(strings.Repeat("a", tc.size))
+			err := os.WriteFile(srcPath, wantContent, 0o640)
+			require.NoError(t, err, "Setup: WriteFile should not return an error")
+			err = os.Chmod(srcPath, 0o640)
+			require.NoError(t, err, "Setup: Chmod should not return an error")
+
+			err = fileutils.CopyFileBuffered(srcPath, destPath, tc.bufSize)
+			if tc.wantError {
+				require.Error(t, err, "CopyFileBuffered should return an error")
+
+				exists, err := fileutils.FileExists(destPath)
+				require.NoError(t, err, "FileExists should not return an error")
+				require.False(t, exists, "Destination file should not be created")
+				return
+			}
+			require.NoError(t, err, "CopyFileBuffered should not return an error")
+
+			fileInfo, err := os.Stat(destPath)
+			require.NoError(t, err, "Stat should not return an error")
+			require.Equal(t, os.FileMode(0o640), fileInfo.Mode(), "File mode should be preserved")
+
+			content, err := os.ReadFile(destPath)
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.Equal(t, wantContent, content, "File contents does not match")
+		})
+	}
+}
+
+func TestCopyFileNoSync(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sourceDoesNotExist bool
+		destExists         bool
+
+		wantError bool
+	}{
+		"Copies_file_when_destination_does_not_exist": {},
+		"Copies_file_when_destination_exists":         {destExists: true},
+
+		"Error_when_source_does_not_exist": {sourceDoesNotExist: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			srcPath := filepath.Join(tempDir, "file")
+			destPath := filepath.Join(tempDir, "dest")
+
+			wantContent := uuid.NewString()
+			if !tc.sourceDoesNotExist {
+				err := os.WriteFile(srcPath, []byte(wantContent), 0o600)
+				require.NoError(t, err, "Setup: WriteFile should not return an error")
+				err = os.Chmod(srcPath, 0o750)
+				require.NoError(t, err, "Setup: Chmod should not return an error")
+			}
+			if tc.destExists {
+				err := os.WriteFile(destPath, []byte("existing content which is longer"), 0o600)
+				require.NoError(t, err, "Setup: WriteFile should not return an error")
+			}
+
+			err := fileutils.CopyFileNoSync(srcPath, destPath)
+			if tc.wantError {
+				require.Error(t, err, "CopyFileNoSync should return an error")
+				return
+			}
+			require.NoError(t, err, "CopyFileNoSync should not return an error")
+
+			if !tc.destExists {
+				fileInfo, err := os.Stat(destPath)
+				require.NoError(t, err, "Stat should not return an error")
+				require.Equal(t, os.FileMode(0o750), fileInfo.Mode(), "File mode should be preserved")
+			}
+
+			content, err := os.ReadFile(destPath)
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.Equal(t, wantContent, string(content), "File contents does not match")
+		})
+	}
+}
+
+func TestCopyFileSparse(t *testing.T) {
+	t.Parallel()
+
+	const size = 8 * 1024 * 1024
+
+	tests := map[string]struct {
+		dataOffsets        []int64
+		holesNotSupported  bool
+		destExists         bool
+		sourceDoesNotExist bool
+		destIsSource       bool
+		seekError          bool
+
+		wantError bool
+	}{
+		"Copies_sparse_file":                     {dataOffsets: []int64{0, 4 * 1024 * 1024}},
+		"Copies_file_starting_with_a_hole":       {dataOffsets: []int64{2 * 1024 * 1024}},
+		"Copies_file_ending_with_data":           {dataOffsets: []int64{size - 4096}},
+		"Copies_file_which_is_only_a_hole":       {},
+		"Copies_whole_file_if_holes_unsupported": {dataOffsets: []int64{1024 * 1024}, holesNotSupported: true},
+		"Copies_file_over_existing_larger_file":  {dataOffsets: []int64{0}, destExists: true},
+
+		"Error_when_source_does_not_exist":    {sourceDoesNotExist: true, wantError: true},
+		"Error_when_destination_is_source":    {destIsSource: true, wantError: true},
+		"Error_when_seeking_the_source_fails": {dataOffsets: []int64{0}, seekError: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			srcPath := filepath.Join(tempDir, "file")
+			destPath := filepath.Join(tempDir, "dest")
+			if tc.destIsSource {
+				destPath = srcPath
+			}
+
+			if !tc.sourceDoesNotExist {
+				f, err := os.OpenFile(srcPath, os.O_WRONLY|os.O_CREATE, 0o640)
+				require.NoError(t, err, "Setup: OpenFile should not return an error")
+				for _, offset := range tc.dataOffsets {
+					_, err = f.WriteAt([]byte(uuid.NewString()), offset)
+					require.NoError(t, err, "Setup: WriteAt should not return an error")
+				}
+				require.NoError(t, f.Truncate(size), "Setup: Truncate should not return an error")
+				require.NoError(t, f.Close(), "Setup: Close should not return an error")
+			}
+			if tc.destExists {
+				err := os.WriteFile(destPath, make([]byte, 2*size), 0o600)
+				require.NoError(t, err, "Setup: WriteFile should not return an error")
+			}
+
+			seek := unix.Seek
+			if tc.holesNotSupported {
+				seek = func(int, int64, int) (int64, error) { return 0, unix.EINVAL }
+			}
+			if tc.seekError {
+				seek = func(int, int64, int) (int64, error) { return 0, unix.EIO }
+			}
+
+			err := fileutils.CopyFileSparseWithSeek(srcPath, destPath, seek)
+			if tc.wantError {
+				require.Error(t, err, "CopyFileSparse should return an error")
+				return
+			}
+			require.NoError(t, err, "CopyFileSparse should not return an error")
+
+			want, err := os.ReadFile(srcPath)
+			require.NoError(t, err, "ReadFile should not return an error")
+			got, err := os.ReadFile(destPath)
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.True(t, bytes.Equal(want, got), "File contents does not match")
+
+			destInfo, err := os.Stat(destPath)
+			require.NoError(t, err, "Stat should not return an error")
+			if !tc.destExists {
+				require.Equal(t, os.FileMode(0o640), destInfo.Mode(), "File mode should be preserved")
+			}
+
+			srcInfo, err := os.Stat(srcPath)
+			require.NoError(t, err, "Stat should not return an error")
+			srcBlocks := srcInfo.Sys().(*syscall.Stat_t).Blocks
+			destBlocks := destInfo.Sys().(*syscall.Stat_t).Blocks
+			if tc.holesNotSupported || srcBlocks*512 >= size {
+				// The whole file is copied, or the filesystem of the test doesn't create sparse files.
+				return
+			}
+			require.LessOrEqual(t, destBlocks, srcBlocks, "The holes of the source should be preserved")
+		})
+	}
+}
+
+func BenchmarkCopyFileBuffered(b *testing.B) {
+	tempDir := b.TempDir()
+	srcPath := filepath.Join(tempDir, "file")
+	destPath := filepath.Join(tempDir, "dest")
+
+	const size = 64 * 1024 * 1024
+	err := os.WriteFile(srcPath, make([]byte, size), 0o600)
+	require.NoError(b, err, "Setup: WriteFile should not return an error")
+
+	for _, bufSize := range []int{32 * 1024, 1024 * 1024} {
+		b.Run(fmt.Sprintf("buffer_%dKiB", bufSize/1024), func(b *testing.B) {
+			b.SetBytes(size)
+			for b.Loop() {
+				err := fileutils.CopyFileBuffered(srcPath, destPath, bufSize)
+				require.NoError(b, err, "CopyFileBuffered should not return an error")
+			}
+		})
+	}
+}
+
+// requireNoTempFiles checks that dir doesn't contain other files than the given paths.
+func requireNoTempFiles(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err, "ReadDir should not return an error")
+	for _, e := range entries {
+		require.Contains(t, paths, filepath.Join(dir, e.Name()), "Temporary file %q should be removed", e.Name())
+	}
+}
+
+func TestCopyFileWithMeta(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		preserveOwner      bool
+		sourceDoesNotExist bool
+
+		wantError bool
+	}{
+		"Preserves_timestamps":               {},
+		"Preserves_timestamps_and_ownership": {preserveOwner: true},
+
+		"Error_when_source_does_not_exist": {sourceDoesNotExist: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			srcPath := filepath.Join(tempDir, "file")
+			destPath := filepath.Join(tempDir, "dest")
+
+			wantContent := uuid.NewString()
+			wantAtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			wantMtime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+			if !tc.sourceDoesNotExist {
+				err := os.WriteFile(srcPath, []byte(wantContent), 0o640)
+				require.NoError(t, err, "WriteFile should not return an error")
+				err = os.Chtimes(srcPath, wantAtime, wantMtime)
+				require.NoError(t, err, "Chtimes should not return an error")
+			}
+
+			err := fileutils.CopyFileWithMeta(srcPath, destPath, tc.preserveOwner)
+			if tc.wantError {
+				require.Error(t, err, "CopyFileWithMeta should return an error")
+				return
+			}
+			require.NoError(t, err, "CopyFileWithMeta should not return an error")
+
+			srcInfo, err := os.Stat(srcPath)
+			require.NoError(t, err, "Stat should not return an error")
+			destInfo, err := os.Stat(destPath)
+			require.NoError(t, err, "Stat should not return an error")
+
+			require.Equal(t, srcInfo.Mode(), destInfo.Mode(), "File mode does not match")
+			require.True(t, wantMtime.Equal(destInfo.ModTime()), "Modification time does not match: %v", destInfo.ModTime())
+
+			srcStat, ok := srcInfo.Sys().(*syscall.Stat_t)
+			require.True(t, ok, "File should have a syscall.Stat_t")
+			destStat, ok := destInfo.Sys().(*syscall.Stat_t)
+			require.True(t, ok, "File should have a syscall.Stat_t")
+			require.True(t, wantAtime.Equal(time.Unix(destStat.Atim.Unix())), "Access time does not match")
+			require.Equal(t, srcStat.Uid, destStat.Uid, "Owner does not match")
+			require.Equal(t, srcStat.Gid, destStat.Gid, "Group does not match")
+
+			// Only read the content after checking the access time, because reading may update it.
+			content, err := os.ReadFile(destPath)
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.Equal(t, wantContent, string(content), "File contents does not match")
+		})
+	}
+}
+
+func TestFileChecksum(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		content          string
+		hash             func() hash.Hash
+		fileDoesNotExist bool
+		fileIsDir        bool
+
+		wantChecksum string
+		wantError    bool
+	}{
+		"Returns_SHA256_checksum_of_file":       {content: "hello world\n", wantChecksum: "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"},
+		"Returns_SHA256_checksum_of_empty_file": {wantChecksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		"Returns_checksum_with_the_given_hash":  {content: "hello world\n", hash: func() hash.Hash { return crc32.NewIEEE() }, wantChecksum: "af083b2d"},
+
+		"Error_when_file_does_not_exist": {fileDoesNotExist: true, wantError: true},
+		"Error_when_file_is_a_directory": {fileIsDir: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "file")
+			if tc.fileIsDir {
+				err := os.Mkdir(path, 0o700)
+				require.NoError(t, err, "Mkdir should not return an error")
+			} else if !tc.fileDoesNotExist {
+				err := os.WriteFile(path, []byte(tc.content), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+			}
+
+			var checksum string
+			var err error
+			if tc.hash != nil {
+				checksum, err = fileutils.FileChecksum(path, tc.hash())
+			} else {
+				checksum, err = fileutils.FileSHA256(path)
+			}
+			if tc.wantError {
+				require.Error(t, err, "FileChecksum should return an error")
+				return
+			}
+			require.NoError(t, err, "FileChecksum should not return an error")
+			require.Equal(t, tc.wantChecksum, checksum, "FileChecksum should return the expected checksum")
+		})
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		content      string
+		doesNotExist bool
+
+		wantLines []string
+		wantError bool
+	}{
+		"Returns_the_lines_of_the_file":         {content: "first\nsecond\n", wantLines: []string{"first", "second"}},
+		"Returns_the_last_line_without_newline": {content: "first\nsecond", wantLines: []string{"first", "second"}},
+		"Trims_whitespace":                      {content: "  first \t\n\tsecond\r\n", wantLines: []string{"first", "second"}},
+		"Skips_empty_lines":                     {content: "first\n\n   \nsecond\n", wantLines: []string{"first", "second"}},
+		"Skips_comment_lines":                   {content: "# comment\nfirst\n  # indented comment\nsecond # not a comment\n", wantLines: []string{"first", "second # not a comment"}},
+		"Returns_long_lines":                    {content: strings.Repeat("a", 100*1024) + "\n", wantLines: []string{strings.Repeat("a", 100*1024)}},
+		"Returns_no_lines_for_empty_file":       {},
+
+		"Error_when_file_does_not_exist": {doesNotExist: true, wantError: true},
+		"Error_when_line_is_too_long":    {content: strings.Repeat("a", 2*1024*1024), wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "file")
+			if !tc.doesNotExist {
+				err := os.WriteFile(path, []byte(tc.content), 0o600)
+				require.NoError(t, err, "Setup: WriteFile should not return an error")
+			}
+
+			lines, err := fileutils.ReadLines(path)
+			if tc.wantError {
+				require.Error(t, err, "ReadLines should return an error")
+				return
+			}
+			require.NoError(t, err, "ReadLines should not return an error")
+			require.Equal(t, tc.wantLines, lines, "ReadLines should return the expected lines")
+
+			if tc.doesNotExist {
+				return
+			}
+			lines, err = fileutils.ReadLinesFrom(strings.NewReader(tc.content))
+			require.NoError(t, err, "ReadLinesFrom should not return an error")
+			require.Equal(t, tc.wantLines, lines, "ReadLinesFrom should return the same lines as ReadLines")
+		})
+	}
+}
+
+func TestWalkDirLimited(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		maxDepth int
+		skipDir  string
+
+		wantVisited []string
+		wantError   error
+	}{
+		"Walks_the_whole_tree_when_it_is_not_deeper_than_the_limit": {
+			maxDepth:    3,
+			wantVisited: []string{".", "a", "a/b", "a/b/c", "file"},
+		},
+		"Does_not_return_an_error_when_deep_directories_are_skipped": {
+			maxDepth:    2,
+			skipDir:     "a/b",
+			wantVisited: []string{".", "a", "a/b", "file"},
+		},
+
+		"Error_when_the_tree_is_deeper_than_the_limit":  {maxDepth: 2, wantError: fileutils.ErrMaxDepthExceeded},
+		"Error_when_the_root_has_children_with_limit_0": {maxDepth: 0, wantError: fileutils.ErrMaxDepthExceeded},
+		"Error_when_the_limit_is_negative":              {maxDepth: -1, wantError: errAny},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+			err := os.MkdirAll(filepath.Join(root, "a", "b", "c"), 0o700)
+			require.NoError(t, err, "Setup: MkdirAll should not return an error")
+			err = fileutils.Touch(filepath.Join(root, "file"))
+			require.NoError(t, err, "Setup: Touch should not return an error")
+
+			var visited []string
+			err = fileutils.WalkDirLimited(root, tc.maxDepth, func(path string, d os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				relPath, err := filepath.Rel(root, path)
+				require.NoError(t, err, "Rel should not return an error")
+				visited = append(visited, relPath)
+
+				if relPath == tc.skipDir {
+					return filepath.SkipDir
+				}
+				return nil
+			})
+			if errors.Is(tc.wantError, errAny) {
+				require.Error(t, err, "WalkDirLimited should return an error")
+				return
+			}
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError, "WalkDirLimited should return the expected error")
+				return
+			}
+			require.NoError(t, err, "WalkDirLimited should not return an error")
+			require.Equal(t, tc.wantVisited, visited, "WalkDirLimited should visit the expected entries")
+		})
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		emptyDir         bool
+		withSymlink      bool
+		rootDoesNotExist bool
+
+		wantBytes int64
+		wantFiles int64
+		wantError bool
+	}{
+		"Returns_size_and_entries_of_directory_tree": {wantBytes: 15, wantFiles: 3},
+		"Does_not_follow_symlinks":                   {withSymlink: true, wantBytes: 15, wantFiles: 4},
+		"Returns_zero_for_empty_directory":           {emptyDir: true},
+
+		"Error_when_root_does_not_exist": {rootDoesNotExist: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			root := filepath.Join(tempDir, "root")
+
+			if !tc.rootDoesNotExist {
+				err := os.Mkdir(root, 0o700)
+				require.NoError(t, err, "Mkdir should not return an error")
+			}
+
+			if !tc.emptyDir && !tc.rootDoesNotExist {
+				err := os.Mkdir(filepath.Join(root, "subdir"), 0o700)
+				require.NoError(t, err, "Mkdir should not return an error")
+				err = os.WriteFile(filepath.Join(root, "file"), []byte("12345"), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+				err = os.WriteFile(filepath.Join(root, "subdir", "file"), []byte("1234567890"), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+			}
+
+			if tc.withSymlink {
+				// The target is outside of root and must not be counted.
+				target := filepath.Join(tempDir, "target")
+				err := os.WriteFile(target, []byte("some content"), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+				err = os.Symlink(target, filepath.Join(root, "symlink"))
+				require.NoError(t, err, "Symlink should not return an error")
+			}
+
+			bytes, files, err := fileutils.DiskUsage(root)
+			if tc.wantError {
+				require.Error(t, err, "DiskUsage should return an error")
+				return
+			}
+			require.NoError(t, err, "DiskUsage should not return an error")
+			require.Equal(t, tc.wantBytes, bytes, "DiskUsage should return the expected size")
+			require.Equal(t, tc.wantFiles, files, "DiskUsage should return the expected number of entries")
+		})
+	}
+}
+
+func TestDirsEqual(t *testing.T) {
+	t.Parallel()
+
+	writeFile := func(content string, path ...string) func(t *testing.T, dir string) {
+		return func(t *testing.T, dir string) {
+			t.Helper()
+			err := os.WriteFile(filepath.Join(append([]string{dir}, path...)...), []byte(content), 0o600)
+			require.NoError(t, err, "Setup: WriteFile should not return an error")
+		}
+	}
+
+	tests := map[string]struct {
+		modify             func(t *testing.T, dir string)
+		compareContent     bool
+		secondDoesNotExist bool
+		needsRoot          bool
+
+		wantDiffs []string
+		wantError bool
+	}{
+		"Trees_are_equal":                                 {},
+		"Trees_are_equal_with_content_comparison":         {compareContent: true},
+		"Different_content_is_ignored_without_comparison": {modify: writeFile("FILE CONTENT", "file")},
+
+		"Entry_only_in_second_tree": {modify: writeFile("extra", "subdir", "extra"), wantDiffs: []string{"subdir/extra"}},
+		"Entry_only_in_first_tree": {
+			modify: func(t *testing.T, dir string) {
+				t.Helper()
+				require.NoError(t, os.Remove(filepath.Join(dir, "subdir", "file")), "Setup: Remove should not return an error")
+			},
+			wantDiffs: []string{"subdir/file"},
+		},
+		"Entries_of_different_types": {
+			modify: func(t *testing.T, dir string) {
+				t.Helper()
+				require.NoError(t, os.Remove(filepath.Join(dir, "file")), "Setup: Remove should not return an error")
+				require.NoError(t, os.Mkdir(filepath.Join(dir, "file"), 0o700), "Setup: Mkdir should not return an error")
+			},
+			wantDiffs: []string{"file"},
+		},
+		"Files_of_different_sizes": {modify: writeFile("longer file content", "file"), wantDiffs: []string{"file"}},
+		"Files_of_different_content_with_comparison": {
+			modify:         writeFile("FILE CONTENT", "file"),
+			compareContent: true,
+			wantDiffs:      []string{"file"},
+		},
+		"Symlinks_with_different_targets": {
+			modify: func(t *testing.T, dir string) {
+				t.Helper()
+				require.NoError(t, os.Remove(filepath.Join(dir, "symlink")), "Setup: Remove should not return an error")
+				require.NoError(t, os.Symlink("subdir/file", filepath.Join(dir, "symlink")), "Setup: Symlink should not return an error")
+			},
+			wantDiffs: []string{"symlink"},
+		},
+		"Entries_with_different_owners": {
+			modify: func(t *testing.T, dir string) {
+				t.Helper()
+				require.NoError(t, os.Lchown(filepath.Join(dir, "subdir", "file"), 4242, 4243), "Setup: Lchown should not return an error")
+			},
+			needsRoot: true,
+			wantDiffs: []string{"subdir/file"},
+		},
+		"Differences_are_sorted": {
+			modify: func(t *testing.T, dir string) {
+				t.Helper()
+				writeFile("z", "z")(t, dir)
+				writeFile("a", "a")(t, dir)
+				writeFile("longer file content", "file")(t, dir)
+			},
+			wantDiffs: []string{"a", "file", "z"},
+		},
+
+		"Error_when_a_tree_does_not_exist": {secondDoesNotExist: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.needsRoot && os.Geteuid() != 0 {
+				t.Skip("Changing the owner of files requires root")
+			}
+
+			tempDir := t.TempDir()
+			first := filepath.Join(tempDir, "first")
+			second := filepath.Join(tempDir, "second")
+
+			err := os.MkdirAll(filepath.Join(first, "subdir"), 0o700)
+			require.NoError(t, err, "Setup: MkdirAll should not return an error")
+			writeFile("file content", "file")(t, first)
+			writeFile("nested content", "subdir", "file")(t, first)
+			err = os.Symlink("file", filepath.Join(first, "symlink"))
+			require.NoError(t, err, "Setup: Symlink should not return an error")
+
+			if !tc.secondDoesNotExist {
+				err = fileutils.CopyDir(first, second)
+				require.NoError(t, err, "Setup: CopyDir should not return an error")
+			}
+			if tc.modify != nil {
+				tc.modify(t, second)
+			}
+
+			equal, diffs, err := fileutils.DirsEqual(first, second, tc.compareContent)
+			if tc.wantError {
+				require.Error(t, err, "DirsEqual should return an error")
+				return
+			}
+			require.NoError(t, err, "DirsEqual should not return an error")
+			require.Equal(t, tc.wantDiffs, diffs, "DirsEqual should return the differing entries")
+			require.Equal(t, len(tc.wantDiffs) == 0, equal, "DirsEqual should only report equal trees if nothing differs")
+		})
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sourceDoesNotExist  bool
+		destExists          bool
+		destIsNotEmpty      bool
+		withDanglingSymlink bool
+		withSpecialFile     bool
+		restrictiveDirModes bool
+		preserveOwnership   bool
+
+		wantError bool
+	}{
+		"Copies_directory_tree":                 {},
+		"Preserves_ownership":                   {preserveOwnership: true},
+		"Copies_directory_tree_into_empty_dir":  {destExists: true},
+		"Copies_dangling_symlinks_as_is":        {withDanglingSymlink: true},
+		"Preserves_restrictive_directory_modes": {restrictiveDirModes: true},
+
+		"Error_when_source_does_not_exist":    {sourceDoesNotExist: true, wantError: true},
+		"Error_when_destination_is_not_empty": {destExists: true, destIsNotEmpty: true, wantError: true},
+		"Error_listing_skipped_special_files": {withSpecialFile: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.preserveOwnership && os.Geteuid() != 0 {
+				t.Skip("Changing the owner of files requires root")
+			}
+
+			tempDir := t.TempDir()
+			srcDir := filepath.Join(tempDir, "src")
+			destDir := filepath.Join(tempDir, "dest")
+
+			subDirMode := os.FileMode(0o750)
+			if tc.restrictiveDirModes {
+				subDirMode = 0o500
+			}
+
+			if !tc.sourceDoesNotExist {
+				err := os.MkdirAll(filepath.Join(srcDir, "subdir"), 0o700)
+				require.NoError(t, err, "MkdirAll should not return an error")
+				err = os.WriteFile(filepath.Join(srcDir, "file"), []byte("file content"), 0o640)
+				require.NoError(t, err, "WriteFile should not return an error")
+				err = os.WriteFile(filepath.Join(srcDir, "subdir", "file"), []byte("nested content"), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+				err = os.Symlink("file", filepath.Join(srcDir, "symlink"))
+				require.NoError(t, err, "Symlink should not return an error")
+			}
+
+			if tc.withDanglingSymlink {
+				err := os.Symlink("nonexistent_target", filepath.Join(srcDir, "dangling"))
+				require.NoError(t, err, "Symlink should not return an error")
+			}
+
+			if tc.withSpecialFile {
+				err := syscall.Mkfifo(filepath.Join(srcDir, "fifo"), 0o600)
+				require.NoError(t, err, "Mkfifo should not return an error")
+			}
+
+			if !tc.sourceDoesNotExist {
+				err := os.Chmod(filepath.Join(srcDir, "subdir"), subDirMode)
+				require.NoError(t, err, "Chmod should not return an error")
+			}
+
+			if tc.destExists {
+				err := os.Mkdir(destDir, 0o700)
+				require.NoError(t, err, "Mkdir should not return an error")
+			}
+
+			if tc.destIsNotEmpty {
+				err := fileutils.Touch(filepath.Join(destDir, "existing"))
+				require.NoError(t, err, "Touch should not return an error")
+			}
+
+			// The entries are given to other users, which must be kept in the copy.
+			owners := map[string][2]int{"file": {4242, 4243}, "subdir": {4244, 4245}, "symlink": {4246, 4247}}
+			if tc.preserveOwnership {
+				for name, owner := range owners {
+					err := os.Lchown(filepath.Join(srcDir, name), owner[0], owner[1])
+					require.NoError(t, err, "Setup: Lchown should not return an error")
+				}
+			}
+
+			var opts []fileutils.CopyDirOption
+			if tc.preserveOwnership {
+				opts = append(opts, fileutils.WithPreservedOwnership())
+			}
+			err := fileutils.CopyDir(srcDir, destDir, opts...)
+			if tc.wantError {
+				require.Error(t, err, "CopyDir should return an error")
+				if tc.withSpecialFile {
+					require.ErrorContains(t, err, filepath.Join(srcDir, "fifo"), "Error should list the skipped file")
+					exists, err := fileutils.FileExists(filepath.Join(destDir, "fifo"))
+					require.NoError(t, err, "FileExists should not return an error")
+					require.False(t, exists, "Special file should not be copied")
+				}
+				return
+			}
+			require.NoError(t, err, "CopyDir should not return an error")
+
+			content, err := os.ReadFile(filepath.Join(destDir, "file"))
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.Equal(t, "file content", string(content), "File contents does not match")
+
+			fileInfo, err := os.Stat(filepath.Join(destDir, "file"))
+			require.NoError(t, err, "Stat should not return an error")
+			require.Equal(t, os.FileMode(0o640), fileInfo.Mode(), "File mode does not match")
+
+			fileInfo, err = os.Stat(filepath.Join(destDir, "subdir"))
+			require.NoError(t, err, "Stat should not return an error")
+			require.Equal(t, subDirMode, fileInfo.Mode().Perm(), "Directory mode does not match")
+
+			content, err = os.ReadFile(filepath.Join(destDir, "subdir", "file"))
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.Equal(t, "nested content", string(content), "Nested file contents does not match")
+
+			target, err := os.Readlink(filepath.Join(destDir, "symlink"))
+			require.NoError(t, err, "Readlink should not return an error")
+			require.Equal(t, "file", target, "Symlink target does not match")
+
+			if tc.preserveOwnership {
+				for name, owner := range owners {
+					fileInfo, err := os.Lstat(filepath.Join(destDir, name))
+					require.NoError(t, err, "Lstat should not return an error")
+					stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+					require.True(t, ok, "Lstat should return a raw stat")
+					require.Equal(t, owner, [2]int{int(stat.Uid), int(stat.Gid)}, "Ownership of %q does not match", name)
+				}
+				fileInfo, err := os.Lstat(filepath.Join(destDir, "subdir", "file"))
+				require.NoError(t, err, "Lstat should not return an error")
+				stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+				require.True(t, ok, "Lstat should return a raw stat")
+				require.Equal(t, [2]int{os.Getuid(), os.Getgid()}, [2]int{int(stat.Uid), int(stat.Gid)}, "Ownership of nested file does not match")
+			}
+
+			if tc.withDanglingSymlink {
+				target, err := os.Readlink(filepath.Join(destDir, "dangling"))
+				require.NoError(t, err, "Readlink should not return an error")
+				require.Equal(t, "nonexistent_target", target, "Dangling symlink target does not match")
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fileExists         bool
+		fileIsDir          bool
+		parentDoesNotExist bool
+		fileMode           os.FileMode
+
+		wantError bool
+	}{
+		"Creates_file_when_it_does_not_exist":    {},
+		"Replaces_file_when_it_already_exists":   {fileExists: true},
+		"Sets_the_file_mode":                     {fileMode: 0o644},
+		"Sets_the_file_mode_on_an_existing_file": {fileExists: true, fileMode: 0o400},
+
+		"Error_when_file_is_a_directory":             {fileIsDir: true, wantError: true},
+		"Error_when_parent_directory_does_not_exist": {parentDoesNotExist: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "file")
+
+			if tc.fileExists {
+				err := os.WriteFile(path, []byte("old content"), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+			}
+
+			if tc.fileIsDir {
+				err := os.Mkdir(path, 0o700)
+				require.NoError(t, err, "Mkdir should not return an error")
+				err = fileutils.Touch(filepath.Join(path, "file"))
+				require.NoError(t, err, "Touch should not return an error")
+			}
+
+			if tc.parentDoesNotExist {
+				path = filepath.Join(tempDir, "dir", "file")
+			}
+
+			if tc.fileMode == 0 {
+				tc.fileMode = 0o600
+			}
+
+			wantContent := uuid.NewString()
+			err := fileutils.WriteFileAtomic(path, []byte(wantContent), tc.fileMode)
+			if tc.wantError {
+				require.Error(t, err, "WriteFileAtomic should return an error")
+
+				// No temporary file should be left behind.
+				entries, err := os.ReadDir(tempDir)
+				require.NoError(t, err, "ReadDir should not return an error")
+				for _, e := range entries {
+					require.False(t, strings.Contains(e.Name(), ".tmp"), "Temporary file %q should be removed", e.Name())
+				}
+				return
+			}
+			require.NoError(t, err, "WriteFileAtomic should not return an error")
+
+			fileInfo, err := os.Stat(path)
+			require.NoError(t, err, "Stat should not return an error")
+			require.Equal(t, tc.fileMode, fileInfo.Mode(), "File mode does not match")
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.Equal(t, wantContent, string(content), "File contents does not match")
+
+			entries, err := os.ReadDir(tempDir)
+			require.NoError(t, err, "ReadDir should not return an error")
+			require.Len(t, entries, 1, "Only the target file should exist in the directory")
+		})
+	}
+}
+
+func TestCreateTempInDir(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dirIsSymlink         bool
+		dirIsDanglingSymlink bool
+		dirDoesNotExist      bool
+
+		wantError error
+	}{
+		"Creates_temporary_file_in_the_directory":           {},
+		"Creates_temporary_file_in_the_symlinked_directory": {dirIsSymlink: true},
+
+		"Error_when_directory_does_not_exist":      {dirDoesNotExist: true, wantError: os.ErrNotExist},
+		"Error_when_directory_is_dangling_symlink": {dirIsDanglingSymlink: true, wantError: fileutils.SymlinkResolutionError{}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			targetDir := filepath.Join(tempDir, "target")
+			dir := targetDir
+
+			if !tc.dirDoesNotExist && !tc.dirIsDanglingSymlink {
+				err := os.Mkdir(targetDir, 0o700)
+				require.NoError(t, err, "Setup: Mkdir should not return an error")
+			}
+			if tc.dirIsSymlink || tc.dirIsDanglingSymlink {
+				dir = filepath.Join(tempDir, "link")
+				err := os.Symlink(targetDir, dir)
+				require.NoError(t, err, "Setup: Symlink should not return an error")
+			}
+
+			f, cleanup, err := fileutils.CreateTempInDir(dir, "file.tmp*")
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError, "CreateTempInDir should return the expected error")
+				return
+			}
+			require.NoError(t, err, "CreateTempInDir should not return an error")
+
+			require.Equal(t, targetDir, filepath.Dir(f.Name()), "Temporary file should be created in the resolved directory")
+			require.True(t, strings.HasPrefix(filepath.Base(f.Name()), "file.tmp"), "Temporary file name should match the pattern")
+
+			fileInfo, err := os.Stat(f.Name())
+			require.NoError(t, err, "Stat should not return an error")
+			require.Equal(t, os.FileMode(0o600), fileInfo.Mode(), "Temporary file should only be accessible by the owner")
+
+			cleanup()
+
+			exists, err := fileutils.FileExists(f.Name())
+			require.NoError(t, err, "FileExists should not return an error")
+			require.False(t, exists, "Temporary file should be removed by the cleanup function")
+
+			_, err = f.Write([]byte("content"))
+			require.ErrorIs(t, err, os.ErrClosed, "Temporary file should be closed by the cleanup function")
+		})
+	}
+}
+
+func TestSafeRemoveAll(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		path             string
+		symlinkTarget    string
+		pathDoesNotExist bool
+
+		wantError bool
+	}{
+		"Removes_directory_tree":                    {},
+		"Removes_symlink_to_a_safe_directory":       {symlinkTarget: "target"},
+		"Does_not_return_error_when_path_is_absent": {pathDoesNotExist: true},
+
+		"Error_when_path_is_empty":                            {path: "-", wantError: true},
+		"Error_when_path_is_the_root":                         {path: "/", wantError: true},
+		"Error_when_path_is_the_root_after_cleaning":          {path: "/home/..//./", wantError: true},
+		"Error_when_path_is_a_top_level_directory":            {path: "/home", wantError: true},
+		"Error_when_top_level_directory_has_a_trailing_slash": {path: "/etc/", wantError: true},
+		"Error_when_path_is_a_symlink_to_the_root":            {symlinkTarget: "/", wantError: true},
+		"Error_when_path_is_a_symlink_to_a_top_level_dir":     {symlinkTarget: "/usr", wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			path := filepath.Join(tempDir, "dir")
+
+			switch {
+			case tc.path == "-":
+				path = ""
+			case tc.path != "":
+				path = tc.path
+			case tc.symlinkTarget != "":
+				target := tc.symlinkTarget
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(tempDir, target)
+					err := os.Mkdir(target, 0o700)
+					require.NoError(t, err, "Mkdir should not return an error")
+				}
+				err := os.Symlink(target, path)
+				require.NoError(t, err, "Symlink should not return an error")
+			case !tc.pathDoesNotExist:
+				err := os.MkdirAll(filepath.Join(path, "subdir"), 0o700)
+				require.NoError(t, err, "MkdirAll should not return an error")
+				err = fileutils.Touch(filepath.Join(path, "subdir", "file"))
+				require.NoError(t, err, "Touch should not return an error")
+			}
+
+			err := fileutils.SafeRemoveAll(path)
+			if tc.wantError {
+				require.Error(t, err, "SafeRemoveAll should return an error")
+				return
+			}
+			require.NoError(t, err, "SafeRemoveAll should not return an error")
+
+			_, err = os.Lstat(path)
+			require.ErrorIs(t, err, os.ErrNotExist, "Path should have been removed")
+		})
+	}
+}
+
+func TestRemoveDir(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		path             string
+		pathDoesNotExist bool
+		pathIsFile       bool
+		otherOwner       bool
+
+		wantOwnerError bool
+		wantError      bool
+	}{
+		"Removes_directory_tree_owned_by_expected_user": {},
+		"Does_not_return_error_when_path_is_absent":     {pathDoesNotExist: true},
+
+		"Error_when_path_is_empty":                 {path: "-", wantError: true},
+		"Error_when_path_is_a_top_level_directory": {path: "/home", wantError: true},
+		"Error_when_path_is_not_a_directory":       {pathIsFile: true, wantError: true},
+		"Error_when_directory_has_another_owner":   {otherOwner: true, wantOwnerError: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "dir")
+			switch {
+			case tc.path == "-":
+				path = ""
+			case tc.path != "":
+				path = tc.path
+			case tc.pathIsFile:
+				err := fileutils.Touch(path)
+				require.NoError(t, err, "Setup: Touch should not return an error")
+			case !tc.pathDoesNotExist:
+				err := os.MkdirAll(filepath.Join(path, "subdir"), 0o700)
+				require.NoError(t, err, "Setup: MkdirAll should not return an error")
+				err = fileutils.Touch(filepath.Join(path, "subdir", "file"))
+				require.NoError(t, err, "Setup: Touch should not return an error")
+			}
+
+			// The directories created by the test are owned by the current user.
+			expectUID := uint32(os.Getuid())
+			if tc.path == "/home" {
+				fileInfo, err := os.Stat(tc.path)
+				require.NoError(t, err, "Setup: Stat should not return an error")
+				expectUID = fileInfo.Sys().(*syscall.Stat_t).Uid
+			}
+			if tc.otherOwner {
+				expectUID++
+			}
+
+			err := fileutils.RemoveDir(path, expectUID)
+			if tc.wantError {
+				require.Error(t, err, "RemoveDir should return an error")
+				require.Equal(t, tc.wantOwnerError, errors.As(err, &fileutils.UnexpectedOwnerError{}),
+					"RemoveDir should only return an UnexpectedOwnerError if the owner differs")
+				if path != "" {
+					_, err = os.Lstat(path)
+					require.NoError(t, err, "Path should not have been removed")
+				}
+				return
+			}
+			require.NoError(t, err, "RemoveDir should not return an error")
+
+			_, err = os.Lstat(path)
+			require.ErrorIs(t, err, os.ErrNotExist, "Path should have been removed")
+		})
+	}
+}
+
+func TestLrename(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sourceDoesNotExist     bool
+		destIsFile             bool
+		destIsSymlink          bool
+		destIsDanglingSymlink  bool
+		destIsDir              bool
+		destIsUnreadable       bool
+		destParentDoesNotExist bool
+		sourceIsSymlink        bool
+		sourceIsDangling       bool
+		resolveSource          bool
+
+		wantError         error
+		wantSymlinkMoved  bool
+		wantTargetRenamed bool
+	}{
+		"Successfully_rename_file_if_destination_does_not_exist": {},
+		"Successfully_rename_file_if_destination_is_a_file":      {destIsFile: true},
+		"Successfully_rename_file_if_destination_is_a_symlink":   {destIsSymlink: true},
+		"Successfully_rename_file_if_destination_is_unreadable":  {destIsFile: true, destIsUnreadable: true},
+		"Successfully_rename_symlink_if_source_is_a_symlink":     {sourceIsSymlink: true, wantSymlinkMoved: true},
+		"Successfully_rename_symlink_target_if_source_is_resolved": {
+			sourceIsSymlink: true, resolveSource: true, wantTargetRenamed: true,
+		},
+		"Successfully_rename_file_if_source_is_resolved_and_is_not_a_symlink": {resolveSource: true},
+
+		"Error_when_source_does_not_exist":                       {sourceDoesNotExist: true, wantError: errAny},
+		"Error_when_destination_is_a_directory":                  {destIsDir: true, wantError: errAny},
+		"Error_when_destination_parent_directory_does_not_exist": {destParentDoesNotExist: true, wantError: errAny},
+		"Error_when_destination_is_a_dangling_symlink":           {destIsDanglingSymlink: true, wantError: fileutils.SymlinkResolutionError{}},
+		"Error_unwrap_when_destination_is_a_dangling_symlink":    {destIsDanglingSymlink: true, wantError: os.ErrNotExist},
+		"Error_when_resolved_source_is_a_dangling_symlink": {
+			sourceIsSymlink: true, sourceIsDangling: true, resolveSource: true, wantError: fileutils.SymlinkResolutionError{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			srcPath := filepath.Join(tempDir, "source")
+			destPath := filepath.Join(tempDir, "dest")
+
+			sourceTarget := filepath.Join(tempDir, "source_target")
+			if tc.sourceIsSymlink {
+				if !tc.sourceIsDangling {
+					err := os.WriteFile(sourceTarget, []byte("test content"), 0o600)
+					require.NoError(t, err, "WriteFile should not return an error")
+				}
+				err := os.Symlink(sourceTarget, srcPath)
+				require.NoError(t, err, "Symlink should not return an error")
+			} else if !tc.sourceDoesNotExist {
+				err := os.WriteFile(srcPath, []byte("test content"), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+			}
+
+			if tc.destIsFile {
+				err := os.WriteFile(destPath, []byte("existing content"), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+			}
+
+			if tc.destIsSymlink {
+				symlinkTarget := filepath.Join(tempDir, "symlink_target")
+				err := os.WriteFile(symlinkTarget, []byte("symlink content"), 0o600)
+				require.NoError(t, err, "WriteFile should not return an error")
+
+				err = os.Symlink(symlinkTarget, destPath)
+				require.NoError(t, err, "Symlink should not return an error")
+			}
+
+			if tc.destIsDanglingSymlink {
+				err := os.Symlink("nonexistent_target", destPath)
+				require.NoError(t, err, "Symlink should not return an error")
+			}
+
+			if tc.destIsDir {
+				err := os.Mkdir(destPath, 0o700)
+				require.NoError(t, err, "Mkdir should not return an error")
+			}
+
+			if tc.destIsUnreadable {
+				err := os.Chmod(destPath, 0o000)
+				require.NoError(t, err, "Chmod should not return an error")
+				// Restore permissions after test
+				defer func() {
+					//nolint:gosec // G302 Permissions 0700 are not insecure for a directory
 					err := os.Chmod(destPath, 0700)
 					require.NoError(t, err, "Chmod should not return an error")
 				}()
@@ -335,7 +1894,12 @@ func TestLrename(t *testing.T) {
 				destPath = filepath.Join(tempDir, "nonexistent", "dest")
 			}
 
-			err := fileutils.Lrename(srcPath, destPath)
+			var opts []fileutils.RenameOption
+			if tc.resolveSource {
+				opts = append(opts, fileutils.WithResolvedSource())
+			}
+
+			err := fileutils.Lrename(srcPath, destPath, opts...)
 			if errors.Is(tc.wantError, errAny) {
 				require.Error(t, err, "Lrename should return an error")
 				return
@@ -355,6 +1919,119 @@ func TestLrename(t *testing.T) {
 			exists, err = fileutils.FileExists(destPath)
 			require.NoError(t, err, "FileExists should not return an error")
 			require.True(t, exists, "Destination file should exist")
+
+			if !tc.sourceIsSymlink {
+				return
+			}
+
+			fi, err := os.Lstat(destPath)
+			require.NoError(t, err, "Lstat should not return an error")
+			require.Equal(t, tc.wantSymlinkMoved, fi.Mode()&os.ModeSymlink != 0, "Destination should be a symlink only if the symlink was renamed")
+
+			if tc.wantTargetRenamed {
+				// The symlink is left in place, dangling.
+				exists, err = fileutils.Lexists(srcPath)
+				require.NoError(t, err, "Lexists should not return an error")
+				require.True(t, exists, "Source symlink should be left in place")
+
+				exists, err = fileutils.FileExists(sourceTarget)
+				require.NoError(t, err, "FileExists should not return an error")
+				require.False(t, exists, "Symlink target should no longer exist")
+			}
+		})
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	t.Parallel()
+
+	exdevRename := func(string, string) error {
+		return &os.LinkError{Op: "rename", Err: syscall.EXDEV}
+	}
+
+	tests := map[string]struct {
+		crossDevice        bool
+		fileMode           os.FileMode
+		destExists         bool
+		sourceIsDir        bool
+		parentDoesNotExist bool
+		renameErr          error
+
+		wantError   bool
+		wantSrcKept bool
+	}{
+		"Successfully_move_file_on_the_same_filesystem":                 {},
+		"Successfully_move_file_across_filesystems":                     {crossDevice: true},
+		"Successfully_move_file_across_filesystems_preserving_the_mode": {crossDevice: true, fileMode: 0o751},
+		"Successfully_move_file_across_filesystems_over_existing_file":  {crossDevice: true, destExists: true, fileMode: 0o640},
+
+		"Error_when_rename_fails_with_other_error":                             {renameErr: syscall.EACCES, wantError: true, wantSrcKept: true},
+		"Error_and_keep_source_when_source_is_a_directory_across_filesystems":  {crossDevice: true, sourceIsDir: true, wantError: true, wantSrcKept: true},
+		"Error_and_keep_source_when_destination_parent_does_not_exist_on_copy": {crossDevice: true, parentDoesNotExist: true, wantError: true, wantSrcKept: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			srcPath := filepath.Join(tempDir, "source")
+			destPath := filepath.Join(tempDir, "dest")
+
+			if tc.fileMode == 0 {
+				tc.fileMode = 0o600
+			}
+
+			wantContent := uuid.NewString()
+			if tc.sourceIsDir {
+				err := os.Mkdir(srcPath, 0o700)
+				require.NoError(t, err, "Setup: Mkdir should not return an error")
+			} else {
+				err := os.WriteFile(srcPath, []byte(wantContent), 0o600)
+				require.NoError(t, err, "Setup: WriteFile should not return an error")
+				err = os.Chmod(srcPath, tc.fileMode)
+				require.NoError(t, err, "Setup: Chmod should not return an error")
+			}
+
+			if tc.destExists {
+				err := os.WriteFile(destPath, []byte("existing content"), 0o600)
+				require.NoError(t, err, "Setup: WriteFile should not return an error")
+			}
+
+			if tc.parentDoesNotExist {
+				destPath = filepath.Join(tempDir, "nonexistent", "dest")
+			}
+
+			rename := func(oldPath, newPath string) error { return fileutils.Lrename(oldPath, newPath) }
+			if tc.crossDevice {
+				rename = exdevRename
+			}
+			if tc.renameErr != nil {
+				rename = func(string, string) error { return tc.renameErr }
+			}
+
+			err := fileutils.MoveFileWithRename(srcPath, destPath, rename)
+			if tc.wantError {
+				require.Error(t, err, "MoveFile should return an error")
+
+				exists, err := fileutils.Lexists(srcPath)
+				require.NoError(t, err, "Lexists should not return an error")
+				require.Equal(t, tc.wantSrcKept, exists, "Source should be kept on error")
+				return
+			}
+			require.NoError(t, err, "MoveFile should not return an error")
+
+			exists, err := fileutils.Lexists(srcPath)
+			require.NoError(t, err, "Lexists should not return an error")
+			require.False(t, exists, "Source file should no longer exist")
+
+			fileInfo, err := os.Stat(destPath)
+			require.NoError(t, err, "Stat should not return an error")
+			require.Equal(t, tc.fileMode, fileInfo.Mode(), "Destination file mode should match the source")
+
+			content, err := os.ReadFile(destPath)
+			require.NoError(t, err, "ReadFile should not return an error")
+			require.Equal(t, wantContent, string(content), "Destination file content should match the source")
 		})
 	}
 }
@@ -379,26 +2056,333 @@ func TestLockDir(t *testing.T) {
 	}()
 	select {
 	case <-unlockCh:
-		require.Fail(t, "LockDir should block when trying to lock an already locked directory")
+		require.Fail(t, "LockDir should block when trying to lock an already locked directory")
+	case <-time.After(testutils.MultipliedSleepDuration(100 * time.Millisecond)):
+		// Expected behavior, LockDir is blocking
+	}
+
+	// Unlock the first lock
+	t.Log("Releasing first lock")
+	err = unlock()
+	require.NoError(t, err, "Unlock should not return an error")
+
+	// Now we should be able to acquire the lock again
+	select {
+	case unlock = <-unlockCh:
+		// Expected behavior, LockDir returned after the first lock was released
+		t.Log("Releasing lock")
+		err = unlock()
+		require.NoError(t, err, "Unlock should not return an error")
+	case <-time.After(testutils.MultipliedSleepDuration(5 * time.Second)):
+		require.Fail(t, "LockDir should have returned after the first lock was released")
+	}
+}
+
+func TestLockDirContext(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		alreadyLocked   bool
+		cancelContext   bool
+		dirDoesNotExist bool
+
+		wantError error
+	}{
+		"Successfully_lock_directory": {},
+
+		"Error_when_deadline_is_exceeded":     {alreadyLocked: true, wantError: context.DeadlineExceeded},
+		"Error_when_context_is_cancelled":     {alreadyLocked: true, cancelContext: true, wantError: context.Canceled},
+		"Error_when_directory_does_not_exist": {dirDoesNotExist: true, wantError: os.ErrNotExist},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if tc.dirDoesNotExist {
+				dir = filepath.Join(dir, "nonexistent")
+			}
+
+			if tc.alreadyLocked {
+				unlock, err := fileutils.LockDir(dir)
+				require.NoError(t, err, "LockDir should not return an error")
+				t.Cleanup(func() {
+					err := unlock()
+					require.NoError(t, err, "Unlock should not return an error")
+				})
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), testutils.MultipliedSleepDuration(200*time.Millisecond))
+			defer cancel()
+			if tc.cancelContext {
+				cancel()
+			}
+
+			unlock, err := fileutils.LockDirContext(ctx, dir)
+			if tc.wantError != nil {
+				require.ErrorIs(t, err, tc.wantError, "LockDirContext should return the expected error")
+				return
+			}
+			require.NoError(t, err, "LockDirContext should not return an error")
+
+			err = unlock()
+			require.NoError(t, err, "Unlock should not return an error")
+		})
+	}
+}
+
+func TestLockDirNamed(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name         string
+		lockedByName string
+
+		wantBlocked bool
+		wantError   bool
+	}{
+		"Acquires_the_lock_when_it_is_not_held":                        {name: "provisioning.lock"},
+		"Acquires_the_lock_when_the_default_lock_is_held":              {name: "provisioning.lock", lockedByName: ".lock"},
+		"Acquires_the_lock_when_a_lock_with_another_name_is_held":      {name: "provisioning.lock", lockedByName: "migration.lock"},
+		"Blocks_when_the_lock_with_the_same_name_is_held":              {name: "provisioning.lock", lockedByName: "provisioning.lock", wantBlocked: true},
+		"Blocks_when_the_default_lock_is_held_and_the_name_is_default": {name: ".lock", lockedByName: ".lock", wantBlocked: true},
+
+		"Error_when_name_is_empty":                {name: "", wantError: true},
+		"Error_when_name_contains_path_separator": {name: "../escape.lock", wantError: true},
+		"Error_when_name_is_dot_dot":              {name: "..", wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			unlockHeld := func() error { return nil }
+			if tc.lockedByName != "" {
+				var err error
+				unlockHeld, err = fileutils.LockDirNamed(dir, tc.lockedByName)
+				require.NoError(t, err, "Setup: LockDirNamed should not return an error")
+			}
+
+			type result struct {
+				unlock func() error
+				err    error
+			}
+			resultCh := make(chan result, 1)
+			go func() {
+				unlock, err := fileutils.LockDirNamed(dir, tc.name)
+				resultCh <- result{unlock, err}
+			}()
+
+			select {
+			case res := <-resultCh:
+				require.False(t, tc.wantBlocked, "LockDirNamed should block when the lock is held")
+				if tc.wantError {
+					require.Error(t, res.err, "LockDirNamed should return an error")
+					return
+				}
+				require.NoError(t, res.err, "LockDirNamed should not return an error")
+				require.FileExists(t, filepath.Join(dir, tc.name), "Lock file should be created in the directory")
+
+				err := res.unlock()
+				require.NoError(t, err, "Unlock should not return an error")
+				err = unlockHeld()
+				require.NoError(t, err, "Unlock should not return an error")
+			case <-time.After(testutils.MultipliedSleepDuration(100 * time.Millisecond)):
+				require.True(t, tc.wantBlocked, "LockDirNamed should not block when the lock is not held")
+
+				err := unlockHeld()
+				require.NoError(t, err, "Unlock should not return an error")
+
+				res := <-resultCh
+				require.NoError(t, res.err, "LockDirNamed should not return an error once the lock is released")
+				err = res.unlock()
+				require.NoError(t, err, "Unlock should not return an error")
+			}
+		})
+	}
+}
+
+func TestTryLockDir(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		alreadyLocked   bool
+		dirDoesNotExist bool
+
+		wantAcquired bool
+		wantError    bool
+	}{
+		"Acquires_the_lock_when_it_is_not_held":     {wantAcquired: true},
+		"Does_not_acquire_the_lock_when_it_is_held": {alreadyLocked: true, wantAcquired: false},
+
+		"Error_when_directory_does_not_exist": {dirDoesNotExist: true, wantError: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if tc.dirDoesNotExist {
+				dir = filepath.Join(dir, "nonexistent")
+			}
+
+			if tc.alreadyLocked {
+				unlock, err := fileutils.LockDir(dir)
+				require.NoError(t, err, "LockDir should not return an error")
+				t.Cleanup(func() {
+					err := unlock()
+					require.NoError(t, err, "Unlock should not return an error")
+				})
+			}
+
+			unlock, acquired, err := fileutils.TryLockDir(dir)
+			if tc.wantError {
+				require.Error(t, err, "TryLockDir should return an error")
+				require.False(t, acquired, "TryLockDir should not acquire the lock on error")
+				return
+			}
+			require.NoError(t, err, "TryLockDir should not return an error")
+			require.Equal(t, tc.wantAcquired, acquired, "TryLockDir should return the expected result")
+			if !acquired {
+				return
+			}
+
+			// The lock is now held, so a second attempt should not acquire it.
+			_, acquiredAgain, err := fileutils.TryLockDir(dir)
+			require.NoError(t, err, "TryLockDir should not return an error")
+			require.False(t, acquiredAgain, "TryLockDir should not acquire a held lock")
+
+			err = unlock()
+			require.NoError(t, err, "Unlock should not return an error")
+		})
+	}
+}
+
+func TestRLockDir(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	// Multiple shared locks can be held at the same time.
+	unlock1, err := fileutils.RLockDir(tempDir)
+	require.NoError(t, err, "RLockDir should not return an error")
+	unlock2, err := fileutils.RLockDir(tempDir)
+	require.NoError(t, err, "Second RLockDir should not block nor return an error")
+
+	// An exclusive lock can't be acquired while a shared lock is held.
+	_, acquired, err := fileutils.TryLockDir(tempDir)
+	require.NoError(t, err, "TryLockDir should not return an error")
+	require.False(t, acquired, "TryLockDir should not acquire the lock while shared locks are held")
+
+	err = unlock1()
+	require.NoError(t, err, "Unlock should not return an error")
+	err = unlock2()
+	require.NoError(t, err, "Unlock should not return an error")
+
+	// A shared lock can't be acquired while an exclusive lock is held.
+	unlock, err := fileutils.LockDir(tempDir)
+	require.NoError(t, err, "LockDir should not return an error")
+
+	unlockCh := make(chan func() error, 1)
+	go func() {
+		unlock, err := fileutils.RLockDir(tempDir)
+		t.Logf("RLockDir returned with error: %v", err)
+		unlockCh <- unlock
+	}()
+	select {
+	case <-unlockCh:
+		require.Fail(t, "RLockDir should block while an exclusive lock is held")
 	case <-time.After(testutils.MultipliedSleepDuration(100 * time.Millisecond)):
-		// Expected behavior, LockDir is blocking
+		// Expected behavior, RLockDir is blocking
 	}
 
-	// Unlock the first lock
-	t.Log("Releasing first lock")
 	err = unlock()
 	require.NoError(t, err, "Unlock should not return an error")
 
-	// Now we should be able to acquire the lock again
 	select {
 	case unlock = <-unlockCh:
-		// Expected behavior, LockDir returned after the first lock was released
-		t.Log("Releasing lock")
 		err = unlock()
 		require.NoError(t, err, "Unlock should not return an error")
 	case <-time.After(testutils.MultipliedSleepDuration(5 * time.Second)):
-		require.Fail(t, "LockDir should have returned after the first lock was released")
+		require.Fail(t, "RLockDir should have returned after the exclusive lock was released")
+	}
+}
+
+func TestCanChown(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		missingRoot  bool
+		dropCapChown bool
+
+		wantError bool
+	}{
+		"Successfully_check_if_ownership_can_be_changed": {},
+		"Successfully_check_without_effective_CAP_CHOWN": {dropCapChown: true},
+
+		"Error_if_root_does_not_exist": {missingRoot: true, wantError: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+			if tc.missingRoot {
+				root = filepath.Join(root, "does-not-exist")
+			}
+			if tc.dropCapChown {
+				if !hasEffectiveCapChown(t) {
+					t.Skip("CAP_CHOWN is not effective, so it can't be dropped")
+				}
+				// Capabilities are per thread, so CAP_CHOWN is only dropped from the effective set of the thread of
+				// this test. The thread is not unlocked, so that it exits with the test instead of being reused.
+				runtime.LockOSThread()
+				hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+				var data [2]unix.CapUserData
+				require.NoError(t, unix.Capget(&hdr, &data[0]), "Setup: could not get capabilities")
+				data[0].Effective &^= 1 << unix.CAP_CHOWN
+				require.NoError(t, unix.Capset(&hdr, &data[0]), "Setup: could not drop CAP_CHOWN")
+			}
+
+			got, err := fileutils.CanChown(root)
+			if tc.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.dropCapChown {
+				require.False(t, got, "CanChown should return false without CAP_CHOWN, even as root")
+				return
+			}
+			require.Equal(t, hasEffectiveCapChown(t), got, "CanChown should return whether CAP_CHOWN is effective")
+		})
+	}
+}
+
+// hasEffectiveCapChown returns whether CAP_CHOWN is in the effective capability set of the current thread, according
+// to /proc/thread-self/status.
+func hasEffectiveCapChown(t *testing.T) bool {
+	t.Helper()
+
+	status, err := os.ReadFile("/proc/thread-self/status")
+	require.NoError(t, err, "Setup: could not read /proc/thread-self/status")
+
+	for _, line := range strings.Split(string(status), "\n") {
+		capEff, ok := strings.CutPrefix(line, "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(capEff), 16, 64)
+		require.NoError(t, err, "Setup: could not parse effective capabilities")
+		return caps&(1<<unix.CAP_CHOWN) != 0
 	}
+
+	require.Fail(t, "Setup: no effective capabilities in /proc/thread-self/status")
+	return false
 }
 
 func TestChownRecursiveFrom(t *testing.T) {
@@ -408,6 +2392,8 @@ func TestChownRecursiveFrom(t *testing.T) {
 		uidArgs            *fileutils.ChownUIDArgs
 		gidArgs            *fileutils.ChownGIDArgs
 		readOnlyFilesystem bool
+		otherDeviceDir     bool
+		workers            int
 		fileUID            uint32
 		fileGID            uint32
 		dirUID             uint32
@@ -477,6 +2463,19 @@ func TestChownRecursiveFrom(t *testing.T) {
 			gidArgs: &fileutils.ChownGIDArgs{FromGID: 1, ToGID: 0},
 		},
 
+		"Successfully_change_owner_and_group_in_parallel": {
+			uidArgs: &fileutils.ChownUIDArgs{FromUID: 0, ToUID: 1},
+			gidArgs: &fileutils.ChownGIDArgs{FromGID: 0, ToGID: 1},
+			workers: 4,
+		},
+		"Change_only_the_file_owner_and_group_in_parallel": {
+			uidArgs: &fileutils.ChownUIDArgs{FromUID: 2, ToUID: 1},
+			gidArgs: &fileutils.ChownGIDArgs{FromGID: 2, ToGID: 1},
+			fileUID: 2,
+			fileGID: 2,
+			workers: 4,
+		},
+
 		"Error_if_UID_args_and_GID_args_are_both_nil": {
 			wantError: true, wantErrorMatch: "at least one of uidArgs or gidArgs must be non-nil",
 		},
@@ -484,6 +2483,22 @@ func TestChownRecursiveFrom(t *testing.T) {
 			uidArgs:            &fileutils.ChownUIDArgs{FromUID: 0, ToUID: 1},
 			readOnlyFilesystem: true, wantError: true, wantErrorMatch: "read-only file system",
 		},
+		"Error_when_filesystem_is_read_only_in_parallel": {
+			uidArgs:            &fileutils.ChownUIDArgs{FromUID: 0, ToUID: 1},
+			readOnlyFilesystem: true, workers: 4, wantError: true, wantErrorMatch: "read-only file system",
+		},
+		"Error_and_skip_entries_on_other_devices": {
+			uidArgs:        &fileutils.ChownUIDArgs{FromUID: 0, ToUID: 1},
+			otherDeviceDir: true, wantError: true, wantErrorMatch: "skipped entries on other devices",
+		},
+		"Error_and_skip_entries_on_other_devices_in_parallel": {
+			uidArgs:        &fileutils.ChownUIDArgs{FromUID: 0, ToUID: 1},
+			otherDeviceDir: true, workers: 4, wantError: true, wantErrorMatch: "skipped entries on other devices",
+		},
+		"Error_when_number_of_workers_is_not_positive": {
+			uidArgs: &fileutils.ChownUIDArgs{FromUID: 0, ToUID: 1},
+			workers: -1, wantError: true, wantErrorMatch: "number of workers must be positive",
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -530,11 +2545,54 @@ func TestChownRecursiveFrom(t *testing.T) {
 				}()
 			}
 
-			err = fileutils.ChownRecursiveFrom(targetDir, tc.uidArgs, tc.gidArgs)
+			otherDeviceDir := filepath.Join(targetDir, "mnt")
+			if tc.otherDeviceDir {
+				err := os.Mkdir(otherDeviceDir, 0o700)
+				require.NoError(t, err)
+				//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+				cmd := exec.Command("mount", "-t", "tmpfs", "tmpfs", otherDeviceDir)
+				cmd.Stderr = os.Stderr
+				err = cmd.Run()
+				require.NoError(t, err)
+				defer func() {
+					//nolint:gosec // G204 it's safe to use exec.Command with a variable here
+					cmd := exec.Command("umount", otherDeviceDir)
+					cmd.Stderr = os.Stderr
+					_ = cmd.Run()
+				}()
+				err = fileutils.Touch(filepath.Join(otherDeviceDir, "file"))
+				require.NoError(t, err)
+			}
+
+			if tc.workers != 0 {
+				err = fileutils.ChownRecursiveFromParallel(targetDir, tc.uidArgs, tc.gidArgs, tc.workers)
+			} else {
+				err = fileutils.ChownRecursiveFrom(targetDir, tc.uidArgs, tc.gidArgs)
+			}
 			t.Logf("ChownRecursiveFrom error: %v", err)
 			if tc.wantError {
 				require.Error(t, err)
 				require.Contains(t, err.Error(), tc.wantErrorMatch)
+			}
+			if tc.otherDeviceDir {
+				var skippedErr fileutils.SkippedEntriesError
+				require.ErrorAs(t, err, &skippedErr, "Skipped entries should be returned as a SkippedEntriesError")
+				require.Contains(t, skippedErr.Paths, otherDeviceDir, "The mount point should be listed as skipped")
+
+				// The entries on the other device should not be changed, but the other ones should.
+				for _, f := range []string{otherDeviceDir, filepath.Join(otherDeviceDir, "file"), filePath} {
+					fileInfo, err := os.Lstat(f)
+					require.NoError(t, err)
+					stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+					require.True(t, ok, "File should have a syscall.Stat_t")
+					wantUID := uint32(0)
+					if f == filePath {
+						wantUID = 1
+					}
+					require.Equal(t, wantUID, stat.Uid, "Unexpected UID of %q", f)
+				}
+			}
+			if tc.wantError {
 				return
 			}
 			require.NoError(t, err)
@@ -562,3 +2620,299 @@ func TestChownRecursiveFrom(t *testing.T) {
 		})
 	}
 }
+
+func TestChownRecursiveFromDryRun(t *testing.T) {
+	t.Parallel()
+
+	//nolint:gosec // G115 UIDs and GIDs are never negative
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+
+	tests := map[string]struct {
+		uidArgs *fileutils.ChownUIDArgs
+		gidArgs *fileutils.ChownGIDArgs
+
+		wantPaths []string
+		wantError bool
+	}{
+		"Returns_all_paths_when_UID_matches": {
+			uidArgs:   &fileutils.ChownUIDArgs{FromUID: uid, ToUID: uid + 1},
+			wantPaths: []string{"dir", "dir/subdir", "dir/subdir/file", "dir/symlink"},
+		},
+		"Returns_all_paths_when_GID_matches": {
+			gidArgs:   &fileutils.ChownGIDArgs{FromGID: gid, ToGID: gid + 1},
+			wantPaths: []string{"dir", "dir/subdir", "dir/subdir/file", "dir/symlink"},
+		},
+		"Returns_no_paths_when_neither_UID_nor_GID_match": {
+			uidArgs: &fileutils.ChownUIDArgs{FromUID: uid + 1, ToUID: uid},
+			gidArgs: &fileutils.ChownGIDArgs{FromGID: gid + 1, ToGID: gid},
+		},
+
+		"Error_if_UID_args_and_GID_args_are_both_nil": {wantError: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			targetDir := filepath.Join(tempDir, "dir")
+			subDir := filepath.Join(targetDir, "subdir")
+			err := os.MkdirAll(subDir, 0o700)
+			require.NoError(t, err)
+			err = fileutils.Touch(filepath.Join(subDir, "file"))
+			require.NoError(t, err)
+			err = os.Symlink(filepath.Join(tempDir, "symlink_target"), filepath.Join(targetDir, "symlink"))
+			require.NoError(t, err)
+
+			var progressPaths []string
+			progress := func(path string, changed bool) {
+				if changed {
+					progressPaths = append(progressPaths, path)
+				}
+			}
+
+			paths, err := fileutils.ChownRecursiveFromDryRun(targetDir, tc.uidArgs, tc.gidArgs, fileutils.WithProgress(progress))
+			if tc.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, paths, progressPaths, "Progress should be reported for the changed paths")
+
+			var relPaths []string
+			for _, p := range paths {
+				relPath, err := filepath.Rel(tempDir, p)
+				require.NoError(t, err)
+				relPaths = append(relPaths, relPath)
+			}
+			require.Equal(t, tc.wantPaths, relPaths, "ChownRecursiveFromDryRun should return the expected paths")
+
+			// Nothing should have been changed.
+			for _, p := range []string{targetDir, subDir, filepath.Join(subDir, "file")} {
+				fileInfo, err := os.Lstat(p)
+				require.NoError(t, err)
+				stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+				require.True(t, ok, "File should have a syscall.Stat_t")
+				require.Equal(t, uid, stat.Uid, "UID of %q should not have changed", p)
+				require.Equal(t, gid, stat.Gid, "GID of %q should not have changed", p)
+			}
+		})
+	}
+}
+
+func TestChownRollback(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		removeFile       bool
+		incompleteRecord bool
+		invalidRecord    bool
+		noJournal        bool
+
+		wantError bool
+	}{
+		"Successfully_restore_ownership":                    {},
+		"Successfully_restore_ownership_of_remaining_files": {removeFile: true},
+		"Ignore_incomplete_last_record":                     {incompleteRecord: true},
+
+		"Error_if_journal_does_not_exist":     {noJournal: true, wantError: true},
+		"Error_if_journal_has_invalid_record": {invalidRecord: true, wantError: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if !testutils.RunningInBubblewrap() {
+				testutils.RunTestInBubbleWrap(t)
+				return
+			}
+
+			tempDir := testutils.TempDir(t)
+			targetDir := filepath.Join(tempDir, "dir")
+			subDir := filepath.Join(targetDir, "sub dir")
+			filePath := filepath.Join(subDir, "file")
+			otherFilePath := filepath.Join(subDir, "other file")
+			err := os.MkdirAll(subDir, 0o700)
+			require.NoError(t, err)
+			err = fileutils.Touch(filePath)
+			require.NoError(t, err)
+			err = fileutils.Touch(otherFilePath)
+			require.NoError(t, err)
+			err = os.Chown(otherFilePath, 2, 2)
+			require.NoError(t, err)
+
+			journalPath := filepath.Join(tempDir, "journal")
+			err = fileutils.ChownRecursiveFrom(targetDir,
+				&fileutils.ChownUIDArgs{FromUID: 0, ToUID: 1},
+				&fileutils.ChownGIDArgs{FromGID: 0, ToGID: 1},
+				fileutils.WithJournal(journalPath),
+			)
+			require.NoError(t, err, "Setup: ChownRecursiveFrom should not have returned an error")
+
+			if tc.removeFile {
+				err = os.Remove(filePath)
+				require.NoError(t, err)
+			}
+			if tc.incompleteRecord || tc.invalidRecord {
+				record := `3 3 "` + targetDir
+				if tc.invalidRecord {
+					record = "invalid\n"
+				}
+				f, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND, 0)
+				require.NoError(t, err)
+				_, err = f.WriteString(record)
+				require.NoError(t, err)
+				require.NoError(t, f.Close())
+			}
+			if tc.noJournal {
+				journalPath = filepath.Join(tempDir, "does-not-exist")
+			}
+
+			err = fileutils.ChownRollback(journalPath)
+			if tc.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			wantOwners := map[string]uint32{targetDir: 0, subDir: 0, filePath: 0, otherFilePath: 2}
+			if tc.removeFile {
+				delete(wantOwners, filePath)
+			}
+			for f, want := range wantOwners {
+				fileInfo, err := os.Lstat(f)
+				require.NoError(t, err)
+				stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+				require.True(t, ok, "File should have a syscall.Stat_t")
+				require.Equal(t, want, stat.Uid, "UID of %q should have been restored", f)
+				require.Equal(t, want, stat.Gid, "GID of %q should have been restored", f)
+			}
+		})
+	}
+}
+
+func TestChownRecursiveMap(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uidMap  map[uint32]uint32
+		gidMap  map[uint32]uint32
+		fileUID uint32
+		fileGID uint32
+
+		wantError bool
+	}{
+		"Successfully_change_owner": {uidMap: map[uint32]uint32{0: 1}},
+		"Successfully_change_group": {gidMap: map[uint32]uint32{0: 1}},
+		"Successfully_remap_several_UIDs_and_GIDs": {
+			uidMap:  map[uint32]uint32{0: 1, 2: 3},
+			gidMap:  map[uint32]uint32{0: 1, 2: 4},
+			fileUID: 2,
+			fileGID: 2,
+		},
+		"Unmapped_IDs_are_not_changed": {
+			uidMap:  map[uint32]uint32{2: 3},
+			gidMap:  map[uint32]uint32{2: 4},
+			fileUID: 2,
+		},
+		"Mappings_are_not_chained": {
+			uidMap: map[uint32]uint32{0: 1, 1: 2},
+			gidMap: map[uint32]uint32{0: 1, 1: 2},
+		},
+
+		"Error_if_UID_map_and_GID_map_are_both_empty": {gidMap: map[uint32]uint32{}, wantError: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if !testutils.RunningInBubblewrap() {
+				testutils.RunTestInBubbleWrap(t)
+				return
+			}
+
+			tempDir := testutils.TempDir(t)
+			targetDir := filepath.Join(tempDir, "dir")
+			subDir := filepath.Join(targetDir, "subdir")
+			filePath := filepath.Join(subDir, "file")
+			err := os.MkdirAll(subDir, 0o700)
+			require.NoError(t, err)
+			err = fileutils.Touch(filePath)
+			require.NoError(t, err)
+			err = os.Chown(filePath, int(tc.fileUID), int(tc.fileGID))
+			require.NoError(t, err)
+
+			err = fileutils.ChownRecursiveMap(targetDir, tc.uidMap, tc.gidMap)
+			if tc.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			// Check ownership
+			var s string
+			for _, f := range []string{targetDir, subDir, filePath} {
+				fileInfo, err := os.Stat(f)
+				require.NoError(t, err)
+				stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+				require.True(t, ok, "File should have a syscall.Stat_t")
+				relPath, err := filepath.Rel(tempDir, f)
+				require.NoError(t, err)
+				s += fmt.Sprintf("%s: %d:%d\n", relPath, stat.Uid, stat.Gid)
+			}
+			golden.CheckOrUpdate(t, s)
+		})
+	}
+}
+
+func TestChgrpRecursiveFrom(t *testing.T) {
+	t.Parallel()
+
+	//nolint:gosec // G115 UIDs and GIDs are never negative
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+
+	tests := map[string]struct {
+		fromGID     uint32
+		toGID       uint32
+		missingRoot bool
+
+		wantError bool
+	}{
+		"No_op_if_GIDs_are_equal":                     {fromGID: gid + 1, toGID: gid + 1},
+		"No_op_if_GIDs_are_equal_and_root_is_missing": {fromGID: gid + 1, toGID: gid + 1, missingRoot: true},
+		"Does_not_change_files_not_owned_by_from_GID": {fromGID: gid + 1, toGID: gid + 2},
+
+		"Error_if_root_does_not_exist": {fromGID: gid + 1, toGID: gid + 2, missingRoot: true, wantError: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			targetDir := filepath.Join(t.TempDir(), "dir")
+			if !tc.missingRoot {
+				err := os.MkdirAll(filepath.Join(targetDir, "subdir"), 0o700)
+				require.NoError(t, err)
+				err = fileutils.Touch(filepath.Join(targetDir, "subdir", "file"))
+				require.NoError(t, err)
+			}
+
+			err := fileutils.ChgrpRecursiveFrom(targetDir, tc.fromGID, tc.toGID)
+			if tc.wantError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if tc.missingRoot {
+				return
+			}
+			for _, p := range []string{targetDir, filepath.Join(targetDir, "subdir"), filepath.Join(targetDir, "subdir", "file")} {
+				fileInfo, err := os.Lstat(p)
+				require.NoError(t, err)
+				stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+				require.True(t, ok, "File should have a syscall.Stat_t")
+				require.Equal(t, uid, stat.Uid, "UID of %q should not have changed", p)
+				require.Equal(t, gid, stat.Gid, "GID of %q should not have changed", p)
+			}
+		})
+	}
+}
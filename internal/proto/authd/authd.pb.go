@@ -27,6 +27,7 @@ const (
 	SessionMode_UNDEFINED       SessionMode = 0
 	SessionMode_LOGIN           SessionMode = 1
 	SessionMode_CHANGE_PASSWORD SessionMode = 2
+	SessionMode_DIAGNOSTIC      SessionMode = 3
 )
 
 // Enum value maps for SessionMode.
@@ -35,11 +36,13 @@ var (
 		0: "UNDEFINED",
 		1: "LOGIN",
 		2: "CHANGE_PASSWORD",
+		3: "DIAGNOSTIC",
 	}
 	SessionMode_value = map[string]int32{
 		"UNDEFINED":       0,
 		"LOGIN":           1,
 		"CHANGE_PASSWORD": 2,
+		"DIAGNOSTIC":      3,
 	}
 )
 
@@ -1037,6 +1040,50 @@ func (x *LockUserRequest) GetName() string {
 	return ""
 }
 
+type LockUserResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WasLocked     bool                   `protobuf:"varint,1,opt,name=was_locked,json=wasLocked,proto3" json:"was_locked,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LockUserResponse) Reset() {
+	*x = LockUserResponse{}
+	mi := &file_authd_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LockUserResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LockUserResponse) ProtoMessage() {}
+
+func (x *LockUserResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LockUserResponse.ProtoReflect.Descriptor instead.
+func (*LockUserResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *LockUserResponse) GetWasLocked() bool {
+	if x != nil {
+		return x.WasLocked
+	}
+	return false
+}
+
 type UnlockUserRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
@@ -1046,7 +1093,7 @@ type UnlockUserRequest struct {
 
 func (x *UnlockUserRequest) Reset() {
 	*x = UnlockUserRequest{}
-	mi := &file_authd_proto_msgTypes[19]
+	mi := &file_authd_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1058,7 +1105,7 @@ func (x *UnlockUserRequest) String() string {
 func (*UnlockUserRequest) ProtoMessage() {}
 
 func (x *UnlockUserRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[19]
+	mi := &file_authd_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1071,7 +1118,7 @@ func (x *UnlockUserRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UnlockUserRequest.ProtoReflect.Descriptor instead.
 func (*UnlockUserRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{19}
+	return file_authd_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *UnlockUserRequest) GetName() string {
@@ -1081,6 +1128,50 @@ func (x *UnlockUserRequest) GetName() string {
 	return ""
 }
 
+type UnlockUserResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WasLocked     bool                   `protobuf:"varint,1,opt,name=was_locked,json=wasLocked,proto3" json:"was_locked,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnlockUserResponse) Reset() {
+	*x = UnlockUserResponse{}
+	mi := &file_authd_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnlockUserResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnlockUserResponse) ProtoMessage() {}
+
+func (x *UnlockUserResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnlockUserResponse.ProtoReflect.Descriptor instead.
+func (*UnlockUserResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *UnlockUserResponse) GetWasLocked() bool {
+	if x != nil {
+		return x.WasLocked
+	}
+	return false
+}
+
 type GetGroupByNameRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
@@ -1090,7 +1181,7 @@ type GetGroupByNameRequest struct {
 
 func (x *GetGroupByNameRequest) Reset() {
 	*x = GetGroupByNameRequest{}
-	mi := &file_authd_proto_msgTypes[20]
+	mi := &file_authd_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1102,7 +1193,7 @@ func (x *GetGroupByNameRequest) String() string {
 func (*GetGroupByNameRequest) ProtoMessage() {}
 
 func (x *GetGroupByNameRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[20]
+	mi := &file_authd_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1115,7 +1206,7 @@ func (x *GetGroupByNameRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetGroupByNameRequest.ProtoReflect.Descriptor instead.
 func (*GetGroupByNameRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{20}
+	return file_authd_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *GetGroupByNameRequest) GetName() string {
@@ -1134,19 +1225,433 @@ type GetGroupByIDRequest struct {
 
 func (x *GetGroupByIDRequest) Reset() {
 	*x = GetGroupByIDRequest{}
-	mi := &file_authd_proto_msgTypes[21]
+	mi := &file_authd_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetGroupByIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGroupByIDRequest) ProtoMessage() {}
+
+func (x *GetGroupByIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGroupByIDRequest.ProtoReflect.Descriptor instead.
+func (*GetGroupByIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *GetGroupByIDRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type SetUserIDRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Id    uint32                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	// The language to use for any warnings returned.
+	// Note: This is currently not implemented and warnings are always in English.
+	Lang          string `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetUserIDRequest) Reset() {
+	*x = SetUserIDRequest{}
+	mi := &file_authd_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetUserIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetUserIDRequest) ProtoMessage() {}
+
+func (x *SetUserIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetUserIDRequest.ProtoReflect.Descriptor instead.
+func (*SetUserIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *SetUserIDRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetUserIDRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SetUserIDRequest) GetLang() string {
+	if x != nil {
+		return x.Lang
+	}
+	return ""
+}
+
+type SetUserIDResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IdChanged           bool                   `protobuf:"varint,1,opt,name=id_changed,json=idChanged,proto3" json:"id_changed,omitempty"`
+	HomeDirOwnerChanged bool                   `protobuf:"varint,2,opt,name=home_dir_owner_changed,json=homeDirOwnerChanged,proto3" json:"home_dir_owner_changed,omitempty"`
+	Warnings            []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SetUserIDResponse) Reset() {
+	*x = SetUserIDResponse{}
+	mi := &file_authd_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetUserIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetUserIDResponse) ProtoMessage() {}
+
+func (x *SetUserIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetUserIDResponse.ProtoReflect.Descriptor instead.
+func (*SetUserIDResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *SetUserIDResponse) GetIdChanged() bool {
+	if x != nil {
+		return x.IdChanged
+	}
+	return false
+}
+
+func (x *SetUserIDResponse) GetHomeDirOwnerChanged() bool {
+	if x != nil {
+		return x.HomeDirOwnerChanged
+	}
+	return false
+}
+
+func (x *SetUserIDResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type SetUserShellRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Shell string                 `protobuf:"bytes,2,opt,name=shell,proto3" json:"shell,omitempty"`
+	// Whether to set the shell even if it's not listed in /etc/shells.
+	Force         bool `protobuf:"varint,3,opt,name=force,proto3" json:"force,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetUserShellRequest) Reset() {
+	*x = SetUserShellRequest{}
+	mi := &file_authd_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetUserShellRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetUserShellRequest) ProtoMessage() {}
+
+func (x *SetUserShellRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetUserShellRequest.ProtoReflect.Descriptor instead.
+func (*SetUserShellRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *SetUserShellRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetUserShellRequest) GetShell() string {
+	if x != nil {
+		return x.Shell
+	}
+	return ""
+}
+
+func (x *SetUserShellRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
+type SetUserHomeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Home  string                 `protobuf:"bytes,2,opt,name=home,proto3" json:"home,omitempty"`
+	// Whether to also move the content of the current home directory to the new one.
+	Move bool `protobuf:"varint,3,opt,name=move,proto3" json:"move,omitempty"`
+	// The language to use for any warnings returned.
+	// Note: This is currently not implemented and warnings are always in English.
+	Lang          string `protobuf:"bytes,4,opt,name=lang,proto3" json:"lang,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetUserHomeRequest) Reset() {
+	*x = SetUserHomeRequest{}
+	mi := &file_authd_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetUserHomeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetUserHomeRequest) ProtoMessage() {}
+
+func (x *SetUserHomeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetUserHomeRequest.ProtoReflect.Descriptor instead.
+func (*SetUserHomeRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *SetUserHomeRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetUserHomeRequest) GetHome() string {
+	if x != nil {
+		return x.Home
+	}
+	return ""
+}
+
+func (x *SetUserHomeRequest) GetMove() bool {
+	if x != nil {
+		return x.Move
+	}
+	return false
+}
+
+func (x *SetUserHomeRequest) GetLang() string {
+	if x != nil {
+		return x.Lang
+	}
+	return ""
+}
+
+type SetUserHomeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HomeDirMoved  bool                   `protobuf:"varint,1,opt,name=home_dir_moved,json=homeDirMoved,proto3" json:"home_dir_moved,omitempty"`
+	Warnings      []string               `protobuf:"bytes,2,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetUserHomeResponse) Reset() {
+	*x = SetUserHomeResponse{}
+	mi := &file_authd_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetUserHomeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetUserHomeResponse) ProtoMessage() {}
+
+func (x *SetUserHomeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetUserHomeResponse.ProtoReflect.Descriptor instead.
+func (*SetUserHomeResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *SetUserHomeResponse) GetHomeDirMoved() bool {
+	if x != nil {
+		return x.HomeDirMoved
+	}
+	return false
+}
+
+func (x *SetUserHomeResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type SetGroupIDRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Id    uint32                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	// The language to use for any warnings returned.
+	// Note: This is currently not implemented and warnings are always in English.
+	Lang          string `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetGroupIDRequest) Reset() {
+	*x = SetGroupIDRequest{}
+	mi := &file_authd_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetGroupIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetGroupIDRequest) ProtoMessage() {}
+
+func (x *SetGroupIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetGroupIDRequest.ProtoReflect.Descriptor instead.
+func (*SetGroupIDRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *SetGroupIDRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetGroupIDRequest) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SetGroupIDRequest) GetLang() string {
+	if x != nil {
+		return x.Lang
+	}
+	return ""
+}
+
+type SetGroupIDResponse struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	IdChanged           bool                   `protobuf:"varint,1,opt,name=id_changed,json=idChanged,proto3" json:"id_changed,omitempty"`
+	HomeDirOwnerChanged bool                   `protobuf:"varint,2,opt,name=home_dir_owner_changed,json=homeDirOwnerChanged,proto3" json:"home_dir_owner_changed,omitempty"`
+	Warnings            []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *SetGroupIDResponse) Reset() {
+	*x = SetGroupIDResponse{}
+	mi := &file_authd_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetGroupByIDRequest) String() string {
+func (x *SetGroupIDResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetGroupByIDRequest) ProtoMessage() {}
+func (*SetGroupIDResponse) ProtoMessage() {}
 
-func (x *GetGroupByIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[21]
+func (x *SetGroupIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1157,22 +1662,37 @@ func (x *GetGroupByIDRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetGroupByIDRequest.ProtoReflect.Descriptor instead.
-func (*GetGroupByIDRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{21}
+// Deprecated: Use SetGroupIDResponse.ProtoReflect.Descriptor instead.
+func (*SetGroupIDResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *GetGroupByIDRequest) GetId() uint32 {
+func (x *SetGroupIDResponse) GetIdChanged() bool {
 	if x != nil {
-		return x.Id
+		return x.IdChanged
 	}
-	return 0
+	return false
 }
 
-type SetUserIDRequest struct {
+func (x *SetGroupIDResponse) GetHomeDirOwnerChanged() bool {
+	if x != nil {
+		return x.HomeDirOwnerChanged
+	}
+	return false
+}
+
+func (x *SetGroupIDResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type DeleteUserRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Id    uint32                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	// Whether to also remove the home directory of the user.
+	RemoveHome bool `protobuf:"varint,2,opt,name=remove_home,json=removeHome,proto3" json:"remove_home,omitempty"`
 	// The language to use for any warnings returned.
 	// Note: This is currently not implemented and warnings are always in English.
 	Lang          string `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
@@ -1180,21 +1700,21 @@ type SetUserIDRequest struct {
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetUserIDRequest) Reset() {
-	*x = SetUserIDRequest{}
-	mi := &file_authd_proto_msgTypes[22]
+func (x *DeleteUserRequest) Reset() {
+	*x = DeleteUserRequest{}
+	mi := &file_authd_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetUserIDRequest) String() string {
+func (x *DeleteUserRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetUserIDRequest) ProtoMessage() {}
+func (*DeleteUserRequest) ProtoMessage() {}
 
-func (x *SetUserIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[22]
+func (x *DeleteUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1205,56 +1725,55 @@ func (x *SetUserIDRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetUserIDRequest.ProtoReflect.Descriptor instead.
-func (*SetUserIDRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{22}
+// Deprecated: Use DeleteUserRequest.ProtoReflect.Descriptor instead.
+func (*DeleteUserRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{31}
 }
 
-func (x *SetUserIDRequest) GetName() string {
+func (x *DeleteUserRequest) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *SetUserIDRequest) GetId() uint32 {
+func (x *DeleteUserRequest) GetRemoveHome() bool {
 	if x != nil {
-		return x.Id
+		return x.RemoveHome
 	}
-	return 0
+	return false
 }
 
-func (x *SetUserIDRequest) GetLang() string {
+func (x *DeleteUserRequest) GetLang() string {
 	if x != nil {
 		return x.Lang
 	}
 	return ""
 }
 
-type SetUserIDResponse struct {
-	state               protoimpl.MessageState `protogen:"open.v1"`
-	IdChanged           bool                   `protobuf:"varint,1,opt,name=id_changed,json=idChanged,proto3" json:"id_changed,omitempty"`
-	HomeDirOwnerChanged bool                   `protobuf:"varint,2,opt,name=home_dir_owner_changed,json=homeDirOwnerChanged,proto3" json:"home_dir_owner_changed,omitempty"`
-	Warnings            []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+type DeleteUserResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	HomeDirRemoved bool                   `protobuf:"varint,1,opt,name=home_dir_removed,json=homeDirRemoved,proto3" json:"home_dir_removed,omitempty"`
+	Warnings       []string               `protobuf:"bytes,2,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
-func (x *SetUserIDResponse) Reset() {
-	*x = SetUserIDResponse{}
-	mi := &file_authd_proto_msgTypes[23]
+func (x *DeleteUserResponse) Reset() {
+	*x = DeleteUserResponse{}
+	mi := &file_authd_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetUserIDResponse) String() string {
+func (x *DeleteUserResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetUserIDResponse) ProtoMessage() {}
+func (*DeleteUserResponse) ProtoMessage() {}
 
-func (x *SetUserIDResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[23]
+func (x *DeleteUserResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1265,58 +1784,49 @@ func (x *SetUserIDResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetUserIDResponse.ProtoReflect.Descriptor instead.
-func (*SetUserIDResponse) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{23}
-}
-
-func (x *SetUserIDResponse) GetIdChanged() bool {
-	if x != nil {
-		return x.IdChanged
-	}
-	return false
+// Deprecated: Use DeleteUserResponse.ProtoReflect.Descriptor instead.
+func (*DeleteUserResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{32}
 }
 
-func (x *SetUserIDResponse) GetHomeDirOwnerChanged() bool {
+func (x *DeleteUserResponse) GetHomeDirRemoved() bool {
 	if x != nil {
-		return x.HomeDirOwnerChanged
+		return x.HomeDirRemoved
 	}
 	return false
 }
 
-func (x *SetUserIDResponse) GetWarnings() []string {
+func (x *DeleteUserResponse) GetWarnings() []string {
 	if x != nil {
 		return x.Warnings
 	}
 	return nil
 }
 
-type SetGroupIDRequest struct {
+type CreateGroupRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Id    uint32                 `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
-	// The language to use for any warnings returned.
-	// Note: This is currently not implemented and warnings are always in English.
-	Lang          string `protobuf:"bytes,3,opt,name=lang,proto3" json:"lang,omitempty"`
+	// The GID of the new group. If 0, a GID is generated.
+	Gid           uint32 `protobuf:"varint,2,opt,name=gid,proto3" json:"gid,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetGroupIDRequest) Reset() {
-	*x = SetGroupIDRequest{}
-	mi := &file_authd_proto_msgTypes[24]
+func (x *CreateGroupRequest) Reset() {
+	*x = CreateGroupRequest{}
+	mi := &file_authd_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetGroupIDRequest) String() string {
+func (x *CreateGroupRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetGroupIDRequest) ProtoMessage() {}
+func (*CreateGroupRequest) ProtoMessage() {}
 
-func (x *SetGroupIDRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[24]
+func (x *CreateGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1327,56 +1837,93 @@ func (x *SetGroupIDRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetGroupIDRequest.ProtoReflect.Descriptor instead.
-func (*SetGroupIDRequest) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{24}
+// Deprecated: Use CreateGroupRequest.ProtoReflect.Descriptor instead.
+func (*CreateGroupRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{33}
 }
 
-func (x *SetGroupIDRequest) GetName() string {
+func (x *CreateGroupRequest) GetName() string {
 	if x != nil {
 		return x.Name
 	}
 	return ""
 }
 
-func (x *SetGroupIDRequest) GetId() uint32 {
+func (x *CreateGroupRequest) GetGid() uint32 {
 	if x != nil {
-		return x.Id
+		return x.Gid
 	}
 	return 0
 }
 
-func (x *SetGroupIDRequest) GetLang() string {
+type CreateGroupResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Gid           uint32                 `protobuf:"varint,1,opt,name=gid,proto3" json:"gid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateGroupResponse) Reset() {
+	*x = CreateGroupResponse{}
+	mi := &file_authd_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateGroupResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateGroupResponse) ProtoMessage() {}
+
+func (x *CreateGroupResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[34]
 	if x != nil {
-		return x.Lang
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-type SetGroupIDResponse struct {
-	state               protoimpl.MessageState `protogen:"open.v1"`
-	IdChanged           bool                   `protobuf:"varint,1,opt,name=id_changed,json=idChanged,proto3" json:"id_changed,omitempty"`
-	HomeDirOwnerChanged bool                   `protobuf:"varint,2,opt,name=home_dir_owner_changed,json=homeDirOwnerChanged,proto3" json:"home_dir_owner_changed,omitempty"`
-	Warnings            []string               `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+// Deprecated: Use CreateGroupResponse.ProtoReflect.Descriptor instead.
+func (*CreateGroupResponse) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *SetGroupIDResponse) Reset() {
-	*x = SetGroupIDResponse{}
-	mi := &file_authd_proto_msgTypes[25]
+func (x *CreateGroupResponse) GetGid() uint32 {
+	if x != nil {
+		return x.Gid
+	}
+	return 0
+}
+
+type DeleteGroupRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Whether to delete the group even if it still has members.
+	Force         bool `protobuf:"varint,2,opt,name=force,proto3" json:"force,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteGroupRequest) Reset() {
+	*x = DeleteGroupRequest{}
+	mi := &file_authd_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetGroupIDResponse) String() string {
+func (x *DeleteGroupRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetGroupIDResponse) ProtoMessage() {}
+func (*DeleteGroupRequest) ProtoMessage() {}
 
-func (x *SetGroupIDResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[25]
+func (x *DeleteGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1387,47 +1934,43 @@ func (x *SetGroupIDResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetGroupIDResponse.ProtoReflect.Descriptor instead.
-func (*SetGroupIDResponse) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{25}
+// Deprecated: Use DeleteGroupRequest.ProtoReflect.Descriptor instead.
+func (*DeleteGroupRequest) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{35}
 }
 
-func (x *SetGroupIDResponse) GetIdChanged() bool {
+func (x *DeleteGroupRequest) GetName() string {
 	if x != nil {
-		return x.IdChanged
+		return x.Name
 	}
-	return false
+	return ""
 }
 
-func (x *SetGroupIDResponse) GetHomeDirOwnerChanged() bool {
+func (x *DeleteGroupRequest) GetForce() bool {
 	if x != nil {
-		return x.HomeDirOwnerChanged
+		return x.Force
 	}
 	return false
 }
 
-func (x *SetGroupIDResponse) GetWarnings() []string {
-	if x != nil {
-		return x.Warnings
-	}
-	return nil
-}
-
 type User struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Uid           uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
-	Gid           uint32                 `protobuf:"varint,3,opt,name=gid,proto3" json:"gid,omitempty"`
-	Gecos         string                 `protobuf:"bytes,4,opt,name=gecos,proto3" json:"gecos,omitempty"`
-	Homedir       string                 `protobuf:"bytes,5,opt,name=homedir,proto3" json:"homedir,omitempty"`
-	Shell         string                 `protobuf:"bytes,6,opt,name=shell,proto3" json:"shell,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Name    string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Uid     uint32                 `protobuf:"varint,2,opt,name=uid,proto3" json:"uid,omitempty"`
+	Gid     uint32                 `protobuf:"varint,3,opt,name=gid,proto3" json:"gid,omitempty"`
+	Gecos   string                 `protobuf:"bytes,4,opt,name=gecos,proto3" json:"gecos,omitempty"`
+	Homedir string                 `protobuf:"bytes,5,opt,name=homedir,proto3" json:"homedir,omitempty"`
+	Shell   string                 `protobuf:"bytes,6,opt,name=shell,proto3" json:"shell,omitempty"`
+	Locked  bool                   `protobuf:"varint,7,opt,name=locked,proto3" json:"locked,omitempty"`
+	// The name of the broker the user is bound to. It is only set by GetUserByName.
+	Broker        string `protobuf:"bytes,8,opt,name=broker,proto3" json:"broker,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *User) Reset() {
 	*x = User{}
-	mi := &file_authd_proto_msgTypes[26]
+	mi := &file_authd_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1439,7 +1982,7 @@ func (x *User) String() string {
 func (*User) ProtoMessage() {}
 
 func (x *User) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[26]
+	mi := &file_authd_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1452,7 +1995,7 @@ func (x *User) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use User.ProtoReflect.Descriptor instead.
 func (*User) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{26}
+	return file_authd_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *User) GetName() string {
@@ -1497,6 +2040,20 @@ func (x *User) GetShell() string {
 	return ""
 }
 
+func (x *User) GetLocked() bool {
+	if x != nil {
+		return x.Locked
+	}
+	return false
+}
+
+func (x *User) GetBroker() string {
+	if x != nil {
+		return x.Broker
+	}
+	return ""
+}
+
 type Users struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Users         []*User                `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
@@ -1506,7 +2063,7 @@ type Users struct {
 
 func (x *Users) Reset() {
 	*x = Users{}
-	mi := &file_authd_proto_msgTypes[27]
+	mi := &file_authd_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1518,7 +2075,7 @@ func (x *Users) String() string {
 func (*Users) ProtoMessage() {}
 
 func (x *Users) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[27]
+	mi := &file_authd_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1531,7 +2088,7 @@ func (x *Users) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Users.ProtoReflect.Descriptor instead.
 func (*Users) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{27}
+	return file_authd_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *Users) GetUsers() []*User {
@@ -1554,7 +2111,7 @@ type Group struct {
 
 func (x *Group) Reset() {
 	*x = Group{}
-	mi := &file_authd_proto_msgTypes[28]
+	mi := &file_authd_proto_msgTypes[38]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1566,7 +2123,7 @@ func (x *Group) String() string {
 func (*Group) ProtoMessage() {}
 
 func (x *Group) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[28]
+	mi := &file_authd_proto_msgTypes[38]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1579,7 +2136,7 @@ func (x *Group) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Group.ProtoReflect.Descriptor instead.
 func (*Group) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{28}
+	return file_authd_proto_rawDescGZIP(), []int{38}
 }
 
 func (x *Group) GetName() string {
@@ -1619,7 +2176,7 @@ type Groups struct {
 
 func (x *Groups) Reset() {
 	*x = Groups{}
-	mi := &file_authd_proto_msgTypes[29]
+	mi := &file_authd_proto_msgTypes[39]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1631,7 +2188,7 @@ func (x *Groups) String() string {
 func (*Groups) ProtoMessage() {}
 
 func (x *Groups) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[29]
+	mi := &file_authd_proto_msgTypes[39]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1644,7 +2201,7 @@ func (x *Groups) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Groups.ProtoReflect.Descriptor instead.
 func (*Groups) Descriptor() ([]byte, []int) {
-	return file_authd_proto_rawDescGZIP(), []int{29}
+	return file_authd_proto_rawDescGZIP(), []int{39}
 }
 
 func (x *Groups) GetGroups() []*Group {
@@ -1654,6 +2211,83 @@ func (x *Groups) GetGroups() []*Group {
 	return nil
 }
 
+type DaemonStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	UptimeSeconds int64                  `protobuf:"varint,2,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	NumUsers      uint32                 `protobuf:"varint,3,opt,name=num_users,json=numUsers,proto3" json:"num_users,omitempty"`
+	Brokers       []string               `protobuf:"bytes,4,rep,name=brokers,proto3" json:"brokers,omitempty"`
+	// The name of the first configured broker, or of the local broker if none is configured.
+	DefaultBroker string `protobuf:"bytes,5,opt,name=default_broker,json=defaultBroker,proto3" json:"default_broker,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DaemonStatus) Reset() {
+	*x = DaemonStatus{}
+	mi := &file_authd_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DaemonStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DaemonStatus) ProtoMessage() {}
+
+func (x *DaemonStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_authd_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DaemonStatus.ProtoReflect.Descriptor instead.
+func (*DaemonStatus) Descriptor() ([]byte, []int) {
+	return file_authd_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *DaemonStatus) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *DaemonStatus) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *DaemonStatus) GetNumUsers() uint32 {
+	if x != nil {
+		return x.NumUsers
+	}
+	return 0
+}
+
+func (x *DaemonStatus) GetBrokers() []string {
+	if x != nil {
+		return x.Brokers
+	}
+	return nil
+}
+
+func (x *DaemonStatus) GetDefaultBroker() string {
+	if x != nil {
+		return x.DefaultBroker
+	}
+	return ""
+}
+
 type ABResponse_BrokerInfo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -1665,7 +2299,7 @@ type ABResponse_BrokerInfo struct {
 
 func (x *ABResponse_BrokerInfo) Reset() {
 	*x = ABResponse_BrokerInfo{}
-	mi := &file_authd_proto_msgTypes[30]
+	mi := &file_authd_proto_msgTypes[41]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1677,7 +2311,7 @@ func (x *ABResponse_BrokerInfo) String() string {
 func (*ABResponse_BrokerInfo) ProtoMessage() {}
 
 func (x *ABResponse_BrokerInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[30]
+	mi := &file_authd_proto_msgTypes[41]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1724,7 +2358,7 @@ type GAMResponse_AuthenticationMode struct {
 
 func (x *GAMResponse_AuthenticationMode) Reset() {
 	*x = GAMResponse_AuthenticationMode{}
-	mi := &file_authd_proto_msgTypes[31]
+	mi := &file_authd_proto_msgTypes[42]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1736,7 +2370,7 @@ func (x *GAMResponse_AuthenticationMode) String() string {
 func (*GAMResponse_AuthenticationMode) ProtoMessage() {}
 
 func (x *GAMResponse_AuthenticationMode) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[31]
+	mi := &file_authd_proto_msgTypes[42]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1781,7 +2415,7 @@ type IARequest_AuthenticationData struct {
 
 func (x *IARequest_AuthenticationData) Reset() {
 	*x = IARequest_AuthenticationData{}
-	mi := &file_authd_proto_msgTypes[32]
+	mi := &file_authd_proto_msgTypes[43]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1793,7 +2427,7 @@ func (x *IARequest_AuthenticationData) String() string {
 func (*IARequest_AuthenticationData) ProtoMessage() {}
 
 func (x *IARequest_AuthenticationData) ProtoReflect() protoreflect.Message {
-	mi := &file_authd_proto_msgTypes[32]
+	mi := &file_authd_proto_msgTypes[43]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1974,9 +2608,15 @@ const file_authd_proto_rawDesc = "" +
 	"\x12GetUserByIDRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\rR\x02id\"%\n" +
 	"\x0fLockUserRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\"'\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"1\n" +
+	"\x10LockUserResponse\x12\x1d\n" +
+	"\n" +
+	"was_locked\x18\x01 \x01(\bR\twasLocked\"'\n" +
 	"\x11UnlockUserRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\"+\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"3\n" +
+	"\x12UnlockUserResponse\x12\x1d\n" +
+	"\n" +
+	"was_locked\x18\x01 \x01(\bR\twasLocked\"+\n" +
 	"\x15GetGroupByNameRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\"%\n" +
 	"\x13GetGroupByIDRequest\x12\x0e\n" +
@@ -1989,7 +2629,19 @@ const file_authd_proto_rawDesc = "" +
 	"\n" +
 	"id_changed\x18\x01 \x01(\bR\tidChanged\x123\n" +
 	"\x16home_dir_owner_changed\x18\x02 \x01(\bR\x13homeDirOwnerChanged\x12\x1a\n" +
-	"\bwarnings\x18\x03 \x03(\tR\bwarnings\"K\n" +
+	"\bwarnings\x18\x03 \x03(\tR\bwarnings\"U\n" +
+	"\x13SetUserShellRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05shell\x18\x02 \x01(\tR\x05shell\x12\x14\n" +
+	"\x05force\x18\x03 \x01(\bR\x05force\"d\n" +
+	"\x12SetUserHomeRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04home\x18\x02 \x01(\tR\x04home\x12\x12\n" +
+	"\x04move\x18\x03 \x01(\bR\x04move\x12\x12\n" +
+	"\x04lang\x18\x04 \x01(\tR\x04lang\"W\n" +
+	"\x13SetUserHomeResponse\x12$\n" +
+	"\x0ehome_dir_moved\x18\x01 \x01(\bR\fhomeDirMoved\x12\x1a\n" +
+	"\bwarnings\x18\x02 \x03(\tR\bwarnings\"K\n" +
 	"\x11SetGroupIDRequest\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x0e\n" +
 	"\x02id\x18\x02 \x01(\rR\x02id\x12\x12\n" +
@@ -1998,14 +2650,32 @@ const file_authd_proto_rawDesc = "" +
 	"\n" +
 	"id_changed\x18\x01 \x01(\bR\tidChanged\x123\n" +
 	"\x16home_dir_owner_changed\x18\x02 \x01(\bR\x13homeDirOwnerChanged\x12\x1a\n" +
-	"\bwarnings\x18\x03 \x03(\tR\bwarnings\"\x84\x01\n" +
+	"\bwarnings\x18\x03 \x03(\tR\bwarnings\"\\\n" +
+	"\x11DeleteUserRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1f\n" +
+	"\vremove_home\x18\x02 \x01(\bR\n" +
+	"removeHome\x12\x12\n" +
+	"\x04lang\x18\x03 \x01(\tR\x04lang\"Z\n" +
+	"\x12DeleteUserResponse\x12(\n" +
+	"\x10home_dir_removed\x18\x01 \x01(\bR\x0ehomeDirRemoved\x12\x1a\n" +
+	"\bwarnings\x18\x02 \x03(\tR\bwarnings\":\n" +
+	"\x12CreateGroupRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03gid\x18\x02 \x01(\rR\x03gid\"'\n" +
+	"\x13CreateGroupResponse\x12\x10\n" +
+	"\x03gid\x18\x01 \x01(\rR\x03gid\">\n" +
+	"\x12DeleteGroupRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05force\x18\x02 \x01(\bR\x05force\"\xb4\x01\n" +
 	"\x04User\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
 	"\x03uid\x18\x02 \x01(\rR\x03uid\x12\x10\n" +
 	"\x03gid\x18\x03 \x01(\rR\x03gid\x12\x14\n" +
 	"\x05gecos\x18\x04 \x01(\tR\x05gecos\x12\x18\n" +
 	"\ahomedir\x18\x05 \x01(\tR\ahomedir\x12\x14\n" +
-	"\x05shell\x18\x06 \x01(\tR\x05shell\"*\n" +
+	"\x05shell\x18\x06 \x01(\tR\x05shell\x12\x16\n" +
+	"\x06locked\x18\a \x01(\bR\x06locked\x12\x16\n" +
+	"\x06broker\x18\b \x01(\tR\x06broker\"*\n" +
 	"\x05Users\x12!\n" +
 	"\x05users\x18\x01 \x03(\v2\v.authd.UserR\x05users\"_\n" +
 	"\x05Group\x12\x12\n" +
@@ -2014,11 +2684,19 @@ const file_authd_proto_rawDesc = "" +
 	"\amembers\x18\x03 \x03(\tR\amembers\x12\x16\n" +
 	"\x06passwd\x18\x04 \x01(\tR\x06passwd\".\n" +
 	"\x06Groups\x12$\n" +
-	"\x06groups\x18\x01 \x03(\v2\f.authd.GroupR\x06groups*<\n" +
+	"\x06groups\x18\x01 \x03(\v2\f.authd.GroupR\x06groups\"\xad\x01\n" +
+	"\fDaemonStatus\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12%\n" +
+	"\x0euptime_seconds\x18\x02 \x01(\x03R\ruptimeSeconds\x12\x1b\n" +
+	"\tnum_users\x18\x03 \x01(\rR\bnumUsers\x12\x18\n" +
+	"\abrokers\x18\x04 \x03(\tR\abrokers\x12%\n" +
+	"\x0edefault_broker\x18\x05 \x01(\tR\rdefaultBroker*L\n" +
 	"\vSessionMode\x12\r\n" +
 	"\tUNDEFINED\x10\x00\x12\t\n" +
 	"\x05LOGIN\x10\x01\x12\x13\n" +
-	"\x0fCHANGE_PASSWORD\x10\x022\xd3\x03\n" +
+	"\x0fCHANGE_PASSWORD\x10\x02\x12\x0e\n" +
+	"\n" +
+	"DIAGNOSTIC\x10\x032\xd3\x03\n" +
 	"\x03PAM\x123\n" +
 	"\x10AvailableBrokers\x12\f.authd.Empty\x1a\x11.authd.ABResponse\x12:\n" +
 	"\x11GetPreviousBroker\x12\x11.authd.GPBRequest\x1a\x12.authd.GPBResponse\x123\n" +
@@ -2028,21 +2706,29 @@ const file_authd_proto_rawDesc = "" +
 	"\x0fIsAuthenticated\x12\x10.authd.IARequest\x1a\x11.authd.IAResponse\x12,\n" +
 	"\n" +
 	"EndSession\x12\x10.authd.ESRequest\x1a\f.authd.Empty\x12<\n" +
-	"\x17SetDefaultBrokerForUser\x12\x13.authd.SDBFURequest\x1a\f.authd.Empty2\xb6\x04\n" +
+	"\x17SetDefaultBrokerForUser\x12\x13.authd.SDBFURequest\x1a\f.authd.Empty2\xf1\a\n" +
 	"\vUserService\x129\n" +
 	"\rGetUserByName\x12\x1b.authd.GetUserByNameRequest\x1a\v.authd.User\x125\n" +
 	"\vGetUserByID\x12\x19.authd.GetUserByIDRequest\x1a\v.authd.User\x12'\n" +
-	"\tListUsers\x12\f.authd.Empty\x1a\f.authd.Users\x120\n" +
-	"\bLockUser\x12\x16.authd.LockUserRequest\x1a\f.authd.Empty\x124\n" +
+	"\tListUsers\x12\f.authd.Empty\x1a\f.authd.Users\x12*\n" +
+	"\vStreamUsers\x12\f.authd.Empty\x1a\v.authd.User0\x01\x12;\n" +
+	"\bLockUser\x12\x16.authd.LockUserRequest\x1a\x17.authd.LockUserResponse\x12A\n" +
+	"\n" +
+	"UnlockUser\x12\x18.authd.UnlockUserRequest\x1a\x19.authd.UnlockUserResponse\x12>\n" +
+	"\tSetUserID\x12\x17.authd.SetUserIDRequest\x1a\x18.authd.SetUserIDResponse\x128\n" +
+	"\fSetUserShell\x12\x1a.authd.SetUserShellRequest\x1a\f.authd.Empty\x12D\n" +
+	"\vSetUserHome\x12\x19.authd.SetUserHomeRequest\x1a\x1a.authd.SetUserHomeResponse\x12A\n" +
 	"\n" +
-	"UnlockUser\x12\x18.authd.UnlockUserRequest\x1a\f.authd.Empty\x12>\n" +
-	"\tSetUserID\x12\x17.authd.SetUserIDRequest\x1a\x18.authd.SetUserIDResponse\x12A\n" +
+	"SetGroupID\x12\x18.authd.SetGroupIDRequest\x1a\x19.authd.SetGroupIDResponse\x12A\n" +
 	"\n" +
-	"SetGroupID\x12\x18.authd.SetGroupIDRequest\x1a\x19.authd.SetGroupIDResponse\x12<\n" +
+	"DeleteUser\x12\x18.authd.DeleteUserRequest\x1a\x19.authd.DeleteUserResponse\x12D\n" +
+	"\vCreateGroup\x12\x19.authd.CreateGroupRequest\x1a\x1a.authd.CreateGroupResponse\x126\n" +
+	"\vDeleteGroup\x12\x19.authd.DeleteGroupRequest\x1a\f.authd.Empty\x12<\n" +
 	"\x0eGetGroupByName\x12\x1c.authd.GetGroupByNameRequest\x1a\f.authd.Group\x128\n" +
 	"\fGetGroupByID\x12\x1a.authd.GetGroupByIDRequest\x1a\f.authd.Group\x12)\n" +
 	"\n" +
-	"ListGroups\x12\f.authd.Empty\x1a\r.authd.GroupsB1Z/github.com/canonical/authd/internal/proto/authdb\x06proto3"
+	"ListGroups\x12\f.authd.Empty\x1a\r.authd.Groups\x124\n" +
+	"\x0fGetDaemonStatus\x12\f.authd.Empty\x1a\x13.authd.DaemonStatusB1Z/github.com/canonical/authd/internal/proto/authdb\x06proto3"
 
 var (
 	file_authd_proto_rawDescOnce sync.Once
@@ -2057,7 +2743,7 @@ func file_authd_proto_rawDescGZIP() []byte {
 }
 
 var file_authd_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_authd_proto_msgTypes = make([]protoimpl.MessageInfo, 33)
+var file_authd_proto_msgTypes = make([]protoimpl.MessageInfo, 44)
 var file_authd_proto_goTypes = []any{
 	(SessionMode)(0),                       // 0: authd.SessionMode
 	(*Empty)(nil),                          // 1: authd.Empty
@@ -2079,30 +2765,41 @@ var file_authd_proto_goTypes = []any{
 	(*GetUserByNameRequest)(nil),           // 17: authd.GetUserByNameRequest
 	(*GetUserByIDRequest)(nil),             // 18: authd.GetUserByIDRequest
 	(*LockUserRequest)(nil),                // 19: authd.LockUserRequest
-	(*UnlockUserRequest)(nil),              // 20: authd.UnlockUserRequest
-	(*GetGroupByNameRequest)(nil),          // 21: authd.GetGroupByNameRequest
-	(*GetGroupByIDRequest)(nil),            // 22: authd.GetGroupByIDRequest
-	(*SetUserIDRequest)(nil),               // 23: authd.SetUserIDRequest
-	(*SetUserIDResponse)(nil),              // 24: authd.SetUserIDResponse
-	(*SetGroupIDRequest)(nil),              // 25: authd.SetGroupIDRequest
-	(*SetGroupIDResponse)(nil),             // 26: authd.SetGroupIDResponse
-	(*User)(nil),                           // 27: authd.User
-	(*Users)(nil),                          // 28: authd.Users
-	(*Group)(nil),                          // 29: authd.Group
-	(*Groups)(nil),                         // 30: authd.Groups
-	(*ABResponse_BrokerInfo)(nil),          // 31: authd.ABResponse.BrokerInfo
-	(*GAMResponse_AuthenticationMode)(nil), // 32: authd.GAMResponse.AuthenticationMode
-	(*IARequest_AuthenticationData)(nil),   // 33: authd.IARequest.AuthenticationData
+	(*LockUserResponse)(nil),               // 20: authd.LockUserResponse
+	(*UnlockUserRequest)(nil),              // 21: authd.UnlockUserRequest
+	(*UnlockUserResponse)(nil),             // 22: authd.UnlockUserResponse
+	(*GetGroupByNameRequest)(nil),          // 23: authd.GetGroupByNameRequest
+	(*GetGroupByIDRequest)(nil),            // 24: authd.GetGroupByIDRequest
+	(*SetUserIDRequest)(nil),               // 25: authd.SetUserIDRequest
+	(*SetUserIDResponse)(nil),              // 26: authd.SetUserIDResponse
+	(*SetUserShellRequest)(nil),            // 27: authd.SetUserShellRequest
+	(*SetUserHomeRequest)(nil),             // 28: authd.SetUserHomeRequest
+	(*SetUserHomeResponse)(nil),            // 29: authd.SetUserHomeResponse
+	(*SetGroupIDRequest)(nil),              // 30: authd.SetGroupIDRequest
+	(*SetGroupIDResponse)(nil),             // 31: authd.SetGroupIDResponse
+	(*DeleteUserRequest)(nil),              // 32: authd.DeleteUserRequest
+	(*DeleteUserResponse)(nil),             // 33: authd.DeleteUserResponse
+	(*CreateGroupRequest)(nil),             // 34: authd.CreateGroupRequest
+	(*CreateGroupResponse)(nil),            // 35: authd.CreateGroupResponse
+	(*DeleteGroupRequest)(nil),             // 36: authd.DeleteGroupRequest
+	(*User)(nil),                           // 37: authd.User
+	(*Users)(nil),                          // 38: authd.Users
+	(*Group)(nil),                          // 39: authd.Group
+	(*Groups)(nil),                         // 40: authd.Groups
+	(*DaemonStatus)(nil),                   // 41: authd.DaemonStatus
+	(*ABResponse_BrokerInfo)(nil),          // 42: authd.ABResponse.BrokerInfo
+	(*GAMResponse_AuthenticationMode)(nil), // 43: authd.GAMResponse.AuthenticationMode
+	(*IARequest_AuthenticationData)(nil),   // 44: authd.IARequest.AuthenticationData
 }
 var file_authd_proto_depIdxs = []int32{
-	31, // 0: authd.ABResponse.brokers_infos:type_name -> authd.ABResponse.BrokerInfo
+	42, // 0: authd.ABResponse.brokers_infos:type_name -> authd.ABResponse.BrokerInfo
 	0,  // 1: authd.SBRequest.mode:type_name -> authd.SessionMode
 	9,  // 2: authd.GAMRequest.supported_ui_layouts:type_name -> authd.UILayout
-	32, // 3: authd.GAMResponse.authentication_modes:type_name -> authd.GAMResponse.AuthenticationMode
+	43, // 3: authd.GAMResponse.authentication_modes:type_name -> authd.GAMResponse.AuthenticationMode
 	9,  // 4: authd.SAMResponse.ui_layout_info:type_name -> authd.UILayout
-	33, // 5: authd.IARequest.authentication_data:type_name -> authd.IARequest.AuthenticationData
-	27, // 6: authd.Users.users:type_name -> authd.User
-	29, // 7: authd.Groups.groups:type_name -> authd.Group
+	44, // 5: authd.IARequest.authentication_data:type_name -> authd.IARequest.AuthenticationData
+	37, // 6: authd.Users.users:type_name -> authd.User
+	39, // 7: authd.Groups.groups:type_name -> authd.Group
 	1,  // 8: authd.PAM.AvailableBrokers:input_type -> authd.Empty
 	2,  // 9: authd.PAM.GetPreviousBroker:input_type -> authd.GPBRequest
 	6,  // 10: authd.PAM.SelectBroker:input_type -> authd.SBRequest
@@ -2114,33 +2811,47 @@ var file_authd_proto_depIdxs = []int32{
 	17, // 16: authd.UserService.GetUserByName:input_type -> authd.GetUserByNameRequest
 	18, // 17: authd.UserService.GetUserByID:input_type -> authd.GetUserByIDRequest
 	1,  // 18: authd.UserService.ListUsers:input_type -> authd.Empty
-	19, // 19: authd.UserService.LockUser:input_type -> authd.LockUserRequest
-	20, // 20: authd.UserService.UnlockUser:input_type -> authd.UnlockUserRequest
-	23, // 21: authd.UserService.SetUserID:input_type -> authd.SetUserIDRequest
-	25, // 22: authd.UserService.SetGroupID:input_type -> authd.SetGroupIDRequest
-	21, // 23: authd.UserService.GetGroupByName:input_type -> authd.GetGroupByNameRequest
-	22, // 24: authd.UserService.GetGroupByID:input_type -> authd.GetGroupByIDRequest
-	1,  // 25: authd.UserService.ListGroups:input_type -> authd.Empty
-	4,  // 26: authd.PAM.AvailableBrokers:output_type -> authd.ABResponse
-	3,  // 27: authd.PAM.GetPreviousBroker:output_type -> authd.GPBResponse
-	7,  // 28: authd.PAM.SelectBroker:output_type -> authd.SBResponse
-	10, // 29: authd.PAM.GetAuthenticationModes:output_type -> authd.GAMResponse
-	12, // 30: authd.PAM.SelectAuthenticationMode:output_type -> authd.SAMResponse
-	14, // 31: authd.PAM.IsAuthenticated:output_type -> authd.IAResponse
-	1,  // 32: authd.PAM.EndSession:output_type -> authd.Empty
-	1,  // 33: authd.PAM.SetDefaultBrokerForUser:output_type -> authd.Empty
-	27, // 34: authd.UserService.GetUserByName:output_type -> authd.User
-	27, // 35: authd.UserService.GetUserByID:output_type -> authd.User
-	28, // 36: authd.UserService.ListUsers:output_type -> authd.Users
-	1,  // 37: authd.UserService.LockUser:output_type -> authd.Empty
-	1,  // 38: authd.UserService.UnlockUser:output_type -> authd.Empty
-	24, // 39: authd.UserService.SetUserID:output_type -> authd.SetUserIDResponse
-	26, // 40: authd.UserService.SetGroupID:output_type -> authd.SetGroupIDResponse
-	29, // 41: authd.UserService.GetGroupByName:output_type -> authd.Group
-	29, // 42: authd.UserService.GetGroupByID:output_type -> authd.Group
-	30, // 43: authd.UserService.ListGroups:output_type -> authd.Groups
-	26, // [26:44] is the sub-list for method output_type
-	8,  // [8:26] is the sub-list for method input_type
+	1,  // 19: authd.UserService.StreamUsers:input_type -> authd.Empty
+	19, // 20: authd.UserService.LockUser:input_type -> authd.LockUserRequest
+	21, // 21: authd.UserService.UnlockUser:input_type -> authd.UnlockUserRequest
+	25, // 22: authd.UserService.SetUserID:input_type -> authd.SetUserIDRequest
+	27, // 23: authd.UserService.SetUserShell:input_type -> authd.SetUserShellRequest
+	28, // 24: authd.UserService.SetUserHome:input_type -> authd.SetUserHomeRequest
+	30, // 25: authd.UserService.SetGroupID:input_type -> authd.SetGroupIDRequest
+	32, // 26: authd.UserService.DeleteUser:input_type -> authd.DeleteUserRequest
+	34, // 27: authd.UserService.CreateGroup:input_type -> authd.CreateGroupRequest
+	36, // 28: authd.UserService.DeleteGroup:input_type -> authd.DeleteGroupRequest
+	23, // 29: authd.UserService.GetGroupByName:input_type -> authd.GetGroupByNameRequest
+	24, // 30: authd.UserService.GetGroupByID:input_type -> authd.GetGroupByIDRequest
+	1,  // 31: authd.UserService.ListGroups:input_type -> authd.Empty
+	1,  // 32: authd.UserService.GetDaemonStatus:input_type -> authd.Empty
+	4,  // 33: authd.PAM.AvailableBrokers:output_type -> authd.ABResponse
+	3,  // 34: authd.PAM.GetPreviousBroker:output_type -> authd.GPBResponse
+	7,  // 35: authd.PAM.SelectBroker:output_type -> authd.SBResponse
+	10, // 36: authd.PAM.GetAuthenticationModes:output_type -> authd.GAMResponse
+	12, // 37: authd.PAM.SelectAuthenticationMode:output_type -> authd.SAMResponse
+	14, // 38: authd.PAM.IsAuthenticated:output_type -> authd.IAResponse
+	1,  // 39: authd.PAM.EndSession:output_type -> authd.Empty
+	1,  // 40: authd.PAM.SetDefaultBrokerForUser:output_type -> authd.Empty
+	37, // 41: authd.UserService.GetUserByName:output_type -> authd.User
+	37, // 42: authd.UserService.GetUserByID:output_type -> authd.User
+	38, // 43: authd.UserService.ListUsers:output_type -> authd.Users
+	37, // 44: authd.UserService.StreamUsers:output_type -> authd.User
+	20, // 45: authd.UserService.LockUser:output_type -> authd.LockUserResponse
+	22, // 46: authd.UserService.UnlockUser:output_type -> authd.UnlockUserResponse
+	26, // 47: authd.UserService.SetUserID:output_type -> authd.SetUserIDResponse
+	1,  // 48: authd.UserService.SetUserShell:output_type -> authd.Empty
+	29, // 49: authd.UserService.SetUserHome:output_type -> authd.SetUserHomeResponse
+	31, // 50: authd.UserService.SetGroupID:output_type -> authd.SetGroupIDResponse
+	33, // 51: authd.UserService.DeleteUser:output_type -> authd.DeleteUserResponse
+	35, // 52: authd.UserService.CreateGroup:output_type -> authd.CreateGroupResponse
+	1,  // 53: authd.UserService.DeleteGroup:output_type -> authd.Empty
+	39, // 54: authd.UserService.GetGroupByName:output_type -> authd.Group
+	39, // 55: authd.UserService.GetGroupByID:output_type -> authd.Group
+	40, // 56: authd.UserService.ListGroups:output_type -> authd.Groups
+	41, // 57: authd.UserService.GetDaemonStatus:output_type -> authd.DaemonStatus
+	33, // [33:58] is the sub-list for method output_type
+	8,  // [8:33] is the sub-list for method input_type
 	8,  // [8:8] is the sub-list for extension type_name
 	8,  // [8:8] is the sub-list for extension extendee
 	0,  // [0:8] is the sub-list for field type_name
@@ -2152,8 +2863,8 @@ func file_authd_proto_init() {
 		return
 	}
 	file_authd_proto_msgTypes[8].OneofWrappers = []any{}
-	file_authd_proto_msgTypes[30].OneofWrappers = []any{}
-	file_authd_proto_msgTypes[32].OneofWrappers = []any{
+	file_authd_proto_msgTypes[41].OneofWrappers = []any{}
+	file_authd_proto_msgTypes[43].OneofWrappers = []any{
 		(*IARequest_AuthenticationData_Secret)(nil),
 		(*IARequest_AuthenticationData_Wait)(nil),
 		(*IARequest_AuthenticationData_Skip)(nil),
@@ -2165,7 +2876,7 @@ func file_authd_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_authd_proto_rawDesc), len(file_authd_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   33,
+			NumMessages:   44,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
@@ -387,16 +387,23 @@ var PAM_ServiceDesc = grpc.ServiceDesc{
 }
 
 const (
-	UserService_GetUserByName_FullMethodName  = "/authd.UserService/GetUserByName"
-	UserService_GetUserByID_FullMethodName    = "/authd.UserService/GetUserByID"
-	UserService_ListUsers_FullMethodName      = "/authd.UserService/ListUsers"
-	UserService_LockUser_FullMethodName       = "/authd.UserService/LockUser"
-	UserService_UnlockUser_FullMethodName     = "/authd.UserService/UnlockUser"
-	UserService_SetUserID_FullMethodName      = "/authd.UserService/SetUserID"
-	UserService_SetGroupID_FullMethodName     = "/authd.UserService/SetGroupID"
-	UserService_GetGroupByName_FullMethodName = "/authd.UserService/GetGroupByName"
-	UserService_GetGroupByID_FullMethodName   = "/authd.UserService/GetGroupByID"
-	UserService_ListGroups_FullMethodName     = "/authd.UserService/ListGroups"
+	UserService_GetUserByName_FullMethodName   = "/authd.UserService/GetUserByName"
+	UserService_GetUserByID_FullMethodName     = "/authd.UserService/GetUserByID"
+	UserService_ListUsers_FullMethodName       = "/authd.UserService/ListUsers"
+	UserService_StreamUsers_FullMethodName     = "/authd.UserService/StreamUsers"
+	UserService_LockUser_FullMethodName        = "/authd.UserService/LockUser"
+	UserService_UnlockUser_FullMethodName      = "/authd.UserService/UnlockUser"
+	UserService_SetUserID_FullMethodName       = "/authd.UserService/SetUserID"
+	UserService_SetUserShell_FullMethodName    = "/authd.UserService/SetUserShell"
+	UserService_SetUserHome_FullMethodName     = "/authd.UserService/SetUserHome"
+	UserService_SetGroupID_FullMethodName      = "/authd.UserService/SetGroupID"
+	UserService_DeleteUser_FullMethodName      = "/authd.UserService/DeleteUser"
+	UserService_CreateGroup_FullMethodName     = "/authd.UserService/CreateGroup"
+	UserService_DeleteGroup_FullMethodName     = "/authd.UserService/DeleteGroup"
+	UserService_GetGroupByName_FullMethodName  = "/authd.UserService/GetGroupByName"
+	UserService_GetGroupByID_FullMethodName    = "/authd.UserService/GetGroupByID"
+	UserService_ListGroups_FullMethodName      = "/authd.UserService/ListGroups"
+	UserService_GetDaemonStatus_FullMethodName = "/authd.UserService/GetDaemonStatus"
 )
 
 // UserServiceClient is the client API for UserService service.
@@ -406,13 +413,20 @@ type UserServiceClient interface {
 	GetUserByName(ctx context.Context, in *GetUserByNameRequest, opts ...grpc.CallOption) (*User, error)
 	GetUserByID(ctx context.Context, in *GetUserByIDRequest, opts ...grpc.CallOption) (*User, error)
 	ListUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Users, error)
-	LockUser(ctx context.Context, in *LockUserRequest, opts ...grpc.CallOption) (*Empty, error)
-	UnlockUser(ctx context.Context, in *UnlockUserRequest, opts ...grpc.CallOption) (*Empty, error)
+	StreamUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[User], error)
+	LockUser(ctx context.Context, in *LockUserRequest, opts ...grpc.CallOption) (*LockUserResponse, error)
+	UnlockUser(ctx context.Context, in *UnlockUserRequest, opts ...grpc.CallOption) (*UnlockUserResponse, error)
 	SetUserID(ctx context.Context, in *SetUserIDRequest, opts ...grpc.CallOption) (*SetUserIDResponse, error)
+	SetUserShell(ctx context.Context, in *SetUserShellRequest, opts ...grpc.CallOption) (*Empty, error)
+	SetUserHome(ctx context.Context, in *SetUserHomeRequest, opts ...grpc.CallOption) (*SetUserHomeResponse, error)
 	SetGroupID(ctx context.Context, in *SetGroupIDRequest, opts ...grpc.CallOption) (*SetGroupIDResponse, error)
+	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error)
+	CreateGroup(ctx context.Context, in *CreateGroupRequest, opts ...grpc.CallOption) (*CreateGroupResponse, error)
+	DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*Empty, error)
 	GetGroupByName(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (*Group, error)
 	GetGroupByID(ctx context.Context, in *GetGroupByIDRequest, opts ...grpc.CallOption) (*Group, error)
 	ListGroups(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Groups, error)
+	GetDaemonStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DaemonStatus, error)
 }
 
 type userServiceClient struct {
@@ -453,9 +467,28 @@ func (c *userServiceClient) ListUsers(ctx context.Context, in *Empty, opts ...gr
 	return out, nil
 }
 
-func (c *userServiceClient) LockUser(ctx context.Context, in *LockUserRequest, opts ...grpc.CallOption) (*Empty, error) {
+func (c *userServiceClient) StreamUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[User], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Empty)
+	stream, err := c.cc.NewStream(ctx, &UserService_ServiceDesc.Streams[0], UserService_StreamUsers_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Empty, User]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserService_StreamUsersClient = grpc.ServerStreamingClient[User]
+
+func (c *userServiceClient) LockUser(ctx context.Context, in *LockUserRequest, opts ...grpc.CallOption) (*LockUserResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LockUserResponse)
 	err := c.cc.Invoke(ctx, UserService_LockUser_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -463,9 +496,9 @@ func (c *userServiceClient) LockUser(ctx context.Context, in *LockUserRequest, o
 	return out, nil
 }
 
-func (c *userServiceClient) UnlockUser(ctx context.Context, in *UnlockUserRequest, opts ...grpc.CallOption) (*Empty, error) {
+func (c *userServiceClient) UnlockUser(ctx context.Context, in *UnlockUserRequest, opts ...grpc.CallOption) (*UnlockUserResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Empty)
+	out := new(UnlockUserResponse)
 	err := c.cc.Invoke(ctx, UserService_UnlockUser_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
@@ -483,6 +516,26 @@ func (c *userServiceClient) SetUserID(ctx context.Context, in *SetUserIDRequest,
 	return out, nil
 }
 
+func (c *userServiceClient) SetUserShell(ctx context.Context, in *SetUserShellRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_SetUserShell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) SetUserHome(ctx context.Context, in *SetUserHomeRequest, opts ...grpc.CallOption) (*SetUserHomeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetUserHomeResponse)
+	err := c.cc.Invoke(ctx, UserService_SetUserHome_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userServiceClient) SetGroupID(ctx context.Context, in *SetGroupIDRequest, opts ...grpc.CallOption) (*SetGroupIDResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(SetGroupIDResponse)
@@ -493,6 +546,36 @@ func (c *userServiceClient) SetGroupID(ctx context.Context, in *SetGroupIDReques
 	return out, nil
 }
 
+func (c *userServiceClient) DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteUserResponse)
+	err := c.cc.Invoke(ctx, UserService_DeleteUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) CreateGroup(ctx context.Context, in *CreateGroupRequest, opts ...grpc.CallOption) (*CreateGroupResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateGroupResponse)
+	err := c.cc.Invoke(ctx, UserService_CreateGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userServiceClient) DeleteGroup(ctx context.Context, in *DeleteGroupRequest, opts ...grpc.CallOption) (*Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, UserService_DeleteGroup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *userServiceClient) GetGroupByName(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (*Group, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Group)
@@ -523,6 +606,16 @@ func (c *userServiceClient) ListGroups(ctx context.Context, in *Empty, opts ...g
 	return out, nil
 }
 
+func (c *userServiceClient) GetDaemonStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DaemonStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DaemonStatus)
+	err := c.cc.Invoke(ctx, UserService_GetDaemonStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServiceServer is the server API for UserService service.
 // All implementations must embed UnimplementedUserServiceServer
 // for forward compatibility.
@@ -530,13 +623,20 @@ type UserServiceServer interface {
 	GetUserByName(context.Context, *GetUserByNameRequest) (*User, error)
 	GetUserByID(context.Context, *GetUserByIDRequest) (*User, error)
 	ListUsers(context.Context, *Empty) (*Users, error)
-	LockUser(context.Context, *LockUserRequest) (*Empty, error)
-	UnlockUser(context.Context, *UnlockUserRequest) (*Empty, error)
+	StreamUsers(*Empty, grpc.ServerStreamingServer[User]) error
+	LockUser(context.Context, *LockUserRequest) (*LockUserResponse, error)
+	UnlockUser(context.Context, *UnlockUserRequest) (*UnlockUserResponse, error)
 	SetUserID(context.Context, *SetUserIDRequest) (*SetUserIDResponse, error)
+	SetUserShell(context.Context, *SetUserShellRequest) (*Empty, error)
+	SetUserHome(context.Context, *SetUserHomeRequest) (*SetUserHomeResponse, error)
 	SetGroupID(context.Context, *SetGroupIDRequest) (*SetGroupIDResponse, error)
+	DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
+	CreateGroup(context.Context, *CreateGroupRequest) (*CreateGroupResponse, error)
+	DeleteGroup(context.Context, *DeleteGroupRequest) (*Empty, error)
 	GetGroupByName(context.Context, *GetGroupByNameRequest) (*Group, error)
 	GetGroupByID(context.Context, *GetGroupByIDRequest) (*Group, error)
 	ListGroups(context.Context, *Empty) (*Groups, error)
+	GetDaemonStatus(context.Context, *Empty) (*DaemonStatus, error)
 	mustEmbedUnimplementedUserServiceServer()
 }
 
@@ -556,18 +656,36 @@ func (UnimplementedUserServiceServer) GetUserByID(context.Context, *GetUserByIDR
 func (UnimplementedUserServiceServer) ListUsers(context.Context, *Empty) (*Users, error) {
 	return nil, status.Error(codes.Unimplemented, "method ListUsers not implemented")
 }
-func (UnimplementedUserServiceServer) LockUser(context.Context, *LockUserRequest) (*Empty, error) {
+func (UnimplementedUserServiceServer) StreamUsers(*Empty, grpc.ServerStreamingServer[User]) error {
+	return status.Error(codes.Unimplemented, "method StreamUsers not implemented")
+}
+func (UnimplementedUserServiceServer) LockUser(context.Context, *LockUserRequest) (*LockUserResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method LockUser not implemented")
 }
-func (UnimplementedUserServiceServer) UnlockUser(context.Context, *UnlockUserRequest) (*Empty, error) {
+func (UnimplementedUserServiceServer) UnlockUser(context.Context, *UnlockUserRequest) (*UnlockUserResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method UnlockUser not implemented")
 }
 func (UnimplementedUserServiceServer) SetUserID(context.Context, *SetUserIDRequest) (*SetUserIDResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method SetUserID not implemented")
 }
+func (UnimplementedUserServiceServer) SetUserShell(context.Context, *SetUserShellRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetUserShell not implemented")
+}
+func (UnimplementedUserServiceServer) SetUserHome(context.Context, *SetUserHomeRequest) (*SetUserHomeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetUserHome not implemented")
+}
 func (UnimplementedUserServiceServer) SetGroupID(context.Context, *SetGroupIDRequest) (*SetGroupIDResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method SetGroupID not implemented")
 }
+func (UnimplementedUserServiceServer) DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteUser not implemented")
+}
+func (UnimplementedUserServiceServer) CreateGroup(context.Context, *CreateGroupRequest) (*CreateGroupResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateGroup not implemented")
+}
+func (UnimplementedUserServiceServer) DeleteGroup(context.Context, *DeleteGroupRequest) (*Empty, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteGroup not implemented")
+}
 func (UnimplementedUserServiceServer) GetGroupByName(context.Context, *GetGroupByNameRequest) (*Group, error) {
 	return nil, status.Error(codes.Unimplemented, "method GetGroupByName not implemented")
 }
@@ -577,6 +695,9 @@ func (UnimplementedUserServiceServer) GetGroupByID(context.Context, *GetGroupByI
 func (UnimplementedUserServiceServer) ListGroups(context.Context, *Empty) (*Groups, error) {
 	return nil, status.Error(codes.Unimplemented, "method ListGroups not implemented")
 }
+func (UnimplementedUserServiceServer) GetDaemonStatus(context.Context, *Empty) (*DaemonStatus, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetDaemonStatus not implemented")
+}
 func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
 func (UnimplementedUserServiceServer) testEmbeddedByValue()                     {}
 
@@ -652,6 +773,17 @@ func _UserService_ListUsers_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_StreamUsers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserServiceServer).StreamUsers(m, &grpc.GenericServerStream[Empty, User]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserService_StreamUsersServer = grpc.ServerStreamingServer[User]
+
 func _UserService_LockUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(LockUserRequest)
 	if err := dec(in); err != nil {
@@ -706,6 +838,42 @@ func _UserService_SetUserID_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_SetUserShell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserShellRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SetUserShell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_SetUserShell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SetUserShell(ctx, req.(*SetUserShellRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_SetUserHome_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserHomeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SetUserHome(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_SetUserHome_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SetUserHome(ctx, req.(*SetUserHomeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _UserService_SetGroupID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SetGroupIDRequest)
 	if err := dec(in); err != nil {
@@ -724,6 +892,60 @@ func _UserService_SetGroupID_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_DeleteUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).DeleteUser(ctx, req.(*DeleteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_CreateGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).CreateGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_CreateGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).CreateGroup(ctx, req.(*CreateGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserService_DeleteGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).DeleteGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_DeleteGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).DeleteGroup(ctx, req.(*DeleteGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _UserService_GetGroupByName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetGroupByNameRequest)
 	if err := dec(in); err != nil {
@@ -778,6 +1000,24 @@ func _UserService_ListGroups_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_GetDaemonStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetDaemonStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserService_GetDaemonStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetDaemonStatus(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -809,10 +1049,30 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SetUserID",
 			Handler:    _UserService_SetUserID_Handler,
 		},
+		{
+			MethodName: "SetUserShell",
+			Handler:    _UserService_SetUserShell_Handler,
+		},
+		{
+			MethodName: "SetUserHome",
+			Handler:    _UserService_SetUserHome_Handler,
+		},
 		{
 			MethodName: "SetGroupID",
 			Handler:    _UserService_SetGroupID_Handler,
 		},
+		{
+			MethodName: "DeleteUser",
+			Handler:    _UserService_DeleteUser_Handler,
+		},
+		{
+			MethodName: "CreateGroup",
+			Handler:    _UserService_CreateGroup_Handler,
+		},
+		{
+			MethodName: "DeleteGroup",
+			Handler:    _UserService_DeleteGroup_Handler,
+		},
 		{
 			MethodName: "GetGroupByName",
 			Handler:    _UserService_GetGroupByName_Handler,
@@ -825,7 +1085,17 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListGroups",
 			Handler:    _UserService_ListGroups_Handler,
 		},
+		{
+			MethodName: "GetDaemonStatus",
+			Handler:    _UserService_GetDaemonStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUsers",
+			Handler:       _UserService_StreamUsers_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "authd.proto",
 }
@@ -151,6 +151,8 @@ func (s Service) SelectBroker(ctx context.Context, req *authd.SBRequest) (resp *
 		mode = auth.SessionModeLogin
 	case authd.SessionMode_CHANGE_PASSWORD:
 		mode = auth.SessionModeChangePassword
+	case authd.SessionMode_DIAGNOSTIC:
+		mode = auth.SessionModeDiagnostic
 	default:
 		log.Errorf(ctx, "SelectBroker: Invalid session mode %q", req.GetMode())
 		return nil, status.Error(codes.InvalidArgument, "invalid session mode")
@@ -294,6 +296,21 @@ func (s Service) IsAuthenticated(ctx context.Context, req *authd.IARequest) (res
 		uInfo.Groups[i].Name = strings.ToLower(g.Name)
 	}
 
+	// Sessions in diagnostic mode only check that the user can authenticate: the user info is returned to the caller
+	// instead of being stored in the database.
+	if s.brokerManager.IsDiagnosticSession(sessionID) {
+		userInfo, err := json.Marshal(uInfo)
+		if err != nil {
+			log.Errorf(ctx, "IsAuthenticated: Could not marshal user data for session %q: %v", sessionID, err)
+			return nil, err
+		}
+		log.Infof(ctx, "%s: Diagnostic authentication of user %q succeeded, not updating the database", sessionID, uInfo.Name)
+		return &authd.IAResponse{
+			Access: access,
+			Msg:    string(userInfo),
+		}, nil
+	}
+
 	// Check if the user is locked. We can only do this after the broker has granted access, because we want to avoid
 	// leaking whether a user exists or not to unauthenticated users.
 	// TODO: We might want to let the broker know whether the user is locked or not, so that it can avoid storing any
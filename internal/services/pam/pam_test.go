@@ -202,6 +202,9 @@ func TestSelectBroker(t *testing.T) {
 	}{
 		"Successfully_select_a_broker_and_creates_auth_session":   {username: "success@example.com", sessionMode: auth.SessionModeLogin},
 		"Successfully_select_a_broker_and_creates_passwd_session": {username: "success@example.com", sessionMode: auth.SessionModeChangePassword},
+		"Successfully_select_a_broker_and_creates_diagnostic_session": {
+			username: "success@example.com", sessionMode: auth.SessionModeDiagnostic,
+		},
 
 		"Error_when_not_root":                             {username: "success@example.com", currentUserNotRoot: true, wantErr: true},
 		"Error_when_username_is_empty":                    {wantErr: true},
@@ -246,6 +249,8 @@ func TestSelectBroker(t *testing.T) {
 				sessionMode = authd.SessionMode_LOGIN
 			case auth.SessionModeChangePassword:
 				sessionMode = authd.SessionMode_CHANGE_PASSWORD
+			case auth.SessionModeDiagnostic:
+				sessionMode = authd.SessionMode_DIAGNOSTIC
 			case "-":
 				sessionMode = authd.SessionMode_UNDEFINED
 			}
@@ -433,6 +438,7 @@ func TestIsAuthenticated(t *testing.T) {
 		cancelFirstCall    bool
 		localGroupsFile    string
 		currentUserNotRoot bool
+		diagnostic         bool
 
 		// There is no wantErr as it's stored in the golden file.
 	}{
@@ -443,6 +449,8 @@ func TestIsAuthenticated(t *testing.T) {
 		"Update_local_groups":                                  {username: "success_with_local_groups@example.com", localGroupsFile: "valid.group"},
 		"Successfully_authenticate_user_with_uppercase":        {username: "SUCCESS@example.com"},
 		"Successfully_authenticate_with_groups_with_uppercase": {username: "success_with_uppercase_groups@example.com"},
+		"Do_not_update_DB_in_diagnostic_session":               {username: "success@example.com", diagnostic: true},
+		"Do_not_check_lock_in_diagnostic_session":              {username: "locked@example.com", existingDB: "cache-with-locked-user.db", diagnostic: true},
 
 		// service errors
 		"Error_when_not_root":           {username: "success@example.com", currentUserNotRoot: true},
@@ -497,7 +505,11 @@ func TestIsAuthenticated(t *testing.T) {
 			case "-":
 				tc.sessionID = ""
 			default:
-				id := startSession(t, client, tc.username)
+				mode := authd.SessionMode_LOGIN
+				if tc.diagnostic {
+					mode = authd.SessionMode_DIAGNOSTIC
+				}
+				id := startSessionWithMode(t, client, tc.username, mode)
 				if tc.sessionID == "" {
 					tc.sessionID = id
 				}
@@ -836,6 +848,13 @@ func getMockBrokerGeneratedID(brokerManager *brokers.Manager) (string, error) {
 func startSession(t *testing.T, client authd.PAMClient, username string) string {
 	t.Helper()
 
+	return startSessionWithMode(t, client, username, authd.SessionMode_LOGIN)
+}
+
+// startSessionWithMode starts a session in the given mode with the mock broker.
+func startSessionWithMode(t *testing.T, client authd.PAMClient, username string, mode authd.SessionMode) string {
+	t.Helper()
+
 	if username == "" {
 		username = "user@example.com"
 	}
@@ -846,7 +865,7 @@ func startSession(t *testing.T, client authd.PAMClient, username string) string
 	sbResp, err := client.SelectBroker(context.Background(), &authd.SBRequest{
 		BrokerId: mockBrokerGeneratedID,
 		Username: username,
-		Mode:     authd.SessionMode_LOGIN,
+		Mode:     mode,
 	})
 	require.NoError(t, err, "Setup: failed to create session for tests")
 	return sbResp.GetSessionId()
@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/canonical/authd/internal/services/permissions"
 	"github.com/canonical/authd/internal/users"
@@ -23,6 +25,7 @@ type Service struct {
 	userManager       *users.Manager
 	brokerManager     *brokers.Manager
 	permissionManager *permissions.Manager
+	startTime         time.Time
 
 	authd.UnimplementedUserServiceServer
 }
@@ -35,6 +38,7 @@ func NewService(ctx context.Context, userManager *users.Manager, brokerManager *
 		userManager:       userManager,
 		brokerManager:     brokerManager,
 		permissionManager: permissionManager,
+		startTime:         time.Now(),
 	}
 }
 
@@ -49,7 +53,20 @@ func (s Service) GetUserByName(ctx context.Context, req *authd.GetUserByNameRequ
 
 	user, err := s.userManager.UserByName(name)
 	if err == nil {
-		return userToProtobuf(user), nil
+		pu := userToProtobuf(user)
+		// Whether the user is locked and which broker they use is only disclosed to root.
+		if s.permissionManager.CheckRequestIsFromRoot(ctx) != nil {
+			return pu, nil
+		}
+		if pu.Locked, err = s.userManager.IsUserLocked(name); err != nil {
+			log.Errorf(context.Background(), "GetUserByName: %v", err)
+			return nil, grpcError(err)
+		}
+		if pu.Broker, err = s.brokerNameForUser(name); err != nil {
+			log.Errorf(context.Background(), "GetUserByName: %v", err)
+			return nil, grpcError(err)
+		}
+		return pu, nil
 	}
 
 	if !errors.Is(err, users.NoDataFoundError{}) {
@@ -103,22 +120,43 @@ func (s Service) GetUserByID(ctx context.Context, req *authd.GetUserByIDRequest)
 
 // ListUsers returns all authd users.
 func (s Service) ListUsers(ctx context.Context, req *authd.Empty) (*authd.Users, error) {
-	allUsers, err := s.userManager.AllUsers()
+	var res authd.Users
+	err := s.userManager.ForEachUser(func(u types.UserEntry, locked bool) error {
+		pu := userToProtobuf(u)
+		pu.Locked = locked
+		res.Users = append(res.Users, pu)
+		return nil
+	})
 	if err != nil {
 		log.Errorf(context.Background(), "ListUsers: %v", err)
 		return nil, grpcError(err)
 	}
 
-	var res authd.Users
-	for _, u := range allUsers {
-		res.Users = append(res.Users, userToProtobuf(u))
+	return &res, nil
+}
+
+// StreamUsers sends all authd users one by one, so that large lists don't have to be held in a single message.
+func (s Service) StreamUsers(req *authd.Empty, stream authd.UserService_StreamUsersServer) error {
+	var sendErr error
+	err := s.userManager.ForEachUser(func(u types.UserEntry, locked bool) error {
+		pu := userToProtobuf(u)
+		pu.Locked = locked
+		sendErr = stream.Send(pu)
+		return sendErr
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		log.Errorf(context.Background(), "StreamUsers: %v", err)
+		return grpcError(err)
 	}
 
-	return &res, nil
+	return nil
 }
 
-// LockUser marks a user as locked.
-func (s Service) LockUser(ctx context.Context, req *authd.LockUserRequest) (*authd.Empty, error) {
+// LockUser marks a user as locked and returns whether it was already locked.
+func (s Service) LockUser(ctx context.Context, req *authd.LockUserRequest) (*authd.LockUserResponse, error) {
 	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
@@ -130,15 +168,16 @@ func (s Service) LockUser(ctx context.Context, req *authd.LockUserRequest) (*aut
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
 	}
 
-	if err := s.userManager.LockUser(name); err != nil {
+	wasLocked, err := s.userManager.LockUser(name)
+	if err != nil {
 		return nil, grpcError(err)
 	}
 
-	return &authd.Empty{}, nil
+	return &authd.LockUserResponse{WasLocked: wasLocked}, nil
 }
 
-// UnlockUser marks a user as unlocked.
-func (s Service) UnlockUser(ctx context.Context, req *authd.UnlockUserRequest) (*authd.Empty, error) {
+// UnlockUser marks a user as unlocked and returns whether it was locked before.
+func (s Service) UnlockUser(ctx context.Context, req *authd.UnlockUserRequest) (*authd.UnlockUserResponse, error) {
 	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
@@ -150,11 +189,12 @@ func (s Service) UnlockUser(ctx context.Context, req *authd.UnlockUserRequest) (
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
 	}
 
-	if err := s.userManager.UnlockUser(name); err != nil {
+	wasLocked, err := s.userManager.UnlockUser(name)
+	if err != nil {
 		return nil, grpcError(err)
 	}
 
-	return &authd.Empty{}, nil
+	return &authd.UnlockUserResponse{WasLocked: wasLocked}, nil
 }
 
 // GetGroupByName returns the group entry for the given group name.
@@ -244,6 +284,60 @@ func (s Service) SetUserID(ctx context.Context, req *authd.SetUserIDRequest) (*a
 	}, nil
 }
 
+// SetUserShell sets the login shell of a user.
+func (s Service) SetUserShell(ctx context.Context, req *authd.SetUserShellRequest) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses lowercase usernames.
+	name := strings.ToLower(req.GetName())
+
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	if req.GetShell() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no shell provided")
+	}
+
+	if err := s.userManager.SetUserShell(name, req.GetShell(), req.GetForce()); err != nil {
+		log.Errorf(ctx, "SetUserShell: %v", err)
+		return nil, grpcError(err)
+	}
+
+	return &authd.Empty{}, nil
+}
+
+// SetUserHome sets the home directory of a user.
+func (s Service) SetUserHome(ctx context.Context, req *authd.SetUserHomeRequest) (*authd.SetUserHomeResponse, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses lowercase usernames.
+	name := strings.ToLower(req.GetName())
+
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	if req.GetHome() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no home directory provided")
+	}
+
+	resp, err := s.userManager.SetUserHome(name, req.GetHome(), req.GetMove())
+	if err != nil {
+		log.Errorf(ctx, "SetUserHome: %v", err)
+		return nil, grpcError(err)
+	}
+
+	return &authd.SetUserHomeResponse{
+		HomeDirMoved: resp.HomeDirMoved,
+		Warnings:     resp.Warnings,
+	}, nil
+}
+
 // SetGroupID sets the GID of a group.
 func (s Service) SetGroupID(ctx context.Context, req *authd.SetGroupIDRequest) (*authd.SetGroupIDResponse, error) {
 	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
@@ -270,6 +364,126 @@ func (s Service) SetGroupID(ctx context.Context, req *authd.SetGroupIDRequest) (
 	}, nil
 }
 
+// DeleteUser deletes a user and, if requested, their home directory.
+func (s Service) DeleteUser(ctx context.Context, req *authd.DeleteUserRequest) (*authd.DeleteUserResponse, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses lowercase usernames.
+	name := strings.ToLower(req.GetName())
+
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no user name provided")
+	}
+
+	resp, err := s.userManager.DeleteUser(name, req.GetRemoveHome())
+	if err != nil {
+		log.Errorf(ctx, "DeleteUser: %v", err)
+		return nil, grpcError(err)
+	}
+
+	return &authd.DeleteUserResponse{
+		HomeDirRemoved: resp.HomeDirRemoved,
+		Warnings:       resp.Warnings,
+	}, nil
+}
+
+// CreateGroup creates a new group with the given name and, if specified, GID.
+func (s Service) CreateGroup(ctx context.Context, req *authd.CreateGroupRequest) (*authd.CreateGroupResponse, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses lowercase group names.
+	name := strings.ToLower(req.GetName())
+
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no group name provided")
+	}
+
+	gid, err := s.userManager.CreateGroup(name, req.GetGid())
+	if err != nil {
+		log.Errorf(ctx, "CreateGroup: %v", err)
+		return nil, grpcError(err)
+	}
+
+	return &authd.CreateGroupResponse{Gid: gid}, nil
+}
+
+// DeleteGroup deletes a group.
+func (s Service) DeleteGroup(ctx context.Context, req *authd.DeleteGroupRequest) (*authd.Empty, error) {
+	if err := s.permissionManager.CheckRequestIsFromRoot(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// authd uses lowercase group names.
+	name := strings.ToLower(req.GetName())
+
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "no group name provided")
+	}
+
+	if err := s.userManager.DeleteGroup(name, req.GetForce()); err != nil {
+		log.Errorf(ctx, "DeleteGroup: %v", err)
+		return nil, grpcError(err)
+	}
+
+	return &authd.Empty{}, nil
+}
+
+// GetDaemonStatus returns the version and uptime of the daemon, the number of users it manages, the names of the
+// available brokers and the name of the default one.
+func (s Service) GetDaemonStatus(ctx context.Context, req *authd.Empty) (*authd.DaemonStatus, error) {
+	allUsers, err := s.userManager.AllUsers()
+	if err != nil {
+		log.Errorf(context.Background(), "GetDaemonStatus: %v", err)
+		return nil, grpcError(err)
+	}
+
+	var brokerNames []string
+	var defaultBroker, localBroker string
+	for _, b := range s.brokerManager.AvailableBrokers() {
+		brokerNames = append(brokerNames, b.Name)
+		if b.ID == brokers.LocalBrokerName {
+			localBroker = b.Name
+			continue
+		}
+		if defaultBroker == "" {
+			defaultBroker = b.Name
+		}
+	}
+	// The local broker is only the default one if no other broker is configured.
+	if defaultBroker == "" {
+		defaultBroker = localBroker
+	}
+
+	return &authd.DaemonStatus{
+		Version:       consts.Version,
+		UptimeSeconds: int64(time.Since(s.startTime).Seconds()),
+		//nolint:gosec // G115 we won't ever have more than MaxUint32 users.
+		NumUsers:      uint32(len(allUsers)),
+		Brokers:       brokerNames,
+		DefaultBroker: defaultBroker,
+	}, nil
+}
+
+// brokerNameForUser returns the name of the broker the user is bound to. If that broker is not available anymore, its
+// ID is returned instead.
+func (s Service) brokerNameForUser(name string) (string, error) {
+	brokerID, err := s.userManager.BrokerForUser(name)
+	if err != nil {
+		return "", err
+	}
+
+	for _, b := range s.brokerManager.AvailableBrokers() {
+		if b.ID == brokerID {
+			return b.Name, nil
+		}
+	}
+	return brokerID, nil
+}
+
 // userToProtobuf converts a types.UserEntry to authd.User.
 func userToProtobuf(u types.UserEntry) *authd.User {
 	return &authd.User{
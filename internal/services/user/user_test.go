@@ -2,7 +2,9 @@ package user_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
@@ -10,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/canonical/authd/internal/brokers"
+	"github.com/canonical/authd/internal/consts"
 	"github.com/canonical/authd/internal/proto/authd"
 	"github.com/canonical/authd/internal/services/errmessages"
 	"github.com/canonical/authd/internal/services/permissions"
@@ -48,15 +51,20 @@ func TestGetUserByName(t *testing.T) {
 	tests := map[string]struct {
 		username string
 
-		dbFile         string
-		shouldPreCheck bool
-		closeDB        bool
+		dbFile             string
+		shouldPreCheck     bool
+		closeDB            bool
+		currentUserNotRoot bool
 
 		wantErr          bool
 		wantErrNotExists bool
 	}{
 		"Return_existing_user":                {username: "user1@example.com"},
 		"Return_existing_user_with_uppercase": {username: "user1@example.com"},
+		"Return_locked_user":                  {username: "user1@example.com", dbFile: "locked-user.db.yaml"},
+		"Return_locked_user_without_lock_state_and_broker_if_not_root": {
+			username: "user1@example.com", dbFile: "locked-user.db.yaml", currentUserNotRoot: true,
+		},
 
 		"Precheck_user_if_not_in_db": {username: "user-pre-check@example.com", shouldPreCheck: true},
 		"Prechecked_user_with_upper_cases_in_username_has_same_id_as_lower_case": {username: "User-Pre-Check@Example.com", shouldPreCheck: true},
@@ -75,7 +83,7 @@ func TestGetUserByName(t *testing.T) {
 				userslocking.Z_ForTests_OverrideLockingWithCleanup(t)
 			}
 
-			client, m := newUserServiceClient(t, tc.dbFile)
+			client, m := newUserServiceClient(t, tc.dbFile, tc.currentUserNotRoot)
 
 			if tc.closeDB {
 				// Close the database to trigger a database error
@@ -212,9 +220,10 @@ func TestListUsers(t *testing.T) {
 
 		wantErr bool
 	}{
-		"Return_all_users":        {},
-		"Return_no_users":         {dbFile: "empty.db.yaml"},
-		"Error_on_database_error": {closeDB: true, wantErr: true},
+		"Return_all_users":                {},
+		"Return_no_users":                 {dbFile: "empty.db.yaml"},
+		"Return_users_with_locked_status": {dbFile: "locked-user.db.yaml"},
+		"Error_on_database_error":         {closeDB: true, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -236,6 +245,95 @@ func TestListUsers(t *testing.T) {
 	}
 }
 
+func TestStreamUsers(t *testing.T) {
+	tests := map[string]struct {
+		dbFile  string
+		closeDB bool
+
+		wantErr bool
+	}{
+		"Return_all_users":                {},
+		"Return_no_users":                 {dbFile: "empty.db.yaml"},
+		"Return_users_with_locked_status": {dbFile: "locked-user.db.yaml"},
+		"Error_on_database_error":         {closeDB: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.dbFile == "" {
+				tc.dbFile = "default.db.yaml"
+			}
+
+			client, m := newUserServiceClient(t, tc.dbFile)
+
+			if tc.closeDB {
+				// Close the database to trigger a database error
+				err := userstestutils.DBManager(m).Close()
+				require.NoError(t, err, "Setup: failed to close database")
+			}
+
+			stream, err := client.StreamUsers(context.Background(), &authd.Empty{})
+			require.NoError(t, err, "StreamUsers should not fail to open the stream")
+
+			var users []*authd.User
+			for {
+				u, recvErr := stream.Recv()
+				if errors.Is(recvErr, io.EOF) {
+					break
+				}
+				if recvErr != nil {
+					err = recvErr
+					break
+				}
+				users = append(users, u)
+			}
+			requireExpectedListResult(t, "StreamUsers", users, err, tc.wantErr)
+		})
+	}
+}
+
+func TestGetDaemonStatus(t *testing.T) {
+	tests := map[string]struct {
+		dbFile  string
+		closeDB bool
+
+		wantNumUsers uint32
+		wantErr      bool
+	}{
+		"Return_daemon_status":               {wantNumUsers: 3},
+		"Return_daemon_status_without_users": {dbFile: "empty.db.yaml"},
+		"Error_on_database_error":            {closeDB: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.dbFile == "" {
+				tc.dbFile = "default.db.yaml"
+			}
+
+			client, m := newUserServiceClient(t, tc.dbFile)
+
+			if tc.closeDB {
+				// Close the database to trigger a database error
+				err := userstestutils.DBManager(m).Close()
+				require.NoError(t, err, "Setup: failed to close database")
+			}
+
+			got, err := client.GetDaemonStatus(context.Background(), &authd.Empty{})
+			if tc.wantErr {
+				require.Error(t, err, "GetDaemonStatus should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "GetDaemonStatus should not return an error, but did")
+
+			require.Equal(t, consts.Version, got.GetVersion(), "Version should be the one of the daemon")
+			require.GreaterOrEqual(t, got.GetUptimeSeconds(), int64(0), "Uptime should not be negative")
+			require.Equal(t, tc.wantNumUsers, got.GetNumUsers(), "Number of users should match the database")
+			require.Contains(t, got.GetBrokers(), brokers.LocalBrokerName, "Brokers should include the local broker")
+			require.Len(t, got.GetBrokers(), 2, "Setup: there should be the local broker and a configured one")
+			require.Equal(t, got.GetBrokers()[1], got.GetDefaultBroker(), "Default broker should be the configured broker")
+		})
+	}
+}
+
 func TestListGroups(t *testing.T) {
 	tests := map[string]struct {
 		dbFile  string
@@ -282,10 +380,12 @@ func TestLockUser(t *testing.T) {
 		username           string
 		currentUserNotRoot bool
 
-		wantErr bool
+		wantWasLocked bool
+		wantErr       bool
 	}{
 		"Successfully_lock_user":                {username: "user1@example.com"},
 		"Successfully_lock_user_with_uppercase": {username: "user1@example.com"},
+		"Successfully_lock_already_locked_user": {sourceDB: "locked-user.db.yaml", username: "user1@example.com", wantWasLocked: true},
 
 		"Error_when_username_is_empty":   {wantErr: true},
 		"Error_when_user_does_not_exist": {username: "doesnotexist@example.com", wantErr: true},
@@ -295,12 +395,13 @@ func TestLockUser(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			client, m := newUserServiceClient(t, tc.sourceDB, tc.currentUserNotRoot)
 
-			_, err := client.LockUser(context.Background(), &authd.LockUserRequest{Name: tc.username})
+			resp, err := client.LockUser(context.Background(), &authd.LockUserRequest{Name: tc.username})
 			if tc.wantErr {
 				require.Error(t, err, "LockUser should return an error, but did not")
 				return
 			}
 			require.NoError(t, err, "LockUser should not return an error, but did")
+			require.Equal(t, tc.wantWasLocked, resp.WasLocked, "LockUser should return the previous lock state")
 
 			dbContent, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
 			require.NoError(t, err, "Setup: failed to dump database for comparing")
@@ -316,10 +417,12 @@ func TestUnlockUser(t *testing.T) {
 		username           string
 		currentUserNotRoot bool
 
-		wantErr bool
+		wantWasLocked bool
+		wantErr       bool
 	}{
-		"Successfully_unlock_user":                {username: "user1@example.com"},
-		"Successfully_unlock_user_with_uppercase": {username: "user1@example.com"},
+		"Successfully_unlock_user":                  {username: "user1@example.com", wantWasLocked: true},
+		"Successfully_unlock_user_with_uppercase":   {username: "user1@example.com", wantWasLocked: true},
+		"Successfully_unlock_already_unlocked_user": {sourceDB: "default.db.yaml", username: "user1@example.com"},
 
 		"Error_when_username_is_empty":   {wantErr: true},
 		"Error_when_user_does_not_exist": {username: "doesnotexist@example.com", wantErr: true},
@@ -333,12 +436,13 @@ func TestUnlockUser(t *testing.T) {
 
 			client, m := newUserServiceClient(t, tc.sourceDB, tc.currentUserNotRoot)
 
-			_, err := client.UnlockUser(context.Background(), &authd.UnlockUserRequest{Name: tc.username})
+			resp, err := client.UnlockUser(context.Background(), &authd.UnlockUserRequest{Name: tc.username})
 			if tc.wantErr {
 				require.Error(t, err, "UnlockUser should return an error, but did not")
 				return
 			}
 			require.NoError(t, err, "UnlockUser should not return an error, but did")
+			require.Equal(t, tc.wantWasLocked, resp.WasLocked, "UnlockUser should return the previous lock state")
 
 			dbContent, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
 			require.NoError(t, err, "Setup: failed to dump database for comparing")
@@ -385,6 +489,80 @@ func TestSetUserID(t *testing.T) {
 	}
 }
 
+func TestSetUserShell(t *testing.T) {
+	tests := map[string]struct {
+		sourceDB string
+
+		username           string
+		shell              string
+		currentUserNotRoot bool
+
+		wantErr bool
+	}{
+		"Successfully_set_user_shell":                {username: "user1@example.com", shell: "/bin/sh"},
+		"Successfully_set_user_shell_with_uppercase": {username: "USER1@EXAMPLE.COM", shell: "/bin/sh"},
+
+		"Error_when_username_is_empty":   {shell: "/bin/sh", wantErr: true},
+		"Error_when_shell_is_empty":      {username: "user1@example.com", wantErr: true},
+		"Error_when_user_does_not_exist": {username: "doesnotexist@example.com", shell: "/bin/sh", wantErr: true},
+		"Error_when_shell_is_invalid":    {username: "user1@example.com", shell: "/non-existent/shell", wantErr: true},
+		"Error_when_not_root":            {username: "user1@example.com", shell: "/bin/sh", currentUserNotRoot: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newUserServiceClient(t, tc.sourceDB, tc.currentUserNotRoot)
+
+			_, err := client.SetUserShell(context.Background(), &authd.SetUserShellRequest{Name: tc.username, Shell: tc.shell})
+			if tc.wantErr {
+				require.Error(t, err, "SetUserShell should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "SetUserShell should not return an error, but did")
+
+			user, err := client.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: tc.username})
+			require.NoError(t, err, "GetUserByName should not return an error")
+			require.Equal(t, tc.shell, user.Shell, "Shell should have been updated")
+		})
+	}
+}
+
+func TestSetUserHome(t *testing.T) {
+	tests := map[string]struct {
+		sourceDB string
+
+		username           string
+		home               string
+		currentUserNotRoot bool
+
+		wantErr bool
+	}{
+		"Successfully_set_user_home":                {username: "user1@example.com", home: "/home/new-user1"},
+		"Successfully_set_user_home_with_uppercase": {username: "USER1@EXAMPLE.COM", home: "/home/new-user1"},
+
+		"Error_when_username_is_empty":              {home: "/home/new-user1", wantErr: true},
+		"Error_when_home_is_empty":                  {username: "user1@example.com", wantErr: true},
+		"Error_when_user_does_not_exist":            {username: "doesnotexist@example.com", home: "/home/new-user1", wantErr: true},
+		"Error_when_home_is_not_under_allowed_base": {username: "user1@example.com", home: "/srv/new-user1", wantErr: true},
+		"Error_when_not_root":                       {username: "user1@example.com", home: "/home/new-user1", currentUserNotRoot: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newUserServiceClient(t, tc.sourceDB, tc.currentUserNotRoot)
+
+			_, err := client.SetUserHome(context.Background(), &authd.SetUserHomeRequest{Name: tc.username, Home: tc.home})
+			if tc.wantErr {
+				require.Error(t, err, "SetUserHome should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "SetUserHome should not return an error, but did")
+
+			user, err := client.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: tc.username})
+			require.NoError(t, err, "GetUserByName should not return an error")
+			require.Equal(t, tc.home, user.Homedir, "Home directory should have been updated")
+		})
+	}
+}
+
 //nolint:dupl // This is not a duplicate test
 func TestSetGroupID(t *testing.T) {
 	tests := map[string]struct {
@@ -423,6 +601,122 @@ func TestSetGroupID(t *testing.T) {
 	}
 }
 
+func TestDeleteUser(t *testing.T) {
+	tests := map[string]struct {
+		sourceDB string
+
+		username           string
+		currentUserNotRoot bool
+
+		wantErr bool
+	}{
+		"Successfully_delete_user":                {username: "user1@example.com"},
+		"Successfully_delete_user_with_uppercase": {username: "USER1@EXAMPLE.COM"},
+
+		"Error_when_username_is_empty":   {wantErr: true},
+		"Error_when_user_does_not_exist": {username: "doesnotexist@example.com", wantErr: true},
+		"Error_when_not_root":            {username: "user1@example.com", currentUserNotRoot: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if !tc.wantErr {
+				userslocking.Z_ForTests_OverrideLockingWithCleanup(t)
+			}
+
+			client, _ := newUserServiceClient(t, tc.sourceDB, tc.currentUserNotRoot)
+
+			resp, err := client.DeleteUser(context.Background(), &authd.DeleteUserRequest{Name: tc.username})
+			if tc.wantErr {
+				require.Error(t, err, "DeleteUser should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "DeleteUser should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, resp)
+
+			_, err = client.GetUserByName(context.Background(), &authd.GetUserByNameRequest{Name: tc.username})
+			require.Error(t, err, "GetUserByName should return an error for the deleted user")
+		})
+	}
+}
+
+func TestCreateGroup(t *testing.T) {
+	tests := map[string]struct {
+		sourceDB string
+
+		groupname          string
+		gid                uint32
+		currentUserNotRoot bool
+
+		wantErr bool
+	}{
+		"Successfully_create_group":                {groupname: "newgroup", gid: 16666},
+		"Successfully_create_group_with_uppercase": {groupname: "NEWGROUP", gid: 16666},
+
+		"Error_when_groupname_is_empty":   {wantErr: true},
+		"Error_when_group_already_exists": {groupname: "group1", gid: 16666, wantErr: true},
+		"Error_when_gid_is_already_taken": {groupname: "newgroup", gid: 11111, wantErr: true},
+		"Error_when_not_root":             {groupname: "newgroup", gid: 16666, currentUserNotRoot: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if !tc.wantErr {
+				userslocking.Z_ForTests_OverrideLockingWithCleanup(t)
+			}
+
+			client, _ := newUserServiceClient(t, tc.sourceDB, tc.currentUserNotRoot)
+
+			resp, err := client.CreateGroup(context.Background(), &authd.CreateGroupRequest{Name: tc.groupname, Gid: tc.gid})
+			if tc.wantErr {
+				require.Error(t, err, "CreateGroup should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "CreateGroup should not return an error, but did")
+
+			golden.CheckOrUpdateYAML(t, resp)
+
+			_, err = client.GetGroupByName(context.Background(), &authd.GetGroupByNameRequest{Name: tc.groupname})
+			require.NoError(t, err, "GetGroupByName should not return an error for the created group")
+		})
+	}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	tests := map[string]struct {
+		sourceDB string
+
+		groupname          string
+		force              bool
+		currentUserNotRoot bool
+
+		wantErr bool
+	}{
+		"Successfully_delete_group":                {groupname: "commongroup", force: true},
+		"Successfully_delete_group_with_uppercase": {groupname: "COMMONGROUP", force: true},
+
+		"Error_when_groupname_is_empty":     {wantErr: true},
+		"Error_when_group_does_not_exist":   {groupname: "doesnotexist", wantErr: true},
+		"Error_when_group_has_members":      {groupname: "commongroup", wantErr: true},
+		"Error_when_group_is_primary_group": {groupname: "group1", force: true, wantErr: true},
+		"Error_when_not_root":               {groupname: "commongroup", force: true, currentUserNotRoot: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newUserServiceClient(t, tc.sourceDB, tc.currentUserNotRoot)
+
+			_, err := client.DeleteGroup(context.Background(), &authd.DeleteGroupRequest{Name: tc.groupname, Force: tc.force})
+			if tc.wantErr {
+				require.Error(t, err, "DeleteGroup should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "DeleteGroup should not return an error, but did")
+
+			_, err = client.GetGroupByName(context.Background(), &authd.GetGroupByNameRequest{Name: tc.groupname})
+			require.Error(t, err, "GetGroupByName should return an error for the deleted group")
+		})
+	}
+}
+
 // newUserServiceClient returns a new gRPC client for the CLI service.
 func newUserServiceClient(t *testing.T, dbFile string, currentUserNotRoot ...bool) (client authd.UserServiceClient, userManager *users.Manager) {
 	t.Helper()
@@ -295,6 +295,13 @@ func (b *BrokerBusMock) IsAuthenticated(sessionID, authenticationData string) (a
 	case "ia_invalid_userinfo":
 		data = `{"userinfo": "not valid"}`
 
+	case "ia_info_with_claims":
+		data = fmt.Sprintf(`{"userinfo": %s, "claims": {"sub": "sub-%s", "groups": ["group1", "group2"]}}`,
+			userInfoFromName(sessionID, nil), parsedID)
+
+	case "ia_invalid_claims":
+		data = fmt.Sprintf(`{"userinfo": %s, "claims": "not valid"}`, userInfoFromName(sessionID, nil))
+
 	case "ia_denied_without_data":
 		access = authDenied
 		data = ""
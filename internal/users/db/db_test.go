@@ -689,6 +689,40 @@ func TestAllUsers(t *testing.T) {
 	}
 }
 
+func TestUsersPage(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile   string
+		afterUID uint32
+		limit    int
+
+		wantUIDs []uint32
+	}{
+		"Get_first_page":              {dbFile: "multiple_users_and_groups", limit: 2, wantUIDs: []uint32{1111, 2222}},
+		"Get_page_after_UID":          {dbFile: "multiple_users_and_groups", afterUID: 2222, limit: 2, wantUIDs: []uint32{3333, 4444}},
+		"Get_last_page_partially":     {dbFile: "multiple_users_and_groups", afterUID: 3333, limit: 5, wantUIDs: []uint32{4444}},
+		"Get_no_users_after_last_UID": {dbFile: "multiple_users_and_groups", afterUID: 4444, limit: 2},
+		"Get_no_users_from_empty_db":  {dbFile: "empty", limit: 2},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, tc.dbFile)
+
+			got, err := c.UsersPage(tc.afterUID, tc.limit)
+			require.NoError(t, err, "UsersPage should not return an error")
+
+			var gotUIDs []uint32
+			for _, u := range got {
+				gotUIDs = append(gotUIDs, u.UID)
+			}
+			require.Equal(t, tc.wantUIDs, gotUIDs, "UsersPage should return the expected users")
+		})
+	}
+}
+
 func TestGroupByID(t *testing.T) {
 	t.Parallel()
 
@@ -858,14 +892,56 @@ func TestUpdateLockedFieldForUser(t *testing.T) {
 	c := initDB(t, "one_user_and_group")
 
 	// Update broker for existent user
-	err := c.UpdateLockedFieldForUser("user1", true)
+	wasLocked, err := c.UpdateLockedFieldForUser("user1", true)
+	require.NoError(t, err, "UpdateLockedFieldForUser for an existent user should not return an error")
+	require.False(t, wasLocked, "UpdateLockedFieldForUser should return that the user was not locked")
+
+	// The previous value is returned when the user is already locked
+	wasLocked, err = c.UpdateLockedFieldForUser("user1", false)
 	require.NoError(t, err, "UpdateLockedFieldForUser for an existent user should not return an error")
+	require.True(t, wasLocked, "UpdateLockedFieldForUser should return that the user was locked")
 
 	// Error when updating broker for nonexistent user
-	err = c.UpdateLockedFieldForUser("nonexistent", false)
+	_, err = c.UpdateLockedFieldForUser("nonexistent", false)
 	require.Error(t, err, "UpdateLockedFieldForUser for a nonexistent user should return an error")
 }
 
+func TestUpdateShellForUser(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "one_user_and_group")
+
+	// Update shell for existent user
+	err := c.UpdateShellForUser("user1", "/bin/zsh")
+	require.NoError(t, err, "UpdateShellForUser for an existent user should not return an error")
+
+	u, err := c.UserByName("user1")
+	require.NoError(t, err, "UserByName should not return an error")
+	require.Equal(t, "/bin/zsh", u.Shell, "Shell should have been updated")
+
+	// Error when updating shell for nonexistent user
+	err = c.UpdateShellForUser("nonexistent", "/bin/zsh")
+	require.Error(t, err, "UpdateShellForUser for a nonexistent user should return an error")
+}
+
+func TestUpdateHomeForUser(t *testing.T) {
+	t.Parallel()
+
+	c := initDB(t, "one_user_and_group")
+
+	// Update home directory for existent user
+	err := c.UpdateHomeForUser("user1", "/home/new-user1")
+	require.NoError(t, err, "UpdateHomeForUser for an existent user should not return an error")
+
+	u, err := c.UserByName("user1")
+	require.NoError(t, err, "UserByName should not return an error")
+	require.Equal(t, "/home/new-user1", u.Dir, "Home directory should have been updated")
+
+	// Error when updating home directory for nonexistent user
+	err = c.UpdateHomeForUser("nonexistent", "/home/new-user1")
+	require.Error(t, err, "UpdateHomeForUser for a nonexistent user should return an error")
+}
+
 func TestSetUserID(t *testing.T) {
 	t.Parallel()
 
@@ -1066,6 +1142,72 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+func TestAddGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		group db.GroupRow
+
+		wantErr bool
+	}{
+		"Successfully_add_group": {group: db.NewGroupRow("newgroup", 12345, "newgroup")},
+
+		"Error_if_name_already_exists": {group: db.NewGroupRow("group1", 12345, "newgroup"), wantErr: true},
+		"Error_if_GID_already_exists":  {group: db.NewGroupRow("newgroup", 11111, "newgroup"), wantErr: true},
+		"Error_if_UGID_already_exists": {group: db.NewGroupRow("newgroup", 12345, "12345678"), wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, "multiple_users_and_groups")
+
+			err := c.AddGroup(tc.group)
+			if tc.wantErr {
+				require.Error(t, err, "AddGroup should return an error but didn't")
+				return
+			}
+			require.NoError(t, err, "AddGroup should not return an error")
+
+			got, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErrType error
+	}{
+		"Deleting_group_removes_its_memberships": {dbFile: "multiple_users_and_groups"},
+
+		"Error_on_missing_group": {wantErrType: db.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initDB(t, tc.dbFile)
+
+			err := c.DeleteGroup(99999)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "DeleteGroup should return expected error")
+				return
+			}
+			require.NoError(t, err, "DeleteGroup should not return an error")
+
+			got, err := db.Z_ForTests_DumpNormalizedYAML(c)
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
 // TestBackwardCompatibilityAndMigrations covers loading legacy schemas (e.g., v2 with INT ugid)
 // and migrating older schemas (e.g., v1 without 'locked' column) to the latest schema.
 func TestBackwardCompatibilityAndMigrations(t *testing.T) {
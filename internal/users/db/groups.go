@@ -260,3 +260,41 @@ func updateGroupByID(db queryable, g GroupRow) error {
 
 	return nil
 }
+
+// AddGroup adds a new group to the database.
+// It returns an error if a group with the same name, GID or UGID already exists.
+func (m *Manager) AddGroup(g GroupRow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exists, err := groupExists(m.db, g)
+	if err != nil {
+		return fmt.Errorf("failed to check if group exists: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("a group with name %q, GID %d or UGID %q already exists", g.Name, g.GID, g.UGID)
+	}
+
+	return insertGroup(m.db, g)
+}
+
+// DeleteGroup removes the group from the database.
+func (m *Manager) DeleteGroup(gid uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `DELETE FROM groups WHERE gid = ?`
+	res, err := m.db.Exec(query, gid)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return NewGIDNotFoundError(gid)
+	}
+
+	return nil
+}
@@ -182,15 +182,62 @@ func (m *Manager) UpdateBrokerForUser(username, brokerID string) error {
 	return nil
 }
 
-// UpdateLockedFieldForUser sets the "locked" field of a user record.
-func (m *Manager) UpdateLockedFieldForUser(username string, locked bool) error {
+// UpdateLockedFieldForUser sets the "locked" field of a user record and returns its previous value.
+func (m *Manager) UpdateLockedFieldForUser(username string, locked bool) (wasLocked bool, err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	u, err := userByName(m.db, username)
+	if err != nil {
+		return false, err
+	}
+
 	query := `UPDATE users SET locked = ? WHERE name = ?`
 	res, err := m.db.Exec(query, locked, username)
 	if err != nil {
-		return fmt.Errorf("failed to update locked field for user: %w", err)
+		return false, fmt.Errorf("failed to update locked field for user: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return false, NewUserNotFoundError(username)
+	}
+
+	return u.Locked, nil
+}
+
+// UpdateShellForUser sets the login shell of a user.
+func (m *Manager) UpdateShellForUser(username, shell string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `UPDATE users SET shell = ? WHERE name = ?`
+	res, err := m.db.Exec(query, shell, username)
+	if err != nil {
+		return fmt.Errorf("failed to update shell for user: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return NewUserNotFoundError(username)
+	}
+
+	return nil
+}
+
+// UpdateHomeForUser sets the home directory of a user.
+func (m *Manager) UpdateHomeForUser(username, home string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	query := `UPDATE users SET dir = ? WHERE name = ?`
+	res, err := m.db.Exec(query, home, username)
+	if err != nil {
+		return fmt.Errorf("failed to update home directory for user: %w", err)
 	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
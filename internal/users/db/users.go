@@ -89,7 +89,17 @@ func (m *Manager) AllUsers() ([]UserRow, error) {
 
 func allUsers(db queryable) ([]UserRow, error) {
 	query := fmt.Sprintf(`SELECT %s FROM users`, allUserColumns)
-	rows, err := db.Query(query)
+	return queryUsers(db, query)
+}
+
+// UsersPage returns at most limit users with a UID greater than afterUID, ordered by UID.
+func (m *Manager) UsersPage(afterUID uint32, limit int) ([]UserRow, error) {
+	query := fmt.Sprintf(`SELECT %s FROM users WHERE uid > ? ORDER BY uid LIMIT ?`, allUserColumns)
+	return queryUsers(m.db, query, afterUID, limit)
+}
+
+func queryUsers(db queryable, query string, args ...any) ([]UserRow, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query error: %w", err)
 	}
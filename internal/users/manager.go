@@ -8,8 +8,10 @@ import (
 	"math"
 	"os"
 	"os/user"
+	"path/filepath"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -40,6 +42,12 @@ var DefaultConfig = Config{
 	GIDMax: 60000,
 }
 
+// defaultShellsFile is the file listing the valid login shells.
+const defaultShellsFile = "/etc/shells"
+
+// defaultHomeBaseDirs are the directories under which home directories can be set via SetUserHome.
+var defaultHomeBaseDirs = []string{"/home"}
+
 // Manager is the manager for any user related operation.
 type Manager struct {
 	// userManagementMu must be used to protect all the operations in which we
@@ -51,10 +59,14 @@ type Manager struct {
 	config         Config
 	preAuthRecords *tempentries.PreAuthUserRecords
 	idGenerator    IDGeneratorIface
+	shellsFile     string
+	homeBaseDirs   []string
 }
 
 type options struct {
-	idGenerator IDGeneratorIface
+	idGenerator  IDGeneratorIface
+	shellsFile   string
+	homeBaseDirs []string
 }
 
 // Option is a function that allows changing some of the default behaviors of the manager.
@@ -68,11 +80,27 @@ func WithIDGenerator(g IDGeneratorIface) Option {
 	}
 }
 
+// WithShellsFile makes the manager use a specific file listing the valid login shells.
+// This option is only useful in tests.
+func WithShellsFile(path string) Option {
+	return func(o *options) {
+		o.shellsFile = path
+	}
+}
+
+// WithHomeBaseDirs makes the manager only accept home directories under the given directories in SetUserHome.
+// This option is only useful in tests.
+func WithHomeBaseDirs(dirs ...string) Option {
+	return func(o *options) {
+		o.homeBaseDirs = dirs
+	}
+}
+
 // NewManager creates a new user manager.
 func NewManager(config Config, dbDir string, args ...Option) (m *Manager, err error) {
 	log.Debugf(context.Background(), "Creating user manager with config: %+v", config)
 
-	opts := &options{}
+	opts := &options{shellsFile: defaultShellsFile, homeBaseDirs: defaultHomeBaseDirs}
 	for _, arg := range args {
 		arg(opts)
 	}
@@ -125,6 +153,8 @@ func NewManager(config Config, dbDir string, args ...Option) (m *Manager, err er
 		config:         config,
 		preAuthRecords: tempentries.NewPreAuthUserRecords(),
 		idGenerator:    opts.idGenerator,
+		shellsFile:     opts.shellsFile,
+		homeBaseDirs:   opts.homeBaseDirs,
 	}
 
 	m.db, err = db.New(dbDir)
@@ -457,6 +487,10 @@ func (m *Manager) SetUserID(name string, uid uint32) (resp *SetUserIDResp, err e
 		return resp, nil
 	}
 
+	if err := checkCanChown(oldUser.Dir); err != nil {
+		return resp, err
+	}
+
 	// Change the ownership of all files in the home directory from the old UID to the new UID.
 	log.Debugf(context.Background(), "Changing ownership of home directory %q from UID %d to UID %d", oldUser.Dir, oldUser.UID, uid)
 	err = fileutils.ChownRecursiveFrom(
@@ -464,6 +498,10 @@ func (m *Manager) SetUserID(name string, uid uint32) (resp *SetUserIDResp, err e
 		&fileutils.ChownUIDArgs{FromUID: oldUser.UID, ToUID: uid},
 		nil,
 	)
+	if warning, ok := skippedEntriesWarning(err); ok {
+		resp.Warnings = append(resp.Warnings, warning)
+		err = nil
+	}
 	if err != nil {
 		return resp, err
 	}
@@ -472,6 +510,213 @@ func (m *Manager) SetUserID(name string, uid uint32) (resp *SetUserIDResp, err e
 	return resp, nil
 }
 
+// SetUserShell sets the login shell of the user with the given name.
+// The shell must be an absolute path to an existing executable file. Unless force is true, it must also be listed in
+// the shells file (usually /etc/shells).
+func (m *Manager) SetUserShell(name, shell string, force bool) error {
+	log.Debugf(context.TODO(), "Setting shell for user %q to %q", name, shell)
+
+	if name == "" {
+		return errors.New("empty username")
+	}
+
+	if !filepath.IsAbs(shell) {
+		return fmt.Errorf("shell %q is not an absolute path", shell)
+	}
+
+	fi, err := os.Stat(shell)
+	if err != nil {
+		return fmt.Errorf("invalid shell %q: %w", shell, err)
+	}
+	if fi.IsDir() || fi.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("shell %q is not an executable file", shell)
+	}
+
+	if !force {
+		shells, err := fileutils.ReadLines(m.shellsFile)
+		if err != nil {
+			return fmt.Errorf("could not read list of valid shells: %w", err)
+		}
+		if !slices.Contains(shells, shell) {
+			return fmt.Errorf("shell %q is not listed in %s", shell, m.shellsFile)
+		}
+	}
+
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	return m.db.UpdateShellForUser(name, shell)
+}
+
+// SetUserHomeResp is the response type of SetUserHome.
+type SetUserHomeResp struct {
+	HomeDirMoved bool
+	Warnings     []string
+}
+
+// SetUserHome sets the home directory of the user with the given name.
+// The home directory must be an absolute path under one of the allowed base directories (by default /home).
+// If move is true, the content of the current home directory is moved to the new one, which must either not exist or
+// be empty. The record is only updated once the content has been moved, and the move is reverted if the update fails.
+func (m *Manager) SetUserHome(name, home string, move bool) (resp *SetUserHomeResp, err error) {
+	log.Debugf(context.TODO(), "Setting home directory for user %q to %q", name, home)
+	resp = &SetUserHomeResp{}
+
+	if name == "" {
+		return nil, errors.New("empty username")
+	}
+
+	if !filepath.IsAbs(home) {
+		return nil, fmt.Errorf("home directory %q is not an absolute path", home)
+	}
+	home = filepath.Clean(home)
+	if !slices.ContainsFunc(m.homeBaseDirs, func(base string) bool { return isStrictlyUnder(home, base) }) {
+		return nil, fmt.Errorf("home directory %q is not under any of the allowed base directories (%s)", home, strings.Join(m.homeBaseDirs, ", "))
+	}
+
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	// Check if the user exists
+	u, err := m.db.UserByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if u.Dir == home {
+		warning := fmt.Sprintf("User '%s' already has home directory '%s'.", name, home)
+		log.Info(context.Background(), warning)
+		resp.Warnings = append(resp.Warnings, warning)
+		return resp, nil
+	}
+
+	if !move {
+		return resp, m.db.UpdateHomeForUser(name, home)
+	}
+
+	// Check if the user has active processes
+	if err := proc.CheckUserBusy(name, u.UID); err != nil {
+		return nil, err
+	}
+
+	// Only move the home directory if it is owned by the user.
+	homeUID, _, err := getHomeDirOwner(u.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		warning := fmt.Sprintf("Home directory '%s' does not exist, nothing to move.", u.Dir)
+		log.Info(context.Background(), warning)
+		resp.Warnings = append(resp.Warnings, warning)
+		return resp, m.db.UpdateHomeForUser(name, home)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if homeUID != u.UID {
+		return nil, fmt.Errorf("not moving home directory %q because it is not owned by UID %d (current owner: %d)", u.Dir, u.UID, homeUID)
+	}
+
+	revert, err := moveHomeDir(u.Dir, home)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.db.UpdateHomeForUser(name, home); err != nil {
+		if revertErr := revert(); revertErr != nil {
+			log.Errorf(context.Background(), "Could not move home directory %q back to %q: %v", home, u.Dir, revertErr)
+		}
+		return nil, err
+	}
+	resp.HomeDirMoved = true
+
+	// The home directory was copied across filesystems, remove the original now that the record is updated.
+	if exists, _ := fileutils.Lexists(u.Dir); exists {
+		log.Debugf(context.Background(), "Removing previous home directory %q of user %q", u.Dir, name)
+		if err := fileutils.SafeRemoveAll(u.Dir); err != nil {
+			warning := fmt.Sprintf("Could not remove previous home directory '%s'.", u.Dir)
+			log.Warningf(context.Background(), "%s: %v", warning, err)
+			resp.Warnings = append(resp.Warnings, warning)
+		}
+	}
+
+	return resp, nil
+}
+
+// moveHomeDir moves the home directory oldHome to newHome, which must either not exist or be an empty directory.
+// If oldHome and newHome are on different filesystems, the content is copied with the ownership of each entry, the copy
+// is checked to match oldHome, and oldHome is left in place for the caller to remove.
+// It returns a function which reverts the move.
+func moveHomeDir(oldHome, newHome string) (revert func() error, err error) {
+	empty, err := fileutils.IsDirEmpty(newHome)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil && !empty {
+		return nil, fmt.Errorf("destination %q already exists and is not empty", newHome)
+	}
+	if err == nil {
+		// Remove the empty destination, so that the home directory can be renamed to it.
+		if err := os.Remove(newHome); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newHome), 0o755); err != nil {
+		return nil, err
+	}
+
+	log.Debugf(context.Background(), "Moving home directory %q to %q", oldHome, newHome)
+	err = fileutils.Lrename(oldHome, newHome)
+	if err == nil {
+		return func() error { return fileutils.Lrename(newHome, oldHome) }, nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return nil, err
+	}
+
+	// The new home directory is on a different filesystem, so we have to copy the content over.
+	log.Debugf(context.Background(), "Copying home directory %q to %q across filesystems", oldHome, newHome)
+	revert = func() error { return fileutils.SafeRemoveAll(newHome) }
+	// The ownership of each entry is preserved, as the home directory can contain files which are not owned by the user
+	// (e.g. files owned by root), which must not be handed over to them.
+	if err := fileutils.CopyDir(oldHome, newHome, fileutils.WithPreservedOwnership()); err != nil {
+		return nil, errors.Join(err, revert())
+	}
+
+	// Make sure that nothing is lost and that the ownership is preserved before the caller removes the original. The
+	// contents are not compared, because reading the whole home directory again would be too slow.
+	equal, diffs, err := fileutils.DirsEqual(oldHome, newHome, false)
+	if err != nil {
+		return nil, errors.Join(err, revert())
+	}
+	if !equal {
+		err := fmt.Errorf("copy %q of home directory %q differs from the original in %d entries, including %q",
+			newHome, oldHome, len(diffs), diffs[0])
+		return nil, errors.Join(err, revert())
+	}
+
+	return revert, nil
+}
+
+// checkCanChown returns an error if we are not allowed to change the ownership of the files under dir, so that we fail
+// before changing the ownership of some of them.
+func checkCanChown(dir string) error {
+	canChown, err := fileutils.CanChown(dir)
+	if err != nil {
+		return err
+	}
+	if !canChown {
+		return errors.New("must run as root to re-own files")
+	}
+	return nil
+}
+
+// isStrictlyUnder returns true if the cleaned absolute path is located below the base directory.
+func isStrictlyUnder(path, base string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
 // SetGroupIDResp is the response type of SetGroupID.
 type SetGroupIDResp struct {
 	IDChanged           bool
@@ -565,13 +810,16 @@ func (m *Manager) updateUserHomeDirOwnership(userRow db.UserRow, oldGID uint32,
 		return false, warning, nil
 	}
 
+	if err := checkCanChown(userRow.Dir); err != nil {
+		return false, "", err
+	}
+
 	// Change the ownership of all files in the home directory from the old GID to the new GID.
 	log.Debugf(context.Background(), "Changing ownership of home directory %q from GID %d to GID %d", userRow.Dir, oldGID, newGID)
-	err = fileutils.ChownRecursiveFrom(
-		userRow.Dir,
-		nil,
-		&fileutils.ChownGIDArgs{FromGID: oldGID, ToGID: newGID},
-	)
+	err = fileutils.ChgrpRecursiveFrom(userRow.Dir, oldGID, newGID)
+	if warning, ok := skippedEntriesWarning(err); ok {
+		return true, warning, nil
+	}
 	if err != nil {
 		return false, "", err
 	}
@@ -579,6 +827,237 @@ func (m *Manager) updateUserHomeDirOwnership(userRow db.UserRow, oldGID uint32,
 	return true, "", nil
 }
 
+// skippedEntriesWarning returns a warning and true if err is a fileutils.SkippedEntriesError, i.e. the ownership of
+// the home directory was changed, except for the entries on other filesystems.
+func skippedEntriesWarning(err error) (string, bool) {
+	var skippedErr fileutils.SkippedEntriesError
+	if !errors.As(err, &skippedErr) {
+		return "", false
+	}
+
+	warning := fmt.Sprintf("Not updating ownership of entries in home directory '%s' which are on another filesystem: %s.",
+		skippedErr.Root, strings.Join(skippedErr.Paths, ", "))
+	log.Warning(context.Background(), warning)
+	return warning, true
+}
+
+// DeleteUserResp is the response type of DeleteUser.
+type DeleteUserResp struct {
+	HomeDirRemoved bool
+	Warnings       []string
+}
+
+// DeleteUser removes the user with the given name from the database and from the local groups they are part of.
+// The user private group is removed too, unless it has other members.
+// If removeHome is true, the home directory of the user is removed as well, if it's owned by the user.
+func (m *Manager) DeleteUser(name string, removeHome bool) (resp *DeleteUserResp, err error) {
+	log.Debugf(context.TODO(), "Deleting user %q", name)
+	resp = &DeleteUserResp{}
+
+	if name == "" {
+		return nil, errors.New("empty username")
+	}
+
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	lockedEntries, unlockEntries, err := localentries.WithUserDBLock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, unlockEntries()) }()
+
+	// Check if the user exists
+	u, err := m.db.UserByName(name)
+	if err != nil {
+		return nil, err
+	}
+	localGroups, err := m.db.UserLocalGroups(u.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the user has active processes
+	err = proc.CheckUserBusy(name, u.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the user private group before deleting the user, because its memberships are deleted with the user.
+	privateGroup, err := m.db.GroupWithMembersByID(u.GID)
+	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+		// Unexpected error
+		return nil, err
+	}
+	isPrivateGroup := err == nil && privateGroup.UGID == name
+
+	if err = m.db.DeleteUser(u.UID); err != nil {
+		return nil, err
+	}
+
+	if isPrivateGroup && !slices.ContainsFunc(privateGroup.Users, func(member string) bool { return member != name }) {
+		log.Debugf(context.Background(), "Deleting private group %q of user %q", privateGroup.Name, name)
+		if err = m.db.DeleteGroup(privateGroup.GID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Remove the user from the local groups.
+	if err := localentries.UpdateGroups(lockedEntries, name, nil, localGroups); err != nil {
+		return nil, err
+	}
+
+	if !removeHome {
+		return resp, nil
+	}
+
+	// Only remove the home directory if it is owned by the user.
+	homeUID, _, err := getHomeDirOwner(u.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Debugf(context.Background(), "Home directory %q for user %q does not exist, nothing to remove", u.Dir, name)
+		return resp, nil
+	}
+	if err != nil {
+		warning := fmt.Sprintf("Could not get owner of home directory '%s'.", u.Dir)
+		log.Warningf(context.Background(), "%s: %v", warning, err)
+		resp.Warnings = append(resp.Warnings, warning)
+		return resp, nil
+	}
+	if homeUID != u.UID {
+		warning := fmt.Sprintf("Not removing home directory '%s' because it is not owned by UID %d (current owner: %d).", u.Dir, u.UID, homeUID)
+		log.Warning(context.Background(), warning)
+		resp.Warnings = append(resp.Warnings, warning)
+		return resp, nil
+	}
+
+	log.Debugf(context.Background(), "Removing home directory %q of user %q", u.Dir, name)
+	// The owner is checked again right before removing the home directory, in case it changed in the meantime.
+	if err := fileutils.RemoveDir(u.Dir, u.UID); err != nil {
+		return resp, err
+	}
+	resp.HomeDirRemoved = true
+
+	return resp, nil
+}
+
+// CreateGroup creates a new group with the given name and GID and returns its GID.
+// If gid is 0, a unique GID is generated. Otherwise, it must be in the range configured via GID_MIN and GID_MAX.
+// The UGID of the group is set to its name.
+func (m *Manager) CreateGroup(name string, gid uint32) (_ uint32, err error) {
+	log.Debugf(context.TODO(), "Creating group %q with GID %d", name, gid)
+
+	if name == "" {
+		return 0, errors.New("empty group name")
+	}
+
+	if gid > math.MaxInt32 {
+		return 0, fmt.Errorf("GID %d is too large to convert to int32", gid)
+	}
+	if gid != 0 && (gid < m.config.GIDMin || gid > m.config.GIDMax) {
+		return 0, fmt.Errorf("GID %d is outside of the configured range (%d-%d)", gid, m.config.GIDMin, m.config.GIDMax)
+	}
+
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	lockedEntries, unlockEntries, err := localentries.WithUserDBLock()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = errors.Join(err, unlockEntries()) }()
+
+	// Check if a group with the given name already exists, in our database or on the system.
+	_, err = m.db.GroupByName(name)
+	if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+		// Unexpected error
+		return 0, err
+	}
+	if err == nil {
+		return 0, fmt.Errorf("group %q already exists", name)
+	}
+	unique, err := lockedEntries.IsUniqueGroupName(name)
+	if err != nil {
+		return 0, err
+	}
+	if !unique {
+		return 0, fmt.Errorf("group %q already exists", name)
+	}
+
+	if gid == 0 {
+		var cleanupGID func()
+		gid, cleanupGID, err = m.idGenerator.GenerateGID(lockedEntries, m)
+		if err != nil {
+			return 0, err
+		}
+		defer cleanupGID()
+		log.Debugf(context.Background(), "Using new GID %d for group %q", gid, name)
+	} else {
+		// Check if another group already has the given GID, in our database or on the system.
+		_, err = m.db.GroupByID(gid)
+		if err != nil && !errors.Is(err, db.NoDataFoundError{}) {
+			// Unexpected error
+			return 0, err
+		}
+		if err == nil {
+			return 0, fmt.Errorf("GID %d already exists", gid)
+		}
+		unique, err := lockedEntries.IsUniqueGID(gid)
+		if err != nil {
+			return 0, err
+		}
+		if !unique {
+			return 0, fmt.Errorf("GID %d already exists", gid)
+		}
+	}
+
+	if err := m.db.AddGroup(db.NewGroupRow(name, gid, name)); err != nil {
+		return 0, err
+	}
+
+	return gid, nil
+}
+
+// DeleteGroup removes the group with the given name from the database.
+// A group which still has members is only removed if force is true. The primary group of a user is never removed.
+func (m *Manager) DeleteGroup(name string, force bool) (err error) {
+	log.Debugf(context.TODO(), "Deleting group %q", name)
+
+	if name == "" {
+		return errors.New("empty group name")
+	}
+
+	m.userManagementMu.Lock()
+	defer m.userManagementMu.Unlock()
+
+	_, unlockEntries, err := localentries.WithUserDBLock()
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlockEntries()) }()
+
+	group, err := m.db.GroupWithMembersByName(name)
+	if err != nil {
+		return err
+	}
+
+	// Removing the primary group of a user would leave them with a dangling GID.
+	users, err := m.db.AllUsers()
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.GID == group.GID {
+			return fmt.Errorf("group %q is the primary group of user %q", name, u.Name)
+		}
+	}
+
+	if len(group.Users) > 0 && !force {
+		return fmt.Errorf("group %q still has members: %s", name, strings.Join(group.Users, ", "))
+	}
+
+	return m.db.DeleteGroup(group.GID)
+}
+
 // checkGroupNameConflict checks if a group with the given name already exists.
 // If it does, it checks if it has the same UGID.
 func (m *Manager) checkGroupNameConflict(name string, ugid string) error {
@@ -684,22 +1163,14 @@ func (m *Manager) UpdateBrokerForUser(username, brokerID string) error {
 	return nil
 }
 
-// LockUser sets the "locked" field to true for the given user.
-func (m *Manager) LockUser(username string) error {
-	if err := m.db.UpdateLockedFieldForUser(username, true); err != nil {
-		return err
-	}
-
-	return nil
+// LockUser sets the "locked" field to true for the given user. It returns whether the user was already locked.
+func (m *Manager) LockUser(username string) (wasLocked bool, err error) {
+	return m.db.UpdateLockedFieldForUser(username, true)
 }
 
-// UnlockUser sets the "locked" field to false for the given user.
-func (m *Manager) UnlockUser(username string) error {
-	if err := m.db.UpdateLockedFieldForUser(username, false); err != nil {
-		return err
-	}
-
-	return nil
+// UnlockUser sets the "locked" field to false for the given user. It returns whether the user was locked before.
+func (m *Manager) UnlockUser(username string) (wasLocked bool, err error) {
+	return m.db.UpdateLockedFieldForUser(username, false)
 }
 
 // IsUserLocked returns true if the user with the given user name is locked, false otherwise.
@@ -750,6 +1221,34 @@ func (m *Manager) AllUsers() ([]types.UserEntry, error) {
 	return usrEntries, err
 }
 
+// usersPageSize is the number of users ForEachUser loads from the database at once.
+const usersPageSize = 100
+
+// ForEachUser calls fn for each user ordered by UID, with whether the user is locked, stopping at the first error
+// returned by fn. Like AllUsers, it doesn't include temporary users.
+func (m *Manager) ForEachUser(fn func(u types.UserEntry, locked bool) error) error {
+	// The users are loaded page by page together with their lock state, so that the whole list is never held in
+	// memory and the lock state isn't queried for each user. No query is kept open while fn is called.
+	var afterUID uint32
+	for {
+		usrs, err := m.db.UsersPage(afterUID, usersPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, usr := range usrs {
+			if err := fn(userEntryFromUserRow(usr), usr.Locked); err != nil {
+				return err
+			}
+		}
+
+		if len(usrs) < usersPageSize {
+			return nil
+		}
+		afterUID = usrs[len(usrs)-1].UID
+	}
+}
+
 // UsedUIDs returns all user IDs, including the UIDs of temporary pre-auth users.
 func (m *Manager) UsedUIDs() ([]uint32, error) {
 	var uids []uint32
@@ -37,6 +37,7 @@ func TestSetUserID(t *testing.T) {
 		homeDirOwnedByOtherUser          bool
 		homeDirCannotBeAccessed          bool
 		homeDirOwnerCannotBeChanged      bool
+		homeDirHasMountPoint             bool
 
 		wantErr                 bool
 		wantErrType             error
@@ -66,6 +67,9 @@ func TestSetUserID(t *testing.T) {
 		"Warning_if_home_directory_cannot_be_accessed": {
 			homeDirCannotBeAccessed: true, wantWarnings: 1, wantIDChanged: true,
 		},
+		"Warning_if_home_directory_contains_a_mount_point": {
+			homeDirHasMountPoint: true, wantWarnings: 1, wantIDChanged: true, wantHomeDirOwnerChanged: true,
+		},
 
 		"Error_if_username_is_empty":               {emptyUsername: true, wantErr: true},
 		"Error_if_user_does_not_exist":             {nonExistentUser: true, wantErrType: db.NoDataFoundError{}},
@@ -103,6 +107,9 @@ func TestSetUserID(t *testing.T) {
 					uid = 2222
 				}
 				home := createTemporaryHome(t, uid, gid, tc.homeDirCannotBeAccessed, tc.homeDirOwnerCannotBeChanged)
+				if tc.homeDirHasMountPoint {
+					mountTmpfsInHome(t, home)
+				}
 				setHome(t, m, username, home)
 			}
 
@@ -186,6 +193,7 @@ func TestSetGroupID(t *testing.T) {
 		homeDirOwnedByOtherGroup         bool
 		homeDirCannotBeAccessed          bool
 		homeDirOwnerCannotBeChanged      bool
+		homeDirHasMountPoint             bool
 
 		wantErr                 bool
 		wantErrType             error
@@ -219,6 +227,9 @@ func TestSetGroupID(t *testing.T) {
 		"Warning_if_home_directory_cannot_be_accessed": {
 			homeDirCannotBeAccessed: true, wantWarnings: 1, wantIDChanged: true,
 		},
+		"Warning_if_home_directory_contains_a_mount_point": {
+			homeDirHasMountPoint: true, wantWarnings: 1, wantIDChanged: true, wantHomeDirOwnerChanged: true,
+		},
 
 		"Error_if_groupname_is_empty":              {emptyGroupname: true, wantErr: true},
 		"Error_if_group_does_not_exist":            {nonExistentGroup: true, wantErrType: db.NoDataFoundError{}},
@@ -256,6 +267,9 @@ func TestSetGroupID(t *testing.T) {
 					gid = 2222
 				}
 				home := createTemporaryHome(t, uid, gid, tc.homeDirCannotBeAccessed, tc.homeDirOwnerCannotBeChanged)
+				if tc.homeDirHasMountPoint {
+					mountTmpfsInHome(t, home)
+				}
 				setHome(t, m, "user1@example.com", home)
 			}
 
@@ -329,6 +343,248 @@ func TestSetGroupID(t *testing.T) {
 	}
 }
 
+func TestDeleteUser(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile                  string
+		username                string
+		removeHome              bool
+		homeDirDoesNotExist     bool
+		homeDirOwnedByOtherUser bool
+		homeDirCannotBeAccessed bool
+
+		wantErr            bool
+		wantErrType        error
+		wantHomeDirRemoved bool
+		wantWarnings       int
+	}{
+		"Successfully_delete_user_and_keep_home_directory": {},
+		"Successfully_delete_user_and_remove_home_directory": {
+			removeHome: true, wantHomeDirRemoved: true,
+		},
+		"Successfully_delete_user_when_home_directory_does_not_exist": {
+			removeHome: true, homeDirDoesNotExist: true,
+		},
+		// Setting the home directory of a user drops their group memberships, so we don't create one in these cases.
+		"Successfully_delete_user_and_private_group": {
+			dbFile: "users_with_private_groups", homeDirDoesNotExist: true,
+		},
+		"Private_group_is_kept_if_it_has_other_members": {
+			dbFile: "users_with_private_groups", username: "user2@example.com", homeDirDoesNotExist: true,
+		},
+
+		"Warning_if_home_directory_is_owned_by_other_user": {
+			removeHome: true, homeDirOwnedByOtherUser: true, wantWarnings: 1,
+		},
+		"Warning_if_home_directory_cannot_be_accessed": {
+			removeHome: true, homeDirCannotBeAccessed: true, wantWarnings: 1,
+		},
+
+		"Error_if_username_is_empty":   {username: "-", wantErr: true},
+		"Error_if_user_does_not_exist": {username: "nonexistent", wantErrType: db.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if !testutils.RunningInBubblewrap() {
+				testutils.RunTestInBubbleWrap(t)
+				return
+			}
+
+			if tc.dbFile == "" {
+				tc.dbFile = "multiple_users_and_groups"
+			}
+			if tc.username == "" {
+				tc.username = "user1@example.com"
+			}
+			if tc.username == "-" {
+				tc.username = ""
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", tc.dbFile+".db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+
+			m := newManagerForTests(t, dbDir)
+
+			var home string
+			if u, err := m.DB().UserByName(tc.username); err == nil && !tc.homeDirDoesNotExist {
+				uid := int(u.UID)
+				if tc.homeDirOwnedByOtherUser {
+					uid = 2222
+				}
+				home = createTemporaryHome(t, uid, int(u.GID), tc.homeDirCannotBeAccessed, false)
+				setHome(t, m, tc.username, home)
+			}
+
+			resp, err := m.DeleteUser(tc.username, tc.removeHome)
+			log.Infof(context.Background(), "DeleteUser error: %v", err)
+			log.Infof(context.Background(), "DeleteUser resp: %v", resp)
+
+			requireErrorAssertions(t, err, tc.wantErrType, tc.wantErr)
+			if tc.wantErrType != nil || tc.wantErr {
+				return
+			}
+			require.NotNil(t, resp, "DeleteUser should return a response")
+			require.Equal(t, tc.wantHomeDirRemoved, resp.HomeDirRemoved, "Unexpected HomeDirRemoved")
+			require.Len(t, resp.Warnings, tc.wantWarnings, "Unexpected number of warnings")
+
+			if home != "" && !tc.homeDirCannotBeAccessed {
+				exists, err := fileutils.FileExists(home)
+				require.NoError(t, err, "Failed to check if the home directory exists")
+				require.Equal(t, !tc.wantHomeDirRemoved, exists, "The home directory should only be removed if requested")
+			}
+
+			yamlData, err := db.Z_ForTests_DumpNormalizedYAML(m.DB())
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, yamlData, golden.WithPath("db"))
+
+			// To make the tests deterministic, we replace the temporary home directory path with a placeholder
+			for i, w := range resp.Warnings {
+				if regexp.MustCompile(`Could not get owner of home directory '([^"]+)'.`).MatchString(w) {
+					resp.Warnings[i] = `Could not get owner of home directory '{{HOME}}'.`
+				}
+				if regexp.MustCompile(`Not removing home directory '([^"]+)' because it is not owned by UID \d+ \(current owner: \d+\).`).MatchString(w) {
+					resp.Warnings[i] = `Not removing home directory '{{HOME}}' because it is not owned by UID {{UID}} (current owner: {{CURR_UID}}).`
+				}
+			}
+
+			golden.CheckOrUpdateYAML(t, resp, golden.WithPath("response"))
+		})
+	}
+}
+
+func TestSetUserHome(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username                 string
+		newHome                  string
+		move                     bool
+		homeDirDoesNotExist      bool
+		homeDirOwnedByOtherUser  bool
+		newHomeExists            bool
+		newHomeExistsAndNotEmpty bool
+
+		wantErr          bool
+		wantErrType      error
+		wantHomeDirMoved bool
+		wantWarnings     int
+	}{
+		"Successfully_set_home_directory": {},
+		"Successfully_set_home_directory_and_move_content": {
+			move: true, wantHomeDirMoved: true,
+		},
+		"Successfully_move_content_to_existing_empty_directory": {
+			move: true, newHomeExists: true, wantHomeDirMoved: true,
+		},
+
+		"Warning_if_home_directory_is_already_set": {
+			newHome: "-", move: true, wantWarnings: 1,
+		},
+		"Warning_if_home_directory_to_move_does_not_exist": {
+			move: true, homeDirDoesNotExist: true, wantWarnings: 1,
+		},
+
+		"Error_if_username_is_empty":                        {username: "-", wantErr: true},
+		"Error_if_user_does_not_exist":                      {username: "nonexistent", wantErrType: db.NoDataFoundError{}},
+		"Error_if_home_directory_is_not_absolute":           {newHome: "home/new-home", wantErr: true},
+		"Error_if_home_directory_is_not_under_allowed_base": {newHome: "/srv/new-home", wantErr: true},
+		"Error_if_home_directory_is_the_allowed_base":       {newHome: filepath.Join(os.TempDir(), "home"), wantErr: true},
+		"Error_if_home_directory_to_move_is_owned_by_other_user": {
+			move: true, homeDirOwnedByOtherUser: true, wantErr: true,
+		},
+		"Error_if_new_home_directory_is_not_empty": {
+			move: true, newHomeExistsAndNotEmpty: true, wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if !testutils.RunningInBubblewrap() {
+				testutils.RunTestInBubbleWrap(t)
+				return
+			}
+
+			if tc.username == "" {
+				tc.username = "user1@example.com"
+			}
+			if tc.username == "-" {
+				tc.username = ""
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+
+			baseDir := filepath.Join(os.TempDir(), "home")
+			m := newManagerForTests(t, dbDir, users.WithHomeBaseDirs(baseDir))
+
+			var home string
+			if u, err := m.DB().UserByName(tc.username); err == nil {
+				uid := int(u.UID)
+				if tc.homeDirOwnedByOtherUser {
+					uid = 2222
+				}
+				home = createTemporaryHome(t, uid, int(u.GID), false, false)
+				setHome(t, m, tc.username, home)
+
+				err = os.WriteFile(filepath.Join(home, "file"), []byte("content"), 0600)
+				require.NoError(t, err, "Setup: could not create file in home directory")
+				if tc.homeDirDoesNotExist {
+					err = os.RemoveAll(home)
+					require.NoError(t, err, "Setup: could not remove home directory")
+				}
+			}
+
+			switch tc.newHome {
+			case "":
+				tc.newHome = filepath.Join(baseDir, "new-home")
+			case "-":
+				tc.newHome = home
+			}
+			if tc.newHomeExists || tc.newHomeExistsAndNotEmpty {
+				err = os.MkdirAll(tc.newHome, 0700)
+				require.NoError(t, err, "Setup: could not create new home directory")
+			}
+			if tc.newHomeExistsAndNotEmpty {
+				err = fileutils.Touch(filepath.Join(tc.newHome, "other-file"))
+				require.NoError(t, err, "Setup: could not create file in new home directory")
+			}
+
+			resp, err := m.SetUserHome(tc.username, tc.newHome, tc.move)
+			log.Infof(context.Background(), "SetUserHome error: %v", err)
+			log.Infof(context.Background(), "SetUserHome resp: %v", resp)
+
+			requireErrorAssertions(t, err, tc.wantErrType, tc.wantErr)
+			if tc.wantErrType != nil || tc.wantErr {
+				if home != "" && !tc.homeDirDoesNotExist {
+					require.FileExists(t, filepath.Join(home, "file"), "The home directory should be left untouched on error")
+				}
+				return
+			}
+			require.NotNil(t, resp, "SetUserHome should return a response")
+			require.Equal(t, tc.wantHomeDirMoved, resp.HomeDirMoved, "Unexpected HomeDirMoved")
+			require.Len(t, resp.Warnings, tc.wantWarnings, "Unexpected number of warnings")
+
+			if tc.wantHomeDirMoved {
+				require.NoDirExists(t, home, "The previous home directory should have been moved")
+				require.FileExists(t, filepath.Join(tc.newHome, "file"), "The content of the home directory should have been moved")
+			} else if !tc.homeDirDoesNotExist {
+				require.FileExists(t, filepath.Join(home, "file"), "The home directory should only be moved if requested")
+			}
+
+			yamlData, err := db.Z_ForTests_DumpNormalizedYAML(m.DB())
+			require.NoError(t, err)
+			golden.CheckOrUpdate(t, yamlData, golden.WithPath("db"))
+			golden.CheckOrUpdateYAML(t, resp, golden.WithPath("response"))
+		})
+	}
+}
+
 // createTemporaryHome creates a temporary home directory for the given user.
 func createTemporaryHome(t *testing.T, uid, gid int, inaccessible, cannotBeChanged bool) string {
 	t.Helper()
@@ -378,6 +634,23 @@ func createTemporaryHome(t *testing.T, uid, gid int, inaccessible, cannotBeChang
 	return home
 }
 
+// mountTmpfsInHome mounts a tmpfs on a directory in the given home directory, so that the home directory contains
+// entries on another filesystem.
+func mountTmpfsInHome(t *testing.T, home string) {
+	t.Helper()
+
+	mountPoint := filepath.Join(home, "mnt")
+	err := os.Mkdir(mountPoint, 0700)
+	require.NoError(t, err, "Setup: could not create mount point")
+
+	//nolint:gosec // G204 we want to use exec.Command with variables here
+	cmd := exec.Command("mount", "-t", "tmpfs", "tmpfs", mountPoint)
+	cmd.Stdout = t.Output()
+	cmd.Stderr = t.Output()
+	err = cmd.Run()
+	require.NoError(t, err, "Setup: could not mount tmpfs")
+}
+
 // setHome updates the home directory of the given user.
 func setHome(t *testing.T, m *users.Manager, username string, home string) {
 	t.Helper()
@@ -2,11 +2,14 @@ package users_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"os"
+	"os/user"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -787,10 +790,12 @@ func TestLockUser(t *testing.T) {
 
 		dbFile string
 
-		wantErr     bool
-		wantErrType error
+		wantWasLocked bool
+		wantErr       bool
+		wantErrType   error
 	}{
-		"Successfully_lock_user": {},
+		"Successfully_lock_user":                {},
+		"Successfully_lock_already_locked_user": {dbFile: "locked_user", wantWasLocked: true},
 
 		"Error_if_user_does_not_exist": {username: "doesnotexist", wantErrType: db.NoDataFoundError{}},
 	}
@@ -811,12 +816,13 @@ func TestLockUser(t *testing.T) {
 			require.NoError(t, err, "Setup: could not create database from testdata")
 			m := newManagerForTests(t, dbDir)
 
-			err = m.LockUser(tc.username)
+			wasLocked, err := m.LockUser(tc.username)
 
 			requireErrorAssertions(t, err, tc.wantErrType, tc.wantErr)
 			if tc.wantErrType != nil || tc.wantErr {
 				return
 			}
+			require.Equal(t, tc.wantWasLocked, wasLocked, "LockUser should return the previous lock state")
 
 			got, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
 			require.NoError(t, err, "Created database should be valid yaml content")
@@ -833,10 +839,12 @@ func TestUnlockUser(t *testing.T) {
 
 		dbFile string
 
-		wantErr     bool
-		wantErrType error
+		wantWasLocked bool
+		wantErr       bool
+		wantErrType   error
 	}{
-		"Successfully_enable_user": {},
+		"Successfully_enable_user":                 {wantWasLocked: true},
+		"Successfully_enable_already_enabled_user": {dbFile: "multiple_users_and_groups"},
 
 		"Error_if_user_does_not_exist": {username: "doesnotexist", wantErrType: db.NoDataFoundError{}},
 	}
@@ -857,7 +865,218 @@ func TestUnlockUser(t *testing.T) {
 			require.NoError(t, err, "Setup: could not create database from testdata")
 			m := newManagerForTests(t, dbDir)
 
-			err = m.UnlockUser(tc.username)
+			wasLocked, err := m.UnlockUser(tc.username)
+
+			requireErrorAssertions(t, err, tc.wantErrType, tc.wantErr)
+			if tc.wantErrType != nil || tc.wantErr {
+				return
+			}
+			require.Equal(t, tc.wantWasLocked, wasLocked, "UnlockUser should return the previous lock state")
+
+			got, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
+			require.NoError(t, err, "Created database should be valid yaml content")
+
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
+func TestSetUserShell(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username             string
+		shell                string
+		shellIsNotExecutable bool
+		force                bool
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Successfully_set_shell":                               {},
+		"Successfully_set_shell_not_listed_in_shells_if_force": {shell: "/usr/bin/env", force: true},
+
+		"Error_if_username_is_empty":             {username: "-", wantErr: true},
+		"Error_if_user_does_not_exist":           {username: "doesnotexist", wantErrType: db.NoDataFoundError{}},
+		"Error_if_shell_is_not_absolute":         {shell: "sh", wantErr: true},
+		"Error_if_shell_does_not_exist":          {shell: "/non-existent/shell", force: true, wantErr: true},
+		"Error_if_shell_is_a_directory":          {shell: "/bin", force: true, wantErr: true},
+		"Error_if_shell_is_not_executable":       {shellIsNotExecutable: true, force: true, wantErr: true},
+		"Error_if_shell_is_not_listed_in_shells": {shell: "/usr/bin/env", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.username == "" {
+				tc.username = "user1@example.com"
+			}
+			if tc.username == "-" {
+				tc.username = ""
+			}
+			if tc.shell == "" {
+				tc.shell = "/bin/sh"
+			}
+			if tc.shellIsNotExecutable {
+				tc.shell = filepath.Join(t.TempDir(), "shell")
+				err := os.WriteFile(tc.shell, []byte("#!/bin/sh\n"), 0600)
+				require.NoError(t, err, "Setup: could not create non-executable shell")
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir, users.WithShellsFile(filepath.Join("testdata", "shells")))
+
+			err = m.SetUserShell(tc.username, tc.shell, tc.force)
+
+			requireErrorAssertions(t, err, tc.wantErrType, tc.wantErr)
+			if tc.wantErrType != nil || tc.wantErr {
+				return
+			}
+
+			got, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
+			require.NoError(t, err, "Created database should be valid yaml content")
+
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
+func TestCreateGroup(t *testing.T) {
+	tests := map[string]struct {
+		groupname string
+		gid       uint32
+		// unusedGID makes the group be created with a GID which is not used on the system.
+		unusedGID bool
+		gidMin    uint32
+
+		wantErr bool
+	}{
+		"Successfully_create_group_with_generated_GID": {},
+		"Successfully_create_group_with_given_GID":     {unusedGID: true},
+
+		"Error_if_group_name_is_empty":             {groupname: "-", wantErr: true},
+		"Error_if_group_already_exists":            {groupname: "group1", wantErr: true},
+		"Error_if_group_already_exists_on_system":  {groupname: "root", wantErr: true},
+		"Error_if_GID_is_already_in_use":           {gid: 11111, wantErr: true},
+		"Error_if_GID_is_already_in_use_on_system": {gid: 1, gidMin: 1, wantErr: true},
+		"Error_if_GID_is_too_large":                {gid: math.MaxInt32 + 1, wantErr: true},
+		"Error_if_GID_is_below_the_range":          {gid: users.DefaultConfig.GIDMin - 1, wantErr: true},
+		"Error_if_GID_is_above_the_range":          {gid: users.DefaultConfig.GIDMax + 1, wantErr: true},
+		"Error_if_no_GID_is_available_to_generate": {groupname: "nogidavailable", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about the output of gpasswd in this test, but we still need to mock it.
+			_ = localgroupstestutils.SetupGroupMock(t, filepath.Join("testdata", "groups", "empty.group"))
+
+			if tc.groupname == "" {
+				tc.groupname = "newgroup"
+			}
+			if tc.groupname == "-" {
+				tc.groupname = ""
+			}
+
+			gidsToGenerate := []uint32{12345}
+			if tc.groupname == "nogidavailable" {
+				gidsToGenerate = nil
+			}
+			if tc.unusedGID {
+				// The system group database is not mocked, so the GID must not be hardcoded.
+				tc.gid = unusedSystemGID(t, 54321)
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "multiple_users_and_groups.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			config := users.DefaultConfig
+			if tc.gidMin != 0 {
+				config.GIDMin = tc.gidMin
+			}
+			m, err := users.NewManager(config, dbDir, users.WithIDGenerator(&users.IDGeneratorMock{
+				GIDsToGenerate: gidsToGenerate,
+			}))
+			require.NoError(t, err, "Setup: NewManager should not return an error")
+
+			gid, err := m.CreateGroup(tc.groupname, tc.gid)
+
+			requireErrorAssertions(t, err, nil, tc.wantErr)
+			if tc.wantErr {
+				return
+			}
+
+			wantGID := tc.gid
+			if wantGID == 0 {
+				wantGID = gidsToGenerate[0]
+			}
+			require.Equal(t, wantGID, gid, "CreateGroup should return the GID of the new group")
+
+			if tc.unusedGID {
+				// The GID depends on the system, so the database can't be compared to a golden file.
+				group, err := m.GroupByName(tc.groupname)
+				require.NoError(t, err, "GroupByName should return the new group")
+				require.Equal(t, wantGID, group.GID, "The new group should have the given GID")
+				return
+			}
+
+			got, err := db.Z_ForTests_DumpNormalizedYAML(userstestutils.DBManager(m))
+			require.NoError(t, err, "Created database should be valid yaml content")
+
+			golden.CheckOrUpdate(t, got)
+		})
+	}
+}
+
+// unusedSystemGID returns the first GID from start on which is not used by a group of the system.
+func unusedSystemGID(t *testing.T, start uint32) uint32 {
+	t.Helper()
+
+	for gid := start; gid < math.MaxInt32; gid++ {
+		if _, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10)); err != nil {
+			return gid
+		}
+	}
+	require.FailNow(t, "Setup: no unused GID found on the system")
+	return 0
+}
+
+func TestDeleteGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		groupname string
+		force     bool
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Successfully_delete_group_without_members":          {},
+		"Successfully_delete_group_with_members_when_forced": {groupname: "commongroup", force: true},
+
+		"Error_if_group_name_is_empty":                  {groupname: "-", wantErr: true},
+		"Error_if_group_does_not_exist":                 {groupname: "doesnotexist", wantErrType: db.NoDataFoundError{}},
+		"Error_if_group_has_members":                    {groupname: "commongroup", wantErr: true},
+		"Error_if_group_is_primary_group_of_a_user":     {groupname: "group1", wantErr: true},
+		"Error_if_group_is_primary_group_even_if_force": {groupname: "group1", force: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.groupname == "" {
+				tc.groupname = "groupwithoutmembers"
+			}
+			if tc.groupname == "-" {
+				tc.groupname = ""
+			}
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", "groups_with_and_without_members.db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			err = m.DeleteGroup(tc.groupname, tc.force)
 
 			requireErrorAssertions(t, err, tc.wantErrType, tc.wantErr)
 			if tc.wantErrType != nil || tc.wantErr {
@@ -968,6 +1187,57 @@ func TestAllUsers(t *testing.T) {
 	}
 }
 
+func TestForEachUser(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile  string
+		fnError bool
+
+		wantUsers map[string]bool
+		wantErr   bool
+	}{
+		"Successfully_iterate_over_all_users": {dbFile: "multiple_users_and_groups"},
+		"Successfully_return_the_lock_state":  {dbFile: "locked_user", wantUsers: map[string]bool{"user1@example.com": true}},
+
+		"Error_if_fn_returns_an_error": {dbFile: "multiple_users_and_groups", fnError: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dbDir := t.TempDir()
+			err := db.Z_ForTests_CreateDBFromYAML(filepath.Join("testdata", "db", tc.dbFile+".db.yaml"), dbDir)
+			require.NoError(t, err, "Setup: could not create database from testdata")
+			m := newManagerForTests(t, dbDir)
+
+			var calls int
+			got := make(map[string]bool)
+			err = m.ForEachUser(func(u types.UserEntry, locked bool) error {
+				calls++
+				if tc.fnError {
+					return errors.New("some error")
+				}
+				got[u.Name] = locked
+				return nil
+			})
+			if tc.wantErr {
+				require.Error(t, err, "ForEachUser should return the error of fn")
+				require.Equal(t, 1, calls, "ForEachUser should stop at the first error")
+				return
+			}
+			require.NoError(t, err, "ForEachUser should not return an error")
+
+			allUsers, err := m.AllUsers()
+			require.NoError(t, err, "Setup: AllUsers should not return an error")
+			require.Len(t, got, len(allUsers), "ForEachUser should call fn for each user")
+			for name, wantLocked := range tc.wantUsers {
+				require.Equal(t, wantLocked, got[name], "ForEachUser should return the lock state of user %q", name)
+			}
+		})
+	}
+}
+
 func TestGroupByIDAndName(t *testing.T) {
 	t.Parallel()
 
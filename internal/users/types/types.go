@@ -10,6 +10,10 @@ type UserInfo struct {
 	Shell string
 
 	Groups []GroupInfo
+
+	// Claims are the claims of the ID token of the user, which brokers may return in diagnostic sessions to help
+	// debugging their configuration. They are never stored.
+	Claims map[string]any `json:",omitempty"`
 }
 
 // GroupInfo is the group information returned by the broker.